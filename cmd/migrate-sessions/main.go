@@ -0,0 +1,42 @@
+// Command migrate-sessions copies the sessions kept as JSON files into another session store
+//
+//	go run ./cmd/migrate-sessions -from ./data/sessions -db ./data/sessions.db
+//	go run ./cmd/migrate-sessions -from ./data/sessions -to redis -redis redis://localhost:6379/0
+//
+// Sessions already in the target store are overwritten, so it can be run again
+// after an interruption. The JSON files are left in place.
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	ctxmanager "github.com/deca/voicepilot-eino/internal/context"
+)
+
+func main() {
+	from := flag.String("from", "./data/sessions", "directory of the JSON session files (SESSION_STORAGE_PATH)")
+	to := flag.String("to", ctxmanager.StoreSQLite, "kind of the target store")
+	db := flag.String("db", "./data/sessions.db", "database of the sqlite store (SESSION_DB_PATH)")
+	redisURL := flag.String("redis", "redis://localhost:6379/0", "server of the redis store (REDIS_URL)")
+	expiry := flag.Duration("expiry", 72*time.Hour, "TTL of the sessions in the redis store (SESSION_EXPIRY_HOURS)")
+	flag.Parse()
+
+	source, err := ctxmanager.NewFileStore(*from)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *from, err)
+	}
+	target, err := ctxmanager.OpenStore(*to, ctxmanager.StoreOptions{DBPath: *db, RedisURL: *redisURL, Expiry: *expiry})
+	if err != nil {
+		log.Fatalf("Failed to open the %s store: %v", *to, err)
+	}
+	defer target.Close()
+
+	n, err := ctxmanager.MigrateSessions(source, target)
+	if err != nil {
+		target.Close()
+		log.Fatalf("Migration stopped after %d sessions: %v", n, err)
+	}
+	log.Printf("Migrated %d sessions from %s to the %s store", n, *from, *to)
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 	"time"
 
 	"github.com/deca/voicepilot-eino/internal/config"
 	"github.com/deca/voicepilot-eino/internal/handler"
+	"github.com/deca/voicepilot-eino/internal/telemetry"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
@@ -19,6 +21,14 @@ func main() {
 	log.Println("Configuration loaded successfully")
 	log.Printf("Server will listen on port: %s", config.AppConfig.Port)
 	log.Printf("Safe mode: %v", config.AppConfig.EnableSafeMode)
+	log.Printf("Providers: ASR=%s, TTS=%s, LLM=%s", config.AppConfig.ASRProvider, config.AppConfig.TTSProvider, config.AppConfig.LLMProvider)
+
+	// Set up tracing
+	shutdownTracing, err := telemetry.Init(context.Background(), config.AppConfig.TracesExporter, config.AppConfig.ServiceName)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
 
 	// Set Gin mode
 	gin.SetMode(gin.ReleaseMode)
@@ -35,12 +45,17 @@ func main() {
 		AllowCredentials: true,
 	}))
 
+	// Tracing and request metrics
+	r.Use(telemetry.GinMiddleware())
+
 	// Create handler
-	h := handler.NewHandler()
+	h, err := handler.NewHandler()
+	if err != nil {
+		log.Fatalf("Failed to create handler: %v", err)
+	}
 
-	// Start session cleanup task (run every 1 hour)
+	// Start session and trace cleanup task (run every 1 hour)
 	h.StartSessionCleanup(1 * time.Hour)
-	log.Println("Session cleanup task started (interval: 1 hour)")
 
 	// Routes
 	api := r.Group("/api")
@@ -51,13 +66,37 @@ func main() {
 		// Voice interaction
 		api.POST("/voice", h.VoiceInteraction)
 
+		// Real-time voice interaction over WebSocket
+		api.GET("/voice/stream", h.VoiceStream)
+
 		// Text interaction
 		api.POST("/text", h.TextInteraction)
 
+		// Text interaction with token-by-token output (server-sent events)
+		api.GET("/text/stream", h.TextStream)
+		api.POST("/text/stream", h.TextStream)
+
+		// Confirm or cancel a plan awaiting confirmation
+		api.POST("/sessions/:id/confirm", h.ConfirmAction)
+
+		// Events pushed to a session, such as fired reminders (server-sent events)
+		api.GET("/sessions/:id/events", h.SessionEvents)
+
+		// Long-term memory of a user: inspect it, forget all of it or a single fact
+		api.GET("/users/:id/memory", h.GetUserMemory)
+		api.DELETE("/users/:id/memory", h.DeleteUserMemory)
+		api.DELETE("/users/:id/memory/:fact_id", h.DeleteUserFact)
+
+		// Workflow trace of a single request
+		api.GET("/traces/:id", h.GetTrace)
+
 		// Audio upload (for testing)
 		api.POST("/upload", h.UploadAudio)
 	}
 
+	// Prometheus metrics
+	r.GET("/metrics", gin.WrapH(telemetry.MetricsHandler()))
+
 	// Static files
 	r.GET("/static/audio/:filename", h.ServeAudio)
 	r.Static("/static/css", "./web/static/css")
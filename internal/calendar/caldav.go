@@ -0,0 +1,196 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/telemetry"
+)
+
+// caldavTimeout bounds a single request to the CalDAV server
+const caldavTimeout = 15 * time.Second
+
+// maxCalDAVResponse caps how much of a CalDAV response is read
+const maxCalDAVResponse = 10 * 1024 * 1024
+
+// calendarQuery asks a CalDAV collection for all its events
+const calendarQuery = `<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/><C:calendar-data/></D:prop>
+  <C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT"/></C:comp-filter></C:filter>
+</C:calendar-query>`
+
+// CalDAVStore keeps events in a CalDAV calendar collection, one resource per event
+type CalDAVStore struct {
+	collection *url.URL
+	username   string
+	password   string
+	loc        *time.Location
+	client     *http.Client
+
+	mu    sync.Mutex
+	hrefs map[string]*url.URL // resource of each event UID seen by the last Load
+}
+
+// NewCalDAVStore creates a store for the calendar collection at collectionURL
+func NewCalDAVStore(collectionURL, username, password string, loc *time.Location) (*CalDAVStore, error) {
+	u, err := url.Parse(collectionURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid CalDAV URL %q", collectionURL)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return &CalDAVStore{
+		collection: u,
+		username:   username,
+		password:   password,
+		loc:        loc,
+		client:     &http.Client{Timeout: caldavTimeout, Transport: telemetry.Transport(nil)},
+		hrefs:      make(map[string]*url.URL),
+	}, nil
+}
+
+// multistatus is the subset of a WebDAV multistatus response the store reads
+type multistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// Load fetches all events of the collection with a calendar-query REPORT
+func (s *CalDAVStore) Load(ctx context.Context) ([]Event, error) {
+	resp, err := s.do(ctx, "REPORT", s.collection, strings.NewReader(calendarQuery), map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Depth":        "1",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("CalDAV REPORT returned status %d", resp.StatusCode)
+	}
+
+	var ms multistatus
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, maxCalDAVResponse)).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("invalid CalDAV response: %w", err)
+	}
+
+	var events []Event
+	hrefs := make(map[string]*url.URL)
+	for _, r := range ms.Responses {
+		href, err := s.collection.Parse(r.Href)
+		if err != nil {
+			continue
+		}
+		for _, ps := range r.Propstats {
+			if ps.Prop.CalendarData == "" || (ps.Status != "" && !strings.Contains(ps.Status, " 200 ")) {
+				continue
+			}
+			doc, err := parseICS([]byte(ps.Prop.CalendarData), s.loc)
+			if err != nil {
+				return nil, fmt.Errorf("invalid calendar data in %s: %w", r.Href, err)
+			}
+			for _, e := range doc.events {
+				hrefs[e.UID] = href
+				events = append(events, e)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.hrefs = hrefs
+	s.mu.Unlock()
+	return events, nil
+}
+
+// Create stores the event as a new resource named after its UID
+func (s *CalDAVStore) Create(ctx context.Context, event Event) error {
+	href, err := s.collection.Parse(url.PathEscape(event.UID) + ".ics")
+	if err != nil {
+		return fmt.Errorf("invalid event UID %q: %w", event.UID, err)
+	}
+
+	doc := &document{events: []Event{event}}
+	resp, err := s.do(ctx, http.MethodPut, href, bytes.NewReader(doc.encode()), map[string]string{
+		"Content-Type":  "text/calendar; charset=utf-8",
+		"If-None-Match": "*",
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("CalDAV PUT returned status %d", resp.StatusCode)
+	}
+
+	s.mu.Lock()
+	s.hrefs[event.UID] = href
+	s.mu.Unlock()
+	return nil
+}
+
+// Delete removes the resource holding the event
+func (s *CalDAVStore) Delete(ctx context.Context, uid string) error {
+	s.mu.Lock()
+	href, ok := s.hrefs[uid]
+	s.mu.Unlock()
+	if !ok {
+		var err error
+		if href, err = s.collection.Parse(url.PathEscape(uid) + ".ics"); err != nil {
+			return ErrNotFound
+		}
+	}
+
+	resp, err := s.do(ctx, http.MethodDelete, href, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("CalDAV DELETE returned status %d", resp.StatusCode)
+	}
+
+	s.mu.Lock()
+	delete(s.hrefs, uid)
+	s.mu.Unlock()
+	return nil
+}
+
+// do sends an authenticated request to the server
+func (s *CalDAVStore) do(ctx context.Context, method string, u *url.URL, body io.Reader, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CalDAV request: %w", err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("CalDAV %s failed: %w", method, err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,117 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCalDAV serves a calendar collection at /cal/ from an in-memory map of resources
+type fakeCalDAV struct {
+	mu        sync.Mutex
+	resources map[string]string
+}
+
+func (f *fakeCalDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, ok := r.BasicAuth(); !ok || user != "alice" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case "REPORT":
+		if r.URL.Path != "/cal/" || r.Header.Get("Depth") != "1" {
+			http.Error(w, "bad report", http.StatusBadRequest)
+			return
+		}
+		var b strings.Builder
+		b.WriteString(`<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">`)
+		for href, data := range f.resources {
+			fmt.Fprintf(&b, `<d:response><d:href>%s</d:href><d:propstat><d:prop><cal:calendar-data>%s</cal:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, href, data)
+		}
+		b.WriteString(`</d:multistatus>`)
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, b.String())
+	case http.MethodPut:
+		if _, exists := f.resources[r.URL.Path]; exists && r.Header.Get("If-None-Match") == "*" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.resources[r.URL.Path] = string(data)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if _, exists := f.resources[r.URL.Path]; !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.resources, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestCalDAVStore(t *testing.T) {
+	fake := &fakeCalDAV{resources: map[string]string{
+		// An event created by another client, stored under a name unrelated to its UID
+		"/cal/other-client.ics": "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:abc@phone\r\nDTSTART:20261019T070000Z\r\nDTEND:20261019T080000Z\r\nSUMMARY:牙医\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	ctx := context.Background()
+	store, err := NewCalDAVStore(server.URL+"/cal", "alice", "secret", time.UTC)
+	if err != nil {
+		t.Fatalf("NewCalDAVStore() error = %v", err)
+	}
+	cal := New(store, time.UTC)
+
+	event, conflicts, err := cal.Add(ctx, Event{
+		Summary: "团队午餐",
+		Start:   time.Date(2026, 10, 19, 7, 30, 0, 0, time.UTC),
+		End:     time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Event.Summary != "牙医" {
+		t.Errorf("Conflicts = %+v, want the dentist appointment", conflicts)
+	}
+	if _, ok := fake.resources["/cal/"+event.UID+".ics"]; !ok {
+		t.Errorf("Event not stored as %s.ics: %v", event.UID, fake.resources)
+	}
+
+	occs, err := cal.Occurrences(ctx, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(occs) != 2 {
+		t.Fatalf("Occurrences() = %+v, %v, want 2", occs, err)
+	}
+
+	// Deleting uses the resource the event was found in
+	if err := cal.Delete(ctx, "abc@phone"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok := fake.resources["/cal/other-client.ics"]; ok {
+		t.Error("Resource of the deleted event still exists")
+	}
+	if err := cal.Delete(ctx, "abc@phone"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() twice error = %v, want ErrNotFound", err)
+	}
+
+	unauthorized, _ := NewCalDAVStore(server.URL+"/cal/", "alice", "wrong", time.UTC)
+	if _, err := unauthorized.Load(ctx); err == nil {
+		t.Error("Expected an error for rejected credentials")
+	}
+	if _, err := NewCalDAVStore("ftp://example.com/cal", "", "", time.UTC); err == nil {
+		t.Error("Expected an error for a non-HTTP URL")
+	}
+}
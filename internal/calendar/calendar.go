@@ -0,0 +1,249 @@
+// Package calendar manages events in an iCalendar file or on a CalDAV server
+package calendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned when deleting an event that doesn't exist
+var ErrNotFound = errors.New("event not found")
+
+// conflictHorizon is how far ahead the occurrences of a new recurring event are checked for conflicts
+const conflictHorizon = 90 * 24 * time.Hour
+
+// Event is a calendar entry, possibly recurring
+type Event struct {
+	UID          string
+	Summary      string
+	Location     string
+	Description  string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	RRule        string      // RRULE value, empty for one-off events
+	ExDates      []time.Time // start times of cancelled occurrences
+	RecurrenceID time.Time   // set on an event that overrides one occurrence of the recurring event with the same UID
+
+	stamped bool     // a DTSTAMP was read and is kept in extra
+	extra   []string // other properties and nested components, written back unchanged
+}
+
+// Occurrence is a single instance of an event
+type Occurrence struct {
+	Event Event
+	Start time.Time
+	End   time.Time
+}
+
+// Store persists calendar events
+type Store interface {
+	// Load returns all events, including overrides of recurring events
+	Load(ctx context.Context) ([]Event, error)
+	// Create adds a new event
+	Create(ctx context.Context, event Event) error
+	// Delete removes the event with the UID together with its overrides
+	Delete(ctx context.Context, uid string) error
+}
+
+// Calendar expands recurring events and detects conflicts on top of a Store
+type Calendar struct {
+	store Store
+	loc   *time.Location
+}
+
+// New creates a calendar on store; loc is used for times without a time zone
+func New(store Store, loc *time.Location) *Calendar {
+	return &Calendar{store: store, loc: loc}
+}
+
+// Location returns the calendar's time zone
+func (c *Calendar) Location() *time.Location {
+	return c.loc
+}
+
+// Occurrences returns the event occurrences overlapping [from, to), earliest first
+func (c *Calendar) Occurrences(ctx context.Context, from, to time.Time) ([]Occurrence, error) {
+	events, err := c.store.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return occurrences(events, from, to), nil
+}
+
+// Add validates and stores a new event
+//
+// It returns the stored event and the existing occurrences it overlaps;
+// conflicts don't prevent the event from being added. All-day events are
+// not considered conflicting.
+func (c *Calendar) Add(ctx context.Context, event Event) (Event, []Occurrence, error) {
+	if strings.TrimSpace(event.Summary) == "" {
+		return Event{}, nil, errors.New("event has no summary")
+	}
+	if event.End.Before(event.Start) {
+		return Event{}, nil, errors.New("event ends before it starts")
+	}
+	if event.RRule != "" {
+		if _, err := parseRule(event.RRule, c.loc); err != nil {
+			return Event{}, nil, err
+		}
+	}
+	if event.UID == "" {
+		event.UID = uuid.New().String()
+	}
+
+	events, err := c.store.Load(ctx)
+	if err != nil {
+		return Event{}, nil, err
+	}
+	conflicts := conflicting(events, event)
+
+	if err := c.store.Create(ctx, event); err != nil {
+		return Event{}, nil, err
+	}
+	return event, conflicts, nil
+}
+
+// Find returns the events whose UID starts with id when id is given, otherwise those whose summary contains keyword
+//
+// Overrides of recurring events are folded into their series.
+func (c *Calendar) Find(ctx context.Context, id, keyword string) ([]Event, error) {
+	events, err := c.store.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	var found []Event
+	seen := make(map[string]bool)
+	for _, e := range events {
+		if seen[e.UID] {
+			continue
+		}
+		if id != "" && strings.HasPrefix(e.UID, id) || id == "" && keyword != "" && strings.Contains(strings.ToLower(e.Summary), keyword) {
+			seen[e.UID] = true
+			found = append(found, e)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Start.Before(found[j].Start) })
+	return found, nil
+}
+
+// Delete removes an event, with all its occurrences when it recurs
+func (c *Calendar) Delete(ctx context.Context, uid string) error {
+	return c.store.Delete(ctx, uid)
+}
+
+// occurrences expands events into their occurrences overlapping [from, to), earliest first
+func occurrences(events []Event, from, to time.Time) []Occurrence {
+	// Occurrences replaced by an override are skipped when expanding the series
+	overridden := make(map[string]bool)
+	for _, e := range events {
+		if !e.RecurrenceID.IsZero() {
+			overridden[occurrenceKey(e.UID, e.RecurrenceID)] = true
+		}
+	}
+
+	var out []Occurrence
+	for _, e := range events {
+		for _, occ := range expand(e, from, to) {
+			if e.RecurrenceID.IsZero() && overridden[occurrenceKey(e.UID, occ.Start)] {
+				continue
+			}
+			out = append(out, occ)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Start.Equal(out[j].Start) {
+			return out[i].Start.Before(out[j].Start)
+		}
+		return out[i].Event.Summary < out[j].Event.Summary
+	})
+	return out
+}
+
+// expand returns the occurrences of one event overlapping [from, to)
+func expand(e Event, from, to time.Time) []Occurrence {
+	dur := e.End.Sub(e.Start)
+	overlaps := func(start time.Time) bool {
+		end := start.Add(dur)
+		return start.Before(to) && (end.After(from) || dur == 0 && !start.Before(from))
+	}
+
+	if e.RRule == "" || !e.RecurrenceID.IsZero() {
+		if overlaps(e.Start) {
+			return []Occurrence{{Event: e, Start: e.Start, End: e.End}}
+		}
+		return nil
+	}
+
+	r, err := parseRule(e.RRule, e.Start.Location())
+	if err != nil {
+		log.Printf("Calendar event %s has an unsupported RRULE (%v), showing its first occurrence only", e.UID, err)
+		if overlaps(e.Start) {
+			return []Occurrence{{Event: e, Start: e.Start, End: e.End}}
+		}
+		return nil
+	}
+
+	var out []Occurrence
+	for _, start := range r.between(e.Start, dur, from, to) {
+		if excluded(e.ExDates, start) {
+			continue
+		}
+		out = append(out, Occurrence{Event: e, Start: start, End: start.Add(dur)})
+	}
+	return out
+}
+
+// conflicting returns the existing timed occurrences overlapping the new event
+func conflicting(events []Event, event Event) []Occurrence {
+	if event.AllDay {
+		return nil
+	}
+
+	to := event.End
+	if event.RRule != "" {
+		to = event.Start.Add(conflictHorizon)
+	}
+	planned := expand(event, event.Start, to)
+	if len(planned) == 0 {
+		return nil
+	}
+
+	var conflicts []Occurrence
+	seen := make(map[string]bool)
+	for _, existing := range occurrences(events, event.Start, planned[len(planned)-1].End) {
+		if existing.Event.AllDay || seen[occurrenceKey(existing.Event.UID, existing.Start)] {
+			continue
+		}
+		for _, p := range planned {
+			if existing.Start.Before(p.End) && p.Start.Before(existing.End) {
+				seen[occurrenceKey(existing.Event.UID, existing.Start)] = true
+				conflicts = append(conflicts, existing)
+				break
+			}
+		}
+	}
+	return conflicts
+}
+
+func excluded(exDates []time.Time, start time.Time) bool {
+	for _, t := range exDates {
+		if t.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+func occurrenceKey(uid string, start time.Time) string {
+	return fmt.Sprintf("%s@%d", uid, start.Unix())
+}
@@ -0,0 +1,114 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarFileStore(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	at := func(d, h int) time.Time { return time.Date(2026, 10, d, h, 0, 0, 0, shanghai) }
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "calendar.ics")
+	cal := New(NewFileStore(path, shanghai), shanghai)
+
+	weekly, conflicts, err := cal.Add(ctx, Event{Summary: "周会", Start: at(19, 15), End: at(19, 16), RRule: "FREQ=WEEKLY;BYDAY=MO"})
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("Add(周会) = %v, %v", conflicts, err)
+	}
+	if _, _, err := cal.Add(ctx, Event{Summary: "国庆假期", Start: at(19, 0), End: at(20, 0), AllDay: true}); err != nil {
+		t.Fatalf("Add(all-day) error = %v", err)
+	}
+
+	// A one-off event overlapping the second weekly meeting conflicts; the all-day event doesn't count
+	review, conflicts, err := cal.Add(ctx, Event{Summary: "产品评审", Location: "会议室A", Start: time.Date(2026, 10, 26, 15, 30, 0, 0, shanghai), End: at(26, 17)})
+	if err != nil {
+		t.Fatalf("Add(产品评审) error = %v", err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Event.UID != weekly.UID || !conflicts[0].Start.Equal(at(26, 15)) {
+		t.Errorf("Conflicts = %+v, want the 10/26 weekly meeting", conflicts)
+	}
+
+	// A new recurring event is checked against all its upcoming occurrences
+	_, conflicts, err = cal.Add(ctx, Event{Summary: "一对一", Start: at(20, 15), End: at(20, 16), RRule: "FREQ=DAILY"})
+	if err != nil || len(conflicts) == 0 {
+		t.Errorf("Add(daily) conflicts = %v, %v, want the weekly meetings", conflicts, err)
+	}
+
+	// A new store on the same file sees the events, in the calendar's time zone
+	cal = New(NewFileStore(path, shanghai), shanghai)
+	occs, err := cal.Occurrences(ctx, at(26, 0), at(27, 0))
+	if err != nil {
+		t.Fatalf("Occurrences() error = %v", err)
+	}
+	var summaries []string
+	for _, occ := range occs {
+		summaries = append(summaries, occ.Event.Summary)
+	}
+	if strings.Join(summaries, ",") != "一对一,周会,产品评审" {
+		t.Errorf("Occurrences on 10/26 = %v", summaries)
+	}
+
+	found, err := cal.Find(ctx, "", "评审")
+	if err != nil || len(found) != 1 || found[0].UID != review.UID || found[0].Location != "会议室A" {
+		t.Errorf("Find(评审) = %+v, %v", found, err)
+	}
+	if found, _ := cal.Find(ctx, weekly.UID[:8], ""); len(found) != 1 || found[0].UID != weekly.UID {
+		t.Errorf("Find(id prefix) = %+v", found)
+	}
+
+	if err := cal.Delete(ctx, weekly.UID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := cal.Delete(ctx, weekly.UID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() twice error = %v, want ErrNotFound", err)
+	}
+	if occs, _ := cal.Occurrences(ctx, at(19, 0), at(20, 0)); len(occs) != 1 || !occs[0].Event.AllDay {
+		t.Errorf("Occurrences after delete = %+v, want only the all-day event", occs)
+	}
+
+	if _, _, err := cal.Add(ctx, Event{Summary: "", Start: at(21, 9), End: at(21, 10)}); err == nil {
+		t.Error("Expected an error for an event without summary")
+	}
+	if _, _, err := cal.Add(ctx, Event{Summary: "x", Start: at(21, 9), End: at(21, 8)}); err == nil {
+		t.Error("Expected an error for an event ending before it starts")
+	}
+}
+
+func TestOccurrencesWithOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calendar.ics")
+	os.WriteFile(path, []byte("BEGIN:VCALENDAR\r\n"+
+		"BEGIN:VEVENT\r\nUID:standup\r\nDTSTART:20261019T010000Z\r\nDTEND:20261019T011500Z\r\nRRULE:FREQ=DAILY;COUNT=3\r\nEXDATE:20261020T010000Z\r\nSUMMARY:站会\r\nEND:VEVENT\r\n"+
+		"BEGIN:VEVENT\r\nUID:standup\r\nRECURRENCE-ID:20261021T010000Z\r\nDTSTART:20261021T030000Z\r\nDTEND:20261021T031500Z\r\nSUMMARY:站会（改期）\r\nEND:VEVENT\r\n"+
+		"END:VCALENDAR\r\n"), 0644)
+
+	cal := New(NewFileStore(path, time.UTC), time.UTC)
+	occs, err := cal.Occurrences(context.Background(), time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Occurrences() error = %v", err)
+	}
+
+	var got []string
+	for _, occ := range occs {
+		got = append(got, occ.Start.Format("01-02 15:04")+" "+occ.Event.Summary)
+	}
+	want := "10-19 01:00 站会,10-21 03:00 站会（改期）"
+	if strings.Join(got, ",") != want {
+		t.Errorf("Occurrences = %v, want %s", got, want)
+	}
+
+	// Deleting the series removes the override too
+	if err := cal.Delete(context.Background(), "standup"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "VEVENT") {
+		t.Errorf("Calendar still has events after deleting the series:\n%s", data)
+	}
+}
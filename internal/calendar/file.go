@@ -0,0 +1,109 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStore keeps events in a local .ics file
+//
+// Components and properties written by other applications are preserved when
+// the file is rewritten.
+type FileStore struct {
+	path string
+	loc  *time.Location
+	mu   sync.Mutex
+}
+
+// NewFileStore creates a store for the .ics file at path, which is created on the first write
+func NewFileStore(path string, loc *time.Location) *FileStore {
+	return &FileStore{path: path, loc: loc}
+}
+
+// Load returns the events in the file
+func (s *FileStore) Load(ctx context.Context) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return doc.events, nil
+}
+
+// Create appends an event to the file
+func (s *FileStore) Create(ctx context.Context, event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, err := s.read()
+	if err != nil {
+		return err
+	}
+	for _, e := range doc.events {
+		if e.UID == event.UID {
+			return fmt.Errorf("event %s already exists", event.UID)
+		}
+	}
+	doc.events = append(doc.events, event)
+	return s.write(doc)
+}
+
+// Delete removes the event with the UID and its overrides from the file
+func (s *FileStore) Delete(ctx context.Context, uid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, err := s.read()
+	if err != nil {
+		return err
+	}
+	kept := doc.events[:0]
+	for _, e := range doc.events {
+		if e.UID != uid {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(doc.events) {
+		return ErrNotFound
+	}
+	doc.events = kept
+	return s.write(doc)
+}
+
+// read parses the file; a missing file is an empty calendar
+func (s *FileStore) read() (*document, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &document{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read calendar: %w", err)
+	}
+	doc, err := parseICS(data, s.loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse calendar %s: %w", s.path, err)
+	}
+	return doc, nil
+}
+
+// write replaces the file atomically
+func (s *FileStore) write(doc *document) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create calendar directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, doc.encode(), 0644); err != nil {
+		return fmt.Errorf("failed to write calendar: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write calendar: %w", err)
+	}
+	return nil
+}
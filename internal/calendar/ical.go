@@ -0,0 +1,387 @@
+package calendar
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// prodID identifies this program in the calendars it writes
+const prodID = "-//VoicePilot-Eino//Calendar//ZH"
+
+// maxLineOctets is the longest content line RFC 5545 allows before folding
+const maxLineOctets = 75
+
+// document is a parsed iCalendar file
+type document struct {
+	props  []string // calendar properties other than VERSION and PRODID, e.g. X-WR-CALNAME
+	other  []string // lines of components other than events (VTIMEZONE, VTODO, ...)
+	events []Event
+}
+
+// property is a parsed content line
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parseICS parses an iCalendar stream; times without a time zone are taken to be in loc
+//
+// Properties and nested components the calendar doesn't use are kept so the
+// document can be written back without losing them.
+func parseICS(data []byte, loc *time.Location) (*document, error) {
+	doc := &document{}
+
+	var current *eventBuilder
+	nested, otherDepth := 0, 0
+	for i, line := range unfold(data) {
+		if line == "" {
+			continue
+		}
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		switch {
+		case current != nil && nested > 0:
+			current.event.extra = append(current.event.extra, line)
+			if prop.name == "BEGIN" {
+				nested++
+			} else if prop.name == "END" {
+				nested--
+			}
+		case current != nil && prop.name == "BEGIN":
+			current.event.extra = append(current.event.extra, line)
+			nested = 1
+		case current != nil && prop.name == "END":
+			if event, ok := current.build(); ok {
+				doc.events = append(doc.events, event)
+			}
+			current = nil
+		case current != nil:
+			current.set(prop, line, loc)
+		case otherDepth > 0:
+			doc.other = append(doc.other, line)
+			if prop.name == "BEGIN" {
+				otherDepth++
+			} else if prop.name == "END" {
+				otherDepth--
+			}
+		case prop.name == "BEGIN" && prop.value == "VEVENT":
+			current = &eventBuilder{}
+		case prop.name == "BEGIN" && prop.value != "VCALENDAR":
+			doc.other = append(doc.other, line)
+			otherDepth = 1
+		case prop.name == "BEGIN", prop.name == "END", prop.name == "VERSION", prop.name == "PRODID":
+		default:
+			doc.props = append(doc.props, line)
+		}
+	}
+	if current != nil {
+		return nil, fmt.Errorf("unterminated VEVENT")
+	}
+	return doc, nil
+}
+
+// eventBuilder collects the properties of a VEVENT being parsed
+type eventBuilder struct {
+	event    Event
+	duration time.Duration
+	hasEnd   bool
+	invalid  bool
+}
+
+// set applies one property of the event
+func (b *eventBuilder) set(prop property, line string, loc *time.Location) {
+	e := &b.event
+	var err error
+	switch prop.name {
+	case "UID":
+		e.UID = prop.value
+	case "SUMMARY":
+		e.Summary = unescapeText(prop.value)
+	case "LOCATION":
+		e.Location = unescapeText(prop.value)
+	case "DESCRIPTION":
+		e.Description = unescapeText(prop.value)
+	case "DTSTART":
+		e.Start, e.AllDay, err = parseICalTime(prop, prop.value, loc)
+	case "DTEND":
+		e.End, _, err = parseICalTime(prop, prop.value, loc)
+		b.hasEnd = err == nil
+	case "DURATION":
+		b.duration, err = parseICalDuration(prop.value)
+	case "RRULE":
+		e.RRule = prop.value
+	case "RECURRENCE-ID":
+		e.RecurrenceID, _, err = parseICalTime(prop, prop.value, loc)
+	case "EXDATE":
+		for _, value := range strings.Split(prop.value, ",") {
+			t, _, perr := parseICalTime(prop, value, loc)
+			if perr != nil {
+				err = perr
+				break
+			}
+			e.ExDates = append(e.ExDates, t)
+		}
+	case "DTSTAMP":
+		e.stamped = true
+		e.extra = append(e.extra, line)
+	default:
+		e.extra = append(e.extra, line)
+	}
+	if err != nil {
+		log.Printf("Ignoring calendar event %s with invalid %s: %v", e.UID, prop.name, err)
+		b.invalid = true
+	}
+}
+
+// build completes the event, deriving the end from the duration or start when missing
+func (b *eventBuilder) build() (Event, bool) {
+	e := b.event
+	if b.invalid || e.Start.IsZero() {
+		if !b.invalid {
+			log.Printf("Ignoring calendar event %s without DTSTART", e.UID)
+		}
+		return Event{}, false
+	}
+	if e.UID == "" {
+		e.UID = uuid.New().String()
+	}
+	if !b.hasEnd {
+		switch {
+		case b.duration > 0:
+			e.End = e.Start.Add(b.duration)
+		case e.AllDay:
+			e.End = e.Start.AddDate(0, 0, 1)
+		default:
+			e.End = e.Start
+		}
+	}
+	return e, true
+}
+
+// encode writes the document as an iCalendar stream
+func (d *document) encode() []byte {
+	var b bytes.Buffer
+	writeLine(&b, "BEGIN:VCALENDAR")
+	writeLine(&b, "VERSION:2.0")
+	writeLine(&b, "PRODID:"+prodID)
+	for _, line := range d.props {
+		writeLine(&b, line)
+	}
+	for _, line := range d.other {
+		writeLine(&b, line)
+	}
+	for _, e := range d.events {
+		encodeEvent(&b, e)
+	}
+	writeLine(&b, "END:VCALENDAR")
+	return b.Bytes()
+}
+
+// encodeEvent writes one VEVENT
+func encodeEvent(b *bytes.Buffer, e Event) {
+	writeLine(b, "BEGIN:VEVENT")
+	writeLine(b, "UID:"+e.UID)
+	if !e.stamped {
+		writeLine(b, "DTSTAMP:"+time.Now().UTC().Format("20060102T150405Z"))
+	}
+	writeLine(b, formatICalTime("DTSTART", e.Start, e.AllDay))
+	writeLine(b, formatICalTime("DTEND", e.End, e.AllDay))
+	if !e.RecurrenceID.IsZero() {
+		writeLine(b, formatICalTime("RECURRENCE-ID", e.RecurrenceID, e.AllDay))
+	}
+	if e.RRule != "" {
+		writeLine(b, "RRULE:"+e.RRule)
+	}
+	for _, t := range e.ExDates {
+		writeLine(b, formatICalTime("EXDATE", t, e.AllDay))
+	}
+	if e.Summary != "" {
+		writeLine(b, "SUMMARY:"+escapeText(e.Summary))
+	}
+	if e.Location != "" {
+		writeLine(b, "LOCATION:"+escapeText(e.Location))
+	}
+	if e.Description != "" {
+		writeLine(b, "DESCRIPTION:"+escapeText(e.Description))
+	}
+	for _, line := range e.extra {
+		writeLine(b, line)
+	}
+	writeLine(b, "END:VEVENT")
+}
+
+// unfold splits data into content lines, joining folded continuation lines
+func unfold(data []byte) []string {
+	raw := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	lines := make([]string, 0, len(raw))
+	for _, line := range raw {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, "\r"))
+	}
+	return lines
+}
+
+// writeLine writes a content line, folding it at 75 octets without splitting UTF-8 sequences
+func writeLine(b *bytes.Buffer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLineOctets - 1 // continuation lines start with a space
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// parseLine splits a content line into its name, parameters and value
+func parseLine(line string) (property, error) {
+	inQuotes := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return property{}, fmt.Errorf("malformed content line %q", line)
+	}
+
+	parts := splitUnquoted(line[:colon], ';')
+	prop := property{
+		name:   strings.ToUpper(parts[0]),
+		params: make(map[string]string),
+		value:  line[colon+1:],
+	}
+	for _, param := range parts[1:] {
+		if key, value, ok := strings.Cut(param, "="); ok {
+			prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return prop, nil
+}
+
+// splitUnquoted splits s at sep outside double quotes
+func splitUnquoted(s string, sep rune) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// parseICalTime parses a DATE or DATE-TIME value of prop, honouring its TZID and VALUE parameters
+func parseICalTime(prop property, value string, loc *time.Location) (time.Time, bool, error) {
+	if tzid := prop.params["TZID"]; tzid != "" {
+		if tz, err := time.LoadLocation(tzid); err == nil {
+			loc = tz
+		} else {
+			log.Printf("Unknown calendar time zone %s, using %s", tzid, loc)
+		}
+	}
+
+	if prop.params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// formatICalTime formats a DATE or DATE-TIME property
+//
+// Times in a named IANA zone keep it as TZID so recurring events follow its
+// daylight saving rules; other times are written in UTC.
+func formatICalTime(name string, t time.Time, allDay bool) string {
+	if allDay {
+		return name + ";VALUE=DATE:" + t.Format("20060102")
+	}
+	if tz := t.Location().String(); tz != "UTC" && tz != "Local" {
+		if _, err := time.LoadLocation(tz); err == nil {
+			return name + ";TZID=" + tz + ":" + t.Format("20060102T150405")
+		}
+	}
+	return name + ":" + t.UTC().Format("20060102T150405Z")
+}
+
+var icalDurationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseICalDuration parses a DURATION value such as "PT1H30M" or "P1D"
+func parseICalDuration(value string) (time.Duration, error) {
+	m := icalDurationPattern.FindStringSubmatch(value)
+	if m == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] != "" {
+			n, _ := strconv.Atoi(m[i+2])
+			d += time.Duration(n) * unit
+		}
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// escapeText escapes a TEXT value
+func escapeText(s string) string {
+	return textEscaper.Replace(strings.ReplaceAll(s, "\r\n", "\n"))
+}
+
+// unescapeText reverses escapeText
+func unescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,151 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Example//EN\r\n" +
+	"X-WR-CALNAME:工作\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:Asia/Shanghai\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:19700101T000000\r\n" +
+	"TZOFFSETFROM:+0800\r\n" +
+	"TZOFFSETTO:+0800\r\n" +
+	"END:STANDARD\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:weekly@example.com\r\n" +
+	"DTSTAMP:20261001T000000Z\r\n" +
+	"DTSTART;TZID=Asia/Shanghai:20261005T150000\r\n" +
+	"DURATION:PT1H\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=MO\r\n" +
+	"EXDATE;TZID=Asia/Shanghai:20261012T150000\r\n" +
+	"SUMMARY:周会\\, 产品组\r\n" +
+	"DESCRIPTION:第一行\\n第二行\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"TRIGGER:-PT10M\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:holiday@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20261001\r\n" +
+	"SUMMARY:国庆节\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:utc@example.com\r\n" +
+	"DTSTART:20261016T070000Z\r\n" +
+	"DTEND:20261016T080000Z\r\n" +
+	"SUMMARY:A very long summary that needs to be folded across several lines when it is wr\r\n" +
+	" itten back\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICS(t *testing.T) {
+	doc, err := parseICS([]byte(sampleICS), time.UTC)
+	if err != nil {
+		t.Fatalf("parseICS() error = %v", err)
+	}
+	if len(doc.events) != 3 {
+		t.Fatalf("Parsed %d events, want 3", len(doc.events))
+	}
+
+	weekly := doc.events[0]
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	if !weekly.Start.Equal(time.Date(2026, 10, 5, 15, 0, 0, 0, shanghai)) || weekly.Start.Location().String() != "Asia/Shanghai" {
+		t.Errorf("Start = %v, want 15:00 Asia/Shanghai", weekly.Start)
+	}
+	if weekly.End.Sub(weekly.Start) != time.Hour {
+		t.Errorf("End = %v, want one hour after start", weekly.End)
+	}
+	if weekly.Summary != "周会, 产品组" || weekly.Description != "第一行\n第二行" {
+		t.Errorf("Text not unescaped: %q / %q", weekly.Summary, weekly.Description)
+	}
+	if weekly.RRule != "FREQ=WEEKLY;BYDAY=MO" || len(weekly.ExDates) != 1 {
+		t.Errorf("Recurrence = %q %v", weekly.RRule, weekly.ExDates)
+	}
+
+	holiday := doc.events[1]
+	if !holiday.AllDay || holiday.End.Sub(holiday.Start) != 24*time.Hour {
+		t.Errorf("All-day event = %+v", holiday)
+	}
+	if !strings.HasSuffix(doc.events[2].Summary, "written back") {
+		t.Errorf("Folded summary not joined: %q", doc.events[2].Summary)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	doc, err := parseICS([]byte(sampleICS), time.UTC)
+	if err != nil {
+		t.Fatalf("parseICS() error = %v", err)
+	}
+	encoded := doc.encode()
+
+	for _, line := range strings.Split(string(encoded), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("Line longer than %d octets: %q", maxLineOctets, line)
+		}
+	}
+	for _, want := range []string{"X-WR-CALNAME:工作", "BEGIN:VTIMEZONE", "TRIGGER:-PT10M", "DTSTAMP:20261001T000000Z", "DTSTART;TZID=Asia/Shanghai:20261005T150000"} {
+		if !strings.Contains(string(encoded), want) {
+			t.Errorf("Encoded calendar lost %q", want)
+		}
+	}
+
+	again, err := parseICS(encoded, time.UTC)
+	if err != nil {
+		t.Fatalf("Re-parsing failed: %v", err)
+	}
+	for i, e := range again.events {
+		orig := doc.events[i]
+		if e.UID != orig.UID || e.Summary != orig.Summary || !e.Start.Equal(orig.Start) || !e.End.Equal(orig.End) || e.RRule != orig.RRule {
+			t.Errorf("Event %d changed in round trip: %+v, want %+v", i, e, orig)
+		}
+	}
+}
+
+func TestParseICSErrors(t *testing.T) {
+	for _, data := range []string{
+		"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:a\r\n",
+		"BEGIN:VCALENDAR\r\nno colon here\r\nEND:VCALENDAR\r\n",
+	} {
+		if _, err := parseICS([]byte(data), time.UTC); err == nil {
+			t.Errorf("parseICS(%q) succeeded, want error", data)
+		}
+	}
+
+	// Events with invalid times are skipped rather than failing the calendar
+	doc, err := parseICS([]byte("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:a\r\nDTSTART:tomorrow\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"), time.UTC)
+	if err != nil || len(doc.events) != 0 {
+		t.Errorf("parseICS() = %+v, %v, want the invalid event skipped", doc, err)
+	}
+}
+
+func TestParseICalDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"PT1H":    time.Hour,
+		"PT1H30M": 90 * time.Minute,
+		"P1D":     24 * time.Hour,
+		"P1W":     7 * 24 * time.Hour,
+		"P1DT2H":  26 * time.Hour,
+		"-PT15M":  -15 * time.Minute,
+		"PT45S":   45 * time.Second,
+		"P0D":     0,
+	}
+	for value, want := range tests {
+		if got, err := parseICalDuration(value); err != nil || got != want {
+			t.Errorf("parseICalDuration(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+
+	for _, value := range []string{"PT", "1H", "P", "PT1H30MX"} {
+		if _, err := parseICalDuration(value); err == nil {
+			t.Errorf("parseICalDuration(%q) succeeded, want error", value)
+		}
+	}
+}
@@ -0,0 +1,479 @@
+package calendar
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPeriods bounds how many recurrence periods are examined when expanding a rule
+const maxPeriods = 50000
+
+// rule is a parsed RRULE
+//
+// Supported: FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL,
+// BYDAY (with ordinals for MONTHLY/YEARLY), BYMONTHDAY, BYMONTH and WKST.
+type rule struct {
+	freq       string
+	interval   int
+	count      int
+	until      time.Time
+	byDay      []weekdayNum
+	byMonthDay []int
+	byMonth    []time.Month
+}
+
+// weekdayNum is a BYDAY entry: a weekday, optionally the nth (or nth-last when negative) of the month
+type weekdayNum struct {
+	n   int
+	day time.Weekday
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+var byDayPattern = regexp.MustCompile(`^([+-]?\d{1,2})?(SU|MO|TU|WE|TH|FR|SA)$`)
+
+// parseRule parses an RRULE value; a date-only UNTIL is inclusive of that day in loc
+func parseRule(value string, loc *time.Location) (*rule, error) {
+	r := &rule{interval: 1}
+	for _, part := range strings.Split(strings.ToUpper(strings.TrimSpace(value)), ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed RRULE part %q", part)
+		}
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch val {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				r.freq = val
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %s", val)
+			}
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(val)
+			if err == nil && r.interval < 1 {
+				err = fmt.Errorf("INTERVAL must be positive")
+			}
+		case "COUNT":
+			r.count, err = strconv.Atoi(val)
+			if err == nil && r.count < 1 {
+				err = fmt.Errorf("COUNT must be positive")
+			}
+		case "UNTIL":
+			if len(val) == len("20060102") {
+				r.until, err = time.ParseInLocation("20060102", val, loc)
+				r.until = r.until.AddDate(0, 0, 1).Add(-time.Second)
+			} else if strings.HasSuffix(val, "Z") {
+				r.until, err = time.Parse("20060102T150405Z", val)
+			} else {
+				r.until, err = time.ParseInLocation("20060102T150405", val, loc)
+			}
+		case "BYDAY":
+			for _, code := range strings.Split(val, ",") {
+				m := byDayPattern.FindStringSubmatch(code)
+				if m == nil {
+					return nil, fmt.Errorf("invalid BYDAY %q", code)
+				}
+				n, _ := strconv.Atoi(strings.TrimPrefix(m[1], "+"))
+				r.byDay = append(r.byDay, weekdayNum{n: n, day: weekdayCodes[m[2]]})
+			}
+		case "BYMONTHDAY":
+			for _, s := range strings.Split(val, ",") {
+				day, err := strconv.Atoi(s)
+				if err != nil || day == 0 || day < -31 || day > 31 {
+					return nil, fmt.Errorf("invalid BYMONTHDAY %q", s)
+				}
+				r.byMonthDay = append(r.byMonthDay, day)
+			}
+		case "BYMONTH":
+			for _, s := range strings.Split(val, ",") {
+				month, err := strconv.Atoi(s)
+				if err != nil || month < 1 || month > 12 {
+					return nil, fmt.Errorf("invalid BYMONTH %q", s)
+				}
+				r.byMonth = append(r.byMonth, time.Month(month))
+			}
+		case "WKST":
+			// Weeks always start on Monday, the default
+		default:
+			return nil, fmt.Errorf("unsupported RRULE part %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+
+	if r.freq == "" {
+		return nil, fmt.Errorf("RRULE without FREQ")
+	}
+	return r, nil
+}
+
+// between returns the occurrence start times of the rule for an event starting at start
+// whose occurrences of length dur overlap [from, to)
+func (r *rule) between(start time.Time, dur time.Duration, from, to time.Time) []time.Time {
+	var out []time.Time
+	count := 0
+	for i := 0; i < maxPeriods; i++ {
+		for _, t := range r.period(start, i) {
+			if t.Before(start) {
+				continue
+			}
+			if !r.until.IsZero() && t.After(r.until) {
+				return out
+			}
+			count++
+			if r.count > 0 && count > r.count {
+				return out
+			}
+			if !t.Before(to) {
+				return out
+			}
+			if t.Add(dur).After(from) || (dur == 0 && !t.Before(from)) {
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+// period returns the sorted candidate start times in the i-th period after start
+func (r *rule) period(start time.Time, i int) []time.Time {
+	loc := start.Location()
+	y, m, d := start.Date()
+	h, mi, s := start.Clock()
+	at := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, h, mi, s, 0, loc) }
+
+	var candidates []time.Time
+	switch r.freq {
+	case "DAILY":
+		t := at(y, m, d+i*r.interval)
+		if r.matchesDay(t) {
+			candidates = append(candidates, t)
+		}
+	case "WEEKLY":
+		monday := d - (int(start.Weekday())+6)%7 + i*r.interval*7
+		if len(r.byDay) == 0 {
+			candidates = append(candidates, at(y, m, d+i*r.interval*7))
+		}
+		for _, wd := range r.byDay {
+			candidates = append(candidates, at(y, m, monday+(int(wd.day)+6)%7))
+		}
+	case "MONTHLY":
+		first := at(y, m+time.Month(i*r.interval), 1)
+		if len(r.byMonth) == 0 || containsMonth(r.byMonth, first.Month()) {
+			candidates = r.monthDays(first, d, at)
+		}
+	case "YEARLY":
+		year := y + i*r.interval
+		months := r.byMonth
+		if len(months) == 0 {
+			months = []time.Month{m}
+		}
+		for _, month := range months {
+			candidates = append(candidates, r.monthDays(at(year, month, 1), d, at)...)
+		}
+	}
+
+	sort.Slice(candidates, func(a, b int) bool { return candidates[a].Before(candidates[b]) })
+	return candidates
+}
+
+// monthDays returns the candidate days in the month starting at first
+//
+// Without BYDAY or BYMONTHDAY the start's day of the month is used; months
+// too short for it are skipped, as RFC 5545 requires.
+func (r *rule) monthDays(first time.Time, defaultDay int, at func(int, time.Month, int) time.Time) []time.Time {
+	y, m := first.Year(), first.Month()
+	last := time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	var days []int
+	switch {
+	case len(r.byDay) > 0:
+		for _, wd := range r.byDay {
+			firstMatch := 1 + (int(wd.day)-int(first.Weekday())+7)%7
+			var matches []int
+			for day := firstMatch; day <= last; day += 7 {
+				matches = append(matches, day)
+			}
+			switch {
+			case wd.n == 0:
+				days = append(days, matches...)
+			case wd.n > 0 && wd.n <= len(matches):
+				days = append(days, matches[wd.n-1])
+			case wd.n < 0 && -wd.n <= len(matches):
+				days = append(days, matches[len(matches)+wd.n])
+			}
+		}
+		if len(r.byMonthDay) > 0 {
+			days = filterDays(days, r.byMonthDay, last)
+		}
+	case len(r.byMonthDay) > 0:
+		for _, day := range r.byMonthDay {
+			if day < 0 {
+				day = last + day + 1
+			}
+			if day >= 1 && day <= last {
+				days = append(days, day)
+			}
+		}
+	case defaultDay <= last:
+		days = []int{defaultDay}
+	}
+
+	out := make([]time.Time, 0, len(days))
+	seen := make(map[int]bool)
+	for _, day := range days {
+		if !seen[day] {
+			seen[day] = true
+			out = append(out, at(y, m, day))
+		}
+	}
+	return out
+}
+
+// matchesDay applies BYDAY, BYMONTHDAY and BYMONTH as filters for DAILY rules
+func (r *rule) matchesDay(t time.Time) bool {
+	if len(r.byMonth) > 0 && !containsMonth(r.byMonth, t.Month()) {
+		return false
+	}
+	if len(r.byDay) > 0 {
+		found := false
+		for _, wd := range r.byDay {
+			found = found || wd.day == t.Weekday()
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.byMonthDay) > 0 {
+		last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		return len(filterDays([]int{t.Day()}, r.byMonthDay, last)) > 0
+	}
+	return true
+}
+
+// filterDays keeps the days listed in byMonthDay, where negative entries count from the month's end
+func filterDays(days, byMonthDay []int, last int) []int {
+	var out []int
+	for _, day := range days {
+		for _, want := range byMonthDay {
+			if want == day || last+want+1 == day {
+				out = append(out, day)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func containsMonth(months []time.Month, m time.Month) bool {
+	for _, month := range months {
+		if month == m {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// repeatPattern matches "每天", "每两周", "每隔3天", "每周一三五", "每月15号", ...
+	repeatPattern = regexp.MustCompile(`^每(?:隔)?([0-9]+|[一二两三四五六七八九十]+)?个?(天|日|周|星期|礼拜|月|年)(.*)$`)
+
+	// weekdayRangePattern matches a range of weekdays after "每周": "一到五", "一至周五"
+	weekdayRangePattern = regexp.MustCompile(`^([一二三四五六日天])(?:到|至|-)(?:周|星期|礼拜)?([一二三四五六日天])$`)
+
+	// monthDayRepeatPattern matches the day of the month after "每月": "15号", "1日"
+	monthDayRepeatPattern = regexp.MustCompile(`^([0-9]{1,2})[日号]$`)
+)
+
+// chineseWeekdays maps weekday characters to RRULE codes
+var chineseWeekdays = map[rune]string{'一': "MO", '二': "TU", '三': "WE", '四': "TH", '五': "FR", '六': "SA", '日': "SU", '天': "SU"}
+
+var weekdayOrder = []string{"MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+// ParseRecurrence converts a repeat expression from the user's request to an RRULE value
+//
+// It accepts RRULE values ("FREQ=WEEKLY;BYDAY=MO") as well as phrases such as
+// "每天", "工作日", "每周一三五", "每两周", "每月15号", "每年" and "weekly".
+// Empty and "不重复" mean no recurrence and return "".
+func ParseRecurrence(expr string) (string, error) {
+	expr = strings.TrimSpace(expr)
+	upper := strings.TrimPrefix(strings.ToUpper(expr), "RRULE:")
+	if strings.HasPrefix(upper, "FREQ=") {
+		if _, err := parseRule(upper, time.UTC); err != nil {
+			return "", err
+		}
+		return upper, nil
+	}
+
+	switch strings.ToLower(expr) {
+	case "", "不重复", "一次", "once", "none":
+		return "", nil
+	case "daily", "every day":
+		return "FREQ=DAILY", nil
+	case "weekly", "every week":
+		return "FREQ=WEEKLY", nil
+	case "monthly", "every month":
+		return "FREQ=MONTHLY", nil
+	case "yearly", "annually", "every year":
+		return "FREQ=YEARLY", nil
+	case "工作日", "每个工作日", "每周工作日", "weekdays", "every weekday":
+		return "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", nil
+	case "周末", "每个周末", "每周末", "weekends":
+		return "FREQ=WEEKLY;BYDAY=SA,SU", nil
+	case "隔天":
+		return "FREQ=DAILY;INTERVAL=2", nil
+	case "隔周":
+		return "FREQ=WEEKLY;INTERVAL=2", nil
+	}
+
+	m := repeatPattern.FindStringSubmatch(expr)
+	if m == nil {
+		return "", fmt.Errorf("unrecognized recurrence %q", expr)
+	}
+
+	parts := []string{"FREQ=" + map[string]string{
+		"天": "DAILY", "日": "DAILY", "周": "WEEKLY", "星期": "WEEKLY", "礼拜": "WEEKLY", "月": "MONTHLY", "年": "YEARLY",
+	}[m[2]]}
+	if m[1] != "" {
+		interval := chineseNumber(m[1])
+		if interval < 1 {
+			return "", fmt.Errorf("unrecognized recurrence %q", expr)
+		}
+		if interval > 1 {
+			parts = append(parts, fmt.Sprintf("INTERVAL=%d", interval))
+		}
+	}
+
+	rest := strings.TrimSpace(m[3])
+	switch {
+	case rest == "":
+	case parts[0] == "FREQ=WEEKLY":
+		days, ok := parseWeekdays(rest)
+		if !ok {
+			return "", fmt.Errorf("unrecognized recurrence %q", expr)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	case parts[0] == "FREQ=MONTHLY" && (rest == "最后一天" || rest == "底"):
+		parts = append(parts, "BYMONTHDAY=-1")
+	case parts[0] == "FREQ=MONTHLY" && monthDayRepeatPattern.MatchString(rest):
+		day, _ := strconv.Atoi(monthDayRepeatPattern.FindStringSubmatch(rest)[1])
+		if day < 1 || day > 31 {
+			return "", fmt.Errorf("unrecognized recurrence %q", expr)
+		}
+		parts = append(parts, fmt.Sprintf("BYMONTHDAY=%d", day))
+	default:
+		return "", fmt.Errorf("unrecognized recurrence %q", expr)
+	}
+	return strings.Join(parts, ";"), nil
+}
+
+// parseWeekdays parses the weekdays after "每周", e.g. "一三五", "一、周三和周五", "一到五"
+func parseWeekdays(s string) ([]string, bool) {
+	if m := weekdayRangePattern.FindStringSubmatch(s); m != nil {
+		from := indexOf(weekdayOrder, chineseWeekdays[[]rune(m[1])[0]])
+		to := indexOf(weekdayOrder, chineseWeekdays[[]rune(m[2])[0]])
+		if from > to {
+			return nil, false
+		}
+		return weekdayOrder[from : to+1], true
+	}
+
+	selected := make(map[string]bool)
+	cleaned := strings.NewReplacer("星期", "", "礼拜", "", "周", "", "和", "", "及", "", "、", "", ",", "", "，", "", " ", "").Replace(s)
+	if cleaned == "" {
+		return nil, false
+	}
+	for _, r := range cleaned {
+		code, ok := chineseWeekdays[r]
+		if !ok {
+			return nil, false
+		}
+		selected[code] = true
+	}
+
+	var days []string
+	for _, code := range weekdayOrder {
+		if selected[code] {
+			days = append(days, code)
+		}
+	}
+	return days, true
+}
+
+// DescribeRecurrence describes an RRULE value for speech, e.g. "每周一、三重复"
+func DescribeRecurrence(value string) string {
+	r, err := parseRule(value, time.UTC)
+	if err != nil {
+		return "重复"
+	}
+
+	unit := map[string]string{"DAILY": "天", "WEEKLY": "周", "MONTHLY": "月", "YEARLY": "年"}[r.freq]
+	every := "每" + unit
+	if r.interval > 1 {
+		every = fmt.Sprintf("每%d%s", r.interval, map[string]string{"DAILY": "天", "WEEKLY": "周", "MONTHLY": "个月", "YEARLY": "年"}[r.freq])
+	}
+
+	if r.freq == "WEEKLY" && len(r.byDay) > 0 {
+		names := "日一二三四五六"
+		days := make([]string, len(r.byDay))
+		for i, wd := range r.byDay {
+			days[i] = string([]rune(names)[wd.day])
+		}
+		every += strings.Join(days, "、")
+	}
+	if r.freq == "MONTHLY" && len(r.byMonthDay) == 1 {
+		if r.byMonthDay[0] == -1 {
+			every += "最后一天"
+		} else {
+			every += fmt.Sprintf("%d号", r.byMonthDay[0])
+		}
+	}
+	if r.count > 0 {
+		return fmt.Sprintf("%s重复，共%d次", every, r.count)
+	}
+	return every + "重复"
+}
+
+// chineseNumber parses a small Arabic or Chinese number such as "3", "两" or "十二"; it returns 0 if invalid
+func chineseNumber(s string) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	digits := map[rune]int{'一': 1, '二': 2, '两': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+	total, current := 0, 0
+	for _, r := range s {
+		if r == '十' {
+			if current == 0 {
+				current = 1
+			}
+			total += current * 10
+			current = 0
+			continue
+		}
+		digit, ok := digits[r]
+		if !ok {
+			return 0
+		}
+		current = digit
+	}
+	return total + current
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
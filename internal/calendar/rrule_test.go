@@ -0,0 +1,151 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRuleExpansion(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	newYork, _ := time.LoadLocation("America/New_York")
+	at := func(loc *time.Location, y int, m time.Month, d, h int) time.Time {
+		return time.Date(y, m, d, h, 0, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name     string
+		rrule    string
+		start    time.Time
+		from, to time.Time
+		want     []time.Time
+	}{
+		{
+			name:  "daily with count",
+			rrule: "FREQ=DAILY;COUNT=3",
+			start: at(shanghai, 2026, 10, 16, 9),
+			from:  at(shanghai, 2026, 10, 1, 0), to: at(shanghai, 2026, 11, 1, 0),
+			want: []time.Time{at(shanghai, 2026, 10, 16, 9), at(shanghai, 2026, 10, 17, 9), at(shanghai, 2026, 10, 18, 9)},
+		},
+		{
+			name:  "weekly on weekdays within a window",
+			rrule: "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+			start: at(shanghai, 2026, 10, 14, 10), // a Wednesday
+			from:  at(shanghai, 2026, 10, 16, 0), to: at(shanghai, 2026, 10, 22, 0),
+			want: []time.Time{at(shanghai, 2026, 10, 16, 10), at(shanghai, 2026, 10, 19, 10), at(shanghai, 2026, 10, 21, 10)},
+		},
+		{
+			name:  "every other week until a date",
+			rrule: "FREQ=WEEKLY;INTERVAL=2;UNTIL=20261103",
+			start: at(shanghai, 2026, 10, 6, 15),
+			from:  at(shanghai, 2026, 10, 1, 0), to: at(shanghai, 2026, 12, 31, 0),
+			want: []time.Time{at(shanghai, 2026, 10, 6, 15), at(shanghai, 2026, 10, 20, 15), at(shanghai, 2026, 11, 3, 15)},
+		},
+		{
+			name:  "monthly on the 31st skips short months",
+			rrule: "FREQ=MONTHLY;COUNT=3",
+			start: at(shanghai, 2026, 10, 31, 9),
+			from:  at(shanghai, 2026, 10, 1, 0), to: at(shanghai, 2027, 6, 1, 0),
+			want: []time.Time{at(shanghai, 2026, 10, 31, 9), at(shanghai, 2026, 12, 31, 9), at(shanghai, 2027, 1, 31, 9)},
+		},
+		{
+			name:  "monthly on the last Friday",
+			rrule: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=2",
+			start: at(shanghai, 2026, 10, 1, 17),
+			from:  at(shanghai, 2026, 10, 1, 0), to: at(shanghai, 2027, 1, 1, 0),
+			want: []time.Time{at(shanghai, 2026, 10, 30, 17), at(shanghai, 2026, 11, 27, 17)},
+		},
+		{
+			name:  "monthly on the last day",
+			rrule: "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=2",
+			start: at(shanghai, 2027, 1, 31, 9),
+			from:  at(shanghai, 2027, 1, 1, 0), to: at(shanghai, 2027, 12, 1, 0),
+			want: []time.Time{at(shanghai, 2027, 1, 31, 9), at(shanghai, 2027, 2, 28, 9)},
+		},
+		{
+			name:  "yearly on Feb 29 only in leap years",
+			rrule: "FREQ=YEARLY;COUNT=2",
+			start: at(shanghai, 2024, 2, 29, 8),
+			from:  at(shanghai, 2024, 1, 1, 0), to: at(shanghai, 2030, 1, 1, 0),
+			want: []time.Time{at(shanghai, 2024, 2, 29, 8), at(shanghai, 2028, 2, 29, 8)},
+		},
+		{
+			name:  "weekly keeps the local time across daylight saving",
+			rrule: "FREQ=WEEKLY",
+			start: at(newYork, 2026, 10, 26, 9),
+			from:  at(newYork, 2026, 10, 1, 0), to: at(newYork, 2026, 11, 10, 0),
+			want: []time.Time{at(newYork, 2026, 10, 26, 9), at(newYork, 2026, 11, 2, 9), at(newYork, 2026, 11, 9, 9)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := parseRule(tt.rrule, tt.start.Location())
+			if err != nil {
+				t.Fatalf("parseRule(%q) error = %v", tt.rrule, err)
+			}
+			got := r.between(tt.start, time.Hour, tt.from, tt.to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("between() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("Occurrence %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for _, value := range []string{"", "BYDAY=MO", "FREQ=HOURLY", "FREQ=DAILY;INTERVAL=0", "FREQ=WEEKLY;BYDAY=XX", "FREQ=DAILY;BYSETPOS=1", "FREQ"} {
+		if _, err := parseRule(value, time.UTC); err == nil {
+			t.Errorf("parseRule(%q) succeeded, want error", value)
+		}
+	}
+}
+
+func TestParseRecurrence(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"不重复":                      "",
+		"每天":                       "FREQ=DAILY",
+		"每两周":                      "FREQ=WEEKLY;INTERVAL=2",
+		"隔周":                       "FREQ=WEEKLY;INTERVAL=2",
+		"每隔3天":                     "FREQ=DAILY;INTERVAL=3",
+		"每周一三五":                    "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+		"每周二和周四":                   "FREQ=WEEKLY;BYDAY=TU,TH",
+		"每周一到周五":                   "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+		"工作日":                      "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+		"每月15号":                    "FREQ=MONTHLY;BYMONTHDAY=15",
+		"每月最后一天":                   "FREQ=MONTHLY;BYMONTHDAY=-1",
+		"每年":                       "FREQ=YEARLY",
+		"weekly":                   "FREQ=WEEKLY",
+		"RRULE:FREQ=DAILY;COUNT=5": "FREQ=DAILY;COUNT=5",
+	}
+	for expr, want := range tests {
+		if got, err := ParseRecurrence(expr); err != nil || got != want {
+			t.Errorf("ParseRecurrence(%q) = %q, %v, want %q", expr, got, err, want)
+		}
+	}
+
+	for _, expr := range []string{"偶尔", "每周八", "每月32号", "FREQ=SOMETIMES", "每周五到周一"} {
+		if got, err := ParseRecurrence(expr); err == nil {
+			t.Errorf("ParseRecurrence(%q) = %q, want error", expr, got)
+		}
+	}
+}
+
+func TestDescribeRecurrence(t *testing.T) {
+	tests := map[string]string{
+		"FREQ=DAILY":                 "每天重复",
+		"FREQ=WEEKLY;BYDAY=MO,WE":    "每周一、三重复",
+		"FREQ=WEEKLY;INTERVAL=2":     "每2周重复",
+		"FREQ=MONTHLY;BYMONTHDAY=15": "每月15号重复",
+		"FREQ=YEARLY;COUNT=3":        "每年重复，共3次",
+	}
+	for value, want := range tests {
+		if got := DescribeRecurrence(value); got != want {
+			t.Errorf("DescribeRecurrence(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
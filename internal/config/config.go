@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -11,7 +12,13 @@ import (
 // Config holds all configuration for the application
 type Config struct {
 	// Server configuration
-	Port string
+	Port             string
+	WSAllowedOrigins []string // origins allowed to open WebSocket connections besides the server's own, "*" for any
+
+	// Provider selection ("qiniu" or "openai")
+	ASRProvider string
+	TTSProvider string
+	LLMProvider string
 
 	// Qiniu Cloud API configuration
 	QiniuAPIKey   string
@@ -24,23 +31,86 @@ type Config struct {
 	ASRModel  string
 	ASRFormat string
 
+	// OpenAI-compatible API configuration
+	OpenAIAPIKey   string
+	OpenAIBaseURL  string
+	OpenAIASRModel string
+	OpenAITTSModel string
+	OpenAITTSVoice string
+
 	// LLM configuration
-	LLMModel       string
-	LLMMaxTokens   int
-	LLMTemperature float64
+	LLMModel          string
+	LLMMaxTokens      int
+	LLMTemperature    float64
+	LLMToolCalling    bool           // use native tool calling for intent and planning when the provider supports it
+	LLMJSONReprompt   bool           // ask the model once more when its JSON output fails validation
+	LLMContextWindow  int            // tokens the model accepts, prompt and completion together
+	LLMContextWindows map[string]int // per-model overrides of LLMContextWindow
 
 	// Audio storage
 	StaticAudioPath string
 	TempAudioPath   string
 
+	// Workflow graph definition (YAML or JSON); empty uses the built-in graph
+	WorkflowGraphPath string
+
+	// Directory of action plugins, one subdirectory with a manifest per plugin
+	PluginsDir string
+
+	// Root directory file actions are confined to
+	WorkspaceRoot string
+
+	// Reminders: JSON file of scheduled jobs, and an optional webhook fired reminders are posted to
+	RemindersStorePath string
+	PushWebhookURL     string
+
+	// Calendar: a local iCalendar file, or a CalDAV collection when CalDAVURL is set
+	CalendarPath     string
+	CalendarTimezone string // IANA name, empty uses the local time zone
+	CalDAVURL        string
+	CalDAVUsername   string
+	CalDAVPassword   string
+
 	// Session and context management
-	SessionStoragePath  string
-	SessionMaxHistory   int
-	SessionExpiryHours  int
+	SessionStoragePath      string
+	SessionMaxHistory       int
+	SessionExpiryHours      int
+	SessionStore            string // "file", "sqlite", "redis" or "memory"
+	SessionDBPath           string // database of the sqlite store
+	RedisURL                string // server of the redis store
+	SessionSummaryThreshold int    // messages after which older turns are summarized, 0 disables
+	SessionSummaryKeep      int    // recent messages kept verbatim when summarizing
+	SessionSummaryMaxTokens int    // token budget of the summary
+
+	// Long-term memory of facts about users
+	MemoryEnabled     bool
+	MemoryStoragePath string
+	MemoryMaxFacts    int    // per user, the least recently updated are dropped first
+	MemoryRecallLimit int    // facts given to the model per request
+	MemoryAPISecret   string // signs the per-user tokens of the memory endpoints, which are closed without it
+
+	// Observability
+	TracesExporter     string // "none", "stdout" or "otlp"
+	ServiceName        string
+	TraceRetentionDays int // days workflow traces are kept, 0 keeps them forever
 
 	// Security
-	EnableSafeMode bool
-	MaxAudioSize   int64 // in bytes
+	EnableSafeMode     bool
+	MaxAudioSize       int64  // in bytes
+	SecurityPolicyPath string // YAML/JSON policy file, empty uses the built-in checks only
+
+	// Command sandbox
+	SandboxMode           string // "auto", "bwrap", "namespaces" or "none"
+	SandboxWorkDir        string
+	SandboxTimeoutSeconds int
+	SandboxMaxOutputBytes int
+	SandboxMemoryMB       int
+
+	// Action timeouts and retries
+	ActionTimeoutSeconds int // per attempt, 0 for no limit
+	ActionMaxRetries     int
+	ActionRetryBackoffMs int
+	ActionPolicyPath     string // YAML/JSON per-action overrides, empty applies the defaults to every action
 }
 
 var AppConfig *Config
@@ -51,28 +121,79 @@ func Load() error {
 	_ = godotenv.Load()
 
 	AppConfig = &Config{
-		Port:               getEnv("PORT", "8080"),
-		QiniuAPIKey:        getEnv("QINIU_API_KEY", ""),
-		QiniuBaseURL:       getEnv("QINIU_BASE_URL", "https://openai.qiniu.com/v1"),
-		TTSVoiceType:       getEnv("TTS_VOICE_TYPE", "qiniu_zh_female_wwxkjx"),
-		TTSEncoding:        getEnv("TTS_ENCODING", "mp3"),
-		TTSSpeedRatio:      getEnvFloat("TTS_SPEED_RATIO", 1.0),
-		ASRModel:           getEnv("ASR_MODEL", "asr"),
-		ASRFormat:          getEnv("ASR_FORMAT", "wav"),
-		LLMModel:           getEnv("LLM_MODEL", "deepseek/deepseek-v3.1-terminus"),
-		LLMMaxTokens:       getEnvInt("LLM_MAX_TOKENS", 2000),
-		LLMTemperature:     getEnvFloat("LLM_TEMPERATURE", 0.7),
-		StaticAudioPath:    getEnv("STATIC_AUDIO_PATH", "./static/audio"),
-		TempAudioPath:      getEnv("TEMP_AUDIO_PATH", "./temp"),
-		SessionStoragePath: getEnv("SESSION_STORAGE_PATH", "./data/sessions"),
-		SessionMaxHistory:  getEnvInt("SESSION_MAX_HISTORY", 50),
-		SessionExpiryHours: getEnvInt("SESSION_EXPIRY_HOURS", 72),
-		EnableSafeMode:     getEnvBool("ENABLE_SAFE_MODE", true),
-		MaxAudioSize:       getEnvInt64("MAX_AUDIO_SIZE", 10*1024*1024), // 10MB default
+		Port:                    getEnv("PORT", "8080"),
+		WSAllowedOrigins:        getEnvList("WS_ALLOWED_ORIGINS"),
+		ASRProvider:             getEnv("ASR_PROVIDER", "qiniu"),
+		TTSProvider:             getEnv("TTS_PROVIDER", "qiniu"),
+		LLMProvider:             getEnv("LLM_PROVIDER", "qiniu"),
+		QiniuAPIKey:             getEnv("QINIU_API_KEY", ""),
+		QiniuBaseURL:            getEnv("QINIU_BASE_URL", "https://openai.qiniu.com/v1"),
+		TTSVoiceType:            getEnv("TTS_VOICE_TYPE", "qiniu_zh_female_wwxkjx"),
+		TTSEncoding:             getEnv("TTS_ENCODING", "mp3"),
+		TTSSpeedRatio:           getEnvFloat("TTS_SPEED_RATIO", 1.0),
+		ASRModel:                getEnv("ASR_MODEL", "asr"),
+		ASRFormat:               getEnv("ASR_FORMAT", "wav"),
+		OpenAIAPIKey:            getEnv("OPENAI_API_KEY", ""),
+		OpenAIBaseURL:           getEnv("OPENAI_BASE_URL", "https://api.openai.com/v1"),
+		OpenAIASRModel:          getEnv("OPENAI_ASR_MODEL", "whisper-1"),
+		OpenAITTSModel:          getEnv("OPENAI_TTS_MODEL", "tts-1"),
+		OpenAITTSVoice:          getEnv("OPENAI_TTS_VOICE", "alloy"),
+		LLMModel:                getEnv("LLM_MODEL", "deepseek/deepseek-v3.1-terminus"),
+		LLMMaxTokens:            getEnvInt("LLM_MAX_TOKENS", 2000),
+		LLMContextWindow:        getEnvInt("LLM_CONTEXT_WINDOW", 32768),
+		LLMContextWindows:       getEnvIntMap("LLM_CONTEXT_WINDOWS"),
+		LLMTemperature:          getEnvFloat("LLM_TEMPERATURE", 0.7),
+		LLMToolCalling:          getEnvBool("LLM_TOOL_CALLING", true),
+		LLMJSONReprompt:         getEnvBool("LLM_JSON_REPROMPT", true),
+		StaticAudioPath:         getEnv("STATIC_AUDIO_PATH", "./static/audio"),
+		TempAudioPath:           getEnv("TEMP_AUDIO_PATH", "./temp"),
+		WorkflowGraphPath:       getEnv("WORKFLOW_GRAPH_PATH", ""),
+		PluginsDir:              getEnv("PLUGINS_DIR", "./plugins"),
+		WorkspaceRoot:           getEnv("WORKSPACE_ROOT", "./data/workspace"),
+		RemindersStorePath:      getEnv("REMINDERS_STORE_PATH", "./data/reminders.json"),
+		PushWebhookURL:          getEnv("PUSH_WEBHOOK_URL", ""),
+		CalendarPath:            getEnv("CALENDAR_PATH", "./data/calendar.ics"),
+		CalendarTimezone:        getEnv("CALENDAR_TIMEZONE", ""),
+		CalDAVURL:               getEnv("CALDAV_URL", ""),
+		CalDAVUsername:          getEnv("CALDAV_USERNAME", ""),
+		CalDAVPassword:          getEnv("CALDAV_PASSWORD", ""),
+		SessionStoragePath:      getEnv("SESSION_STORAGE_PATH", "./data/sessions"),
+		SessionMaxHistory:       getEnvInt("SESSION_MAX_HISTORY", 50),
+		SessionExpiryHours:      getEnvInt("SESSION_EXPIRY_HOURS", 72),
+		SessionStore:            getEnv("SESSION_STORE", "file"),
+		SessionDBPath:           getEnv("SESSION_DB_PATH", "./data/sessions.db"),
+		RedisURL:                getEnv("REDIS_URL", "redis://localhost:6379/0"),
+		SessionSummaryThreshold: getEnvInt("SESSION_SUMMARY_THRESHOLD", 30),
+		SessionSummaryKeep:      getEnvInt("SESSION_SUMMARY_KEEP", 10),
+		SessionSummaryMaxTokens: getEnvInt("SESSION_SUMMARY_MAX_TOKENS", 300),
+		MemoryEnabled:           getEnvBool("MEMORY_ENABLED", true),
+		MemoryStoragePath:       getEnv("MEMORY_STORAGE_PATH", "./data/memory"),
+		MemoryMaxFacts:          getEnvInt("MEMORY_MAX_FACTS", 100),
+		MemoryRecallLimit:       getEnvInt("MEMORY_RECALL_LIMIT", 5),
+		MemoryAPISecret:         getEnv("MEMORY_API_SECRET", ""),
+		TracesExporter:          getEnv("OTEL_TRACES_EXPORTER", "none"),
+		ServiceName:             getEnv("OTEL_SERVICE_NAME", "voicepilot-eino"),
+		TraceRetentionDays:      getEnvInt("TRACE_RETENTION_DAYS", 7),
+		EnableSafeMode:          getEnvBool("ENABLE_SAFE_MODE", true),
+		MaxAudioSize:            getEnvInt64("MAX_AUDIO_SIZE", 10*1024*1024), // 10MB default
+		SecurityPolicyPath:      getEnv("SECURITY_POLICY_PATH", ""),
+
+		// Command sandbox
+		SandboxMode:           getEnv("SANDBOX_MODE", "auto"),
+		SandboxWorkDir:        getEnv("SANDBOX_WORKDIR", "./data/sandbox"),
+		SandboxTimeoutSeconds: getEnvInt("SANDBOX_TIMEOUT_SECONDS", 10),
+		SandboxMaxOutputBytes: getEnvInt("SANDBOX_MAX_OUTPUT_BYTES", 64*1024),
+		SandboxMemoryMB:       getEnvInt("SANDBOX_MEMORY_MB", 256),
+
+		// Action timeouts and retries
+		ActionTimeoutSeconds: getEnvInt("ACTION_TIMEOUT_SECONDS", 120),
+		ActionMaxRetries:     getEnvInt("ACTION_MAX_RETRIES", 0),
+		ActionRetryBackoffMs: getEnvInt("ACTION_RETRY_BACKOFF_MS", 500),
+		ActionPolicyPath:     getEnv("ACTION_POLICY_PATH", ""),
 	}
 
 	// Validate required configuration
-	if AppConfig.QiniuAPIKey == "" {
+	if AppConfig.usesProvider("qiniu") && AppConfig.QiniuAPIKey == "" {
 		return fmt.Errorf("QINIU_API_KEY is required")
 	}
 
@@ -86,10 +207,26 @@ func Load() error {
 	if err := os.MkdirAll(AppConfig.SessionStoragePath, 0755); err != nil {
 		return fmt.Errorf("failed to create session storage directory: %w", err)
 	}
+	if err := os.MkdirAll(AppConfig.WorkspaceRoot, 0755); err != nil {
+		return fmt.Errorf("failed to create workspace directory: %w", err)
+	}
 
 	return nil
 }
 
+// usesProvider reports whether any of the ASR, TTS or LLM providers is the given one
+func (c *Config) usesProvider(name string) bool {
+	return c.ASRProvider == name || c.TTSProvider == name || c.LLMProvider == name
+}
+
+// ContextWindow returns the tokens the given model accepts, prompt and completion together
+func (c *Config) ContextWindow(model string) int {
+	if window, ok := c.LLMContextWindows[model]; ok {
+		return window
+	}
+	return c.LLMContextWindow
+}
+
 // Helper functions for getting environment variables with defaults
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -125,6 +262,32 @@ func getEnvFloat(key string, defaultValue float64) float64 {
 	return defaultValue
 }
 
+// getEnvIntMap parses a comma-separated list of name=number pairs, skipping malformed entries
+func getEnvIntMap(key string) map[string]int {
+	values := make(map[string]int)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if intVal, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			values[strings.TrimSpace(name)] = intVal
+		}
+	}
+	return values
+}
+
+// getEnvList parses a comma-separated list, skipping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolVal, err := strconv.ParseBool(value); err == nil {
@@ -141,3 +141,44 @@ func TestConfigDefaults(t *testing.T) {
 		t.Error("Expected safe mode to be enabled by default")
 	}
 }
+
+func TestLoadWithoutQiniuProvider(t *testing.T) {
+	os.Unsetenv("QINIU_API_KEY")
+	os.Setenv("ASR_PROVIDER", "openai")
+	os.Setenv("TTS_PROVIDER", "openai")
+	os.Setenv("LLM_PROVIDER", "openai")
+	defer func() {
+		os.Unsetenv("ASR_PROVIDER")
+		os.Unsetenv("TTS_PROVIDER")
+		os.Unsetenv("LLM_PROVIDER")
+	}()
+
+	if err := Load(); err != nil {
+		t.Fatalf("Expected no error when Qiniu is not used, got: %v", err)
+	}
+
+	if AppConfig.LLMProvider != "openai" {
+		t.Errorf("Expected LLMProvider 'openai', got: %s", AppConfig.LLMProvider)
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	os.Setenv("TEST_WINDOWS", "deepseek-v3=128000, qwen-turbo = 8192,broken,bad=x")
+	defer os.Unsetenv("TEST_WINDOWS")
+
+	c := &Config{LLMContextWindow: 32768, LLMContextWindows: getEnvIntMap("TEST_WINDOWS")}
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{"deepseek-v3", 128000},
+		{"qwen-turbo", 8192},
+		{"bad", 32768},
+		{"unknown-model", 32768},
+	}
+	for _, tt := range tests {
+		if got := c.ContextWindow(tt.model); got != tt.want {
+			t.Errorf("ContextWindow(%q) = %d, want %d", tt.model, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package context
+
+import "github.com/deca/voicepilot-eino/internal/tokens"
+
+// ContextUsage reports how BuildLLMContextWithin spent its token budget
+type ContextUsage struct {
+	Budget          int `json:"budget"`
+	SummaryTokens   int `json:"summary_tokens"`
+	HistoryTokens   int `json:"history_tokens"`
+	Messages        int `json:"messages"`         // history messages included
+	OmittedMessages int `json:"omitted_messages"` // older history messages that did not fit
+}
+
+// Used returns the tokens taken by the summary and the history
+func (u ContextUsage) Used() int {
+	return u.SummaryTokens + u.HistoryTokens
+}
+
+// BuildLLMContextWithin builds context messages for LLM that fit in budget tokens
+//
+// The summary of earlier turns comes first, cut to the summary token budget
+// and to half of budget. History is then added newest first until the next
+// message no longer fits, so a long answer doesn't push out everything
+// before it while short exchanges are kept in full. The summary and the
+// history are read together, so a summary being written at the same time
+// neither repeats nor loses the turns it covers.
+func (cm *ContextManager) BuildLLMContextWithin(sessionID string, budget int) ([]map[string]string, ContextUsage) {
+	usage := ContextUsage{Budget: budget}
+	var history []Message
+	var summary string
+	if session := cm.loadSession(sessionID); session != nil {
+		history, summary = session.Messages, session.Summary
+	}
+	if budget <= 0 {
+		usage.OmittedMessages = len(history)
+		return []map[string]string{}, usage
+	}
+
+	var llmMessages []map[string]string
+	if summary != "" {
+		_, opts := cm.summarySettings()
+		limit := opts.MaxTokens
+		if half := budget/2 - tokens.EstimateMessage(summaryPrefix); limit <= 0 || limit > half {
+			limit = half
+		}
+		if limit > 0 {
+			msg := summaryMessage(summary, limit)
+			llmMessages = append(llmMessages, msg)
+			usage.SummaryTokens = tokens.EstimateMessage(msg["content"])
+		}
+	}
+
+	remaining := budget - usage.SummaryTokens
+	start := len(history)
+	for start > 0 {
+		cost := tokens.EstimateMessage(history[start-1].Content)
+		if cost > remaining {
+			break
+		}
+		remaining -= cost
+		usage.HistoryTokens += cost
+		start--
+	}
+	usage.Messages = len(history) - start
+	usage.OmittedMessages = start
+
+	for _, msg := range history[start:] {
+		llmMessages = append(llmMessages, map[string]string{
+			"role":    msg.Role,
+			"content": msg.Content,
+		})
+	}
+	if llmMessages == nil {
+		llmMessages = []map[string]string{}
+	}
+	return llmMessages, usage
+}
+
+// summaryPrefix introduces the summary of earlier turns to the model
+const summaryPrefix = "此前对话的摘要："
+
+// summaryMessage is the system message carrying the summary of earlier turns, the summary cut to about maxTokens
+func summaryMessage(summary string, maxTokens int) map[string]string {
+	return map[string]string{
+		"role":    "system",
+		"content": summaryPrefix + tokens.Truncate(summary, maxTokens),
+	}
+}
@@ -0,0 +1,89 @@
+package context
+
+import (
+	stdcontext "context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/tokens"
+)
+
+func TestBuildLLMContextWithin(t *testing.T) {
+	cm := NewContextManagerWithStore(NewMemoryStore(), 50, time.Hour)
+	cm.AddInteraction("s1", "写一篇文章", "generate_text", strings.Repeat("很长的文章", 200)) // 1000 tokens
+	cm.AddInteraction("s1", "几点了", "unknown", "下午三点")
+	cm.AddInteraction("s1", "谢谢", "unknown", "不客气")
+	// The last four messages cost 3+4, 4+4, 2+4 and 3+4 tokens
+	short := 3 + 4 + 4 + 4 + 2 + 4 + 3 + 4
+
+	tests := []struct {
+		name   string
+		budget int
+		want   int // messages included, newest
+	}{
+		{"everything", 2000, 6},
+		{"stops at the long answer", 500, 4},
+		{"exactly the short turns", short, 4},
+		{"one token short", short - 1, 3},
+		{"no room", 0, 0},
+	}
+	for _, tt := range tests {
+		messages, usage := cm.BuildLLMContextWithin("s1", tt.budget)
+		if len(messages) != tt.want || usage.Messages != tt.want || usage.OmittedMessages != 6-tt.want {
+			t.Errorf("%s: got %d messages, usage %+v, want %d", tt.name, len(messages), usage, tt.want)
+			continue
+		}
+		if usage.Used() > tt.budget {
+			t.Errorf("%s: used %d tokens of %d", tt.name, usage.Used(), tt.budget)
+		}
+		if tt.want > 0 && messages[len(messages)-1]["content"] != "不客气" {
+			t.Errorf("%s: last message = %q, want the newest", tt.name, messages[len(messages)-1]["content"])
+		}
+	}
+}
+
+func TestBuildLLMContextWithinSummary(t *testing.T) {
+	cm := NewContextManagerWithStore(NewMemoryStore(), 50, time.Hour)
+	cm.EnableSummaries((&recordingSummarizer{}).summarize, SummaryOptions{Threshold: 2, Keep: 2, MaxTokens: 300})
+	cm.AddInteraction("s1", strings.Repeat("我叫小李", 50), "unknown", "好的")
+	cm.AddInteraction("s1", "几点了", "unknown", "下午三点")
+	cm.Summarize(stdcontext.Background(), "s1")
+
+	messages, usage := cm.BuildLLMContextWithin("s1", 100)
+	if len(messages) != 3 || messages[0]["role"] != "system" || !strings.HasPrefix(messages[0]["content"], "此前对话的摘要：我叫小李") {
+		t.Fatalf("BuildLLMContextWithin() = %v, want the summary and two messages", messages)
+	}
+	// The summary takes at most half of the budget
+	if usage.SummaryTokens > 50 || usage.SummaryTokens != tokens.EstimateMessage(messages[0]["content"]) {
+		t.Errorf("SummaryTokens = %d, want the cost of the cut summary within 50", usage.SummaryTokens)
+	}
+	if usage.Used() > 100 {
+		t.Errorf("Used %d tokens of 100", usage.Used())
+	}
+}
+
+func TestBuildLLMContextWithinDuringSummary(t *testing.T) {
+	cm := NewContextManagerWithStore(NewMemoryStore(), 50, time.Hour)
+	cm.EnableSummaries((&recordingSummarizer{}).summarize, SummaryOptions{Threshold: 2, Keep: 2, MaxTokens: 300})
+
+	for round := 0; round < 50; round++ {
+		cm.AddInteraction("s1", "我叫小李", "unknown", "好的")
+		cm.AddInteraction("s1", "几点了", "unknown", "下午三点")
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			cm.Summarize(stdcontext.Background(), "s1")
+		}()
+		// Either the turns before summarizing or the summary with the kept turns, never a mix
+		messages, _ := cm.BuildLLMContextWithin("s1", 1000)
+		<-done
+
+		summarized := len(messages) > 0 && messages[0]["role"] == "system"
+		if summarized && len(messages) != 3 || !summarized && len(messages) != 4 {
+			t.Fatalf("Round %d: BuildLLMContextWithin() = %v", round, messages)
+		}
+		cm.ClearSession("s1")
+	}
+}
@@ -1,10 +1,9 @@
 package context
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"math/rand/v2"
 	"sync"
 	"time"
 )
@@ -19,71 +18,86 @@ type Message struct {
 
 // Session represents a conversation session
 type Session struct {
-	ID        string    `json:"id"`
-	Messages  []Message `json:"messages"`
+	ID        string                 `json:"id"`
+	UserID    string                 `json:"user_id,omitempty"` // user the session belongs to, if the client said so
+	Messages  []Message              `json:"messages"`
+	Summary   string                 `json:"summary,omitempty"` // condensed turns that were dropped from Messages
 	Context   map[string]interface{} `json:"context,omitempty"` // Additional context data
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+	Version   int64                  `json:"version,omitempty"` // bumped by stores that detect concurrent updates
 }
 
 // ContextManager manages conversation context and sessions
+//
+// The store is the source of truth: sessions are reloaded from it before
+// they are read or changed, so several server instances sharing a store see
+// each other's messages. sessions caches the last copy of each session.
+//
+// Changes to a session are serialized per session within this instance;
+// stores shared between instances detect concurrent saves themselves.
 type ContextManager struct {
+	mu            sync.RWMutex // guards sessions, locks, endHooks and the summary settings, never held across store calls
 	sessions      map[string]*Session
-	mu            sync.RWMutex
-	storagePath   string
+	locks         map[string]*sessionLock
+	store         SessionStore
+	storagePath   string        // directory of the file store, empty for other stores
 	maxHistory    int           // Maximum number of messages to keep per session
 	sessionExpiry time.Duration // Session expiration time
+
+	summarizer     Summarizer // nil unless EnableSummaries was called
+	summaryOptions SummaryOptions
+
+	endHooks []func(sessionID string) // registered with OnSessionEnd
 }
 
-// NewContextManager creates a new context manager
+// NewContextManager creates a new context manager that keeps sessions as JSON files in storagePath
 func NewContextManager(storagePath string, maxHistory int, sessionExpiry time.Duration) *ContextManager {
-	cm := &ContextManager{
+	store, err := NewFileStore(storagePath)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		store = &FileStore{dir: storagePath}
+	}
+
+	cm := NewContextManagerWithStore(store, maxHistory, sessionExpiry)
+	cm.storagePath = storagePath
+	return cm
+}
+
+// NewContextManagerWithStore creates a new context manager backed by store
+func NewContextManagerWithStore(store SessionStore, maxHistory int, sessionExpiry time.Duration) *ContextManager {
+	return &ContextManager{
 		sessions:      make(map[string]*Session),
-		storagePath:   storagePath,
+		locks:         make(map[string]*sessionLock),
+		store:         store,
 		maxHistory:    maxHistory,
 		sessionExpiry: sessionExpiry,
 	}
+}
 
-	// Ensure storage directory exists
-	if err := os.MkdirAll(storagePath, 0755); err != nil {
-		fmt.Printf("Warning: failed to create storage directory: %v\n", err)
-	}
-
-	return cm
+// Store returns the store the sessions are kept in
+func (cm *ContextManager) Store() SessionStore {
+	return cm.store
 }
 
 // GetSession retrieves a session by ID, creates new one if not exists
 func (cm *ContextManager) GetSession(sessionID string) *Session {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
+	return cm.getOrCreateSession(sessionID)
+}
 
-	session, exists := cm.sessions[sessionID]
-	if !exists {
-		// Try to load from storage
-		session = cm.loadSessionFromStorage(sessionID)
-		if session == nil {
-			// Create new session
-			session = &Session{
-				ID:        sessionID,
-				Messages:  []Message{},
-				Context:   make(map[string]interface{}),
-				CreatedAt: time.Now(),
-				UpdatedAt: time.Now(),
-			}
+// SetUserID records the user a session belongs to
+func (cm *ContextManager) SetUserID(sessionID, userID string) error {
+	return cm.update(sessionID, func(session *Session) bool {
+		if session.UserID == userID {
+			return false
 		}
-		cm.sessions[sessionID] = session
-	}
-
-	return session
+		session.UserID = userID
+		return true
+	})
 }
 
 // AddUserMessage adds a user message to the session
 func (cm *ContextManager) AddUserMessage(sessionID, content string, intent string) error {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
-
-	session := cm.getOrCreateSession(sessionID)
-
 	message := Message{
 		Role:      "user",
 		Content:   content,
@@ -91,51 +105,37 @@ func (cm *ContextManager) AddUserMessage(sessionID, content string, intent strin
 		Intent:    intent,
 	}
 
-	session.Messages = append(session.Messages, message)
-	session.UpdatedAt = time.Now()
-
-	// Trim history if exceeds max
-	cm.trimSessionHistory(session)
+	return cm.update(sessionID, func(session *Session) bool {
+		session.Messages = append(session.Messages, message)
 
-	// Persist to storage
-	return cm.saveSessionToStorage(session)
+		// Trim history if exceeds max
+		cm.trimSessionHistory(session)
+		return true
+	})
 }
 
 // AddAssistantMessage adds an assistant message to the session
 func (cm *ContextManager) AddAssistantMessage(sessionID, content string) error {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
-
-	session := cm.getOrCreateSession(sessionID)
-
 	message := Message{
 		Role:      "assistant",
 		Content:   content,
 		Timestamp: time.Now(),
 	}
 
-	session.Messages = append(session.Messages, message)
-	session.UpdatedAt = time.Now()
+	return cm.update(sessionID, func(session *Session) bool {
+		session.Messages = append(session.Messages, message)
 
-	// Trim history if exceeds max
-	cm.trimSessionHistory(session)
-
-	// Persist to storage
-	return cm.saveSessionToStorage(session)
+		// Trim history if exceeds max
+		cm.trimSessionHistory(session)
+		return true
+	})
 }
 
 // GetHistory retrieves conversation history for a session
 func (cm *ContextManager) GetHistory(sessionID string, limit int) []Message {
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
-
-	session, exists := cm.sessions[sessionID]
-	if !exists {
-		// Try to load from storage
-		session = cm.loadSessionFromStorage(sessionID)
-		if session == nil {
-			return []Message{}
-		}
+	session := cm.loadSession(sessionID)
+	if session == nil {
+		return []Message{}
 	}
 
 	messages := session.Messages
@@ -146,13 +146,19 @@ func (cm *ContextManager) GetHistory(sessionID string, limit int) []Message {
 	return messages
 }
 
+// GetSummary returns the summary of the turns no longer in the history, empty if there is none
+func (cm *ContextManager) GetSummary(sessionID string) string {
+	session := cm.loadSession(sessionID)
+	if session == nil {
+		return ""
+	}
+	return session.Summary
+}
+
 // GetContextData retrieves custom context data for a session
 func (cm *ContextManager) GetContextData(sessionID string, key string) (interface{}, bool) {
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
-
-	session, exists := cm.sessions[sessionID]
-	if !exists {
+	session := cm.loadSession(sessionID)
+	if session == nil {
 		return nil, false
 	}
 
@@ -162,88 +168,130 @@ func (cm *ContextManager) GetContextData(sessionID string, key string) (interfac
 
 // SetContextData sets custom context data for a session
 func (cm *ContextManager) SetContextData(sessionID string, key string, value interface{}) error {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
+	return cm.update(sessionID, func(session *Session) bool {
+		session.Context[key] = value
+		return true
+	})
+}
 
-	session := cm.getOrCreateSession(sessionID)
-	session.Context[key] = value
-	session.UpdatedAt = time.Now()
+// DeleteContextData removes custom context data from a session
+func (cm *ContextManager) DeleteContextData(sessionID string, key string) error {
+	return cm.update(sessionID, func(session *Session) bool {
+		if _, exists := session.Context[key]; !exists {
+			return false
+		}
+		delete(session.Context, key)
+		return true
+	})
+}
 
-	return cm.saveSessionToStorage(session)
+// TakeContextData removes custom context data from a session and returns what was removed
+//
+// Of several callers taking the same key at once, only one gets the value.
+// When err is not nil the value may still be in the session.
+func (cm *ContextManager) TakeContextData(sessionID string, key string) (value interface{}, exists bool, err error) {
+	err = cm.update(sessionID, func(session *Session) bool {
+		value, exists = session.Context[key]
+		if !exists {
+			return false
+		}
+		delete(session.Context, key)
+		return true
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return value, exists, nil
 }
 
-// ClearSession clears a specific session
-func (cm *ContextManager) ClearSession(sessionID string) error {
+// OnSessionEnd registers fn to be called with the ID of every session that is cleared or has expired
+//
+// It lets state kept elsewhere for a session go away together with it. fn
+// is called without the context manager locked, possibly more than once for
+// the same session.
+func (cm *ContextManager) OnSessionEnd(fn func(sessionID string)) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
+	cm.endHooks = append(cm.endHooks, fn)
+}
+
+// endSession drops the cached copy of a session and tells the OnSessionEnd hooks
+func (cm *ContextManager) endSession(sessionID string) {
+	cm.mu.Lock()
 	delete(cm.sessions, sessionID)
+	hooks := cm.endHooks
+	cm.mu.Unlock()
 
-	// Remove from storage
-	sessionPath := filepath.Join(cm.storagePath, fmt.Sprintf("%s.json", sessionID))
-	if err := os.Remove(sessionPath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove session file: %w", err)
+	for _, fn := range hooks {
+		fn(sessionID)
 	}
+}
 
-	return nil
+// ClearSession clears a specific session
+func (cm *ContextManager) ClearSession(sessionID string) error {
+	unlock := cm.lockSession(sessionID)
+	defer unlock()
+
+	cm.endSession(sessionID)
+
+	// Remove from storage
+	return cm.store.Delete(sessionID)
 }
 
 // ClearAllSessions clears all sessions
 func (cm *ContextManager) ClearAllSessions() error {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
-
-	cm.sessions = make(map[string]*Session)
-
-	// Clear storage directory
-	files, err := filepath.Glob(filepath.Join(cm.storagePath, "*.json"))
+	ids, err := cm.store.List()
 	if err != nil {
-		return fmt.Errorf("failed to list session files: %w", err)
+		fmt.Printf("Warning: failed to list sessions: %v\n", err)
 	}
-
-	for _, file := range files {
-		if err := os.Remove(file); err != nil {
-			fmt.Printf("Warning: failed to remove session file %s: %v\n", file, err)
-		}
+	ids = append(ids, cm.cachedSessions(time.Time{})...)
+	for _, id := range ids {
+		cm.endSession(id)
 	}
 
-	return nil
+	// Clear storage
+	return cm.store.DeleteAll()
 }
 
 // CleanupExpiredSessions removes sessions that haven't been updated within the expiry duration
+//
+// All stored sessions are considered, not only those this instance has loaded.
+// When the store expires sessions itself, only the cached copies of sessions
+// that are gone from the store are dropped.
 func (cm *ContextManager) CleanupExpiredSessions() error {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
-
-	now := time.Now()
-	expiredSessions := []string{}
-
-	for id, session := range cm.sessions {
-		if now.Sub(session.UpdatedAt) > cm.sessionExpiry {
-			expiredSessions = append(expiredSessions, id)
+	cutoff := time.Now().Add(-cm.sessionExpiry)
+	if cm.ExpiresSessions() {
+		for _, id := range cm.cachedSessions(cutoff) {
+			cm.loadSession(id) // ends the session if the store has dropped it
 		}
+		return nil
 	}
 
-	// Remove expired sessions
+	expiredSessions, err := cm.store.DeleteExpired(cutoff)
+
 	for _, id := range expiredSessions {
-		delete(cm.sessions, id)
-		sessionPath := filepath.Join(cm.storagePath, fmt.Sprintf("%s.json", id))
-		if err := os.Remove(sessionPath); err != nil && !os.IsNotExist(err) {
-			fmt.Printf("Warning: failed to remove expired session file %s: %v\n", sessionPath, err)
-		}
+		cm.endSession(id)
+	}
+	// Cached sessions may have been deleted from the store by another instance
+	for _, id := range cm.cachedSessions(cutoff) {
+		cm.endSession(id)
 	}
 
 	fmt.Printf("Cleaned up %d expired sessions\n", len(expiredSessions))
-	return nil
+	return err
+}
+
+// ExpiresSessions reports whether the store drops idle sessions itself, leaving CleanupExpiredSessions only the cache
+func (cm *ContextManager) ExpiresSessions() bool {
+	store, ok := cm.store.(ExpiringStore)
+	return ok && store.ExpiresSessions()
 }
 
 // GetSessionSummary generates a summary of the conversation for context
 func (cm *ContextManager) GetSessionSummary(sessionID string) string {
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
-
-	session, exists := cm.sessions[sessionID]
-	if !exists || len(session.Messages) == 0 {
+	session := cm.loadSession(sessionID)
+	if session == nil || len(session.Messages) == 0 {
 		return ""
 	}
 
@@ -262,9 +310,19 @@ func (cm *ContextManager) GetSessionSummary(sessionID string) string {
 }
 
 // BuildLLMContext builds context messages for LLM from session history
+//
+// The summary of earlier turns, if any, comes first as a system message cut
+// to the summary token budget.
 func (cm *ContextManager) BuildLLMContext(sessionID string, maxMessages int) []map[string]string {
 	messages := cm.GetHistory(sessionID, maxMessages)
-	llmMessages := make([]map[string]string, 0, len(messages))
+	llmMessages := make([]map[string]string, 0, len(messages)+1)
+
+	if summary := cm.GetSummary(sessionID); summary != "" {
+		cm.mu.RLock()
+		budget := cm.summaryOptions.MaxTokens
+		cm.mu.RUnlock()
+		llmMessages = append(llmMessages, summaryMessage(summary, budget))
+	}
 
 	for _, msg := range messages {
 		llmMessages = append(llmMessages, map[string]string{
@@ -278,9 +336,85 @@ func (cm *ContextManager) BuildLLMContext(sessionID string, maxMessages int) []m
 
 // Internal helper methods
 
+// cachedSessions returns the IDs of the cached sessions last updated before cutoff, all of them for a zero cutoff
+func (cm *ContextManager) cachedSessions(cutoff time.Time) []string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	var ids []string
+	for id, session := range cm.sessions {
+		if cutoff.IsZero() || session.UpdatedAt.Before(cutoff) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// sessionLock serializes the changes to one session made by this instance
+type sessionLock struct {
+	mu   sync.Mutex
+	refs int // holders and waiters; the lock is dropped when none are left
+}
+
+// lockSession locks a session against other changes from this instance and returns the function unlocking it
+func (cm *ContextManager) lockSession(sessionID string) (unlock func()) {
+	cm.mu.Lock()
+	lock, ok := cm.locks[sessionID]
+	if !ok {
+		lock = &sessionLock{}
+		cm.locks[sessionID] = lock
+	}
+	lock.refs++
+	cm.mu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+
+		cm.mu.Lock()
+		if lock.refs--; lock.refs == 0 {
+			delete(cm.locks, sessionID)
+		}
+		cm.mu.Unlock()
+	}
+}
+
+// loadSession returns the stored session, refreshing the cached copy, or nil if there is none
+//
+// If the store can't be read a copy of the cached session is used. The
+// caller owns the returned session and may change it.
+func (cm *ContextManager) loadSession(sessionID string) *Session {
+	session, err := cm.store.Load(sessionID)
+	switch {
+	case err == nil:
+		if session.Context == nil {
+			session.Context = make(map[string]interface{})
+		}
+		cm.cacheSession(session)
+		return session
+	case errors.Is(err, ErrSessionNotFound):
+		// Cleared or expired, possibly by another instance
+		cm.mu.RLock()
+		_, cached := cm.sessions[sessionID]
+		cm.mu.RUnlock()
+		if cached {
+			cm.endSession(sessionID)
+		}
+		return nil
+	default:
+		fmt.Printf("Warning: failed to load session %s: %v\n", sessionID, err)
+		cm.mu.RLock()
+		defer cm.mu.RUnlock()
+		if cached, ok := cm.sessions[sessionID]; ok {
+			return cached.clone()
+		}
+		return nil
+	}
+}
+
 func (cm *ContextManager) getOrCreateSession(sessionID string) *Session {
-	session, exists := cm.sessions[sessionID]
-	if !exists {
+	session := cm.loadSession(sessionID)
+	if session == nil {
 		session = &Session{
 			ID:        sessionID,
 			Messages:  []Message{},
@@ -288,11 +422,30 @@ func (cm *ContextManager) getOrCreateSession(sessionID string) *Session {
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		}
-		cm.sessions[sessionID] = session
+		cm.cacheSession(session)
 	}
 	return session
 }
 
+// cacheSession keeps a copy of session as the last known state of it
+func (cm *ContextManager) cacheSession(session *Session) {
+	cached := session.clone()
+	cm.mu.Lock()
+	cm.sessions[session.ID] = cached
+	cm.mu.Unlock()
+}
+
+// clone copies a session so the copy can be changed while the original is read
+func (s *Session) clone() *Session {
+	c := *s
+	c.Messages = append(make([]Message, 0, len(s.Messages)), s.Messages...)
+	c.Context = make(map[string]interface{}, len(s.Context))
+	for key, value := range s.Context {
+		c.Context[key] = value
+	}
+	return &c
+}
+
 func (cm *ContextManager) trimSessionHistory(session *Session) {
 	if cm.maxHistory > 0 && len(session.Messages) > cm.maxHistory {
 		// Keep only the most recent messages
@@ -301,38 +454,54 @@ func (cm *ContextManager) trimSessionHistory(session *Session) {
 }
 
 func (cm *ContextManager) saveSessionToStorage(session *Session) error {
-	sessionPath := filepath.Join(cm.storagePath, fmt.Sprintf("%s.json", session.ID))
-
-	data, err := json.MarshalIndent(session, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal session: %w", err)
-	}
+	return cm.store.Save(session)
+}
 
-	if err := os.WriteFile(sessionPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write session file: %w", err)
+// maxUpdateAttempts bounds how often an update is retried while other instances keep changing the session
+const maxUpdateAttempts = 10
+
+// updateBackoff is the longest random pause before retrying a conflicting update, so
+// instances racing for a session don't retry in lockstep
+const updateBackoff = 20 * time.Millisecond
+
+// update applies change to the stored session, creating it if needed, and saves it
+//
+// change returns false to leave the session as it is. If the store reports
+// that another instance saved the session in the meantime, the session is
+// reloaded and change applied again. The session is locked while it is
+// loaded, changed and saved, but not while waiting to retry.
+func (cm *ContextManager) update(sessionID string, change func(session *Session) bool) error {
+	var err error
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(rand.N(updateBackoff))
+		}
+		var changed bool
+		changed, err = cm.tryUpdate(sessionID, change)
+		if !changed || !errors.Is(err, ErrSessionConflict) {
+			return err
+		}
 	}
-
-	return nil
+	return err
 }
 
-func (cm *ContextManager) loadSessionFromStorage(sessionID string) *Session {
-	sessionPath := filepath.Join(cm.storagePath, fmt.Sprintf("%s.json", sessionID))
+// tryUpdate makes one attempt of update, reporting whether change changed the session
+func (cm *ContextManager) tryUpdate(sessionID string, change func(session *Session) bool) (bool, error) {
+	unlock := cm.lockSession(sessionID)
+	defer unlock()
 
-	data, err := os.ReadFile(sessionPath)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			fmt.Printf("Warning: failed to read session file: %v\n", err)
-		}
-		return nil
+	session := cm.getOrCreateSession(sessionID)
+	if !change(session) {
+		return false, nil
 	}
+	session.UpdatedAt = time.Now()
 
-	var session Session
-	if err := json.Unmarshal(data, &session); err != nil {
-		fmt.Printf("Warning: failed to unmarshal session: %v\n", err)
-		return nil
+	// Persist to storage
+	if err := cm.saveSessionToStorage(session); err != nil {
+		return true, err
 	}
-
-	return &session
+	cm.cacheSession(session)
+	return true, nil
 }
 
 // AddInteraction is a convenience method to add both user and assistant messages
@@ -350,21 +519,18 @@ func (cm *ContextManager) AddInteraction(sessionID string, userInput string, int
 
 // GetSessionInfo returns basic information about a session
 func (cm *ContextManager) GetSessionInfo(sessionID string) map[string]interface{} {
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
-
-	session, exists := cm.sessions[sessionID]
-	if !exists {
+	session := cm.loadSession(sessionID)
+	if session == nil {
 		return map[string]interface{}{
 			"exists": false,
 		}
 	}
 
 	return map[string]interface{}{
-		"exists":       true,
-		"id":           session.ID,
+		"exists":        true,
+		"id":            session.ID,
 		"message_count": len(session.Messages),
-		"created_at":   session.CreatedAt,
-		"updated_at":   session.UpdatedAt,
+		"created_at":    session.CreatedAt,
+		"updated_at":    session.UpdatedAt,
 	}
 }
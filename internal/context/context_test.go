@@ -166,6 +166,23 @@ func TestContextData(t *testing.T) {
 	if exists {
 		t.Error("Expected non-existent key to return false")
 	}
+
+	// Delete context data
+	if err := cm.DeleteContextData(sessionID, key); err != nil {
+		t.Fatalf("DeleteContextData failed: %v", err)
+	}
+	if _, exists := cm.GetContextData(sessionID, key); exists {
+		t.Error("Expected deleted key to return false")
+	}
+
+	// Take context data
+	cm.SetContextData(sessionID, key, value)
+	if taken, exists, err := cm.TakeContextData(sessionID, key); err != nil || !exists || taken != value {
+		t.Errorf("TakeContextData() = %v, %v, %v, want %s", taken, exists, err, value)
+	}
+	if _, exists, err := cm.TakeContextData(sessionID, key); err != nil || exists {
+		t.Errorf("TakeContextData() of a taken key = %v, %v, want nothing", exists, err)
+	}
 }
 
 func TestClearSession(t *testing.T) {
@@ -427,3 +444,27 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestOnSessionEnd(t *testing.T) {
+	cm := NewContextManager(t.TempDir(), 100, 100*time.Millisecond)
+	var ended []string
+	cm.OnSessionEnd(func(sessionID string) { ended = append(ended, sessionID) })
+
+	cm.AddUserMessage("cleared", "你好", "greeting")
+	cm.ClearSession("cleared")
+
+	cm.AddUserMessage("expired", "你好", "greeting")
+	time.Sleep(200 * time.Millisecond)
+	cm.AddUserMessage("active", "你好", "greeting")
+	cm.CleanupExpiredSessions()
+
+	cm.ClearAllSessions()
+
+	got := map[string]int{}
+	for _, id := range ended {
+		got[id]++
+	}
+	if got["cleared"] != 1 || got["expired"] != 1 || got["active"] == 0 {
+		t.Errorf("Ended sessions = %v, want cleared, expired and active", ended)
+	}
+}
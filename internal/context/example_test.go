@@ -13,6 +13,7 @@ func Example_basicUsage() {
 	cm := ctx.NewContextManager("./temp/sessions", 100, 24*time.Hour)
 
 	sessionID := "example-session-1"
+	cm.ClearSession(sessionID) // sessions persist across runs
 
 	// Add user message
 	cm.AddUserMessage(sessionID, "播放音乐", "play_music")
@@ -55,6 +56,7 @@ func Example_contextData() {
 func Example_llmIntegration() {
 	cm := ctx.NewContextManager("./temp/sessions", 100, 24*time.Hour)
 	sessionID := "example-session-3"
+	cm.ClearSession(sessionID)
 
 	// Simulate a multi-turn conversation
 	cm.AddInteraction(sessionID, "你好", "greeting", "你好！有什么可以帮助你的吗？")
@@ -80,6 +82,7 @@ func Example_sessionManagement() {
 	cm := ctx.NewContextManager("./temp/sessions", 100, 24*time.Hour)
 
 	// Create multiple sessions
+	cm.ClearAllSessions()
 	cm.AddUserMessage("session-1", "Message 1", "test")
 	cm.AddUserMessage("session-2", "Message 2", "test")
 
@@ -0,0 +1,143 @@
+package context
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileStore keeps each session in a <id>.json file of a directory
+//
+// Files are replaced atomically, so a reader, possibly in another process,
+// never sees a partly written session.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a store in dir, creating the directory if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s.json", id))
+}
+
+// Load reads a session file
+func (s *FileStore) Load(id string) (*Session, error) {
+	data, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session file: %w", err)
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+	}
+	return &session, nil
+}
+
+// Save writes the session to a temporary file and renames it over the session file
+func (s *FileStore) Save(session *Session) error {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "."+session.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(session.ID)); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a session file
+func (s *FileStore) Delete(id string) error {
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove session file: %w", err)
+	}
+	return nil
+}
+
+// DeleteAll removes every session file
+func (s *FileStore) DeleteAll() error {
+	ids, err := s.List()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.Delete(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteExpired removes the session files last updated before cutoff, including sessions never loaded by this process
+func (s *FileStore) DeleteExpired(cutoff time.Time) ([]string, error) {
+	ids, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []string
+	for _, id := range ids {
+		session, err := s.Load(id)
+		if err != nil {
+			fmt.Printf("Warning: skipping session %s during cleanup: %v\n", id, err)
+			continue
+		}
+		if !session.UpdatedAt.Before(cutoff) {
+			continue
+		}
+		if err := s.Delete(id); err != nil {
+			return expired, err
+		}
+		expired = append(expired, id)
+	}
+	return expired, nil
+}
+
+// List returns the IDs of the session files
+func (s *FileStore) List() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session files: %w", err)
+	}
+
+	ids := make([]string, 0, len(files))
+	for _, file := range files {
+		ids = append(ids, strings.TrimSuffix(filepath.Base(file), ".json"))
+	}
+	return ids, nil
+}
+
+// Close does nothing; files are not kept open
+func (s *FileStore) Close() error {
+	return nil
+}
@@ -0,0 +1,122 @@
+package context
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps sessions in memory only, for tests and single-process setups that need no persistence
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string][]byte // JSON, so callers never share a session with the store
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string][]byte)}
+}
+
+// Load returns a copy of a stored session
+func (s *MemoryStore) Load(id string) (*Session, error) {
+	s.mu.RLock()
+	data, ok := s.sessions[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Save stores a copy of the session
+func (s *MemoryStore) Save(session *Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.sessions[session.ID] = data
+	s.mu.Unlock()
+	return nil
+}
+
+// Delete removes a session
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// DeleteAll removes every session
+func (s *MemoryStore) DeleteAll() error {
+	s.mu.Lock()
+	s.sessions = make(map[string][]byte)
+	s.mu.Unlock()
+	return nil
+}
+
+// DeleteExpired removes the sessions last updated before cutoff
+func (s *MemoryStore) DeleteExpired(cutoff time.Time) ([]string, error) {
+	ids, _ := s.List()
+
+	var expired []string
+	for _, id := range ids {
+		session, err := s.Load(id)
+		if err != nil || !session.UpdatedAt.Before(cutoff) {
+			continue
+		}
+		s.Delete(id)
+		expired = append(expired, id)
+	}
+	return expired, nil
+}
+
+// List returns the IDs of all sessions, sorted
+func (s *MemoryStore) List() ([]string, error) {
+	s.mu.RLock()
+	ids := make([]string, 0, len(s.sessions))
+	for id := range s.sessions {
+		ids = append(ids, id)
+	}
+	s.mu.RUnlock()
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// QueryMessages scans every session for matching messages
+func (s *MemoryStore) QueryMessages(q MessageQuery) ([]MessageRecord, error) {
+	ids, _ := s.List()
+
+	var records []MessageRecord
+	for _, id := range ids {
+		session, err := s.Load(id)
+		if err != nil {
+			continue
+		}
+		for _, msg := range session.Messages {
+			if q.matches(session.UserID, msg) {
+				records = append(records, MessageRecord{SessionID: id, UserID: session.UserID, Message: msg})
+			}
+		}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+	if q.Limit > 0 && len(records) > q.Limit {
+		records = records[:q.Limit]
+	}
+	return records, nil
+}
+
+// Close does nothing
+func (s *MemoryStore) Close() error {
+	return nil
+}
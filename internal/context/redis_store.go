@@ -0,0 +1,192 @@
+package context
+
+import (
+	stdcontext "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix namespaces the session keys in a Redis database shared with other data
+const redisKeyPrefix = "voicepilot:session:"
+
+// redisTimeout bounds every Redis round trip so a stuck server doesn't hang a request
+const redisTimeout = 5 * time.Second
+
+// RedisStore keeps sessions in Redis or a server speaking its protocol (Valkey, KeyDB, ...)
+//
+// Each session is one JSON value whose TTL is reset on every save, so Redis
+// expires idle sessions itself and no cleanup sweep is needed. Saves are
+// optimistic: a session changed by another instance since it was loaded is
+// not overwritten and Save returns ErrSessionConflict.
+type RedisStore struct {
+	client *redis.Client
+	ttl    time.Duration // 0 keeps sessions forever
+}
+
+// NewRedisStore connects to the server at url (redis://[user:password@]host:port/db)
+//
+// Sessions expire ttl after their last update.
+func NewRedisStore(url string, ttl time.Duration) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	store := NewRedisStoreWithClient(redis.NewClient(opts), ttl)
+
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), redisTimeout)
+	defer cancel()
+	if err := store.client.Ping(ctx).Err(); err != nil {
+		store.client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis at %s: %w", opts.Addr, err)
+	}
+	return store, nil
+}
+
+// NewRedisStoreWithClient uses an existing client, which is closed by Close
+func NewRedisStoreWithClient(client *redis.Client, ttl time.Duration) *RedisStore {
+	return &RedisStore{client: client, ttl: ttl}
+}
+
+// ExpiresSessions reports that Redis drops idle sessions through their TTL
+func (s *RedisStore) ExpiresSessions() bool {
+	return s.ttl > 0
+}
+
+// Load reads a session
+func (s *RedisStore) Load(id string) (*Session, error) {
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := s.client.Get(ctx, redisKeyPrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Save stores the session if nobody saved it since it was loaded, and resets its TTL
+//
+// The stored version must still be the one session was loaded with (0 for a
+// new session); it is incremented on success.
+func (s *RedisStore) Save(session *Session) error {
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), redisTimeout)
+	defer cancel()
+
+	key := redisKeyPrefix + session.ID
+	next := *session
+	next.Version++
+	data, err := json.Marshal(&next)
+	if err != nil {
+		return err
+	}
+
+	err = s.client.Watch(ctx, func(tx *redis.Tx) error {
+		stored, err := tx.Get(ctx, key).Bytes()
+		switch {
+		case errors.Is(err, redis.Nil):
+			if session.Version != 0 {
+				// Deleted or expired since it was loaded
+				return ErrSessionConflict
+			}
+		case err != nil:
+			return err
+		default:
+			var current struct {
+				Version int64 `json:"version"`
+			}
+			if err := json.Unmarshal(stored, &current); err != nil {
+				return err
+			}
+			if current.Version != session.Version {
+				return ErrSessionConflict
+			}
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, s.ttl)
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		// Written by someone else between WATCH and EXEC
+		err = ErrSessionConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	session.Version = next.Version
+	return nil
+}
+
+// Delete removes a session
+func (s *RedisStore) Delete(id string) error {
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), redisTimeout)
+	defer cancel()
+	return s.client.Del(ctx, redisKeyPrefix+id).Err()
+}
+
+// DeleteAll removes every session, leaving other keys of the database alone
+func (s *RedisStore) DeleteAll() error {
+	keys, err := s.keys()
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), redisTimeout)
+	defer cancel()
+	return s.client.Del(ctx, keys...).Err()
+}
+
+// DeleteExpired does nothing: Redis has already dropped the sessions whose TTL ran out
+func (s *RedisStore) DeleteExpired(cutoff time.Time) ([]string, error) {
+	return nil, nil
+}
+
+// List returns the IDs of all sessions, sorted
+func (s *RedisStore) List() ([]string, error) {
+	keys, err := s.keys()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, strings.TrimPrefix(key, redisKeyPrefix))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Close closes the connection pool
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// keys returns the session keys, scanning instead of KEYS so a large database isn't blocked
+func (s *RedisStore) keys() ([]string, error) {
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), redisTimeout)
+	defer cancel()
+
+	var keys []string
+	iter := s.client.Scan(ctx, 0, redisKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
@@ -0,0 +1,202 @@
+package context
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedisStore(t *testing.T, ttl time.Duration) (*RedisStore, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	store, err := NewRedisStore("redis://"+server.Addr(), ttl)
+	if err != nil {
+		t.Fatalf("NewRedisStore failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, server
+}
+
+func TestRedisStore(t *testing.T) {
+	store, server := newTestRedisStore(t, time.Hour)
+	now := time.Now()
+
+	if _, err := store.Load("missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Load(missing) error = %v, want ErrSessionNotFound", err)
+	}
+
+	session := &Session{
+		ID:        "s1",
+		UserID:    "u1",
+		Messages:  []Message{{Role: "user", Content: "播放晴天", Intent: "play_music", Timestamp: now}},
+		Context:   map[string]interface{}{"key": "value"},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := store.Save(session); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if session.Version != 1 {
+		t.Errorf("Version after Save = %d, want 1", session.Version)
+	}
+	loaded, err := store.Load("s1")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.UserID != "u1" || len(loaded.Messages) != 1 || !loaded.Messages[0].Timestamp.Equal(now) ||
+		loaded.Context["key"] != "value" || loaded.Version != 1 {
+		t.Errorf("Load() = %+v, want %+v", loaded, session)
+	}
+
+	// Other keys of the database are left alone
+	server.Set("other", "x")
+	store.Save(&Session{ID: "s2", CreatedAt: now, UpdatedAt: now})
+	if ids, _ := store.List(); len(ids) != 2 || ids[0] != "s1" || ids[1] != "s2" {
+		t.Errorf("List() = %v, want [s1 s2]", ids)
+	}
+	if err := store.Delete("s1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Load("s1"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Load after Delete error = %v, want ErrSessionNotFound", err)
+	}
+	if err := store.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll failed: %v", err)
+	}
+	if ids, _ := store.List(); len(ids) != 0 {
+		t.Errorf("List after DeleteAll = %v", ids)
+	}
+	if !server.Exists("other") {
+		t.Error("DeleteAll removed a key that is not a session")
+	}
+}
+
+func TestRedisStoreTTL(t *testing.T) {
+	store, server := newTestRedisStore(t, time.Hour)
+	cm := NewContextManagerWithStore(store, 10, time.Hour)
+	if !cm.ExpiresSessions() {
+		t.Fatal("ExpiresSessions() = false for a store with a TTL")
+	}
+
+	cm.AddInteraction("s1", "你好", "greeting", "你好！")
+	server.FastForward(50 * time.Minute)
+	// Every update starts the TTL again
+	cm.AddInteraction("s1", "几点了", "unknown", "下午三点")
+	server.FastForward(50 * time.Minute)
+	if history := cm.GetHistory("s1", 0); len(history) != 4 {
+		t.Errorf("History of an active session = %d messages, want 4", len(history))
+	}
+
+	var ended []string
+	cm.OnSessionEnd(func(sessionID string) { ended = append(ended, sessionID) })
+	server.FastForward(20 * time.Minute)
+	// Cleanup notices the session Redis dropped
+	cm.sessions["s1"].UpdatedAt = time.Now().Add(-2 * time.Hour)
+	if err := cm.CleanupExpiredSessions(); err != nil || len(ended) != 1 || ended[0] != "s1" {
+		t.Errorf("CleanupExpiredSessions() = %v, ended sessions %v, want s1", err, ended)
+	}
+	if history := cm.GetHistory("s1", 0); len(history) != 0 {
+		t.Errorf("History of an idle session = %d messages, want 0", len(history))
+	}
+
+	if store, _ := newTestRedisStore(t, 0); store.ExpiresSessions() {
+		t.Error("ExpiresSessions() = true for a store without a TTL")
+	}
+}
+
+func TestRedisStoreConflict(t *testing.T) {
+	store, _ := newTestRedisStore(t, time.Hour)
+	testSessionConflicts(t, store)
+}
+
+func TestRedisStoreConcurrentInstances(t *testing.T) {
+	store, server := newTestRedisStore(t, time.Hour)
+	other, err := NewRedisStore("redis://"+server.Addr(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewRedisStore failed: %v", err)
+	}
+	defer other.Close()
+
+	// Two replicas take turns of the same session at the same time; no message is lost
+	managers := []*ContextManager{
+		NewContextManagerWithStore(store, 100, time.Hour),
+		NewContextManagerWithStore(other, 100, time.Hour),
+	}
+	var wg sync.WaitGroup
+	for _, cm := range managers {
+		wg.Add(1)
+		go func(cm *ContextManager) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				if err := cm.AddUserMessage("s1", "播放晴天", "play_music"); err != nil {
+					t.Errorf("AddUserMessage failed: %v", err)
+				}
+			}
+		}(cm)
+	}
+	wg.Wait()
+
+	if history := managers[0].GetHistory("s1", 0); len(history) != 20 {
+		t.Errorf("History = %d messages, want 20", len(history))
+	}
+}
+
+func TestTakeContextDataAcrossInstances(t *testing.T) {
+	store, server := newTestRedisStore(t, time.Hour)
+	other, err := NewRedisStore("redis://"+server.Addr(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewRedisStore failed: %v", err)
+	}
+	defer other.Close()
+
+	managers := []*ContextManager{
+		NewContextManagerWithStore(store, 100, time.Hour),
+		NewContextManagerWithStore(other, 100, time.Hour),
+	}
+	for round := 0; round < 20; round++ {
+		managers[0].SetContextData("s1", "pending", round)
+
+		// Both replicas answer the same confirmation; only one of them may get it
+		var mu sync.Mutex
+		taken := 0
+		var wg sync.WaitGroup
+		for _, cm := range managers {
+			wg.Add(1)
+			go func(cm *ContextManager) {
+				defer wg.Done()
+				_, ok, err := cm.TakeContextData("s1", "pending")
+				if err != nil {
+					t.Errorf("TakeContextData failed: %v", err)
+				}
+				if ok {
+					mu.Lock()
+					taken++
+					mu.Unlock()
+				}
+			}(cm)
+		}
+		wg.Wait()
+
+		if taken != 1 {
+			t.Fatalf("Round %d: value taken %d times, want once", round, taken)
+		}
+	}
+}
+
+func TestMigrateSessionsToRedis(t *testing.T) {
+	from := NewMemoryStore()
+	NewContextManagerWithStore(from, 10, time.Hour).AddInteraction("s1", "你好", "greeting", "你好！")
+	to, _ := newTestRedisStore(t, time.Hour)
+
+	// Running it again overwrites the sessions copied before
+	for run := 0; run < 2; run++ {
+		if n, err := MigrateSessions(from, to); err != nil || n != 1 {
+			t.Fatalf("MigrateSessions() = %d, %v, want 1", n, err)
+		}
+	}
+	if session, err := to.Load("s1"); err != nil || len(session.Messages) != 2 {
+		t.Errorf("Migrated session = %+v, %v", session, err)
+	}
+}
@@ -0,0 +1,295 @@
+package context
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // pure-Go SQLite driver
+)
+
+// sqliteDriver is the database/sql driver name of the embedded SQLite engine
+const sqliteDriver = "sqlite"
+
+// sqliteSchema creates the tables and the indexes used by QueryMessages
+//
+// Times are stored as Unix nanoseconds so they sort and compare as integers.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	id         TEXT PRIMARY KEY,
+	user_id    TEXT NOT NULL DEFAULT '',
+	summary    TEXT NOT NULL DEFAULT '',
+	context    TEXT NOT NULL DEFAULT '{}',
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	version    INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS sessions_user ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS sessions_updated ON sessions (updated_at);
+
+CREATE TABLE IF NOT EXISTS messages (
+	session_id TEXT NOT NULL,
+	seq        INTEGER NOT NULL,
+	role       TEXT NOT NULL,
+	content    TEXT NOT NULL,
+	intent     TEXT NOT NULL DEFAULT '',
+	timestamp  INTEGER NOT NULL,
+	PRIMARY KEY (session_id, seq)
+);
+CREATE INDEX IF NOT EXISTS messages_timestamp ON messages (timestamp);
+CREATE INDEX IF NOT EXISTS messages_intent ON messages (intent, timestamp);
+`
+
+// SQLiteStore keeps sessions in an embedded SQLite database
+//
+// Sessions and their messages are separate tables, so messages can be
+// searched by user, time range and intent without loading whole sessions.
+// Several server processes on one host can share the database file; a save
+// of a session that another process saved since it was loaded is not
+// written and Save returns ErrSessionConflict.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens or creates the database at path
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session database: %w", err)
+	}
+	// A single connection keeps the pragmas in effect and serializes writers of this process
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{"PRAGMA journal_mode = WAL", "PRAGMA busy_timeout = 5000", sqliteSchema} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize session database: %w", err)
+		}
+	}
+	// Databases created before summaries and versions were kept lack the
+	// columns; their sessions count as saved once
+	for _, column := range []string{`summary TEXT NOT NULL DEFAULT ''`, `version INTEGER NOT NULL DEFAULT 1`} {
+		if _, err := db.Exec(`ALTER TABLE sessions ADD COLUMN ` + column); err != nil &&
+			!strings.Contains(err.Error(), "duplicate column") {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize session database: %w", err)
+		}
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// Load reads a session and its messages
+func (s *SQLiteStore) Load(id string) (*Session, error) {
+	session := &Session{ID: id, Messages: []Message{}}
+	var contextJSON string
+	var created, updated int64
+	err := s.db.QueryRow(`SELECT user_id, summary, context, created_at, updated_at, version FROM sessions WHERE id = ?`, id).
+		Scan(&session.UserID, &session.Summary, &contextJSON, &created, &updated, &session.Version)
+	if err == sql.ErrNoRows {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	if err := json.Unmarshal([]byte(contextJSON), &session.Context); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session context: %w", err)
+	}
+	session.CreatedAt, session.UpdatedAt = time.Unix(0, created), time.Unix(0, updated)
+
+	rows, err := s.db.Query(`SELECT role, content, intent, timestamp FROM messages WHERE session_id = ? ORDER BY seq`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load messages: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var msg Message
+		var ts int64
+		if err := rows.Scan(&msg.Role, &msg.Content, &msg.Intent, &ts); err != nil {
+			return nil, fmt.Errorf("failed to load messages: %w", err)
+		}
+		msg.Timestamp = time.Unix(0, ts)
+		session.Messages = append(session.Messages, msg)
+	}
+	return session, rows.Err()
+}
+
+// Save replaces a session and its messages in one transaction if nobody saved it since it was loaded
+//
+// The stored version must still be the one session was loaded with (0 for a
+// new session); it is incremented on success.
+func (s *SQLiteStore) Save(session *Session) error {
+	contextJSON, err := json.Marshal(session.Context)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session context: %w", err)
+	}
+	if session.Context == nil {
+		contextJSON = []byte("{}")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Writing first takes the database's write lock, so the version can't change before the messages are written
+	var res sql.Result
+	if session.Version == 0 {
+		res, err = tx.Exec(`INSERT INTO sessions (id, user_id, summary, context, created_at, updated_at, version) VALUES (?, ?, ?, ?, ?, ?, 1)
+			ON CONFLICT (id) DO NOTHING`,
+			session.ID, session.UserID, session.Summary, string(contextJSON), session.CreatedAt.UnixNano(), session.UpdatedAt.UnixNano())
+	} else {
+		res, err = tx.Exec(`UPDATE sessions SET user_id = ?, summary = ?, context = ?, created_at = ?, updated_at = ?, version = version + 1
+			WHERE id = ? AND version = ?`,
+			session.UserID, session.Summary, string(contextJSON), session.CreatedAt.UnixNano(), session.UpdatedAt.UnixNano(),
+			session.ID, session.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	} else if n == 0 {
+		// Created, changed or deleted by someone else since it was loaded
+		return ErrSessionConflict
+	}
+	if _, err := tx.Exec(`DELETE FROM messages WHERE session_id = ?`, session.ID); err != nil {
+		return fmt.Errorf("failed to save messages: %w", err)
+	}
+	for i, msg := range session.Messages {
+		_, err := tx.Exec(`INSERT INTO messages (session_id, seq, role, content, intent, timestamp) VALUES (?, ?, ?, ?, ?, ?)`,
+			session.ID, i, msg.Role, msg.Content, msg.Intent, msg.Timestamp.UnixNano())
+		if err != nil {
+			return fmt.Errorf("failed to save messages: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	session.Version++
+	return nil
+}
+
+// Delete removes a session and its messages
+func (s *SQLiteStore) Delete(id string) error {
+	return s.deleteWhere(`id = ?`, id)
+}
+
+// DeleteAll removes every session
+func (s *SQLiteStore) DeleteAll() error {
+	return s.deleteWhere(`1 = 1`)
+}
+
+// DeleteExpired removes the sessions last updated before cutoff
+func (s *SQLiteStore) DeleteExpired(cutoff time.Time) ([]string, error) {
+	ids, err := s.ids(`SELECT id FROM sessions WHERE updated_at < ? ORDER BY id`, cutoff.UnixNano())
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	if err := s.deleteWhere(`updated_at < ?`, cutoff.UnixNano()); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// deleteWhere removes the sessions matching a condition on the sessions table, with their messages
+func (s *SQLiteStore) deleteWhere(cond string, args ...interface{}) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM messages WHERE session_id IN (SELECT id FROM sessions WHERE `+cond+`)`, args...); err != nil {
+		return fmt.Errorf("failed to delete messages: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE `+cond, args...); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	return nil
+}
+
+// List returns the IDs of all sessions, sorted
+func (s *SQLiteStore) List() ([]string, error) {
+	return s.ids(`SELECT id FROM sessions ORDER BY id`)
+}
+
+func (s *SQLiteStore) ids(query string, args ...interface{}) ([]string, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to list sessions: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// QueryMessages searches messages by user, intent and time range using the indexes
+func (s *SQLiteStore) QueryMessages(q MessageQuery) ([]MessageRecord, error) {
+	var conds []string
+	var args []interface{}
+	if q.UserID != "" {
+		conds = append(conds, "s.user_id = ?")
+		args = append(args, q.UserID)
+	}
+	if q.Intent != "" {
+		conds = append(conds, "m.intent = ?")
+		args = append(args, q.Intent)
+	}
+	if !q.From.IsZero() {
+		conds = append(conds, "m.timestamp >= ?")
+		args = append(args, q.From.UnixNano())
+	}
+	if !q.To.IsZero() {
+		conds = append(conds, "m.timestamp < ?")
+		args = append(args, q.To.UnixNano())
+	}
+
+	query := `SELECT m.session_id, s.user_id, m.role, m.content, m.intent, m.timestamp
+		FROM messages m JOIN sessions s ON s.id = m.session_id`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY m.timestamp, m.session_id, m.seq"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	var records []MessageRecord
+	for rows.Next() {
+		var r MessageRecord
+		var ts int64
+		if err := rows.Scan(&r.SessionID, &r.UserID, &r.Role, &r.Content, &r.Intent, &ts); err != nil {
+			return nil, fmt.Errorf("failed to query messages: %w", err)
+		}
+		r.Timestamp = time.Unix(0, ts)
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
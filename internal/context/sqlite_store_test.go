@@ -0,0 +1,97 @@
+package context
+
+import (
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestSQLiteStore(t *testing.T) *SQLiteStore {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteStore(t *testing.T) {
+	testSessionStore(t, newTestSQLiteStore(t))
+	testQueryMessages(t, newTestSQLiteStore(t))
+}
+
+func TestSQLiteStoreSharedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	store1, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	defer store1.Close()
+	store2, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	defer store2.Close()
+
+	cm1 := NewContextManagerWithStore(store1, 100, time.Hour)
+	cm2 := NewContextManagerWithStore(store2, 100, time.Hour)
+	cm1.AddInteraction("s1", "播放晴天", "play_music", "好的")
+	cm2.AddInteraction("s1", "下一首", "play_music", "好的")
+	if history := cm1.GetHistory("s1", 0); len(history) != 4 {
+		t.Errorf("History through the first store = %d messages, want 4", len(history))
+	}
+
+	// Both processes adding turns at the same time lose no message
+	var wg sync.WaitGroup
+	for _, cm := range []*ContextManager{cm1, cm2} {
+		wg.Add(1)
+		go func(cm *ContextManager) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				if err := cm.AddUserMessage("s1", "播放晴天", "play_music"); err != nil {
+					t.Errorf("AddUserMessage failed: %v", err)
+				}
+			}
+		}(cm)
+	}
+	wg.Wait()
+	if history := cm2.GetHistory("s1", 0); len(history) != 24 {
+		t.Errorf("History = %d messages, want 24", len(history))
+	}
+}
+
+func TestSQLiteStoreConflict(t *testing.T) {
+	testSessionConflicts(t, newTestSQLiteStore(t))
+}
+
+func TestSQLiteStoreUpgradesOldDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	db, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	// Schema before summaries and versions were kept
+	_, err = db.Exec(`CREATE TABLE sessions (id TEXT PRIMARY KEY, user_id TEXT NOT NULL DEFAULT '',
+		context TEXT NOT NULL DEFAULT '{}', created_at INTEGER NOT NULL, updated_at INTEGER NOT NULL);
+		INSERT INTO sessions (id, created_at, updated_at) VALUES ('s1', 0, 0)`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("Creating the old database failed: %v", err)
+	}
+
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	defer store.Close()
+	session, err := store.Load("s1")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	session.Summary = "用户喜欢周杰伦"
+	if err := store.Save(session); err != nil {
+		t.Errorf("Save of an old session failed: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSessionNotFound is returned by SessionStore.Load for sessions that were never saved or were deleted
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrSessionConflict is returned by SessionStore.Save when another instance saved the session after it was loaded
+var ErrSessionConflict = errors.New("session was changed concurrently")
+
+// SessionStore persists sessions for the ContextManager
+//
+// Implementations must be safe for concurrent use. Save replaces the stored
+// session as a whole; stores that detect concurrent updates return
+// ErrSessionConflict instead, and the caller reloads and tries again.
+type SessionStore interface {
+	Load(id string) (*Session, error)
+	Save(session *Session) error
+	Delete(id string) error
+	DeleteAll() error
+	// DeleteExpired removes the sessions last updated before cutoff and returns their IDs
+	DeleteExpired(cutoff time.Time) ([]string, error)
+	// List returns the IDs of all stored sessions
+	List() ([]string, error)
+	Close() error
+}
+
+// ExpiringStore is implemented by stores that drop idle sessions themselves
+//
+// When ExpiresSessions returns true, CleanupExpiredSessions deletes nothing
+// from the store and only drops cached copies of the sessions that are gone.
+type ExpiringStore interface {
+	ExpiresSessions() bool
+}
+
+// Session store kinds accepted by OpenStore
+const (
+	StoreFile   = "file"
+	StoreSQLite = "sqlite"
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+)
+
+// StoreOptions locates the sessions for OpenStore; only the fields of the chosen kind are used
+type StoreOptions struct {
+	Dir      string        // directory of the file store
+	DBPath   string        // database of the sqlite store
+	RedisURL string        // server of the redis store
+	Expiry   time.Duration // TTL of the sessions in the redis store
+}
+
+// OpenStore opens a session store of the given kind: JSON files, an SQLite database, Redis, or memory
+func OpenStore(kind string, opts StoreOptions) (SessionStore, error) {
+	switch kind {
+	case StoreFile, "":
+		return NewFileStore(opts.Dir)
+	case StoreSQLite:
+		return NewSQLiteStore(opts.DBPath)
+	case StoreRedis:
+		return NewRedisStore(opts.RedisURL, opts.Expiry)
+	case StoreMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown session store %q", kind)
+	}
+}
+
+// MessageQuery selects messages across sessions; zero fields match everything
+type MessageQuery struct {
+	UserID string
+	Intent string
+	From   time.Time // inclusive
+	To     time.Time // exclusive
+	Limit  int       // 0 for no limit
+}
+
+// MessageRecord is a message found by a MessageQuery, with the session it belongs to
+type MessageRecord struct {
+	SessionID string `json:"session_id"`
+	UserID    string `json:"user_id,omitempty"`
+	Message
+}
+
+// MessageQuerier is implemented by stores that can search messages across sessions
+type MessageQuerier interface {
+	// QueryMessages returns the matching messages, oldest first
+	QueryMessages(q MessageQuery) ([]MessageRecord, error)
+}
+
+// matches reports whether a message of a session owned by userID is selected by q
+func (q MessageQuery) matches(userID string, msg Message) bool {
+	return (q.UserID == "" || q.UserID == userID) &&
+		(q.Intent == "" || q.Intent == msg.Intent) &&
+		(q.From.IsZero() || !msg.Timestamp.Before(q.From)) &&
+		(q.To.IsZero() || msg.Timestamp.Before(q.To))
+}
+
+// MigrateSessions copies every session of from into to and returns how many were copied
+//
+// Sessions already in to with the same ID are overwritten, so an interrupted
+// migration can simply be run again.
+func MigrateSessions(from, to SessionStore) (int, error) {
+	ids, err := from.List()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	migrated := 0
+	for _, id := range ids {
+		session, err := from.Load(id)
+		if errors.Is(err, ErrSessionNotFound) {
+			continue
+		}
+		if err != nil {
+			return migrated, fmt.Errorf("failed to load session %s: %w", id, err)
+		}
+		err = to.Save(session)
+		if errors.Is(err, ErrSessionConflict) {
+			// Left by an earlier run in a store that checks versions; overwrite it
+			if existing, loadErr := to.Load(id); loadErr == nil {
+				session.Version = existing.Version
+				err = to.Save(session)
+			}
+		}
+		if err != nil {
+			return migrated, fmt.Errorf("failed to save session %s: %w", id, err)
+		}
+		migrated++
+	}
+	return migrated, nil
+}
@@ -0,0 +1,283 @@
+package context
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSessionStore runs the behaviour every SessionStore must have
+func testSessionStore(t *testing.T, store SessionStore) {
+	t.Helper()
+	now := time.Now()
+
+	if _, err := store.Load("missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Load(missing) error = %v, want ErrSessionNotFound", err)
+	}
+
+	session := &Session{
+		ID:     "s1",
+		UserID: "u1",
+		Messages: []Message{
+			{Role: "user", Content: "播放晴天", Intent: "play_music", Timestamp: now.Add(-time.Minute)},
+			{Role: "assistant", Content: "好的", Timestamp: now},
+		},
+		Context:   map[string]interface{}{"key": "value"},
+		CreatedAt: now.Add(-time.Minute),
+		UpdatedAt: now,
+	}
+	if err := store.Save(session); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := store.Load("s1")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.UserID != "u1" || len(loaded.Messages) != 2 || loaded.Messages[0].Intent != "play_music" ||
+		!loaded.Messages[1].Timestamp.Equal(now) || loaded.Context["key"] != "value" || !loaded.UpdatedAt.Equal(now) {
+		t.Errorf("Load() = %+v, want %+v", loaded, session)
+	}
+
+	// Save replaces the session, including dropped messages
+	session.Messages = session.Messages[1:]
+	store.Save(session)
+	if loaded, _ := store.Load("s1"); len(loaded.Messages) != 1 {
+		t.Errorf("Messages after replacing = %+v, want 1", loaded.Messages)
+	}
+
+	store.Save(&Session{ID: "old", Messages: []Message{}, CreatedAt: now.Add(-2 * time.Hour), UpdatedAt: now.Add(-2 * time.Hour)})
+	expired, err := store.DeleteExpired(now.Add(-time.Hour))
+	if err != nil || len(expired) != 1 || expired[0] != "old" {
+		t.Errorf("DeleteExpired() = %v, %v, want [old]", expired, err)
+	}
+	if ids, _ := store.List(); len(ids) != 1 || ids[0] != "s1" {
+		t.Errorf("List() = %v, want [s1]", ids)
+	}
+
+	if err := store.Delete("s1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Load("s1"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Load after Delete error = %v, want ErrSessionNotFound", err)
+	}
+
+	store.Save(&Session{ID: "a", CreatedAt: now, UpdatedAt: now})
+	store.Save(&Session{ID: "b", CreatedAt: now, UpdatedAt: now})
+	if err := store.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll failed: %v", err)
+	}
+	if ids, _ := store.List(); len(ids) != 0 {
+		t.Errorf("List after DeleteAll = %v", ids)
+	}
+}
+
+// testSessionConflicts checks that a store detecting concurrent updates doesn't let a stale session overwrite a newer one
+func testSessionConflicts(t *testing.T, store SessionStore) {
+	t.Helper()
+	now := time.Now()
+	store.Save(&Session{ID: "s1", Messages: []Message{}, CreatedAt: now, UpdatedAt: now})
+
+	// Two instances load the same version
+	first, _ := store.Load("s1")
+	second, _ := store.Load("s1")
+	first.Messages = append(first.Messages, Message{Role: "user", Content: "播放晴天"})
+	if err := store.Save(first); err != nil {
+		t.Fatalf("First Save failed: %v", err)
+	}
+	second.Messages = append(second.Messages, Message{Role: "user", Content: "下一首"})
+	if err := store.Save(second); !errors.Is(err, ErrSessionConflict) {
+		t.Errorf("Save of a stale session error = %v, want ErrSessionConflict", err)
+	}
+	if loaded, _ := store.Load("s1"); len(loaded.Messages) != 1 || loaded.Messages[0].Content != "播放晴天" {
+		t.Errorf("Stored messages = %+v, want the first instance's", loaded.Messages)
+	}
+
+	// A new session must not replace one created meanwhile, nor a deleted one come back
+	if err := store.Save(&Session{ID: "s1"}); !errors.Is(err, ErrSessionConflict) {
+		t.Errorf("Save of a new session over an existing one error = %v, want ErrSessionConflict", err)
+	}
+	store.Delete("s1")
+	if err := store.Save(first); !errors.Is(err, ErrSessionConflict) {
+		t.Errorf("Save of a deleted session error = %v, want ErrSessionConflict", err)
+	}
+}
+
+// testQueryMessages checks searching messages by user, intent and time range
+func testQueryMessages(t *testing.T, store interface {
+	SessionStore
+	MessageQuerier
+}) {
+	t.Helper()
+	base := time.Date(2026, 10, 1, 9, 0, 0, 0, time.Local)
+	msg := func(hours int, intent string) Message {
+		return Message{Role: "user", Content: intent, Intent: intent, Timestamp: base.Add(time.Duration(hours) * time.Hour)}
+	}
+	store.Save(&Session{ID: "a", UserID: "alice", Messages: []Message{msg(0, "play_music"), msg(2, "set_reminder")}, UpdatedAt: base})
+	store.Save(&Session{ID: "b", UserID: "bob", Messages: []Message{msg(1, "play_music"), msg(3, "play_music")}, UpdatedAt: base})
+
+	tests := []struct {
+		name  string
+		query MessageQuery
+		want  []string // session IDs of the results, in order
+	}{
+		{"all", MessageQuery{}, []string{"a", "b", "a", "b"}},
+		{"by user", MessageQuery{UserID: "bob"}, []string{"b", "b"}},
+		{"by intent", MessageQuery{Intent: "play_music"}, []string{"a", "b", "b"}},
+		{"by time range", MessageQuery{From: base.Add(time.Hour), To: base.Add(3 * time.Hour)}, []string{"b", "a"}},
+		{"combined", MessageQuery{UserID: "alice", Intent: "play_music", From: base}, []string{"a"}},
+		{"limit", MessageQuery{Intent: "play_music", Limit: 2}, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		records, err := store.QueryMessages(tt.query)
+		if err != nil {
+			t.Fatalf("%s: QueryMessages failed: %v", tt.name, err)
+		}
+		var got []string
+		for _, r := range records {
+			got = append(got, r.SessionID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: QueryMessages() = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: QueryMessages() = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+	if records, _ := store.QueryMessages(MessageQuery{UserID: "alice", Intent: "set_reminder"}); len(records) != 1 || records[0].UserID != "alice" {
+		t.Errorf("QueryMessages() = %+v, want alice's reminder", records)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	testSessionStore(t, store)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testSessionStore(t, NewMemoryStore())
+	testQueryMessages(t, NewMemoryStore())
+}
+
+func TestSharedStore(t *testing.T) {
+	// Two instances behind a load balancer share one store
+	store := NewMemoryStore()
+	cm1 := NewContextManagerWithStore(store, 10, time.Hour)
+	cm2 := NewContextManagerWithStore(store, 10, time.Hour)
+
+	cm1.AddInteraction("s1", "播放晴天", "play_music", "好的")
+	cm2.GetHistory("s1", 0)
+	cm1.AddInteraction("s1", "下一首", "play_music", "好的")
+	if history := cm2.GetHistory("s1", 0); len(history) != 4 {
+		t.Errorf("Second instance sees %d messages, want 4", len(history))
+	}
+
+	cm2.ClearSession("s1")
+	if history := cm1.GetHistory("s1", 0); len(history) != 0 {
+		t.Errorf("First instance still sees %d messages of a cleared session", len(history))
+	}
+}
+
+// slowStore is a memory store whose saves of one session wait until release is closed
+type slowStore struct {
+	*MemoryStore
+	slowID  string
+	release chan struct{}
+}
+
+func (s *slowStore) Save(session *Session) error {
+	if session.ID == s.slowID {
+		<-s.release
+	}
+	return s.MemoryStore.Save(session)
+}
+
+func TestSessionsLockedSeparately(t *testing.T) {
+	store := &slowStore{MemoryStore: NewMemoryStore(), slowID: "slow", release: make(chan struct{})}
+	cm := NewContextManagerWithStore(store, 100, time.Hour)
+
+	slowDone := make(chan struct{})
+	go func() {
+		defer close(slowDone)
+		cm.AddUserMessage("slow", "播放晴天", "play_music")
+	}()
+
+	// A session waiting on the store doesn't hold up the others
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cm.AddInteraction("fast", "下一首", "play_music", "好的")
+		cm.GetHistory("fast", 0)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Other session blocked by a slow save")
+	}
+	close(store.release)
+	<-slowDone
+
+	// Changes to the same session are still serialized
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cm.AddUserMessage("fast", "播放晴天", "play_music")
+		}()
+	}
+	wg.Wait()
+	if history := cm.GetHistory("fast", 0); len(history) != 22 {
+		t.Errorf("History = %d messages, want 22", len(history))
+	}
+}
+
+func TestCleanupExpiredSessionsNotLoaded(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+	old := time.Now().Add(-2 * time.Hour)
+	store.Save(&Session{ID: "stale", CreatedAt: old, UpdatedAt: old})
+
+	// A fresh instance never loaded the session but still cleans it up
+	cm := NewContextManager(dir, 10, time.Hour)
+	if err := cm.CleanupExpiredSessions(); err != nil {
+		t.Fatalf("CleanupExpiredSessions failed: %v", err)
+	}
+	if _, err := store.Load("stale"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Stale session still stored: %v", err)
+	}
+}
+
+func TestMigrateSessions(t *testing.T) {
+	from, _ := NewFileStore(t.TempDir())
+	cm := NewContextManagerWithStore(from, 10, time.Hour)
+	cm.AddInteraction("s1", "你好", "greeting", "你好！")
+	cm.SetUserID("s1", "u1")
+	cm.AddInteraction("s2", "几点了", "unknown", "下午三点")
+
+	to := NewMemoryStore()
+	n, err := MigrateSessions(from, to)
+	if err != nil || n != 2 {
+		t.Fatalf("MigrateSessions() = %d, %v, want 2", n, err)
+	}
+	session, err := to.Load("s1")
+	if err != nil || session.UserID != "u1" || len(session.Messages) != 2 || session.Messages[0].Intent != "greeting" {
+		t.Errorf("Migrated session = %+v, %v", session, err)
+	}
+
+	// Running it again overwrites instead of duplicating
+	if n, err := MigrateSessions(from, to); err != nil || n != 2 {
+		t.Errorf("Second MigrateSessions() = %d, %v", n, err)
+	}
+	if session, _ := to.Load("s1"); len(session.Messages) != 2 {
+		t.Errorf("Messages after second migration = %d, want 2", len(session.Messages))
+	}
+}
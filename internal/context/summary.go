@@ -0,0 +1,95 @@
+package context
+
+import (
+	stdcontext "context"
+	"fmt"
+)
+
+// Summarizer condenses messages into a summary of at most about maxTokens
+//
+// previous is the summary of the turns before messages, empty at first; the
+// result replaces it, so it must carry over what still matters.
+type Summarizer func(ctx stdcontext.Context, previous string, messages []Message, maxTokens int) (string, error)
+
+// SummaryOptions controls when sessions are summarized
+type SummaryOptions struct {
+	Threshold int // summarize once a session holds more messages than this
+	Keep      int // most recent messages left out of the summary
+	MaxTokens int // budget of the summary, in BuildLLMContext as well
+}
+
+// EnableSummaries makes Summarize condense the older turns of long sessions with summarizer
+//
+// Threshold should stay below the maximum history, or messages are trimmed
+// before they are summarized.
+func (cm *ContextManager) EnableSummaries(summarizer Summarizer, opts SummaryOptions) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if opts.Keep < 0 {
+		opts.Keep = 0
+	}
+	if cm.maxHistory > 0 && opts.Threshold >= cm.maxHistory {
+		fmt.Printf("Warning: session summary threshold %d is not below the maximum history %d\n", opts.Threshold, cm.maxHistory)
+	}
+	cm.summarizer = summarizer
+	cm.summaryOptions = opts
+}
+
+// NeedsSummary reports whether the session has grown past the summary threshold
+func (cm *ContextManager) NeedsSummary(sessionID string) bool {
+	_, ok := cm.pendingSummary(sessionID)
+	return ok
+}
+
+// Summarize folds all but the most recent messages of a long session into its summary
+//
+// The model is called without locking the session. If the history changed
+// in the meantime, for instance because another instance summarized it
+// first, the new summary is dropped.
+func (cm *ContextManager) Summarize(ctx stdcontext.Context, sessionID string) error {
+	session, ok := cm.pendingSummary(sessionID)
+	if !ok {
+		return nil
+	}
+	summarizer, opts := cm.summarySettings()
+	previous := session.Summary
+	older := session.Messages[:len(session.Messages)-opts.Keep]
+
+	summary, err := summarizer(ctx, previous, older, opts.MaxTokens)
+	if err != nil {
+		return fmt.Errorf("failed to summarize session %s: %w", sessionID, err)
+	}
+
+	last := older[len(older)-1]
+	return cm.update(sessionID, func(session *Session) bool {
+		if session.Summary != previous || len(session.Messages) < len(older) ||
+			!session.Messages[len(older)-1].Timestamp.Equal(last.Timestamp) {
+			return false
+		}
+		session.Summary = summary
+		session.Messages = append([]Message{}, session.Messages[len(older):]...)
+		return true
+	})
+}
+
+// summarySettings returns what EnableSummaries set
+func (cm *ContextManager) summarySettings() (Summarizer, SummaryOptions) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.summarizer, cm.summaryOptions
+}
+
+// pendingSummary returns the session if summaries are enabled and it needs one
+func (cm *ContextManager) pendingSummary(sessionID string) (*Session, bool) {
+	summarizer, opts := cm.summarySettings()
+	if summarizer == nil || opts.Threshold <= 0 {
+		return nil, false
+	}
+	session := cm.loadSession(sessionID)
+	if session == nil || len(session.Messages) <= opts.Threshold || len(session.Messages) <= opts.Keep {
+		return nil, false
+	}
+	return session, true
+}
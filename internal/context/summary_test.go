@@ -0,0 +1,128 @@
+package context
+
+import (
+	stdcontext "context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingSummarizer joins the contents of the summarized messages after the previous summary
+type recordingSummarizer struct {
+	calls int
+	err   error
+}
+
+func (r *recordingSummarizer) summarize(ctx stdcontext.Context, previous string, messages []Message, maxTokens int) (string, error) {
+	r.calls++
+	if r.err != nil {
+		return "", r.err
+	}
+	var parts []string
+	if previous != "" {
+		parts = append(parts, previous)
+	}
+	for _, msg := range messages {
+		parts = append(parts, msg.Content)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+func TestSummarize(t *testing.T) {
+	cm := NewContextManagerWithStore(NewMemoryStore(), 50, time.Hour)
+	summarizer := &recordingSummarizer{}
+	cm.EnableSummaries(summarizer.summarize, SummaryOptions{Threshold: 6, Keep: 2, MaxTokens: 100})
+
+	for i := 1; i <= 3; i++ {
+		cm.AddInteraction("s1", fmt.Sprintf("问%d", i), "unknown", fmt.Sprintf("答%d", i))
+	}
+	if cm.NeedsSummary("s1") {
+		t.Error("NeedsSummary() = true at the threshold")
+	}
+	cm.AddInteraction("s1", "问4", "unknown", "答4")
+	if !cm.NeedsSummary("s1") {
+		t.Fatal("NeedsSummary() = false past the threshold")
+	}
+
+	if err := cm.Summarize(stdcontext.Background(), "s1"); err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if summary := cm.GetSummary("s1"); summary != "问1,答1,问2,答2,问3,答3" {
+		t.Errorf("Summary = %q", summary)
+	}
+	if history := cm.GetHistory("s1", 0); len(history) != 2 || history[0].Content != "问4" {
+		t.Errorf("History after summarizing = %+v, want the last turn", history)
+	}
+
+	// The next summary builds on the previous one
+	for i := 5; i <= 7; i++ {
+		cm.AddInteraction("s1", fmt.Sprintf("问%d", i), "unknown", fmt.Sprintf("答%d", i))
+	}
+	cm.Summarize(stdcontext.Background(), "s1")
+	if summary := cm.GetSummary("s1"); summary != "问1,答1,问2,答2,问3,答3,问4,答4,问5,答5,问6,答6" {
+		t.Errorf("Rolled summary = %q", summary)
+	}
+
+	// Nothing to do below the threshold
+	calls := summarizer.calls
+	if err := cm.Summarize(stdcontext.Background(), "s1"); err != nil || summarizer.calls != calls {
+		t.Errorf("Summarize below the threshold called the model: %v", err)
+	}
+}
+
+func TestSummarizeFailure(t *testing.T) {
+	cm := NewContextManagerWithStore(NewMemoryStore(), 50, time.Hour)
+	summarizer := &recordingSummarizer{err: errors.New("model unavailable")}
+	cm.EnableSummaries(summarizer.summarize, SummaryOptions{Threshold: 2, Keep: 0, MaxTokens: 100})
+	cm.AddInteraction("s1", "问1", "unknown", "答1")
+	cm.AddInteraction("s1", "问2", "unknown", "答2")
+
+	if err := cm.Summarize(stdcontext.Background(), "s1"); err == nil {
+		t.Error("Summarize succeeded with a failing model")
+	}
+	if history := cm.GetHistory("s1", 0); len(history) != 4 || cm.GetSummary("s1") != "" {
+		t.Errorf("Session changed by a failed summary: %d messages, summary %q", len(history), cm.GetSummary("s1"))
+	}
+}
+
+func TestSummarizeConcurrentChange(t *testing.T) {
+	store := NewMemoryStore()
+	cm := NewContextManagerWithStore(store, 50, time.Hour)
+	other := NewContextManagerWithStore(store, 50, time.Hour)
+	cm.EnableSummaries(func(ctx stdcontext.Context, previous string, messages []Message, maxTokens int) (string, error) {
+		// Another instance summarizes the session while the model is busy
+		other.EnableSummaries((&recordingSummarizer{}).summarize, SummaryOptions{Threshold: 2, Keep: 0})
+		other.Summarize(ctx, "s1")
+		return "late", nil
+	}, SummaryOptions{Threshold: 2, Keep: 0})
+	cm.AddInteraction("s1", "问1", "unknown", "答1")
+	cm.AddInteraction("s1", "问2", "unknown", "答2")
+
+	if err := cm.Summarize(stdcontext.Background(), "s1"); err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if summary := cm.GetSummary("s1"); summary != "问1,答1,问2,答2" {
+		t.Errorf("Summary = %q, want the one saved first", summary)
+	}
+}
+
+func TestBuildLLMContextSummary(t *testing.T) {
+	cm := NewContextManagerWithStore(NewMemoryStore(), 50, time.Hour)
+	cm.EnableSummaries((&recordingSummarizer{}).summarize, SummaryOptions{Threshold: 2, Keep: 2, MaxTokens: 5})
+	cm.AddInteraction("s1", "我叫小李，住在杭州", "unknown", "好的")
+	cm.AddInteraction("s1", "明天天气怎么样", "unknown", "晴")
+	cm.Summarize(stdcontext.Background(), "s1")
+
+	messages := cm.BuildLLMContext("s1", 4)
+	if len(messages) != 3 || messages[0]["role"] != "system" {
+		t.Fatalf("BuildLLMContext() = %v, want the summary and two messages", messages)
+	}
+	if want := "此前对话的摘要：我叫小李…"; messages[0]["content"] != want {
+		t.Errorf("Summary message = %q, want %q", messages[0]["content"], want)
+	}
+	if messages[1]["content"] != "明天天气怎么样" {
+		t.Errorf("First history message = %q", messages[1]["content"])
+	}
+}
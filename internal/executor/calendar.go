@@ -0,0 +1,240 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/calendar"
+	"github.com/deca/voicepilot-eino/internal/scheduler"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// defaultEventDuration is the length of an event added without an end time
+const defaultEventDuration = time.Hour
+
+// defaultAgendaDays is how many days list_events covers when no date is given
+const defaultAgendaDays = 7
+
+// WithCalendar backs the calendar actions with c; without it they fail
+func WithCalendar(c *calendar.Calendar) Option {
+	return func(e *Executor) { e.calendar = c }
+}
+
+// registerCalendarActions registers the calendar actions
+func (e *Executor) registerCalendarActions() {
+	e.RegisterAction(ActionSpec{
+		Name:        "add_event",
+		Description: "在日历中添加日程，会提示与已有日程的时间冲突",
+		Parameters: objectSchema(map[string]interface{}{
+			"title":    stringParam("日程标题", "周会", "和张三吃饭"),
+			"start":    stringParam("开始时间，保留用户的原话即可；只给日期表示全天日程", "明天下午3点", "下周一10点", "10月20日"),
+			"end":      stringParam("结束时间或持续时长，默认持续一小时", "4点", "90分钟"),
+			"repeat":   stringParam("重复规则，不重复时留空", "每天", "每周一三五", "工作日", "每月15号"),
+			"location": stringParam("地点", "会议室A"),
+		}, "title", "start"),
+	}, e.handleAddEvent)
+	e.RegisterAction(ActionSpec{
+		Name:        "list_events",
+		Description: "查看日历中的日程",
+		Parameters: objectSchema(map[string]interface{}{
+			"date": stringParam("要查看的日期或时间段，默认未来7天", "今天", "明天", "下周", "10月20日"),
+		}),
+	}, e.handleListEvents)
+	e.RegisterAction(ActionSpec{
+		Name:        "delete_event",
+		Description: "删除日历中的日程，可按编号或标题指定；重复日程会整体删除",
+		Parameters: objectSchema(map[string]interface{}{
+			"id":    stringParam("日程编号"),
+			"title": stringParam("日程标题中的关键字", "周会"),
+		}),
+	}, e.handleDeleteEvent)
+}
+
+// handleAddEvent adds an event to the calendar and reports conflicting events
+func (e *Executor) handleAddEvent(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+	if e.calendar == nil {
+		return &types.ExecutionResult{Success: false, Error: "日历功能未启用"}
+	}
+
+	title, _ := params["title"].(string)
+	if strings.TrimSpace(title) == "" {
+		return paramError("title", "缺少日程标题")
+	}
+	startExpr, _ := params["start"].(string)
+	if strings.TrimSpace(startExpr) == "" {
+		return paramError("start", "缺少日程开始时间")
+	}
+
+	now := time.Now().In(e.calendar.Location())
+	event := calendar.Event{Summary: strings.TrimSpace(title)}
+	event.Location, _ = params["location"].(string)
+
+	start, err := scheduler.ParseTime(startExpr, now)
+	switch {
+	case errors.Is(err, scheduler.ErrPast):
+		return paramError("start", fmt.Sprintf("日程开始时间已经过去：%s", startExpr))
+	case err != nil:
+		// A date without a time of day is an all-day event
+		from, to, rangeErr := scheduler.ParseDateRange(startExpr, now)
+		if rangeErr != nil {
+			return paramError("start", fmt.Sprintf("无法识别日程开始时间：%s", startExpr))
+		}
+		if !to.After(now) {
+			return paramError("start", fmt.Sprintf("日程开始时间已经过去：%s", startExpr))
+		}
+		event.Start, event.End, event.AllDay = from, to, true
+	default:
+		event.Start, event.End = start, start.Add(defaultEventDuration)
+		if endExpr, _ := params["end"].(string); strings.TrimSpace(endExpr) != "" {
+			end, err := scheduler.ParseTime(endExpr, start)
+			if err != nil {
+				return paramError("end", fmt.Sprintf("无法识别日程结束时间：%s", endExpr))
+			}
+			event.End = end
+		}
+	}
+
+	if repeat, _ := params["repeat"].(string); strings.TrimSpace(repeat) != "" {
+		if event.RRule, err = calendar.ParseRecurrence(repeat); err != nil {
+			return paramError("repeat", fmt.Sprintf("无法识别重复规则：%s", repeat))
+		}
+	}
+
+	event, conflicts, err := e.calendar.Add(ctx, event)
+	if err != nil {
+		log.Printf("Failed to add calendar event: %v", err)
+		return &types.ExecutionResult{Success: false, Error: "添加日程失败"}
+	}
+	log.Printf("Added calendar event %s at %s with %d conflicts", event.UID, event.Start.Format(time.RFC3339), len(conflicts))
+	OnUndo(ctx, "添加日程 "+event.Summary, func(ctx context.Context) error {
+		if err := e.calendar.Delete(ctx, event.UID); err != nil && !errors.Is(err, calendar.ErrNotFound) {
+			return err
+		}
+		return nil
+	})
+
+	message := fmt.Sprintf("已添加日程：%s %s", formatEventTime(event.Start, event.End, event.AllDay, now), event.Summary)
+	if event.RRule != "" {
+		message += fmt.Sprintf("（%s）", calendar.DescribeRecurrence(event.RRule))
+	}
+	if len(conflicts) > 0 {
+		names := make([]string, len(conflicts))
+		for i, occ := range conflicts {
+			names[i] = fmt.Sprintf("%s %s", formatEventTime(occ.Start, occ.End, false, now), occ.Event.Summary)
+		}
+		message += fmt.Sprintf("。注意，该时间已有日程：%s", strings.Join(names, "、"))
+	}
+
+	return &types.ExecutionResult{Success: true, Message: message, Data: event.UID}
+}
+
+// handleListEvents lists the events of a day or span, the coming week by default
+func (e *Executor) handleListEvents(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+	if e.calendar == nil {
+		return &types.ExecutionResult{Success: false, Error: "日历功能未启用"}
+	}
+
+	now := time.Now().In(e.calendar.Location())
+	expr, _ := params["date"].(string)
+	expr = strings.TrimSpace(expr)
+	label := expr
+	from, to, err := scheduler.ParseDateRange(expr, now)
+	if expr == "" {
+		label = fmt.Sprintf("未来%d天", defaultAgendaDays)
+		from, to, err = scheduler.ParseDateRange(label, now)
+	}
+	if err != nil {
+		return &types.ExecutionResult{Success: false, Error: fmt.Sprintf("无法识别日期：%s", expr)}
+	}
+
+	occs, err := e.calendar.Occurrences(ctx, from, to)
+	if err != nil {
+		log.Printf("Failed to load calendar events: %v", err)
+		return errorResult("读取日程失败", err)
+	}
+	if len(occs) == 0 {
+		return &types.ExecutionResult{Success: true, Message: fmt.Sprintf("%s没有日程", label)}
+	}
+
+	lines := make([]string, len(occs))
+	for i, occ := range occs {
+		line := fmt.Sprintf("%d. %s %s", i+1, formatEventTime(occ.Start, occ.End, occ.Event.AllDay, now), occ.Event.Summary)
+		if occ.Event.Location != "" {
+			line += "，地点：" + occ.Event.Location
+		}
+		lines[i] = line + fmt.Sprintf("（编号 %s）", shortUID(occ.Event.UID))
+	}
+	return &types.ExecutionResult{
+		Success: true,
+		Message: fmt.Sprintf("%s共有 %d 个日程", label, len(occs)),
+		Data:    strings.Join(lines, "\n"),
+	}
+}
+
+// handleDeleteEvent deletes the single event matching an ID or a title keyword
+func (e *Executor) handleDeleteEvent(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+	if e.calendar == nil {
+		return &types.ExecutionResult{Success: false, Error: "日历功能未启用"}
+	}
+
+	id, _ := params["id"].(string)
+	keyword, _ := params["title"].(string)
+	id, keyword = strings.TrimSpace(id), strings.TrimSpace(keyword)
+	if id == "" && keyword == "" {
+		return paramError("title", "请说明要删除哪个日程")
+	}
+
+	found, err := e.calendar.Find(ctx, id, keyword)
+	if err != nil {
+		log.Printf("Failed to load calendar events: %v", err)
+		return errorResult("读取日程失败", err)
+	}
+	now := time.Now().In(e.calendar.Location())
+	switch {
+	case len(found) == 0:
+		return &types.ExecutionResult{Success: false, Error: "没有找到要删除的日程"}
+	case len(found) > 1:
+		// Deleting is not undoable, so ask instead of guessing
+		names := make([]string, len(found))
+		for i, event := range found {
+			names[i] = fmt.Sprintf("%s %s（编号 %s）", formatEventTime(event.Start, event.End, event.AllDay, now), event.Summary, shortUID(event.UID))
+		}
+		return paramError("id", fmt.Sprintf("找到多个匹配的日程，请说明要删除哪一个：%s", strings.Join(names, "；")))
+	}
+
+	event := found[0]
+	if err := e.calendar.Delete(ctx, event.UID); err != nil && !errors.Is(err, calendar.ErrNotFound) {
+		log.Printf("Failed to delete calendar event %s: %v", event.UID, err)
+		return &types.ExecutionResult{Success: false, Error: "删除日程失败"}
+	}
+
+	message := fmt.Sprintf("已删除日程：%s", event.Summary)
+	if event.RRule != "" {
+		message += "，该重复日程的所有时间都已删除"
+	}
+	return &types.ExecutionResult{Success: true, Message: message}
+}
+
+// formatEventTime formats an event's time span for speech, e.g. "今天 15:00-16:00" or "10月20日 全天"
+func formatEventTime(start, end time.Time, allDay bool, now time.Time) string {
+	switch {
+	case allDay:
+		return formatDay(start, now) + " 全天"
+	case start.YearDay() == end.YearDay() && start.Year() == end.Year():
+		return formatWhen(start, now) + "-" + end.Format("15:04")
+	default:
+		return formatWhen(start, now) + " 至 " + formatWhen(end, now)
+	}
+}
+
+// shortUID shortens generated UIDs to something that can be read out
+func shortUID(uid string) string {
+	if len(uid) > 8 {
+		return uid[:8]
+	}
+	return uid
+}
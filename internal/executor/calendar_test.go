@@ -0,0 +1,101 @@
+package executor
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/calendar"
+)
+
+func TestCalendarActions(t *testing.T) {
+	cal := calendar.New(calendar.NewFileStore(filepath.Join(t.TempDir(), "calendar.ics"), time.Local), time.Local)
+	e := NewExecutor(nil, WithCalendar(cal))
+	ctx := context.Background()
+
+	result := e.handleAddEvent(ctx, map[string]interface{}{"title": "周会", "start": "明天下午3点", "repeat": "每周", "location": "会议室A"})
+	if !result.Success || !strings.Contains(result.Message, "明天 15:00-16:00 周会") || !strings.Contains(result.Message, "每周重复") {
+		t.Fatalf("add_event = %+v", result)
+	}
+	weeklyID := result.Data
+
+	result = e.handleAddEvent(ctx, map[string]interface{}{"title": "产品评审", "start": "明天下午3点半", "end": "90分钟"})
+	if !result.Success || !strings.Contains(result.Message, "15:30-17:00") || !strings.Contains(result.Message, "该时间已有日程：明天 15:00-16:00 周会") {
+		t.Errorf("add_event with conflict = %+v", result)
+	}
+	result = e.handleAddEvent(ctx, map[string]interface{}{"title": "出差", "start": "后天"})
+	if !result.Success || !strings.Contains(result.Message, "后天 全天 出差") || strings.Contains(result.Message, "注意") {
+		t.Errorf("all-day add_event = %+v", result)
+	}
+
+	result = e.handleListEvents(ctx, map[string]interface{}{"date": "明天"})
+	if !result.Success || result.Message != "明天共有 2 个日程" || !strings.Contains(result.Data, "周会，地点：会议室A（编号 "+weeklyID[:8]+"）") {
+		t.Errorf("list_events = %+v", result)
+	}
+	if result := e.handleListEvents(ctx, nil); !result.Success || !strings.Contains(result.Message, "未来7天") {
+		t.Errorf("list_events without date = %+v", result)
+	}
+
+	// An ambiguous title is not deleted
+	e.handleAddEvent(ctx, map[string]interface{}{"title": "周会准备", "start": "明天上午9点"})
+	if result := e.handleDeleteEvent(ctx, map[string]interface{}{"title": "周会"}); result.Success || !strings.Contains(result.Error, "多个") {
+		t.Errorf("delete_event with several matches = %+v", result)
+	}
+	result = e.handleDeleteEvent(ctx, map[string]interface{}{"id": weeklyID[:8]})
+	if !result.Success || !strings.Contains(result.Message, "所有时间") {
+		t.Errorf("delete_event by id = %+v", result)
+	}
+	if result := e.handleDeleteEvent(ctx, map[string]interface{}{"title": "不存在"}); result.Success {
+		t.Errorf("delete_event of a missing event = %+v", result)
+	}
+}
+
+func TestAddEventErrors(t *testing.T) {
+	cal := calendar.New(calendar.NewFileStore(filepath.Join(t.TempDir(), "calendar.ics"), time.Local), time.Local)
+
+	tests := []struct {
+		name   string
+		e      *Executor
+		params map[string]interface{}
+		want   string
+	}{
+		{"no calendar", NewExecutor(nil), map[string]interface{}{"title": "周会", "start": "明天下午3点"}, "未启用"},
+		{"missing title", NewExecutor(nil, WithCalendar(cal)), map[string]interface{}{"start": "明天下午3点"}, "缺少日程标题"},
+		{"unknown start", NewExecutor(nil, WithCalendar(cal)), map[string]interface{}{"title": "周会", "start": "改天"}, "无法识别日程开始时间"},
+		{"past start", NewExecutor(nil, WithCalendar(cal)), map[string]interface{}{"title": "周会", "start": "2020-01-01 09:00"}, "已经过去"},
+		{"unknown end", NewExecutor(nil, WithCalendar(cal)), map[string]interface{}{"title": "周会", "start": "明天下午3点", "end": "很久"}, "结束时间"},
+		{"unknown repeat", NewExecutor(nil, WithCalendar(cal)), map[string]interface{}{"title": "周会", "start": "明天下午3点", "repeat": "偶尔"}, "重复规则"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.e.handleAddEvent(context.Background(), tt.params)
+			if result.Success || !strings.Contains(result.Error, tt.want) {
+				t.Errorf("add_event = %+v, want error containing %q", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatEventTime(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	at := func(d, h int) time.Time { return time.Date(2026, 10, d, h, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		start, end time.Time
+		allDay     bool
+		want       string
+	}{
+		{at(16, 15), at(16, 16), false, "今天 15:00-16:00"},
+		{at(17, 22), at(18, 1), false, "明天 22:00 至 后天 01:00"},
+		{at(20, 0), at(21, 0), true, "10月20日 全天"},
+	}
+
+	for _, tt := range tests {
+		if got := formatEventTime(tt.start, tt.end, tt.allDay, now); got != tt.want {
+			t.Errorf("formatEventTime(%v, %v) = %q, want %q", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
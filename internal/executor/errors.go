@@ -0,0 +1,87 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// ActionError is a classified action failure
+//
+// Message is what the user is told; Err, if any, is the underlying cause.
+type ActionError struct {
+	Class   types.ErrorClass
+	Param   string // for user_fixable errors, the parameter the user has to supply or correct
+	Message string
+	Err     error
+}
+
+func (e *ActionError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *ActionError) Unwrap() error {
+	return e.Err
+}
+
+// Result converts the error into a failed execution result
+func (e *ActionError) Result() *types.ExecutionResult {
+	return &types.ExecutionResult{
+		Success:    false,
+		Error:      e.Message,
+		ErrorClass: e.Class,
+		Param:      e.Param,
+	}
+}
+
+// Classify tells whether err is worth retrying
+//
+// ActionErrors keep their class. Rate limits, server errors, timeouts and
+// dropped connections are retryable; everything else, including a cancelled
+// request, is fatal.
+func Classify(err error) types.ErrorClass {
+	var actionErr *ActionError
+	if errors.As(err, &actionErr) {
+		return actionErr.Class
+	}
+
+	var status interface{ HTTPStatus() int }
+	if errors.As(err, &status) {
+		code := status.HTTPStatus()
+		if code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500 {
+			return types.ErrorRetryable
+		}
+		return types.ErrorFatal
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return types.ErrorFatal
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.As(err, &netErr):
+		return types.ErrorRetryable
+	}
+	return types.ErrorFatal
+}
+
+// paramError is the result of an action whose parameter is missing or could not be understood
+func paramError(param, message string) *types.ExecutionResult {
+	return (&ActionError{Class: types.ErrorUserFixable, Param: param, Message: message}).Result()
+}
+
+// errorResult is the result of an action that failed because of err, classified with Classify
+func errorResult(message string, err error) *types.ExecutionResult {
+	return (&ActionError{Class: Classify(err), Message: message, Err: err}).Result()
+}
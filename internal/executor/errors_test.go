@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/internal/qiniu"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want types.ErrorClass
+	}{
+		{"rate limited", &provider.StatusError{StatusCode: 429}, types.ErrorRetryable},
+		{"server error", fmt.Errorf("chat failed: %w", &qiniu.StatusError{API: "Chat API", StatusCode: 503}), types.ErrorRetryable},
+		{"bad request", &provider.StatusError{StatusCode: 400}, types.ErrorFatal},
+		{"unauthorized", &qiniu.StatusError{API: "Chat API", StatusCode: 401}, types.ErrorFatal},
+		{"deadline", fmt.Errorf("stream: %w", context.DeadlineExceeded), types.ErrorRetryable},
+		{"cancelled", context.Canceled, types.ErrorFatal},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), types.ErrorRetryable},
+		{"unexpected EOF", io.ErrUnexpectedEOF, types.ErrorRetryable},
+		{"action error", &ActionError{Class: types.ErrorUserFixable, Param: "song"}, types.ErrorUserFixable},
+		{"other", errors.New("boom"), types.ErrorFatal},
+	}
+
+	for _, tt := range tests {
+		if got := Classify(tt.err); got != tt.want {
+			t.Errorf("Classify(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParamErrors(t *testing.T) {
+	e := NewExecutor(nil, WithWorkspace(t.TempDir()))
+
+	tests := []struct {
+		action string
+		params map[string]interface{}
+		param  string
+	}{
+		{"open_app", map[string]interface{}{}, "name"},
+		{"play_music", map[string]interface{}{}, "song"},
+		{"execute_command", map[string]interface{}{"command": "echo 'unterminated"}, "command"},
+		{"save_file", map[string]interface{}{"path": "a.txt"}, "content"},
+	}
+
+	for _, tt := range tests {
+		result := e.Execute(context.Background(), &types.TaskPlan{Steps: []types.TaskStep{{Action: tt.action, Parameters: tt.params}}})
+		if result.Success || result.ErrorClass != types.ErrorUserFixable || result.Param != tt.param || result.Action != tt.action {
+			t.Errorf("%s: result = %+v, want a user_fixable error about %s", tt.action, result, tt.param)
+		}
+	}
+}
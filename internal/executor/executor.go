@@ -2,54 +2,240 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
 	"os/exec"
 	"runtime"
+	"sort"
 	"strings"
-
-	"github.com/deca/voicepilot-eino/internal/qiniu"
+	"sync"
+
+	"github.com/deca/voicepilot-eino/internal/calendar"
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/internal/sandbox"
+	"github.com/deca/voicepilot-eino/internal/scheduler"
+	"github.com/deca/voicepilot-eino/internal/shell"
+	"github.com/deca/voicepilot-eino/internal/telemetry"
 	"github.com/deca/voicepilot-eino/pkg/types"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Executor executes tasks based on the task plan
 type Executor struct {
-	handlers    map[string]ActionHandler
-	qiniuClient *qiniu.Client
+	handlers  map[string]ActionHandler
+	specs     map[string]ActionSpec
+	chat      provider.ChatProvider
+	sandbox   *sandbox.Runner
+	workspace string // root directory of the file actions
+	scheduler *scheduler.Scheduler
+	calendar  *calendar.Calendar
+
+	paramCheck ParamCheck // re-checks steps whose parameters referred to other steps
+
+	defaultPolicy Policy
+	policies      map[string]Policy // per-action overrides of defaultPolicy
+
+	// undoHistory holds, per session, the compensations of recent successful
+	// plans. It lives in this process only: undo_last can't reverse plans run
+	// by another instance or before a restart.
+	undoMu      sync.Mutex
+	undoHistory map[string][][]compensation
+}
+
+// Option customizes an Executor
+type Option func(*Executor)
+
+// WithSandbox runs execute_command through the given sandbox runner instead of the default one
+func WithSandbox(runner *sandbox.Runner) Option {
+	return func(e *Executor) { e.sandbox = runner }
 }
 
 // ActionHandler is a function that handles a specific action
 type ActionHandler func(ctx context.Context, params map[string]interface{}) *types.ExecutionResult
 
-// NewExecutor creates a new executor
-func NewExecutor() *Executor {
+// ActionSpec describes an action to the LLM so it can be offered as a callable tool
+type ActionSpec struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{} // JSON schema of the parameters object
+}
+
+// NewExecutor creates a new executor that uses chat for text generation
+func NewExecutor(chat provider.ChatProvider, opts ...Option) *Executor {
 	e := &Executor{
 		handlers:    make(map[string]ActionHandler),
-		qiniuClient: qiniu.NewClient(),
+		specs:       make(map[string]ActionSpec),
+		chat:        chat,
+		sandbox:     sandbox.New(sandbox.DefaultConfig()),
+		undoHistory: make(map[string][][]compensation),
+	}
+	for _, opt := range opts {
+		opt(e)
 	}
 
 	// Register action handlers
-	e.RegisterHandler("open_app", e.handleOpenApp)
-	e.RegisterHandler("play_music", e.handlePlayMusic)
-	e.RegisterHandler("execute_command", e.handleExecuteCommand)
-	e.RegisterHandler("generate_text", e.handleGenerateText)
+	e.RegisterAction(ActionSpec{
+		Name:        "open_app",
+		Description: "打开电脑上的应用程序",
+		Parameters: objectSchema(map[string]interface{}{
+			"name": stringParam("应用程序名称", "微信", "Safari"),
+		}, "name"),
+	}, e.handleOpenApp)
+	e.RegisterAction(ActionSpec{
+		Name:        "play_music",
+		Description: "在网易云音乐中搜索并播放歌曲",
+		Parameters: objectSchema(map[string]interface{}{
+			"song": stringParam("歌曲名称，可包含歌手", "晴天", "周杰伦 七里香"),
+		}, "song"),
+	}, e.handlePlayMusic)
+	e.RegisterAction(ActionSpec{
+		Name:        "execute_command",
+		Description: "执行系统命令",
+		Parameters: objectSchema(map[string]interface{}{
+			"command": stringParam("要执行的命令行", "ls -la", "df -h"),
+		}, "command"),
+	}, e.handleExecuteCommand)
+	e.RegisterAction(ActionSpec{
+		Name:        "generate_text",
+		Description: "生成文章、诗歌、总结等文本内容",
+		Parameters: objectSchema(map[string]interface{}{
+			"topic":        stringParam("主题", "人工智能的发展"),
+			"content_type": stringParam("内容类型", "文章", "诗歌", "邮件"),
+			"length":       stringParam("长度要求", "简短", "适中", "详细"),
+		}, "topic"),
+	}, e.handleGenerateText)
 	e.RegisterHandler("write_article", e.handleGenerateText) // Alias for generate_text
-	e.RegisterHandler("clarify", e.handleClarify)
+	e.RegisterAction(ActionSpec{
+		Name:        "clarify",
+		Description: "无法确定用户意图时，请用户澄清",
+		Parameters: objectSchema(map[string]interface{}{
+			"message": stringParam("向用户提出的澄清问题", "您想打开哪个应用？"),
+		}, "message"),
+	}, e.handleClarify)
 	e.RegisterHandler("error", e.handleError)
+	e.RegisterAction(ActionSpec{
+		Name:        "undo_last",
+		Description: "撤销上一次执行的操作，如删除刚保存的文件、取消刚设置的提醒",
+		Parameters:  objectSchema(map[string]interface{}{}),
+	}, e.handleUndoLast)
+	e.registerFileActions()
+	e.registerReminderActions()
+	e.registerCalendarActions()
 
 	return e
 }
 
 // RegisterHandler registers a handler for a specific action
+//
+// Actions registered this way are executable but not offered to the LLM as
+// tools; use RegisterAction for that.
 func (e *Executor) RegisterHandler(action string, handler ActionHandler) {
 	e.handlers[action] = handler
 }
 
+// RegisterAction registers a handler together with the spec describing it to the LLM
+func (e *Executor) RegisterAction(spec ActionSpec, handler ActionHandler) {
+	e.handlers[spec.Name] = handler
+	e.specs[spec.Name] = spec
+}
+
+// HasAction reports whether a handler is registered for the action
+func (e *Executor) HasAction(action string) bool {
+	_, ok := e.handlers[action]
+	return ok
+}
+
+// Spec returns the spec of an action registered with RegisterAction
+func (e *Executor) Spec(action string) (ActionSpec, bool) {
+	spec, ok := e.specs[action]
+	return spec, ok
+}
+
+// Specs returns the registered action specs, sorted by name
+//
+// Actions registered with RegisterHandler only are not included.
+func (e *Executor) Specs() []ActionSpec {
+	names := make([]string, 0, len(e.specs))
+	for name := range e.specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	specs := make([]ActionSpec, 0, len(names))
+	for _, name := range names {
+		specs = append(specs, e.specs[name])
+	}
+	return specs
+}
+
+// Tools returns the registered action specs as tool definitions, sorted by name
+func (e *Executor) Tools() []provider.Tool {
+	specs := e.Specs()
+	tools := make([]provider.Tool, 0, len(specs))
+	for _, spec := range specs {
+		tools = append(tools, spec.Tool())
+	}
+	return tools
+}
+
+// Tool converts the spec into a tool definition, with an empty object schema if it has no parameters
+func (s ActionSpec) Tool() provider.Tool {
+	params := s.Parameters
+	if params == nil {
+		params = objectSchema(map[string]interface{}{})
+	}
+	return provider.NewFunctionTool(s.Name, s.Description, params)
+}
+
+// objectSchema builds a JSON schema for an object with the given properties
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// stringParam builds a JSON schema for a string parameter with optional example values
+func stringParam(description string, examples ...string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":        "string",
+		"description": description,
+	}
+	if len(examples) > 0 {
+		schema["examples"] = examples
+	}
+	return schema
+}
+
 // Execute executes a task plan
+//
+// Steps run one after another unless the plan gives steps IDs, dependencies
+// or references to other steps' results; see executeGraph for those plans.
+// When the plan fails, the completed actions that registered an undo with
+// OnUndo are rolled back in reverse order.
 func (e *Executor) Execute(ctx context.Context, plan *types.TaskPlan) *types.ExecutionResult {
 	log.Printf("Executing task plan with %d steps", len(plan.Steps))
 
+	undo := &undoLog{}
+	ctx = context.WithValue(ctx, undoLogKey{}, undo)
+
+	var result *types.ExecutionResult
+	if plan.HasDependencies() {
+		result = e.executeGraph(ctx, plan)
+	} else {
+		result = e.executeSequence(ctx, plan)
+	}
+	return e.finishPlan(ctx, result, undo)
+}
+
+// executeSequence runs the steps of a plan one after another, stopping at the first failure
+func (e *Executor) executeSequence(ctx context.Context, plan *types.TaskPlan) *types.ExecutionResult {
 	var results []string
 	for i, step := range plan.Steps {
 		log.Printf("Executing step %d: %s", i+1, step.Action)
@@ -62,7 +248,7 @@ func (e *Executor) Execute(ctx context.Context, plan *types.TaskPlan) *types.Exe
 			}
 		}
 
-		result := handler(ctx, step.Parameters)
+		result := e.runAction(ctx, step.Action, handler, step.Parameters)
 		if !result.Success {
 			return result
 		}
@@ -78,14 +264,42 @@ func (e *Executor) Execute(ctx context.Context, plan *types.TaskPlan) *types.Exe
 	}
 }
 
+// runAction runs a single action handler inside a tracing span
+//
+// Each attempt is limited to the action's timeout and retryable failures are
+// retried with backoff according to its policy. Failed results name the action.
+func (e *Executor) runAction(ctx context.Context, action string, handler ActionHandler, params map[string]interface{}) *types.ExecutionResult {
+	ctx, span := telemetry.StartSpan(ctx, "executor.action."+action, attribute.String("executor.action", action))
+
+	policy := e.policyFor(action)
+	var result *types.ExecutionResult
+	for i := 0; ; i++ {
+		result = attempt(ctx, handler, params, policy)
+		if result.Success || result.ErrorClass != types.ErrorRetryable || i >= policy.MaxRetries {
+			break
+		}
+		delay := policy.backoff(i)
+		log.Printf("Action %s failed (%s), retrying in %v (%d/%d)", action, result.Error, delay, i+1, policy.MaxRetries)
+		if !sleep(ctx, delay) {
+			break
+		}
+	}
+
+	var err error
+	if !result.Success {
+		result.Action = action
+		span.SetAttributes(attribute.String("executor.error_class", string(result.ErrorClass)))
+		err = errors.New(result.Error)
+	}
+	telemetry.EndSpan(span, err)
+	return result
+}
+
 // handleOpenApp opens an application
 func (e *Executor) handleOpenApp(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
 	appName, ok := params["name"].(string)
 	if !ok {
-		return &types.ExecutionResult{
-			Success: false,
-			Error:   "缺少应用程序名称参数",
-		}
+		return paramError("name", "缺少应用程序名称参数")
 	}
 
 	log.Printf("Opening application: %s", appName)
@@ -112,6 +326,13 @@ func (e *Executor) handleOpenApp(ctx context.Context, params map[string]interfac
 		}
 	}
 
+	// Only macOS has a uniform way to quit an application again
+	if runtime.GOOS == "darwin" {
+		OnUndo(ctx, "打开应用程序 "+appName, func(ctx context.Context) error {
+			return exec.CommandContext(ctx, "osascript", "-e", fmt.Sprintf("quit app %q", appName)).Run()
+		})
+	}
+
 	return &types.ExecutionResult{
 		Success: true,
 		Message: fmt.Sprintf("已打开应用程序：%s", appName),
@@ -129,10 +350,7 @@ func (e *Executor) handlePlayMusic(ctx context.Context, params map[string]interf
 		song, ok = params["name"].(string)
 	}
 	if !ok {
-		return &types.ExecutionResult{
-			Success: false,
-			Error:   "缺少歌曲名称参数",
-		}
+		return paramError("song", "缺少歌曲名称参数")
 	}
 
 	log.Printf("Opening NetEase Music app and searching for: %s", song)
@@ -250,36 +468,71 @@ func (e *Executor) openNeteaseWebSearch(ctx context.Context, songName string) er
 func (e *Executor) handleExecuteCommand(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
 	command, ok := params["command"].(string)
 	if !ok {
-		return &types.ExecutionResult{
-			Success: false,
-			Error:   "缺少命令参数",
-		}
+		return paramError("command", "缺少命令参数")
 	}
 
 	log.Printf("Executing command: %s", command)
 
-	// Parse command and arguments
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
+	// Parse command and arguments with the same parser the security check used
+	cmd, err := shell.Parse(command)
+	if err != nil {
+		return paramError("command", fmt.Sprintf("命令格式无效：%v", err))
+	}
+	if cmd.HasOperators() {
 		return &types.ExecutionResult{
 			Success: false,
-			Error:   "命令为空",
+			Error:   "不支持管道、重定向或命令链",
 		}
 	}
+	parts := cmd.Argv()
+	if len(parts) == 0 {
+		return paramError("command", "命令为空")
+	}
 
-	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
-	output, err := cmd.CombinedOutput()
+	result, err := e.sandbox.Run(ctx, parts)
 	if err != nil {
 		return &types.ExecutionResult{
 			Success: false,
-			Error:   fmt.Sprintf("命令执行失败：%v\n输出：%s", err, string(output)),
+			Error:   fmt.Sprintf("命令执行失败：%v", err),
+		}
+	}
+
+	commandResult := &types.CommandResult{
+		ExitCode:        result.ExitCode,
+		Stdout:          result.Stdout,
+		Stderr:          result.Stderr,
+		StdoutTruncated: result.StdoutTruncated,
+		StderrTruncated: result.StderrTruncated,
+		TimedOut:        result.TimedOut,
+		DurationMs:      result.Duration.Milliseconds(),
+		Isolation:       result.Isolation,
+	}
+	log.Printf("Command finished (exit code: %d, duration: %v, isolation: %s)", result.ExitCode, result.Duration, result.Isolation)
+
+	if result.TimedOut {
+		return &types.ExecutionResult{
+			Success: false,
+			Error:   fmt.Sprintf("命令执行超时（已运行 %.1f 秒）", result.Duration.Seconds()),
+			Command: commandResult,
+		}
+	}
+	if result.ExitCode != 0 {
+		output := result.Stderr
+		if output == "" {
+			output = result.Stdout
+		}
+		return &types.ExecutionResult{
+			Success: false,
+			Error:   fmt.Sprintf("命令执行失败（退出码 %d）\n输出：%s", result.ExitCode, output),
+			Command: commandResult,
 		}
 	}
 
 	return &types.ExecutionResult{
 		Success: true,
-		Message: fmt.Sprintf("命令执行成功"),
-		Data:    string(output),
+		Message: "命令执行成功",
+		Data:    result.Stdout,
+		Command: commandResult,
 	}
 }
 
@@ -293,10 +546,7 @@ func (e *Executor) handleGenerateText(ctx context.Context, params map[string]int
 		} else if subject, ok := params["subject"].(string); ok {
 			topic = subject
 		} else {
-			return &types.ExecutionResult{
-				Success: false,
-				Error:   "缺少主题参数",
-			}
+			return paramError("topic", "缺少主题参数")
 		}
 	}
 
@@ -317,19 +567,16 @@ func (e *Executor) handleGenerateText(ctx context.Context, params map[string]int
 	systemPrompt := "你是一个专业的内容创作助手。请根据用户的要求生成高质量的文本内容。"
 	userPrompt := fmt.Sprintf("请写一篇关于「%s」的%s，长度要求：%s。", topic, contentType, length)
 
-	messages := []qiniu.Message{
+	messages := []provider.Message{
 		{Role: "system", Content: systemPrompt},
 		{Role: "user", Content: userPrompt},
 	}
 
 	// Call LLM to generate content
-	generatedText, err := e.qiniuClient.ChatCompletion(ctx, messages)
+	generatedText, err := provider.CompleteStreaming(ctx, e.chat, messages, "generate_text")
 	if err != nil {
 		log.Printf("Failed to generate text: %v", err)
-		return &types.ExecutionResult{
-			Success: false,
-			Error:   fmt.Sprintf("文本生成失败：%v", err),
-		}
+		return errorResult(fmt.Sprintf("文本生成失败：%v", err), err)
 	}
 
 	log.Printf("Generated text: %s", generatedText[:min(100, len(generatedText))])
@@ -3,12 +3,30 @@ package executor
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/internal/sandbox"
 	"github.com/deca/voicepilot-eino/pkg/types"
 )
 
+// fakeChat is a ChatProvider that answers every request with a fixed reply
+type fakeChat struct {
+	reply string
+	calls int
+}
+
+func (f *fakeChat) ChatCompletion(ctx context.Context, messages []provider.Message) (string, error) {
+	f.calls++
+	return f.reply, nil
+}
+
+func newTestExecutor() *Executor {
+	return NewExecutor(&fakeChat{reply: "generated text"})
+}
+
 func TestNewExecutor(t *testing.T) {
-	exec := NewExecutor()
+	exec := newTestExecutor()
 
 	if exec == nil {
 		t.Fatal("Executor should not be nil")
@@ -28,7 +46,7 @@ func TestNewExecutor(t *testing.T) {
 }
 
 func TestRegisterHandler(t *testing.T) {
-	exec := NewExecutor()
+	exec := newTestExecutor()
 
 	customHandler := func(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
 		return &types.ExecutionResult{Success: true, Message: "custom"}
@@ -41,8 +59,59 @@ func TestRegisterHandler(t *testing.T) {
 	}
 }
 
+func TestTools(t *testing.T) {
+	exec := newTestExecutor()
+
+	tools := exec.Tools()
+	names := make(map[string]bool)
+	for _, tool := range tools {
+		names[tool.Function.Name] = true
+		if tool.Type != "function" {
+			t.Errorf("Expected tool type 'function', got %s", tool.Type)
+		}
+		if tool.Function.Parameters["type"] != "object" {
+			t.Errorf("Tool %s should have an object parameter schema", tool.Function.Name)
+		}
+	}
+
+	for _, name := range []string{"open_app", "play_music", "execute_command", "generate_text", "clarify"} {
+		if !names[name] {
+			t.Errorf("Expected tool %s", name)
+		}
+	}
+
+	// Aliases and internal actions are not offered to the model
+	for _, name := range []string{"write_article", "error"} {
+		if names[name] {
+			t.Errorf("Did not expect tool %s", name)
+		}
+	}
+}
+
+func TestRegisterAction(t *testing.T) {
+	exec := newTestExecutor()
+
+	exec.RegisterAction(ActionSpec{Name: "custom_action", Description: "custom"}, func(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+		return &types.ExecutionResult{Success: true}
+	})
+
+	if _, exists := exec.handlers["custom_action"]; !exists {
+		t.Error("custom_action should be registered")
+	}
+
+	found := false
+	for _, tool := range exec.Tools() {
+		if tool.Function.Name == "custom_action" {
+			found = tool.Function.Parameters != nil
+		}
+	}
+	if !found {
+		t.Error("custom_action should be exposed as a tool with a default schema")
+	}
+}
+
 func TestExecute(t *testing.T) {
-	exec := NewExecutor()
+	exec := newTestExecutor()
 	ctx := context.Background()
 
 	tests := []struct {
@@ -104,7 +173,7 @@ func TestExecute(t *testing.T) {
 }
 
 func TestHandleClarify(t *testing.T) {
-	exec := NewExecutor()
+	exec := newTestExecutor()
 	ctx := context.Background()
 
 	tests := []struct {
@@ -140,13 +209,13 @@ func TestHandleClarify(t *testing.T) {
 }
 
 func TestHandleError(t *testing.T) {
-	exec := NewExecutor()
+	exec := newTestExecutor()
 	ctx := context.Background()
 
 	tests := []struct {
-		name     string
-		params   map[string]interface{}
-		wantMsg  string
+		name    string
+		params  map[string]interface{}
+		wantMsg string
 	}{
 		{
 			name:    "with custom error",
@@ -176,7 +245,7 @@ func TestHandleError(t *testing.T) {
 }
 
 func TestHandleGenerateText(t *testing.T) {
-	exec := NewExecutor()
+	exec := newTestExecutor()
 	ctx := context.Background()
 
 	tests := []struct {
@@ -208,7 +277,7 @@ func TestHandleGenerateText(t *testing.T) {
 }
 
 func TestHandlePlayMusic(t *testing.T) {
-	exec := NewExecutor()
+	exec := newTestExecutor()
 	ctx := context.Background()
 
 	tests := []struct {
@@ -240,7 +309,7 @@ func TestHandlePlayMusic(t *testing.T) {
 }
 
 func TestHandleOpenApp(t *testing.T) {
-	exec := NewExecutor()
+	exec := newTestExecutor()
 	ctx := context.Background()
 
 	tests := []struct {
@@ -272,7 +341,7 @@ func TestHandleOpenApp(t *testing.T) {
 }
 
 func TestHandleExecuteCommand(t *testing.T) {
-	exec := NewExecutor()
+	exec := newTestExecutor()
 	ctx := context.Background()
 
 	tests := []struct {
@@ -307,3 +376,59 @@ func TestHandleExecuteCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleExecuteCommandSandbox(t *testing.T) {
+	runner := sandbox.New(sandbox.Config{
+		Mode:    sandbox.ModeNone,
+		WorkDir: t.TempDir(),
+		Timeout: 500 * time.Millisecond,
+	})
+	exec := NewExecutor(&fakeChat{}, WithSandbox(runner))
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		command      string
+		wantSuccess  bool
+		wantExitCode int
+		wantTimeout  bool
+	}{
+		{name: "success", command: "echo hello", wantSuccess: true, wantExitCode: 0},
+		{name: "non-zero exit", command: "false", wantSuccess: false, wantExitCode: 1},
+		{name: "timeout", command: "sleep 5", wantSuccess: false, wantExitCode: -1, wantTimeout: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := exec.handleExecuteCommand(ctx, map[string]interface{}{"command": tt.command})
+
+			if result.Success != tt.wantSuccess {
+				t.Errorf("handleExecuteCommand() success = %v, want %v (error: %s)", result.Success, tt.wantSuccess, result.Error)
+			}
+			if result.Command == nil {
+				t.Fatal("Expected command details in the result")
+			}
+			if result.Command.ExitCode != tt.wantExitCode || result.Command.TimedOut != tt.wantTimeout {
+				t.Errorf("Unexpected command result: %+v", result.Command)
+			}
+		})
+	}
+}
+
+func TestHandleExecuteCommandParsing(t *testing.T) {
+	runner := sandbox.New(sandbox.Config{Mode: sandbox.ModeNone, WorkDir: t.TempDir()})
+	exec := NewExecutor(&fakeChat{}, WithSandbox(runner))
+	ctx := context.Background()
+
+	result := exec.handleExecuteCommand(ctx, map[string]interface{}{"command": `echo "hello   world" 'a|b'`})
+	if !result.Success || result.Data != "hello   world a|b\n" {
+		t.Errorf("Quoted arguments not preserved: success=%v data=%q error=%s", result.Success, result.Data, result.Error)
+	}
+
+	for _, command := range []string{"echo a | cat", "echo a > out.txt", `echo "unterminated`, "   "} {
+		result := exec.handleExecuteCommand(ctx, map[string]interface{}{"command": command})
+		if result.Success || result.Command != nil {
+			t.Errorf("handleExecuteCommand(%q) should be rejected before running, got %+v", command, result)
+		}
+	}
+}
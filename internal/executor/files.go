@@ -0,0 +1,433 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/internal/workspace"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// Limits for the file actions
+const (
+	maxReadBytes       = 64 * 1024       // read_file returns at most this much
+	maxSummarizeBytes  = 2 * 1024 * 1024 // summarize_file reads at most this much
+	maxSearchFileBytes = 1024 * 1024     // larger files are only matched by name
+	maxSearchResults   = 50
+	summaryChunkRunes  = 6000        // characters per chunk sent to the model
+	maxUndoFileBytes   = 1024 * 1024 // larger files overwritten by save_file are not kept for undo
+)
+
+// WithWorkspace confines the file actions to root; without it they fail
+func WithWorkspace(root string) Option {
+	return func(e *Executor) { e.workspace = root }
+}
+
+// registerFileActions registers the workspace file actions
+func (e *Executor) registerFileActions() {
+	e.RegisterAction(ActionSpec{
+		Name:        "save_file",
+		Description: "把文本内容保存到工作区中的文件",
+		Parameters: objectSchema(map[string]interface{}{
+			"path":    stringParam("工作区内的相对路径", "notes/todo.txt"),
+			"content": stringParam("要写入的文本内容"),
+			"append": map[string]interface{}{
+				"type":        "boolean",
+				"description": "为 true 时追加到文件末尾，否则覆盖",
+			},
+		}, "path", "content"),
+	}, e.handleSaveFile)
+	e.RegisterAction(ActionSpec{
+		Name:        "read_file",
+		Description: "读取工作区中的文本文件",
+		Parameters: objectSchema(map[string]interface{}{
+			"path": stringParam("工作区内的相对路径", "notes/todo.txt"),
+		}, "path"),
+	}, e.handleReadFile)
+	e.RegisterAction(ActionSpec{
+		Name:        "list_dir",
+		Description: "列出工作区中某个目录下的文件",
+		Parameters: objectSchema(map[string]interface{}{
+			"path": stringParam("工作区内的目录，默认为工作区根目录", ".", "notes"),
+		}),
+	}, e.handleListDir)
+	e.RegisterAction(ActionSpec{
+		Name:        "search_files",
+		Description: "在工作区中按文件名或文件内容搜索",
+		Parameters: objectSchema(map[string]interface{}{
+			"query": stringParam("要搜索的关键字", "会议纪要"),
+			"path":  stringParam("搜索的目录，默认为工作区根目录", "."),
+		}, "query"),
+	}, e.handleSearchFiles)
+	e.RegisterAction(ActionSpec{
+		Name:        "summarize_file",
+		Description: "总结工作区中文本文件的内容",
+		Parameters: objectSchema(map[string]interface{}{
+			"path": stringParam("工作区内的相对路径", "reports/weekly.md"),
+		}, "path"),
+	}, e.handleSummarizeFile)
+}
+
+// resolvePath resolves the "path" parameter inside the workspace
+//
+// The security manager already rejected escapes; this resolves the path again
+// right before use so the file actually touched is the one that was checked.
+func (e *Executor) resolvePath(params map[string]interface{}, required bool) (string, *types.ExecutionResult) {
+	path, ok := params["path"].(string)
+	if !ok || path == "" {
+		if required {
+			return "", paramError("path", "缺少文件路径参数")
+		}
+		path = "."
+	}
+
+	resolved, err := workspace.Resolve(e.workspace, path)
+	if err != nil {
+		log.Printf("Rejected workspace path %s: %v", path, err)
+		return "", &types.ExecutionResult{Success: false, Error: fmt.Sprintf("无法访问路径 %s：%v", path, err)}
+	}
+	return resolved, nil
+}
+
+// handleSaveFile writes content to a file in the workspace
+func (e *Executor) handleSaveFile(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+	path, failure := e.resolvePath(params, true)
+	if failure != nil {
+		return failure
+	}
+	content, ok := params["content"].(string)
+	if !ok {
+		return paramError("content", "缺少文件内容参数")
+	}
+	appendMode, _ := params["append"].(bool)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return &types.ExecutionResult{Success: false, Error: fmt.Sprintf("创建目录失败：%v", err)}
+	}
+
+	undo := e.saveFileUndo(path, appendMode)
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendMode {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return &types.ExecutionResult{Success: false, Error: fmt.Sprintf("保存文件失败：%v", err)}
+	}
+	_, err = f.WriteString(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return &types.ExecutionResult{Success: false, Error: fmt.Sprintf("保存文件失败：%v", err)}
+	}
+
+	rel := workspace.Rel(e.workspace, path)
+	log.Printf("Saved file %s (%d bytes, append: %v)", rel, len(content), appendMode)
+	if undo != nil {
+		OnUndo(ctx, "保存文件 "+rel, undo)
+	}
+	return &types.ExecutionResult{
+		Success: true,
+		Message: fmt.Sprintf("已保存文件 %s", rel),
+		Data:    rel,
+	}
+}
+
+// saveFileUndo captures what is needed to restore path to its state before a write
+//
+// A new file is removed, an appended one truncated to its old size and an
+// overwritten one restored from its old content, unless that is larger than
+// maxUndoFileBytes; then nil is returned and the write cannot be undone.
+func (e *Executor) saveFileUndo(path string, appendMode bool) UndoFunc {
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return func(ctx context.Context) error { return os.Remove(path) }
+	case err != nil || !info.Mode().IsRegular():
+		return nil
+	case appendMode:
+		size := info.Size()
+		return func(ctx context.Context) error { return os.Truncate(path, size) }
+	case info.Size() > maxUndoFileBytes:
+		log.Printf("Not keeping %s for undo, it is larger than %d bytes", path, maxUndoFileBytes)
+		return nil
+	}
+
+	previous, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return func(ctx context.Context) error { return os.WriteFile(path, previous, info.Mode().Perm()) }
+}
+
+// handleReadFile returns the beginning of a text file in the workspace
+func (e *Executor) handleReadFile(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+	path, failure := e.resolvePath(params, true)
+	if failure != nil {
+		return failure
+	}
+
+	content, truncated, err := readText(path, maxReadBytes)
+	if err != nil {
+		return &types.ExecutionResult{Success: false, Error: fmt.Sprintf("读取文件失败：%v", err)}
+	}
+
+	rel := workspace.Rel(e.workspace, path)
+	message := fmt.Sprintf("已读取文件 %s", rel)
+	if truncated {
+		message += fmt.Sprintf("（文件较大，仅显示前 %d KB）", maxReadBytes/1024)
+	}
+	return &types.ExecutionResult{Success: true, Message: message, Data: content}
+}
+
+// handleListDir lists a directory in the workspace, directories first
+func (e *Executor) handleListDir(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+	path, failure := e.resolvePath(params, false)
+	if failure != nil {
+		return failure
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return &types.ExecutionResult{Success: false, Error: fmt.Sprintf("读取目录失败：%v", err)}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].IsDir() && !entries[j].IsDir()
+	})
+
+	lines := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		lines = append(lines, name)
+	}
+
+	rel := workspace.Rel(e.workspace, path)
+	return &types.ExecutionResult{
+		Success: true,
+		Message: fmt.Sprintf("目录 %s 下共有 %d 项", rel, len(entries)),
+		Data:    strings.Join(lines, "\n"),
+	}
+}
+
+// handleSearchFiles finds files whose name or text content contains the query
+func (e *Executor) handleSearchFiles(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+	query, ok := params["query"].(string)
+	if !ok || strings.TrimSpace(query) == "" {
+		return paramError("query", "缺少搜索关键字")
+	}
+	dir, failure := e.resolvePath(params, false)
+	if failure != nil {
+		return failure
+	}
+
+	lowerQuery := strings.ToLower(query)
+	var matches []string
+	errLimit := errors.New("result limit reached")
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip unreadable entries
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// WalkDir doesn't follow symlinks, so the walk stays inside the workspace
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel := workspace.Rel(e.workspace, path)
+		if strings.Contains(strings.ToLower(d.Name()), lowerQuery) {
+			matches = append(matches, rel)
+		} else if line, ok := searchContent(path, lowerQuery); ok {
+			matches = append(matches, fmt.Sprintf("%s:%d: %s", rel, line.number, line.text))
+		}
+		if len(matches) >= maxSearchResults {
+			return errLimit
+		}
+		return nil
+	})
+	if err != nil && err != errLimit {
+		return &types.ExecutionResult{Success: false, Error: fmt.Sprintf("搜索失败：%v", err)}
+	}
+
+	if len(matches) == 0 {
+		return &types.ExecutionResult{Success: true, Message: fmt.Sprintf("没有找到包含「%s」的文件", query)}
+	}
+	message := fmt.Sprintf("找到 %d 个匹配「%s」的结果", len(matches), query)
+	if err == errLimit {
+		message = fmt.Sprintf("找到超过 %d 个匹配「%s」的结果，仅显示前 %d 个", maxSearchResults, query, maxSearchResults)
+	}
+	return &types.ExecutionResult{Success: true, Message: message, Data: strings.Join(matches, "\n")}
+}
+
+type matchedLine struct {
+	number int
+	text   string
+}
+
+// searchContent returns the first line of a small text file containing the lowercase query
+func searchContent(path, lowerQuery string) (matchedLine, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxSearchFileBytes {
+		return matchedLine{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !isText(data) {
+		return matchedLine{}, false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), maxSearchFileBytes)
+	for number := 1; scanner.Scan(); number++ {
+		if text := scanner.Text(); strings.Contains(strings.ToLower(text), lowerQuery) {
+			return matchedLine{number: number, text: truncateRunes(strings.TrimSpace(text), 100)}, true
+		}
+	}
+	return matchedLine{}, false
+}
+
+// handleSummarizeFile summarizes a text file, chunking large files before sending them to the model
+func (e *Executor) handleSummarizeFile(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+	path, failure := e.resolvePath(params, true)
+	if failure != nil {
+		return failure
+	}
+
+	content, truncated, err := readText(path, maxSummarizeBytes)
+	if err != nil {
+		return &types.ExecutionResult{Success: false, Error: fmt.Sprintf("读取文件失败：%v", err)}
+	}
+	if strings.TrimSpace(content) == "" {
+		return &types.ExecutionResult{Success: false, Error: "文件内容为空"}
+	}
+
+	rel := workspace.Rel(e.workspace, path)
+	chunks := chunkText(content, summaryChunkRunes)
+	log.Printf("Summarizing file %s in %d chunks (truncated: %v)", rel, len(chunks), truncated)
+
+	// Summarize each chunk separately, then combine the partial summaries
+	text := content
+	if len(chunks) > 1 {
+		partials := make([]string, 0, len(chunks))
+		for i, chunk := range chunks {
+			messages := []provider.Message{
+				{Role: "system", Content: "你是一个文档总结助手。请用简洁的中文总结下面这段文档片段的要点。"},
+				{Role: "user", Content: fmt.Sprintf("文件 %s 的第 %d/%d 段：\n\n%s", rel, i+1, len(chunks), chunk)},
+			}
+			partial, err := e.chat.ChatCompletion(ctx, messages)
+			if err != nil {
+				log.Printf("Failed to summarize chunk %d of %s: %v", i+1, rel, err)
+				return errorResult(fmt.Sprintf("文件总结失败：%v", err), err)
+			}
+			partials = append(partials, fmt.Sprintf("第 %d 段要点：\n%s", i+1, partial))
+		}
+		text = strings.Join(partials, "\n\n")
+	}
+
+	prompt := fmt.Sprintf("请总结文件 %s 的内容：\n\n%s", rel, text)
+	if len(chunks) > 1 {
+		prompt = fmt.Sprintf("下面是文件 %s 各段的要点，请整合成一份完整的总结：\n\n%s", rel, text)
+	}
+	if truncated {
+		prompt += fmt.Sprintf("\n\n（文件较大，只包含了前 %d MB 的内容）", maxSummarizeBytes/1024/1024)
+	}
+	messages := []provider.Message{
+		{Role: "system", Content: "你是一个文档总结助手。请用简洁的中文概括文档的主要内容和关键信息。"},
+		{Role: "user", Content: prompt},
+	}
+
+	summary, err := provider.CompleteStreaming(ctx, e.chat, messages, "summarize_file")
+	if err != nil {
+		log.Printf("Failed to summarize %s: %v", rel, err)
+		return errorResult(fmt.Sprintf("文件总结失败：%v", err), err)
+	}
+
+	return &types.ExecutionResult{Success: true, Message: summary, Data: summary}
+}
+
+// readText reads at most limit bytes of a text file, reporting whether it was cut off
+func readText(path string, limit int) (string, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", false, err
+	}
+	if info.IsDir() {
+		return "", false, fmt.Errorf("%s 是目录", filepath.Base(path))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(f, int64(limit)+1))
+	if err != nil {
+		return "", false, err
+	}
+	truncated := len(data) > limit
+	if truncated {
+		data = data[:limit]
+		// Don't cut a multi-byte character in half
+		for i := 0; i < utf8.UTFMax-1 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+	if !isText(data) {
+		return "", false, fmt.Errorf("不是文本文件")
+	}
+	return string(data), truncated, nil
+}
+
+// isText reports whether data looks like UTF-8 text
+func isText(data []byte) bool {
+	return !bytes.ContainsRune(data, 0) && utf8.Valid(data)
+}
+
+// chunkText splits text into chunks of at most size characters, preferring to break at newlines
+func chunkText(text string, size int) []string {
+	runes := []rune(text)
+	var chunks []string
+	for len(runes) > size {
+		cut := size
+		// Break after the last newline in the second half of the chunk, if any
+		for i := size - 1; i >= size/2; i-- {
+			if runes[i] == '\n' {
+				cut = i + 1
+				break
+			}
+		}
+		chunks = append(chunks, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+	if len(runes) > 0 {
+		chunks = append(chunks, string(runes))
+	}
+	return chunks
+}
+
+// truncateRunes shortens s to at most n characters
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
@@ -0,0 +1,119 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileActions(t *testing.T) {
+	root := t.TempDir()
+	exec := NewExecutor(&fakeChat{}, WithWorkspace(root))
+	ctx := context.Background()
+
+	result := exec.handleSaveFile(ctx, map[string]interface{}{"path": "notes/todo.txt", "content": "买牛奶\n"})
+	if !result.Success || result.Data != "notes/todo.txt" {
+		t.Fatalf("save_file failed: %+v", result)
+	}
+	result = exec.handleSaveFile(ctx, map[string]interface{}{"path": "notes/todo.txt", "content": "写周报\n", "append": true})
+	if !result.Success {
+		t.Fatalf("save_file append failed: %+v", result)
+	}
+
+	result = exec.handleReadFile(ctx, map[string]interface{}{"path": "notes/todo.txt"})
+	if !result.Success || result.Data != "买牛奶\n写周报\n" {
+		t.Errorf("read_file = %+v", result)
+	}
+
+	os.WriteFile(filepath.Join(root, "report.md"), []byte("# 周报\n本周完成了会议纪要整理"), 0644)
+	result = exec.handleListDir(ctx, map[string]interface{}{})
+	if !result.Success || result.Data != "notes/\nreport.md" {
+		t.Errorf("list_dir = %+v", result)
+	}
+
+	result = exec.handleSearchFiles(ctx, map[string]interface{}{"query": "会议纪要"})
+	if !result.Success || result.Data != "report.md:2: 本周完成了会议纪要整理" {
+		t.Errorf("search_files by content = %+v", result)
+	}
+	result = exec.handleSearchFiles(ctx, map[string]interface{}{"query": "TODO", "path": "notes"})
+	if !result.Success || result.Data != "notes/todo.txt" {
+		t.Errorf("search_files by name = %+v", result)
+	}
+
+	// The executor re-checks paths even though the security layer rejects them first
+	for _, params := range []map[string]interface{}{
+		{"path": "../outside.txt", "content": "x"},
+		{"path": "/etc/passwd", "content": "x"},
+		{"content": "x"},
+	} {
+		if result := exec.handleSaveFile(ctx, params); result.Success {
+			t.Errorf("save_file(%v) should fail", params)
+		}
+	}
+	if result := NewExecutor(&fakeChat{}).handleReadFile(ctx, map[string]interface{}{"path": "a.txt"}); result.Success {
+		t.Error("File actions should fail without a workspace")
+	}
+}
+
+func TestReadFileLimits(t *testing.T) {
+	root := t.TempDir()
+	exec := NewExecutor(&fakeChat{}, WithWorkspace(root))
+	ctx := context.Background()
+
+	// A multi-byte character straddles the read limit
+	os.WriteFile(filepath.Join(root, "big.txt"), []byte(strings.Repeat("a", maxReadBytes-1)+"中文"), 0644)
+	result := exec.handleReadFile(ctx, map[string]interface{}{"path": "big.txt"})
+	if !result.Success || result.Data != strings.Repeat("a", maxReadBytes-1) || !strings.Contains(result.Message, "仅显示") {
+		t.Errorf("read_file of a large file: success=%v len=%d message=%s", result.Success, len(result.Data), result.Message)
+	}
+
+	os.WriteFile(filepath.Join(root, "image.bin"), []byte{0x89, 'P', 'N', 'G', 0, 0, 1}, 0644)
+	if result := exec.handleReadFile(ctx, map[string]interface{}{"path": "image.bin"}); result.Success {
+		t.Error("read_file should reject binary files")
+	}
+}
+
+func TestSummarizeFileChunks(t *testing.T) {
+	root := t.TempDir()
+	line := strings.Repeat("文", 99) + "\n"
+
+	tests := []struct {
+		name      string
+		content   string
+		wantCalls int
+	}{
+		{"small file in one call", "短文本", 1},
+		{"large file chunked", strings.Repeat(line, 150), 4}, // 15000 characters: 3 chunks plus the combined summary
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.WriteFile(filepath.Join(root, "doc.txt"), []byte(tt.content), 0644)
+			chat := &fakeChat{reply: "总结"}
+			exec := NewExecutor(chat, WithWorkspace(root))
+
+			result := exec.handleSummarizeFile(context.Background(), map[string]interface{}{"path": "doc.txt"})
+			if !result.Success || result.Message != "总结" {
+				t.Fatalf("summarize_file = %+v", result)
+			}
+			if chat.calls != tt.wantCalls {
+				t.Errorf("Made %d LLM calls, want %d", chat.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestChunkText(t *testing.T) {
+	text := strings.Repeat("a", 7) + "\n" + strings.Repeat("b", 5)
+	chunks := chunkText(text, 10)
+	if len(chunks) != 2 || chunks[0] != strings.Repeat("a", 7)+"\n" || chunks[1] != strings.Repeat("b", 5) {
+		t.Errorf("chunkText() = %q, want a break after the newline", chunks)
+	}
+
+	chunks = chunkText(strings.Repeat("中", 25), 10)
+	if len(chunks) != 3 || chunks[2] != strings.Repeat("中", 5) {
+		t.Errorf("chunkText() without newlines = %q", chunks)
+	}
+}
@@ -0,0 +1,123 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// ParamCheck validates the parameters of a step once references to other steps' results are resolved
+type ParamCheck func(action string, params map[string]interface{}) error
+
+// WithParamCheck checks steps whose parameters refer to other steps again after resolving the references
+//
+// The plan is validated before it runs, when those parameters still contain
+// the references rather than the values that will be used.
+func WithParamCheck(check ParamCheck) Option {
+	return func(e *Executor) { e.paramCheck = check }
+}
+
+// executeGraph runs a plan whose steps declare dependencies
+//
+// Each step starts as soon as the steps it depends on or refers to have
+// succeeded, so independent steps run concurrently. A step whose dependency
+// failed is skipped. The result is that of the first failed step in plan
+// order, or the messages of all steps in plan order.
+func (e *Executor) executeGraph(ctx context.Context, plan *types.TaskPlan) *types.ExecutionResult {
+	deps, err := plan.Dependencies()
+	if err != nil {
+		return &types.ExecutionResult{
+			Success: false,
+			Error:   fmt.Sprintf("执行计划无效：%v", err),
+		}
+	}
+
+	index := make(map[string]int, len(plan.Steps))
+	for i := range plan.Steps {
+		index[plan.StepID(i)] = i
+	}
+
+	// results[i] is written before done[i] is closed and only read after, nil for skipped steps
+	results := make([]*types.ExecutionResult, len(plan.Steps))
+	done := make([]chan struct{}, len(plan.Steps))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	for i := range plan.Steps {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer close(done[i])
+
+			for _, j := range deps[i] {
+				<-done[j]
+				if results[j] == nil || !results[j].Success {
+					log.Printf("Skipping step %s: step %s did not succeed", plan.StepID(i), plan.StepID(j))
+					return
+				}
+			}
+
+			lookup := func(id, field string) string {
+				result := results[index[id]]
+				if field == "message" {
+					return result.Message
+				}
+				return result.Data
+			}
+			results[i] = e.runStep(ctx, plan.StepID(i), plan.Steps[i], lookup)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if result != nil && !result.Success {
+			return result
+		}
+	}
+	var messages []string
+	for _, result := range results {
+		if result != nil && result.Message != "" {
+			messages = append(messages, result.Message)
+		}
+	}
+
+	return &types.ExecutionResult{
+		Success: true,
+		Message: strings.Join(messages, "\n"),
+	}
+}
+
+// runStep resolves the step's references with lookup, checks the resolved parameters and runs the action
+func (e *Executor) runStep(ctx context.Context, id string, step types.TaskStep, lookup func(id, field string) string) *types.ExecutionResult {
+	log.Printf("Executing step %s: %s", id, step.Action)
+
+	handler, exists := e.handlers[step.Action]
+	if !exists {
+		return &types.ExecutionResult{
+			Success: false,
+			Error:   fmt.Sprintf("未知的操作类型：%s", step.Action),
+		}
+	}
+
+	params := step.Parameters
+	if len(step.References()) > 0 {
+		params = types.ResolveReferences(params, lookup)
+		if e.paramCheck != nil {
+			if err := e.paramCheck(step.Action, params); err != nil {
+				log.Printf("Step %s failed the check after resolving references: %v", id, err)
+				return &types.ExecutionResult{
+					Success: false,
+					Error:   fmt.Sprintf("出于安全考虑，无法执行该操作：%s", err.Error()),
+				}
+			}
+		}
+	}
+
+	return e.runAction(ctx, step.Action, handler, params)
+}
@@ -0,0 +1,177 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// recordingHandler returns a handler that records its parameters and succeeds with data
+func recordingHandler(mu *sync.Mutex, calls map[string]map[string]interface{}, name, data string) ActionHandler {
+	return func(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+		mu.Lock()
+		calls[name] = params
+		mu.Unlock()
+		return &types.ExecutionResult{Success: true, Message: name + " done", Data: data}
+	}
+}
+
+func TestExecuteDataFlow(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]map[string]interface{})
+	e := newTestExecutor()
+	e.RegisterHandler("save", recordingHandler(&mu, calls, "save", "notes.md"))
+
+	plan := &types.TaskPlan{Steps: []types.TaskStep{
+		{ID: "save", Action: "save", Parameters: map[string]interface{}{
+			"path":    "notes.md",
+			"content": "{{steps.gen.data}}",
+			"meta":    map[string]interface{}{"tags": []interface{}{"{{ steps.gen.message }}"}},
+		}},
+		{ID: "gen", Action: "generate_text", Parameters: map[string]interface{}{"topic": "秋天"}},
+	}}
+
+	result := e.Execute(context.Background(), plan)
+	if !result.Success {
+		t.Fatalf("Execute() = %+v", result)
+	}
+	if calls["save"]["content"] != "generated text" {
+		t.Errorf("content = %v, want the generated text", calls["save"]["content"])
+	}
+	tags := calls["save"]["meta"].(map[string]interface{})["tags"].([]interface{})
+	if tags[0] != "generated text" {
+		t.Errorf("Nested reference = %v, want the generated message", tags[0])
+	}
+	// The plan itself is left unresolved, and messages keep plan order
+	if plan.Steps[0].Parameters["content"] != "{{steps.gen.data}}" {
+		t.Errorf("Plan parameters modified: %v", plan.Steps[0].Parameters)
+	}
+	if result.Message != "save done\ngenerated text" {
+		t.Errorf("Message = %q", result.Message)
+	}
+}
+
+func TestExecuteConcurrently(t *testing.T) {
+	e := newTestExecutor()
+
+	// Each step waits until both have started, so they only finish if run concurrently
+	var started sync.WaitGroup
+	started.Add(2)
+	wait := func(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+		started.Done()
+		ch := make(chan struct{})
+		go func() { started.Wait(); close(ch) }()
+		select {
+		case <-ch:
+			return &types.ExecutionResult{Success: true, Message: params["name"].(string)}
+		case <-time.After(2 * time.Second):
+			return &types.ExecutionResult{Success: false, Error: "not run concurrently"}
+		}
+	}
+	e.RegisterHandler("wait", wait)
+
+	var mu sync.Mutex
+	var order []string
+	e.RegisterHandler("last", func(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+		mu.Lock()
+		order = append(order, "last")
+		mu.Unlock()
+		return &types.ExecutionResult{Success: true, Message: "last"}
+	})
+
+	result := e.Execute(context.Background(), &types.TaskPlan{Steps: []types.TaskStep{
+		{ID: "a", Action: "wait", Parameters: map[string]interface{}{"name": "a"}},
+		{ID: "b", Action: "wait", Parameters: map[string]interface{}{"name": "b"}},
+		{ID: "c", Action: "last", DependsOn: []string{"a", "b"}},
+	}})
+	if !result.Success || result.Message != "a\nb\nlast" {
+		t.Errorf("Execute() = %+v", result)
+	}
+}
+
+func TestExecuteSkipsDependentsOfFailedSteps(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]map[string]interface{})
+	e := newTestExecutor()
+	e.RegisterHandler("fail", func(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+		return &types.ExecutionResult{Success: false, Error: "失败了"}
+	})
+	e.RegisterHandler("after", recordingHandler(&mu, calls, "after", ""))
+	e.RegisterHandler("other", recordingHandler(&mu, calls, "other", ""))
+
+	result := e.Execute(context.Background(), &types.TaskPlan{Steps: []types.TaskStep{
+		{ID: "first", Action: "fail"},
+		{ID: "second", Action: "after", Parameters: map[string]interface{}{"x": "{{steps.first.data}}"}},
+		{ID: "third", Action: "after", DependsOn: []string{"second"}},
+		{ID: "independent", Action: "other"},
+	}})
+	if result.Success || result.Error != "失败了" {
+		t.Errorf("Execute() = %+v, want the failed step's result", result)
+	}
+	if _, ran := calls["after"]; ran {
+		t.Error("Dependent step ran after its dependency failed")
+	}
+	if _, ran := calls["other"]; !ran {
+		t.Error("Independent step did not run")
+	}
+}
+
+func TestExecuteParamCheck(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]map[string]interface{})
+	e := NewExecutor(&fakeChat{reply: "rm -rf /"}, WithParamCheck(func(action string, params map[string]interface{}) error {
+		if strings.Contains(params["command"].(string), "rm -rf") {
+			return errors.New("检测到危险命令")
+		}
+		return nil
+	}))
+	e.RegisterHandler("run", recordingHandler(&mu, calls, "run", ""))
+
+	result := e.Execute(context.Background(), &types.TaskPlan{Steps: []types.TaskStep{
+		{ID: "gen", Action: "generate_text", Parameters: map[string]interface{}{"topic": "命令"}},
+		{ID: "run", Action: "run", Parameters: map[string]interface{}{"command": "{{steps.gen.data}}"}},
+	}})
+	if result.Success || !strings.Contains(result.Error, "危险命令") {
+		t.Errorf("Execute() = %+v, want the resolved command rejected", result)
+	}
+	if _, ran := calls["run"]; ran {
+		t.Error("Rejected step ran")
+	}
+}
+
+func TestExecuteInvalidPlans(t *testing.T) {
+	e := newTestExecutor()
+	clarify := func(id string, deps ...string) types.TaskStep {
+		return types.TaskStep{ID: id, Action: "clarify", Parameters: map[string]interface{}{"message": "?"}, DependsOn: deps}
+	}
+
+	tests := []struct {
+		name  string
+		steps []types.TaskStep
+		want  string
+	}{
+		{"duplicate id", []types.TaskStep{clarify("a"), clarify("a")}, "duplicate"},
+		{"unknown dependency", []types.TaskStep{clarify("a", "missing")}, "unknown step"},
+		{"self dependency", []types.TaskStep{clarify("a", "a")}, "itself"},
+		{"cycle", []types.TaskStep{clarify("a", "c"), clarify("b", "a"), clarify("c", "b")}, "cycle"},
+		{"unknown field", []types.TaskStep{clarify("a"), {ID: "b", Action: "clarify", Parameters: map[string]interface{}{"message": "{{steps.a.stdout}}"}}}, "unknown field"},
+		{"invalid id", []types.TaskStep{clarify("a b")}, "invalid id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &types.TaskPlan{Steps: tt.steps}
+			if err := plan.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+			if result := e.Execute(context.Background(), plan); result.Success || !strings.Contains(result.Error, "执行计划无效") {
+				t.Errorf("Execute() = %+v, want an invalid plan error", result)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/deca/voicepilot-eino/pkg/types"
+	"github.com/goccy/go-yaml"
+)
+
+// maxBackoff caps the delay between two attempts of an action
+const maxBackoff = 30 * time.Second
+
+// Policy controls how long an action may run and how often it is retried
+type Policy struct {
+	Timeout    time.Duration // limit for each attempt, 0 for none
+	MaxRetries int           // retries after the first attempt, only for retryable failures
+	Backoff    time.Duration // delay before the first retry, doubled for each further one
+	// Idempotent actions can safely run twice, so an attempt that timed out,
+	// and may have done its work anyway, is retried. Timeouts of other actions are fatal.
+	Idempotent bool
+}
+
+// backoff returns the delay after the given failed attempt, counted from 0
+func (p Policy) backoff(attempt int) time.Duration {
+	delay := p.Backoff
+	for i := 0; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
+
+// WithPolicies sets the timeout and retry policy of every action, with overrides for some of them
+func WithPolicies(defaults Policy, overrides map[string]Policy) Option {
+	return func(e *Executor) {
+		e.defaultPolicy = defaults
+		e.policies = overrides
+	}
+}
+
+// policyFor returns the policy of an action
+func (e *Executor) policyFor(action string) Policy {
+	if p, ok := e.policies[action]; ok {
+		return p
+	}
+	return e.defaultPolicy
+}
+
+// PolicyFile is the file format of per-action policies, JSON or YAML
+//
+//	actions:
+//	  generate_text:
+//	    timeout_seconds: 180
+//	    max_retries: 3
+//	    backoff_ms: 1000
+//	    idempotent: true
+//
+// Fields left out take the default policy's value.
+type PolicyFile struct {
+	Actions map[string]PolicyDefinition `json:"actions" yaml:"actions"`
+}
+
+// PolicyDefinition is the policy of one action in a PolicyFile
+type PolicyDefinition struct {
+	TimeoutSeconds *int  `json:"timeout_seconds,omitempty" yaml:"timeout_seconds,omitempty"`
+	MaxRetries     *int  `json:"max_retries,omitempty" yaml:"max_retries,omitempty"`
+	BackoffMs      *int  `json:"backoff_ms,omitempty" yaml:"backoff_ms,omitempty"`
+	Idempotent     *bool `json:"idempotent,omitempty" yaml:"idempotent,omitempty"`
+}
+
+// LoadPolicies reads per-action policies from a JSON or YAML file, filling unset fields from defaults
+func LoadPolicies(path string, defaults Policy) (map[string]Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read action policies: %w", err)
+	}
+
+	var file PolicyFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse action policies: %w", err)
+	}
+
+	policies := make(map[string]Policy, len(file.Actions))
+	for action, def := range file.Actions {
+		p := defaults
+		if def.TimeoutSeconds != nil {
+			p.Timeout = time.Duration(*def.TimeoutSeconds) * time.Second
+		}
+		if def.MaxRetries != nil {
+			p.MaxRetries = *def.MaxRetries
+		}
+		if def.BackoffMs != nil {
+			p.Backoff = time.Duration(*def.BackoffMs) * time.Millisecond
+		}
+		if def.Idempotent != nil {
+			p.Idempotent = *def.Idempotent
+		}
+		if p.Timeout < 0 || p.MaxRetries < 0 || p.Backoff < 0 {
+			return nil, fmt.Errorf("invalid policy for action %s: values must not be negative", action)
+		}
+		policies[action] = p
+	}
+	return policies, nil
+}
+
+// attempt runs the handler once within the policy's timeout
+//
+// A failure without an error class is fatal; one caused by the timeout is
+// retryable only if the policy marks the action idempotent.
+func attempt(ctx context.Context, handler ActionHandler, params map[string]interface{}, policy Policy) *types.ExecutionResult {
+	attemptCtx := ctx
+	if policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		defer cancel()
+	}
+
+	result := handler(attemptCtx, params)
+	if result.Success {
+		return result
+	}
+	if attemptCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		message := fmt.Sprintf("操作超时（超过 %d 秒）", int(policy.Timeout.Seconds()))
+		class := types.ErrorRetryable
+		if !policy.Idempotent {
+			message += "，可能已部分执行"
+			class = types.ErrorFatal
+		}
+		return (&ActionError{Class: class, Message: message, Err: attemptCtx.Err()}).Result()
+	}
+	if result.ErrorClass == "" {
+		result.ErrorClass = types.ErrorFatal
+	}
+	return result
+}
+
+// sleep waits for d, returning false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
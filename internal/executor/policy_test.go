@@ -0,0 +1,133 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+func TestRunActionRetries(t *testing.T) {
+	e := NewExecutor(nil, WithPolicies(Policy{MaxRetries: 2, Backoff: time.Millisecond}, map[string]Policy{
+		"once": {MaxRetries: 0},
+	}))
+
+	calls := map[string]int{}
+	flaky := func(action string, failures int, class types.ErrorClass) {
+		e.RegisterHandler(action, func(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+			calls[action]++
+			if calls[action] <= failures {
+				if class == types.ErrorRetryable {
+					return errorResult("模型服务繁忙", &provider.StatusError{StatusCode: 429})
+				}
+				return &types.ExecutionResult{Success: false, Error: "失败"}
+			}
+			return &types.ExecutionResult{Success: true, Message: "完成"}
+		})
+	}
+	flaky("flaky", 2, types.ErrorRetryable)
+	flaky("broken", 5, types.ErrorRetryable)
+	flaky("fatal", 1, types.ErrorFatal)
+	flaky("once", 1, types.ErrorRetryable)
+
+	tests := []struct {
+		action  string
+		success bool
+		calls   int
+		class   types.ErrorClass
+	}{
+		{"flaky", true, 3, ""},
+		{"broken", false, 3, types.ErrorRetryable},
+		{"fatal", false, 1, types.ErrorFatal},
+		{"once", false, 1, types.ErrorRetryable},
+	}
+
+	for _, tt := range tests {
+		result := e.Execute(context.Background(), &types.TaskPlan{Steps: []types.TaskStep{{Action: tt.action}}})
+		if result.Success != tt.success || result.ErrorClass != tt.class || calls[tt.action] != tt.calls {
+			t.Errorf("%s: result = %+v after %d calls, want success %v, class %q after %d calls",
+				tt.action, result, calls[tt.action], tt.success, tt.class, tt.calls)
+		}
+	}
+}
+
+func TestRunActionTimeout(t *testing.T) {
+	policy := Policy{Timeout: 20 * time.Millisecond, MaxRetries: 1, Backoff: time.Millisecond}
+	idempotent := policy
+	idempotent.Idempotent = true
+	e := NewExecutor(nil, WithPolicies(policy, map[string]Policy{"slow_read": idempotent}))
+
+	calls := map[string]int{}
+	for _, action := range []string{"slow_read", "slow_write"} {
+		action := action
+		e.RegisterHandler(action, func(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+			calls[action]++
+			<-ctx.Done()
+			return &types.ExecutionResult{Success: false, Error: ctx.Err().Error()}
+		})
+	}
+
+	result := e.Execute(context.Background(), &types.TaskPlan{Steps: []types.TaskStep{{Action: "slow_read"}}})
+	if result.Success || result.ErrorClass != types.ErrorRetryable || result.Action != "slow_read" || calls["slow_read"] != 2 {
+		t.Errorf("Execute() = %+v after %d calls, want a retryable timeout after 2 calls", result, calls["slow_read"])
+	}
+
+	// The action may have done its work before timing out, so it isn't run again
+	result = e.Execute(context.Background(), &types.TaskPlan{Steps: []types.TaskStep{{Action: "slow_write"}}})
+	if result.Success || result.ErrorClass != types.ErrorFatal || calls["slow_write"] != 1 {
+		t.Errorf("Execute() = %+v after %d calls, want a fatal timeout after 1 call", result, calls["slow_write"])
+	}
+}
+
+func TestPolicyBackoff(t *testing.T) {
+	p := Policy{Backoff: 500 * time.Millisecond}
+	want := []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second}
+	for i, w := range want {
+		if got := p.backoff(i); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i, got, w)
+		}
+	}
+	if got := p.backoff(20); got != maxBackoff {
+		t.Errorf("backoff(20) = %v, want %v", got, maxBackoff)
+	}
+}
+
+func TestLoadPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "actions.yaml")
+	os.WriteFile(path, []byte(`actions:
+  generate_text:
+    timeout_seconds: 180
+    idempotent: true
+  open_app:
+    max_retries: 0
+    backoff_ms: 100
+`), 0644)
+
+	defaults := Policy{Timeout: time.Minute, MaxRetries: 2, Backoff: 500 * time.Millisecond}
+	policies, err := LoadPolicies(path, defaults)
+	if err != nil {
+		t.Fatalf("LoadPolicies failed: %v", err)
+	}
+
+	want := map[string]Policy{
+		"generate_text": {Timeout: 3 * time.Minute, MaxRetries: 2, Backoff: 500 * time.Millisecond, Idempotent: true},
+		"open_app":      {Timeout: time.Minute, MaxRetries: 0, Backoff: 100 * time.Millisecond},
+	}
+	if len(policies) != len(want) {
+		t.Fatalf("LoadPolicies() = %+v, want %+v", policies, want)
+	}
+	for action, p := range want {
+		if policies[action] != p {
+			t.Errorf("policy of %s = %+v, want %+v", action, policies[action], p)
+		}
+	}
+
+	os.WriteFile(path, []byte("actions:\n  open_app:\n    max_retries: -1\n"), 0644)
+	if _, err := LoadPolicies(path, defaults); err == nil {
+		t.Error("LoadPolicies accepted a negative retry count")
+	}
+}
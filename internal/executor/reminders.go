@@ -0,0 +1,216 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/scheduler"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// WithScheduler backs the reminder actions with s; without it they fail
+func WithScheduler(s *scheduler.Scheduler) Option {
+	return func(e *Executor) { e.scheduler = s }
+}
+
+type sessionIDKey struct{}
+
+// WithSessionID returns a context telling actions which session they run for
+func WithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionIDKey{}, sessionID)
+}
+
+// SessionIDFromContext returns the session ID attached to ctx, if any
+func SessionIDFromContext(ctx context.Context) string {
+	sessionID, _ := ctx.Value(sessionIDKey{}).(string)
+	return sessionID
+}
+
+// registerReminderActions registers the reminder actions
+func (e *Executor) registerReminderActions() {
+	e.RegisterAction(ActionSpec{
+		Name:        "set_reminder",
+		Description: "在指定时间提醒用户",
+		Parameters: objectSchema(map[string]interface{}{
+			"text": stringParam("提醒内容", "喝水", "开周会"),
+			"time": stringParam("提醒时间，保留用户的原话即可，也可以是具体的日期时间", "10分钟后", "明天上午9点", "2026-10-17 15:00"),
+		}, "text", "time"),
+	}, e.handleSetReminder)
+	e.RegisterAction(ActionSpec{
+		Name:        "list_reminders",
+		Description: "列出当前会话中尚未触发的提醒",
+		Parameters:  objectSchema(map[string]interface{}{}),
+	}, e.handleListReminders)
+	e.RegisterAction(ActionSpec{
+		Name:        "cancel_reminder",
+		Description: "取消提醒，可按编号或提醒内容指定",
+		Parameters: objectSchema(map[string]interface{}{
+			"id":   stringParam("提醒编号"),
+			"text": stringParam("要取消的提醒内容中的关键字", "喝水"),
+		}),
+	}, e.handleCancelReminder)
+}
+
+// reminderSession returns the session the reminder actions apply to, or a failure result
+func (e *Executor) reminderSession(ctx context.Context) (string, *types.ExecutionResult) {
+	if e.scheduler == nil {
+		return "", &types.ExecutionResult{Success: false, Error: "提醒功能未启用"}
+	}
+	sessionID := SessionIDFromContext(ctx)
+	if sessionID == "" {
+		return "", &types.ExecutionResult{Success: false, Error: "无法确定提醒所属的会话"}
+	}
+	return sessionID, nil
+}
+
+// handleSetReminder schedules a reminder for the current session
+func (e *Executor) handleSetReminder(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+	sessionID, failure := e.reminderSession(ctx)
+	if failure != nil {
+		return failure
+	}
+
+	text, _ := params["text"].(string)
+	if strings.TrimSpace(text) == "" {
+		return paramError("text", "缺少提醒内容")
+	}
+	expr, _ := params["time"].(string)
+	if strings.TrimSpace(expr) == "" {
+		return paramError("time", "缺少提醒时间")
+	}
+
+	now := time.Now()
+	fireAt, err := scheduler.ParseTime(expr, now)
+	if errors.Is(err, scheduler.ErrPast) {
+		return paramError("time", fmt.Sprintf("提醒时间已经过去：%s", expr))
+	}
+	if err != nil {
+		return paramError("time", fmt.Sprintf("无法识别提醒时间：%s", expr))
+	}
+
+	job, err := e.scheduler.Add(sessionID, strings.TrimSpace(text), fireAt)
+	if err != nil {
+		log.Printf("Failed to schedule reminder: %v", err)
+		return &types.ExecutionResult{Success: false, Error: "设置提醒失败"}
+	}
+
+	log.Printf("Scheduled reminder %s for session %s at %s", job.ID, sessionID, fireAt.Format(time.RFC3339))
+	OnUndo(ctx, "设置提醒 "+job.Text, func(ctx context.Context) error {
+		if _, err := e.scheduler.Cancel(sessionID, job.ID); err != nil && !errors.Is(err, scheduler.ErrNotFound) {
+			return err
+		}
+		return nil
+	})
+	return &types.ExecutionResult{
+		Success: true,
+		Message: fmt.Sprintf("已设置提醒：%s %s", formatWhen(fireAt, now), job.Text),
+		Data:    job.ID,
+	}
+}
+
+// handleListReminders lists the pending reminders of the current session
+func (e *Executor) handleListReminders(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+	sessionID, failure := e.reminderSession(ctx)
+	if failure != nil {
+		return failure
+	}
+
+	jobs := e.scheduler.List(sessionID)
+	if len(jobs) == 0 {
+		return &types.ExecutionResult{Success: true, Message: "当前没有待触发的提醒"}
+	}
+
+	now := time.Now()
+	lines := make([]string, len(jobs))
+	for i, job := range jobs {
+		lines[i] = fmt.Sprintf("%d. %s %s（编号 %s）", i+1, formatWhen(job.FireAt, now), job.Text, job.ID)
+	}
+	return &types.ExecutionResult{
+		Success: true,
+		Message: fmt.Sprintf("共有 %d 个待触发的提醒", len(jobs)),
+		Data:    strings.Join(lines, "\n"),
+	}
+}
+
+// handleCancelReminder cancels a reminder by ID, or all reminders whose text contains a keyword
+func (e *Executor) handleCancelReminder(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+	sessionID, failure := e.reminderSession(ctx)
+	if failure != nil {
+		return failure
+	}
+
+	id, _ := params["id"].(string)
+	keyword, _ := params["text"].(string)
+	id, keyword = strings.TrimSpace(id), strings.TrimSpace(keyword)
+
+	var ids []string
+	switch {
+	case id != "":
+		ids = []string{id}
+	case keyword != "":
+		for _, job := range e.scheduler.List(sessionID) {
+			if strings.Contains(strings.ToLower(job.Text), strings.ToLower(keyword)) {
+				ids = append(ids, job.ID)
+			}
+		}
+	default:
+		return paramError("text", "请说明要取消哪个提醒")
+	}
+
+	var cancelled []string
+	for _, id := range ids {
+		job, err := e.scheduler.Cancel(sessionID, id)
+		if errors.Is(err, scheduler.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to cancel reminder %s: %v", id, err)
+			return &types.ExecutionResult{Success: false, Error: "取消提醒失败"}
+		}
+		cancelled = append(cancelled, job.Text)
+		OnUndo(ctx, "取消提醒 "+job.Text, func(ctx context.Context) error {
+			if !job.FireAt.After(time.Now()) {
+				return scheduler.ErrPast
+			}
+			_, err := e.scheduler.Add(sessionID, job.Text, job.FireAt)
+			return err
+		})
+	}
+	if len(cancelled) == 0 {
+		return &types.ExecutionResult{Success: false, Error: "没有找到要取消的提醒"}
+	}
+
+	return &types.ExecutionResult{
+		Success: true,
+		Message: fmt.Sprintf("已取消提醒：%s", strings.Join(cancelled, "、")),
+	}
+}
+
+// formatWhen formats t for speech, e.g. "今天 15:04", "明天 09:00" or "10月20日 08:30"
+func formatWhen(t, now time.Time) string {
+	return formatDay(t, now) + " " + t.Format("15:04")
+}
+
+// formatDay names the day of t relative to now, e.g. "今天", "后天", "10月20日" or "2027年1月2日"
+func formatDay(t, now time.Time) string {
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	switch days := int(t.Sub(today).Hours() / 24); {
+	case t.Before(today):
+		return t.Format("1月2日")
+	case days == 0:
+		return "今天"
+	case days == 1:
+		return "明天"
+	case days == 2:
+		return "后天"
+	case t.Year() != now.Year():
+		return t.Format("2006年1月2日")
+	default:
+		return t.Format("1月2日")
+	}
+}
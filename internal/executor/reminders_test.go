@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/scheduler"
+)
+
+func TestReminderActions(t *testing.T) {
+	s, err := scheduler.New("")
+	if err != nil {
+		t.Fatalf("scheduler.New() error = %v", err)
+	}
+	e := NewExecutor(nil, WithScheduler(s))
+	ctx := WithSessionID(context.Background(), "s1")
+
+	result := e.handleSetReminder(ctx, map[string]interface{}{"text": "喝水", "time": "10分钟后"})
+	if !result.Success || !strings.Contains(result.Message, "喝水") {
+		t.Fatalf("set_reminder = %+v", result)
+	}
+	jobs := s.List("s1")
+	if len(jobs) != 1 || jobs[0].ID != result.Data {
+		t.Fatalf("Scheduled jobs = %+v, want the new reminder", jobs)
+	}
+	if d := time.Until(jobs[0].FireAt); d < 9*time.Minute || d > 10*time.Minute {
+		t.Errorf("Reminder due in %v, want about 10 minutes", d)
+	}
+	e.handleSetReminder(ctx, map[string]interface{}{"text": "开周会", "time": "明天上午9点"})
+
+	result = e.handleListReminders(ctx, nil)
+	if !result.Success || !strings.Contains(result.Data, "喝水") || !strings.Contains(result.Data, "明天 09:00 开周会") {
+		t.Errorf("list_reminders = %+v", result)
+	}
+
+	// Other sessions neither see nor cancel the reminders
+	other := WithSessionID(context.Background(), "s2")
+	if result := e.handleListReminders(other, nil); !result.Success || result.Data != "" {
+		t.Errorf("list_reminders for another session = %+v", result)
+	}
+	if result := e.handleCancelReminder(other, map[string]interface{}{"id": jobs[0].ID}); result.Success {
+		t.Errorf("Cancelled another session's reminder: %+v", result)
+	}
+
+	result = e.handleCancelReminder(ctx, map[string]interface{}{"text": "周会"})
+	if !result.Success || !strings.Contains(result.Message, "开周会") {
+		t.Errorf("cancel_reminder by text = %+v", result)
+	}
+	result = e.handleCancelReminder(ctx, map[string]interface{}{"id": jobs[0].ID})
+	if !result.Success || len(s.List("s1")) != 0 {
+		t.Errorf("cancel_reminder by id = %+v, remaining %+v", result, s.List("s1"))
+	}
+}
+
+func TestSetReminderErrors(t *testing.T) {
+	s, _ := scheduler.New("")
+	ctx := WithSessionID(context.Background(), "s1")
+
+	tests := []struct {
+		name   string
+		e      *Executor
+		ctx    context.Context
+		params map[string]interface{}
+		want   string
+	}{
+		{"no scheduler", NewExecutor(nil), ctx, map[string]interface{}{"text": "喝水", "time": "10分钟后"}, "未启用"},
+		{"no session", NewExecutor(nil, WithScheduler(s)), context.Background(), map[string]interface{}{"text": "喝水", "time": "10分钟后"}, "会话"},
+		{"missing text", NewExecutor(nil, WithScheduler(s)), ctx, map[string]interface{}{"time": "10分钟后"}, "缺少提醒内容"},
+		{"unknown time", NewExecutor(nil, WithScheduler(s)), ctx, map[string]interface{}{"text": "喝水", "time": "等会儿"}, "无法识别"},
+		{"past time", NewExecutor(nil, WithScheduler(s)), ctx, map[string]interface{}{"text": "喝水", "time": "2020-01-01 09:00"}, "已经过去"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.e.handleSetReminder(tt.ctx, tt.params)
+			if result.Success || !strings.Contains(result.Error, tt.want) {
+				t.Errorf("set_reminder = %+v, want error containing %q", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatWhen(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{time.Date(2026, 10, 16, 15, 4, 0, 0, time.UTC), "今天 15:04"},
+		{time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC), "明天 09:00"},
+		{time.Date(2026, 10, 18, 23, 59, 0, 0, time.UTC), "后天 23:59"},
+		{time.Date(2026, 10, 20, 8, 30, 0, 0, time.UTC), "10月20日 08:30"},
+		{time.Date(2027, 1, 2, 8, 0, 0, 0, time.UTC), "2027年1月2日 08:00"},
+	}
+
+	for _, tt := range tests {
+		if got := formatWhen(tt.t, now); got != tt.want {
+			t.Errorf("formatWhen(%v) = %q, want %q", tt.t, got, tt.want)
+		}
+	}
+}
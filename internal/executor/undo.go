@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// maxUndoHistory is how many successful plans per session undo_last can reverse
+const maxUndoHistory = 10
+
+// UndoFunc reverses the side effect of a completed action
+type UndoFunc func(ctx context.Context) error
+
+// compensation is an undo registered by an action, with what it reverses, e.g. "保存文件 notes.md"
+type compensation struct {
+	description string
+	undo        UndoFunc
+}
+
+type undoLogKey struct{}
+
+// undoLog collects the compensations registered while a plan runs, in the order they were registered
+type undoLog struct {
+	mu    sync.Mutex
+	steps []compensation
+}
+
+// OnUndo registers how to reverse what the running action has done
+//
+// Actions call it right after their side effect happened. If a later step of
+// the plan fails, the registered compensations run in reverse order; after a
+// successful plan they are kept for undo_last. description names what is
+// reversed, e.g. "保存文件 notes.md". Outside Execute it does nothing.
+func OnUndo(ctx context.Context, description string, undo UndoFunc) {
+	l, ok := ctx.Value(undoLogKey{}).(*undoLog)
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	l.steps = append(l.steps, compensation{description: description, undo: undo})
+	l.mu.Unlock()
+}
+
+// finishPlan rolls back the compensations of a failed plan, or keeps those of a successful one for undo_last
+func (e *Executor) finishPlan(ctx context.Context, result *types.ExecutionResult, undo *undoLog) *types.ExecutionResult {
+	undo.mu.Lock()
+	steps := undo.steps
+	undo.mu.Unlock()
+	if len(steps) == 0 {
+		return result
+	}
+
+	if result.Success {
+		if sessionID := SessionIDFromContext(ctx); sessionID != "" {
+			e.undoMu.Lock()
+			history := append(e.undoHistory[sessionID], steps)
+			if len(history) > maxUndoHistory {
+				history = history[len(history)-maxUndoHistory:]
+			}
+			e.undoHistory[sessionID] = history
+			e.undoMu.Unlock()
+		}
+		return result
+	}
+
+	log.Printf("Plan failed, rolling back %d completed actions", len(steps))
+	undone, failed := rollback(ctx, steps)
+	result.RolledBack = undone
+	if len(undone) > 0 {
+		result.Error += fmt.Sprintf("。已撤销之前完成的操作：%s", strings.Join(undone, "、"))
+	}
+	if len(failed) > 0 {
+		result.Error += fmt.Sprintf("。以下操作未能撤销：%s", strings.Join(failed, "、"))
+	}
+	return result
+}
+
+// ForgetSession drops what undo_last could reverse for a session, e.g. once the session has expired
+func (e *Executor) ForgetSession(sessionID string) {
+	e.undoMu.Lock()
+	delete(e.undoHistory, sessionID)
+	e.undoMu.Unlock()
+}
+
+// rollback runs compensations in reverse order, returning the descriptions of those that succeeded and failed
+//
+// It keeps going after a failure and is not cancelled with ctx, so an
+// interrupted request still cleans up after itself.
+func rollback(ctx context.Context, steps []compensation) (undone, failed []string) {
+	ctx = context.WithoutCancel(ctx)
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+		if err := step.undo(ctx); err != nil {
+			log.Printf("Failed to undo %s: %v", step.description, err)
+			failed = append(failed, step.description)
+			continue
+		}
+		log.Printf("Undid %s", step.description)
+		undone = append(undone, step.description)
+	}
+	return undone, failed
+}
+
+// handleUndoLast reverses the most recent successful plan of the session that registered compensations
+func (e *Executor) handleUndoLast(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+	sessionID := SessionIDFromContext(ctx)
+
+	e.undoMu.Lock()
+	history := e.undoHistory[sessionID]
+	var steps []compensation
+	if len(history) > 0 {
+		steps = history[len(history)-1]
+		e.undoHistory[sessionID] = history[:len(history)-1]
+	}
+	e.undoMu.Unlock()
+
+	if len(steps) == 0 {
+		return &types.ExecutionResult{Success: false, Error: "没有可以撤销的操作"}
+	}
+
+	undone, failed := rollback(ctx, steps)
+	if len(undone) == 0 {
+		return &types.ExecutionResult{
+			Success: false,
+			Error:   fmt.Sprintf("撤销失败：%s", strings.Join(failed, "、")),
+		}
+	}
+
+	message := fmt.Sprintf("已撤销：%s", strings.Join(undone, "、"))
+	if len(failed) > 0 {
+		message += fmt.Sprintf("。以下操作未能撤销：%s", strings.Join(failed, "、"))
+	}
+	return &types.ExecutionResult{Success: true, Message: message, RolledBack: undone}
+}
@@ -0,0 +1,116 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deca/voicepilot-eino/internal/scheduler"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+func TestExecuteRollsBackFailedPlans(t *testing.T) {
+	for _, graph := range []bool{false, true} {
+		root := t.TempDir()
+		os.WriteFile(filepath.Join(root, "todo.txt"), []byte("old"), 0644)
+		s, _ := scheduler.New("")
+		e := NewExecutor(nil, WithWorkspace(root), WithScheduler(s))
+		e.RegisterHandler("fail", func(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+			return &types.ExecutionResult{Success: false, Error: "第三步失败"}
+		})
+		ctx := WithSessionID(context.Background(), "s1")
+
+		steps := []types.TaskStep{
+			{Action: "save_file", Parameters: map[string]interface{}{"path": "notes/new.txt", "content": "hello"}},
+			{Action: "save_file", Parameters: map[string]interface{}{"path": "todo.txt", "content": "new"}},
+			{Action: "set_reminder", Parameters: map[string]interface{}{"text": "喝水", "time": "10分钟后"}},
+			{Action: "fail"},
+		}
+		if graph {
+			for i := range steps {
+				steps[i].ID = "s" + string(rune('a'+i))
+				if i > 0 {
+					steps[i].DependsOn = []string{steps[i-1].ID}
+				}
+			}
+		}
+
+		result := e.Execute(ctx, &types.TaskPlan{Steps: steps})
+		if result.Success || !strings.HasPrefix(result.Error, "第三步失败") {
+			t.Fatalf("graph %v: Execute() = %+v", graph, result)
+		}
+		want := []string{"设置提醒 喝水", "保存文件 todo.txt", "保存文件 notes/new.txt"}
+		if strings.Join(result.RolledBack, ",") != strings.Join(want, ",") || !strings.Contains(result.Error, "已撤销") {
+			t.Errorf("graph %v: RolledBack = %v, error %q, want %v", graph, result.RolledBack, result.Error, want)
+		}
+
+		if _, err := os.Stat(filepath.Join(root, "notes", "new.txt")); !os.IsNotExist(err) {
+			t.Errorf("graph %v: New file not removed: %v", graph, err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(root, "todo.txt")); string(data) != "old" {
+			t.Errorf("graph %v: Overwritten file = %q, want it restored", graph, data)
+		}
+		if jobs := s.List("s1"); len(jobs) != 0 {
+			t.Errorf("graph %v: Reminders not cancelled: %+v", graph, jobs)
+		}
+
+		// Nothing is left to undo after a rollback
+		if result := e.handleUndoLast(ctx, nil); result.Success {
+			t.Errorf("graph %v: undo_last after rollback = %+v", graph, result)
+		}
+	}
+}
+
+func TestUndoLast(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "log.txt"), []byte("line1\n"), 0644)
+	e := NewExecutor(nil, WithWorkspace(root))
+	ctx := WithSessionID(context.Background(), "s1")
+
+	if result := e.handleUndoLast(ctx, nil); result.Success || result.Error != "没有可以撤销的操作" {
+		t.Errorf("undo_last without history = %+v", result)
+	}
+
+	save := func(path, content string, appendMode bool) {
+		result := e.Execute(ctx, &types.TaskPlan{Steps: []types.TaskStep{
+			{Action: "save_file", Parameters: map[string]interface{}{"path": path, "content": content, "append": appendMode}},
+		}})
+		if !result.Success {
+			t.Fatalf("save_file %s = %+v", path, result)
+		}
+	}
+	save("a.txt", "a", false)
+	save("log.txt", "line2\n", true)
+
+	// Plans without undoable actions don't count as the last action
+	e.Execute(ctx, &types.TaskPlan{Steps: []types.TaskStep{{Action: "clarify", Parameters: map[string]interface{}{"message": "?"}}}})
+
+	// Other sessions have their own history
+	if result := e.handleUndoLast(WithSessionID(context.Background(), "s2"), nil); result.Success {
+		t.Errorf("undo_last of another session = %+v", result)
+	}
+
+	result := e.Execute(ctx, &types.TaskPlan{Steps: []types.TaskStep{{Action: "undo_last"}}})
+	if !result.Success || result.Message != "已撤销：保存文件 log.txt" {
+		t.Errorf("First undo_last = %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "log.txt")); string(data) != "line1\n" {
+		t.Errorf("Appended file = %q, want the append undone", data)
+	}
+
+	if result := e.handleUndoLast(ctx, nil); !result.Success || !strings.Contains(result.Message, "a.txt") {
+		t.Errorf("Second undo_last = %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(root, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("Saved file not removed: %v", err)
+	}
+
+	// The history goes away with the session
+	save("b.txt", "b", false)
+	e.ForgetSession("s1")
+	if result := e.handleUndoLast(ctx, nil); result.Success {
+		t.Errorf("undo_last of a forgotten session = %+v", result)
+	}
+}
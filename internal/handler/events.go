@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventsKeepAlive is how often an idle event stream sends a comment so proxies keep it open
+const eventsKeepAlive = 30 * time.Second
+
+// SessionEvents streams events pushed to a session, such as fired reminders, as server-sent events
+//
+// Each event is named after its type ("reminder") and carries the StreamEvent as JSON.
+func (h *Handler) SessionEvents(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	events, unsubscribe := h.workflow.Subscribe(sessionID)
+	defer unsubscribe()
+
+	log.Printf("Event stream opened for session: %s", sessionID)
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			log.Printf("Event stream closed for session: %s", sessionID)
+			return
+		case event := <-events:
+			c.SSEvent(event.Type, event)
+			c.Writer.Flush()
+		case <-keepAlive.C:
+			c.Writer.WriteString(": keep-alive\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
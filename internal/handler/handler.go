@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,6 +11,8 @@ import (
 	"time"
 
 	"github.com/deca/voicepilot-eino/internal/config"
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/internal/trace"
 	"github.com/deca/voicepilot-eino/internal/workflow"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -19,14 +22,26 @@ var cleanupTicker *time.Ticker
 
 // Handler handles HTTP requests
 type Handler struct {
-	workflow *workflow.VoiceWorkflow
+	workflow  *workflow.VoiceWorkflow
+	providers *provider.Providers
 }
 
-// NewHandler creates a new handler
-func NewHandler() *Handler {
-	return &Handler{
-		workflow: workflow.NewVoiceWorkflow(),
+// NewHandler creates a new handler using the providers selected in the configuration
+func NewHandler() (*Handler, error) {
+	providers, err := provider.New(config.AppConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	wf, err := workflow.NewVoiceWorkflow(providers)
+	if err != nil {
+		return nil, err
 	}
+
+	return &Handler{
+		workflow:  wf,
+		providers: providers,
+	}, nil
 }
 
 // VoiceInteraction handles voice interaction requests
@@ -53,6 +68,11 @@ func (h *Handler) VoiceInteraction(c *gin.Context) {
 		return
 	}
 
+	userID, ok := authorizedUserID(c, c.PostForm("user_id"))
+	if !ok {
+		return
+	}
+
 	// Generate session ID
 	sessionID := c.PostForm("session_id")
 	if sessionID == "" {
@@ -80,12 +100,13 @@ func (h *Handler) VoiceInteraction(c *gin.Context) {
 	}()
 
 	// Execute workflow
-	response, err := h.workflow.Execute(c.Request.Context(), audioPath, sessionID)
+	response, err := h.workflow.Execute(c.Request.Context(), audioPath, sessionID, workflow.WithUserID(userID))
 	if err != nil {
 		log.Printf("Workflow execution failed: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   fmt.Sprintf("处理失败：%v", err),
+			"success":  false,
+			"error":    fmt.Sprintf("处理失败：%v", err),
+			"trace_id": response.TraceID,
 		})
 		return
 	}
@@ -98,6 +119,8 @@ func (h *Handler) TextInteraction(c *gin.Context) {
 	var req struct {
 		Text      string `json:"text" binding:"required"`
 		SessionID string `json:"session_id"`
+		UserID    string `json:"user_id"`
+		TextOnly  bool   `json:"text_only"` // skip speech synthesis for text-only clients
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -108,18 +131,70 @@ func (h *Handler) TextInteraction(c *gin.Context) {
 		return
 	}
 
+	userID, ok := authorizedUserID(c, req.UserID)
+	if !ok {
+		return
+	}
+
 	// Generate session ID if not provided
 	if req.SessionID == "" {
 		req.SessionID = uuid.New().String()
 	}
 
+	opts := []workflow.RunOption{workflow.WithUserID(userID)}
+	if req.TextOnly {
+		opts = append(opts, workflow.WithTextOnly())
+	}
+
 	// Execute text-based workflow (skip ASR, start from Intent node)
-	response, err := h.workflow.ExecuteText(c.Request.Context(), req.Text, req.SessionID)
+	response, err := h.workflow.ExecuteText(c.Request.Context(), req.Text, req.SessionID, opts...)
 	if err != nil {
 		log.Printf("Text workflow execution failed: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
+			"success":  false,
+			"error":    fmt.Sprintf("处理失败：%v", err),
+			"trace_id": response.TraceID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ConfirmAction confirms or cancels the plan awaiting confirmation in a session
+func (h *Handler) ConfirmAction(c *gin.Context) {
+	var req struct {
+		Confirm  *bool `json:"confirm" binding:"required"`
+		TextOnly bool  `json:"text_only"` // skip speech synthesis for text-only clients
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求参数错误",
+		})
+		return
+	}
+
+	var opts []workflow.RunOption
+	if req.TextOnly {
+		opts = append(opts, workflow.WithTextOnly())
+	}
+
+	response, err := h.workflow.Confirm(c.Request.Context(), c.Param("id"), *req.Confirm, opts...)
+	if errors.Is(err, workflow.ErrNoPendingConfirmation) {
+		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
-			"error":   fmt.Sprintf("处理失败：%v", err),
+			"error":   "没有待确认的操作",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Confirmation workflow execution failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success":  false,
+			"error":    fmt.Sprintf("处理失败：%v", err),
+			"trace_id": response.TraceID,
 		})
 		return
 	}
@@ -127,6 +202,28 @@ func (h *Handler) TextInteraction(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// GetTrace returns the recorded trace of a workflow run
+func (h *Handler) GetTrace(c *gin.Context) {
+	t, err := h.workflow.LoadTrace(c.Param("id"))
+	if errors.Is(err, trace.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "追踪记录不存在",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load trace: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "读取追踪记录失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, t)
+}
+
 // HealthCheck handles health check requests
 func (h *Handler) HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
@@ -204,8 +301,15 @@ func (h *Handler) UploadAudio(c *gin.Context) {
 	})
 }
 
-// StartSessionCleanup starts a background task to periodically clean up expired sessions
+// StartSessionCleanup starts a background task to periodically clean up expired sessions and old traces
+//
+// When the session store expires sessions itself, only what this instance
+// keeps for them in memory is dropped.
 func (h *Handler) StartSessionCleanup(interval time.Duration) {
+	if h.workflow.SessionsExpireNatively() {
+		log.Println("Session store expires sessions itself, cleanup task only drops cached state and old traces")
+	}
+
 	log.Printf("Starting session cleanup task (interval: %v)", interval)
 
 	cleanupTicker = time.NewTicker(interval)
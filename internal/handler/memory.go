@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+	"github.com/deca/voicepilot-eino/internal/memory"
+	"github.com/gin-gonic/gin"
+)
+
+// memoryStore returns the long-term memory, answering the request itself when it is disabled or not authorized
+//
+// The request must carry the token of the user in the path, see memory.UserToken,
+// as "Authorization: Bearer <token>".
+func (h *Handler) memoryStore(c *gin.Context) (*memory.Store, bool) {
+	store := h.workflow.Memory()
+	if store == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "长期记忆未启用",
+		})
+		return nil, false
+	}
+
+	secret := config.AppConfig.MemoryAPISecret
+	if secret == "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "未配置 MEMORY_API_SECRET，记忆接口已关闭",
+		})
+		return nil, false
+	}
+	if !validUserToken(c, c.Param("id")) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "无权访问该用户的记忆",
+		})
+		return nil, false
+	}
+	return store, true
+}
+
+// validUserToken reports whether the request carries the token of a user as "Authorization: Bearer <token>"
+func validUserToken(c *gin.Context, userID string) bool {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return memory.ValidUserToken(config.AppConfig.MemoryAPISecret, userID, token)
+}
+
+// authorizedUserID returns the user an interaction runs for, answering the request itself when it is not authorized
+//
+// A request without a user ID runs for no user. One that names a user must
+// carry its token like the memory endpoints do, otherwise anyone could read
+// and write the memory of that user through the conversation.
+func authorizedUserID(c *gin.Context, userID string) (string, bool) {
+	if userID == "" {
+		return "", true
+	}
+	if !validUserToken(c, userID) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "无权以该用户身份对话",
+		})
+		return "", false
+	}
+	return userID, true
+}
+
+// GetUserMemory lists what the assistant remembers about a user
+func (h *Handler) GetUserMemory(c *gin.Context) {
+	store, ok := h.memoryStore(c)
+	if !ok {
+		return
+	}
+
+	userID := c.Param("id")
+	facts, err := store.List(userID)
+	if err != nil {
+		log.Printf("Failed to load memory of user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "读取记忆失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"user_id": userID,
+		"facts":   facts,
+	})
+}
+
+// DeleteUserMemory forgets everything the assistant remembers about a user
+func (h *Handler) DeleteUserMemory(c *gin.Context) {
+	store, ok := h.memoryStore(c)
+	if !ok {
+		return
+	}
+
+	userID := c.Param("id")
+	n, err := store.ForgetAll(userID)
+	if err != nil {
+		log.Printf("Failed to delete memory of user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "删除记忆失败",
+		})
+		return
+	}
+
+	log.Printf("Forgot %d facts of user %s", n, userID)
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"user_id":   userID,
+		"forgotten": n,
+	})
+}
+
+// DeleteUserFact forgets a single fact about a user
+func (h *Handler) DeleteUserFact(c *gin.Context) {
+	store, ok := h.memoryStore(c)
+	if !ok {
+		return
+	}
+
+	userID := c.Param("id")
+	fact, err := store.Forget(userID, c.Param("fact_id"))
+	if errors.Is(err, memory.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "记忆不存在",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to delete a fact of user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "删除记忆失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"user_id": userID,
+		"fact":    fact,
+	})
+}
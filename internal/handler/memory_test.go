@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+	"github.com/deca/voicepilot-eino/internal/memory"
+	"github.com/gin-gonic/gin"
+)
+
+func TestUserMemoryAuthorization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newTestHandler(t, func(cfg *config.Config) {
+		cfg.MemoryEnabled = true
+		cfg.MemoryStoragePath = filepath.Join(cfg.StaticAudioPath, "memory")
+	})
+	h.workflow.Memory().Remember("user-1", "s1", 0, []memory.Fact{{Key: "preferred_name", Text: "用户希望被称为小李"}})
+
+	router := gin.New()
+	router.GET("/api/users/:id/memory", h.GetUserMemory)
+	router.DELETE("/api/users/:id/memory", h.DeleteUserMemory)
+	router.DELETE("/api/users/:id/memory/:fact_id", h.DeleteUserFact)
+
+	tests := []struct {
+		name   string
+		secret string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"no secret configured", "", "GET", "/api/users/user-1/memory", memory.UserToken("", "user-1"), http.StatusForbidden},
+		{"own memory", "secret", "GET", "/api/users/user-1/memory", memory.UserToken("secret", "user-1"), http.StatusOK},
+		{"no token", "secret", "GET", "/api/users/user-1/memory", "", http.StatusUnauthorized},
+		{"other user's memory", "secret", "GET", "/api/users/user-1/memory", memory.UserToken("secret", "user-2"), http.StatusUnauthorized},
+		{"other user's fact", "secret", "DELETE", "/api/users/user-1/memory/any", memory.UserToken("secret", "user-2"), http.StatusUnauthorized},
+		{"forget other user", "secret", "DELETE", "/api/users/user-1/memory", memory.UserToken("secret", "user-2"), http.StatusUnauthorized},
+		{"forget own memory", "secret", "DELETE", "/api/users/user-1/memory", memory.UserToken("secret", "user-1"), http.StatusOK},
+	}
+	for _, tt := range tests {
+		config.AppConfig.MemoryAPISecret = tt.secret
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+
+	if facts, _ := h.workflow.Memory().List("user-1"); len(facts) != 0 {
+		t.Errorf("Facts after forgetting = %+v", facts)
+	}
+}
+
+func TestInteractionUserAuthorization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newTestHandler(t, func(cfg *config.Config) {
+		cfg.MemoryEnabled = true
+		cfg.MemoryStoragePath = filepath.Join(cfg.StaticAudioPath, "memory")
+		cfg.MemoryAPISecret = "secret"
+	})
+
+	router := gin.New()
+	router.POST("/api/text", h.TextInteraction)
+	router.POST("/api/text/stream", h.TextStream)
+
+	tests := []struct {
+		name  string
+		path  string
+		body  string
+		token string
+		want  int
+	}{
+		{"no user", "/api/text", `{"text":"你好","text_only":true}`, "", http.StatusOK},
+		{"own user", "/api/text", `{"text":"你好","user_id":"user-1","text_only":true}`, memory.UserToken("secret", "user-1"), http.StatusOK},
+		{"no token", "/api/text", `{"text":"你好","user_id":"user-1","text_only":true}`, "", http.StatusUnauthorized},
+		{"other user", "/api/text", `{"text":"你好","user_id":"user-1","text_only":true}`, memory.UserToken("secret", "user-2"), http.StatusUnauthorized},
+		{"stream without token", "/api/text/stream", `{"text":"你好","user_id":"user-1","text_only":true}`, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+}
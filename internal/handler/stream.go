@@ -0,0 +1,310 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/internal/workflow"
+	"github.com/deca/voicepilot-eino/pkg/types"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+var streamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  16 * 1024,
+	WriteBufferSize: 16 * 1024,
+	CheckOrigin:     allowedOrigin,
+}
+
+// allowedOrigin reports whether a browser page from the request's Origin may open a WebSocket
+//
+// Browsers send cookies and credentials with WebSocket handshakes from any
+// page, so only the server's own origin and those in WSAllowedOrigins are
+// accepted. Requests without an Origin are refused unless "*" is allowed.
+func allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	for _, allowed := range config.AppConfig.WSAllowedOrigins {
+		if allowed == "*" || (origin != "" && strings.EqualFold(allowed, origin)) {
+			return true
+		}
+	}
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// streamControl is a JSON control message sent by streaming clients
+type streamControl struct {
+	Type       string `json:"type"` // start, stop, cancel
+	Format     string `json:"format,omitempty"`
+	SampleRate int    `json:"sample_rate,omitempty"`
+}
+
+// streamConn serializes writes to a client WebSocket connection
+type streamConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (s *streamConn) sendEvent(event types.StreamEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.WriteJSON(event)
+}
+
+// sendEventWithAudio sends an event followed by its audio as a binary frame
+//
+// Both are written under one lock, so no other event can come between them
+// and the client always reads the frame right after the event announcing it.
+func (s *streamConn) sendEventWithAudio(event types.StreamEvent, audio []byte) error {
+	event.AudioSize = len(audio)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.conn.WriteJSON(event); err != nil {
+		return err
+	}
+	if len(audio) == 0 {
+		return nil
+	}
+	return s.conn.WriteMessage(websocket.BinaryMessage, audio)
+}
+
+// sendPushed sends a pushed event, followed by its TTS audio as a binary frame like an "audio" event
+func (s *streamConn) sendPushed(event types.StreamEvent) {
+	s.sendEventWithAudio(event, loadLocalAudio(event.AudioURL))
+}
+
+// utterance tracks the ASR session for the audio currently being spoken
+type utterance struct {
+	asr       provider.StreamingASRSession
+	size      int64
+	forwarded chan struct{}
+}
+
+// VoiceStream handles real-time voice interaction over WebSocket
+//
+// Clients send audio as binary frames (PCM or Opus) and JSON control messages
+// ({"type":"stop"} ends an utterance). The server replies with typed JSON events
+// and, after an "audio" event, a binary frame carrying the TTS audio. Events
+// pushed to the session, such as "reminder", arrive the same way at any time.
+func (h *Handler) VoiceStream(c *gin.Context) {
+	sessionID := c.Query("session_id")
+	if sessionID == "" {
+		sessionID = uuid.New().String()
+	}
+	userID, ok := authorizedUserID(c, c.Query("user_id"))
+	if !ok {
+		return
+	}
+
+	opts := provider.StreamingASROptions{
+		Format: c.DefaultQuery("format", "pcm"),
+	}
+	if rate, err := strconv.Atoi(c.Query("sample_rate")); err == nil {
+		opts.SampleRate = rate
+	}
+
+	ws, err := streamUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade voice stream connection: %v", err)
+		return
+	}
+	defer ws.Close()
+	ws.SetReadLimit(config.AppConfig.MaxAudioSize)
+
+	log.Printf("Voice stream opened for session: %s", sessionID)
+
+	conn := &streamConn{conn: ws}
+	ctx := c.Request.Context()
+
+	if err := conn.sendEvent(types.StreamEvent{Type: "ready", SessionID: sessionID}); err != nil {
+		return
+	}
+
+	// Forward events pushed to the session, such as fired reminders
+	pushed, unsubscribe := h.workflow.Subscribe(sessionID)
+	defer unsubscribe()
+	go func() {
+		for event := range pushed {
+			conn.sendPushed(event)
+		}
+	}()
+
+	var current *utterance
+	defer func() {
+		if current != nil {
+			current.asr.Close()
+		}
+	}()
+
+	for {
+		msgType, data, err := ws.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("Voice stream read failed: %v", err)
+			}
+			log.Printf("Voice stream closed for session: %s", sessionID)
+			return
+		}
+
+		switch msgType {
+		case websocket.BinaryMessage:
+			if current == nil {
+				current, err = h.startUtterance(ctx, conn, opts, sessionID)
+				if err != nil {
+					log.Printf("Failed to start streaming ASR: %v", err)
+					conn.sendEvent(types.StreamEvent{Type: "error", SessionID: sessionID, Error: "语音识别暂不可用"})
+					continue
+				}
+			}
+
+			current.size += int64(len(data))
+			if current.size > config.AppConfig.MaxAudioSize {
+				current.asr.Close()
+				current = nil
+				conn.sendEvent(types.StreamEvent{
+					Type:      "error",
+					SessionID: sessionID,
+					Error:     fmt.Sprintf("音频过长（最大：%d MB）", config.AppConfig.MaxAudioSize/1024/1024),
+				})
+				continue
+			}
+
+			if err := current.asr.SendAudio(data); err != nil {
+				log.Printf("Failed to forward audio: %v", err)
+				current.asr.Close()
+				current = nil
+				conn.sendEvent(types.StreamEvent{Type: "error", SessionID: sessionID, Error: "音频转发失败"})
+			}
+
+		case websocket.TextMessage:
+			var ctrl streamControl
+			if err := json.Unmarshal(data, &ctrl); err != nil {
+				conn.sendEvent(types.StreamEvent{Type: "error", SessionID: sessionID, Error: "无效的控制消息"})
+				continue
+			}
+
+			switch ctrl.Type {
+			case "start":
+				if ctrl.Format != "" {
+					opts.Format = ctrl.Format
+				}
+				if ctrl.SampleRate > 0 {
+					opts.SampleRate = ctrl.SampleRate
+				}
+			case "cancel":
+				if current != nil {
+					current.asr.Close()
+					current = nil
+				}
+			case "stop":
+				if current == nil {
+					conn.sendEvent(types.StreamEvent{Type: "error", SessionID: sessionID, Error: "没有收到音频数据"})
+					continue
+				}
+				u := current
+				current = nil
+				h.finishUtterance(ctx, conn, u, sessionID, userID)
+			default:
+				conn.sendEvent(types.StreamEvent{Type: "error", SessionID: sessionID, Error: fmt.Sprintf("未知的控制消息：%s", ctrl.Type)})
+			}
+		}
+	}
+}
+
+// startUtterance opens an ASR session and forwards its partial transcripts to the client
+func (h *Handler) startUtterance(ctx context.Context, conn *streamConn, opts provider.StreamingASROptions, sessionID string) (*utterance, error) {
+	streamer, ok := h.providers.ASR.(provider.StreamingASRProvider)
+	if !ok {
+		return nil, fmt.Errorf("ASR provider does not support streaming")
+	}
+
+	asr, err := streamer.StartStreamingASR(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &utterance{
+		asr:       asr,
+		forwarded: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(u.forwarded)
+		for result := range asr.Results() {
+			if result.Final {
+				continue
+			}
+			conn.sendEvent(types.StreamEvent{Type: "partial_transcript", SessionID: sessionID, Text: result.Text})
+		}
+	}()
+
+	return u, nil
+}
+
+// finishUtterance waits for the final transcript and runs the workflow on it
+func (h *Handler) finishUtterance(ctx context.Context, conn *streamConn, u *utterance, sessionID, userID string) {
+	text, err := u.asr.Finish(ctx)
+	<-u.forwarded
+	if err != nil {
+		log.Printf("Streaming ASR failed: %v", err)
+		conn.sendEvent(types.StreamEvent{Type: "error", SessionID: sessionID, Error: fmt.Sprintf("语音识别失败：%v", err)})
+		return
+	}
+
+	conn.sendEvent(types.StreamEvent{Type: "final_transcript", SessionID: sessionID, Text: text})
+
+	response, err := h.workflow.ExecuteText(ctx, text, sessionID, workflow.WithUserID(userID), workflow.WithEvents(func(event string, wfCtx *types.WorkflowContext) {
+		switch event {
+		case workflow.EventIntent:
+			conn.sendEvent(types.StreamEvent{Type: "intent", SessionID: sessionID, Intent: wfCtx.Intent})
+		case workflow.EventResponse:
+			conn.sendEvent(types.StreamEvent{Type: "response", SessionID: sessionID, Text: wfCtx.ResponseText})
+		}
+	}))
+	if err != nil {
+		log.Printf("Stream workflow execution failed: %v", err)
+		conn.sendEvent(types.StreamEvent{Type: "error", SessionID: sessionID, TraceID: response.TraceID, Error: fmt.Sprintf("处理失败：%v", err)})
+		return
+	}
+
+	if response.AudioURL != "" {
+		conn.sendEventWithAudio(types.StreamEvent{
+			Type:        "audio",
+			SessionID:   sessionID,
+			AudioURL:    response.AudioURL,
+			AudioFormat: config.AppConfig.TTSEncoding,
+		}, loadLocalAudio(response.AudioURL))
+	}
+
+	conn.sendEvent(types.StreamEvent{Type: "done", SessionID: sessionID, Response: response})
+}
+
+// loadLocalAudio reads TTS audio served from the static audio directory, or returns nil for remote URLs
+func loadLocalAudio(audioURL string) []byte {
+	if !strings.HasPrefix(audioURL, "/static/audio/") {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(config.AppConfig.StaticAudioPath, filepath.Base(audioURL)))
+	if err != nil {
+		log.Printf("Failed to read TTS audio for stream: %v", err)
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/internal/workflow"
+	"github.com/deca/voicepilot-eino/pkg/types"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// fakeStreamingASR transcribes every chunk of audio as one more "你好"
+type fakeStreamingASR struct{}
+
+func (fakeStreamingASR) ASR(ctx context.Context, audioPath string) (string, error) {
+	return "你好", nil
+}
+
+func (fakeStreamingASR) StartStreamingASR(ctx context.Context, opts provider.StreamingASROptions) (provider.StreamingASRSession, error) {
+	return &fakeASRSession{results: make(chan provider.StreamingASRResult, 16)}, nil
+}
+
+type fakeASRSession struct {
+	mu      sync.Mutex
+	text    string
+	results chan provider.StreamingASRResult
+	closed  bool
+}
+
+func (s *fakeASRSession) SendAudio(chunk []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.text += "你好"
+	s.results <- provider.StreamingASRResult{Text: s.text}
+	return nil
+}
+
+func (s *fakeASRSession) Results() <-chan provider.StreamingASRResult {
+	return s.results
+}
+
+func (s *fakeASRSession) Finish(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results <- provider.StreamingASRResult{Text: s.text, Final: true}
+	s.close()
+	return s.text, nil
+}
+
+func (s *fakeASRSession) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.close()
+	return nil
+}
+
+func (s *fakeASRSession) close() {
+	if !s.closed {
+		s.closed = true
+		close(s.results)
+	}
+}
+
+// fakeTTS writes a fixed clip to the static audio directory
+type fakeTTS struct{}
+
+func (fakeTTS) TTS(ctx context.Context, text string) (string, error) {
+	path := filepath.Join(config.AppConfig.StaticAudioPath, "reply.mp3")
+	if err := os.WriteFile(path, []byte("mp3-data"), 0644); err != nil {
+		return "", err
+	}
+	return "/static/audio/reply.mp3", nil
+}
+
+// fakeChat answers intent recognition with an unknown intent and everything else with a fixed reply
+type fakeChat struct{}
+
+func (fakeChat) ChatCompletion(ctx context.Context, messages []provider.Message) (string, error) {
+	if strings.Contains(messages[0].Content, "意图识别") {
+		return `{"intent": "unknown", "parameters": {}, "confidence": 0}`, nil
+	}
+	return "抱歉，我没有听懂", nil
+}
+
+// newTestHandler creates a handler with fake providers; configure may adjust the configuration first
+func newTestHandler(t *testing.T, configure ...func(*config.Config)) *Handler {
+	dir := t.TempDir()
+	config.AppConfig = &config.Config{
+		TTSEncoding:          "mp3",
+		LLMMaxTokens:         100,
+		LLMContextWindow:     4000,
+		StaticAudioPath:      dir,
+		WorkspaceRoot:        filepath.Join(dir, "workspace"),
+		RemindersStorePath:   filepath.Join(dir, "reminders.json"),
+		CalendarPath:         filepath.Join(dir, "calendar.ics"),
+		SessionStoragePath:   filepath.Join(dir, "sessions"),
+		SessionMaxHistory:    10,
+		SessionExpiryHours:   1,
+		SessionStore:         "memory",
+		MaxAudioSize:         1024 * 1024,
+		SandboxMode:          "none",
+		SandboxWorkDir:       filepath.Join(dir, "sandbox"),
+		ActionTimeoutSeconds: 5,
+	}
+	for _, fn := range configure {
+		fn(config.AppConfig)
+	}
+
+	providers := &provider.Providers{ASR: fakeStreamingASR{}, TTS: fakeTTS{}, Chat: fakeChat{}}
+	wf, err := workflow.NewVoiceWorkflow(providers)
+	if err != nil {
+		t.Fatalf("NewVoiceWorkflow failed: %v", err)
+	}
+	return &Handler{workflow: wf, providers: providers}
+}
+
+func TestVoiceStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newTestHandler(t)
+	router := gin.New()
+	router.GET("/api/voice/stream", h.VoiceStream)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/voice/stream?session_id=s1"
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {server.URL}})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	var ready types.StreamEvent
+	if err := conn.ReadJSON(&ready); err != nil || ready.Type != "ready" || ready.SessionID != "s1" {
+		t.Fatalf("First event = %+v, %v, want ready", ready, err)
+	}
+
+	conn.WriteJSON(streamControl{Type: "start", Format: "pcm", SampleRate: 16000})
+	conn.WriteMessage(websocket.BinaryMessage, []byte{0, 1, 2, 3})
+	conn.WriteMessage(websocket.BinaryMessage, []byte{4, 5, 6, 7})
+	conn.WriteJSON(streamControl{Type: "stop"})
+
+	var got []string
+	for {
+		var event types.StreamEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("ReadJSON failed after %v: %v", got, err)
+		}
+		got = append(got, event.Type)
+
+		switch event.Type {
+		case "final_transcript":
+			if event.Text != "你好你好" {
+				t.Errorf("Final transcript = %q", event.Text)
+			}
+		case "audio":
+			msgType, data, err := conn.ReadMessage()
+			if err != nil || msgType != websocket.BinaryMessage || string(data) != "mp3-data" || event.AudioSize != len(data) {
+				t.Errorf("Audio frame = %d %q %v, want %d bytes of audio", msgType, data, err, event.AudioSize)
+			}
+		case "error":
+			t.Fatalf("Error event: %s", event.Error)
+		}
+		if event.Type == "done" {
+			if event.Response == nil || !event.Response.Success || event.Response.RecognizedText != "你好你好" {
+				t.Errorf("Done response = %+v", event.Response)
+			}
+			break
+		}
+	}
+
+	want := "partial_transcript partial_transcript final_transcript intent response audio done"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Events = %v, want %s", got, want)
+	}
+}
+
+func TestVoiceStreamStopWithoutAudio(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newTestHandler(t)
+	router := gin.New()
+	router.GET("/api/voice/stream", h.VoiceStream)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/voice/stream", http.Header{"Origin": {server.URL}})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	var event types.StreamEvent
+	conn.ReadJSON(&event)
+	if event.Type != "ready" || event.SessionID == "" {
+		t.Fatalf("First event = %+v, want ready with a new session", event)
+	}
+
+	conn.WriteJSON(streamControl{Type: "stop"})
+	if err := conn.ReadJSON(&event); err != nil || event.Type != "error" {
+		t.Errorf("Event after an empty stop = %+v, %v, want error", event, err)
+	}
+	conn.WriteJSON(streamControl{Type: "rewind"})
+	if err := conn.ReadJSON(&event); err != nil || event.Type != "error" || !strings.Contains(event.Error, "rewind") {
+		t.Errorf("Event after an unknown control = %+v, %v, want error", event, err)
+	}
+}
+
+func TestAllowedOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"same origin", nil, "http://voice.example.com", true},
+		{"other origin", nil, "http://evil.example.com", false},
+		{"no origin", nil, "", false},
+		{"listed origin", []string{"https://app.example.com"}, "https://app.example.com", true},
+		{"unlisted origin", []string{"https://app.example.com"}, "https://evil.example.com", false},
+		{"any origin", []string{"*"}, "https://evil.example.com", true},
+		{"no origin allowed by any", []string{"*"}, "", true},
+	}
+	for _, tt := range tests {
+		config.AppConfig = &config.Config{WSAllowedOrigins: tt.allowed}
+		req := httptest.NewRequest("GET", "http://voice.example.com/api/voice/stream", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if got := allowedOrigin(req); got != tt.want {
+			t.Errorf("%s: allowedOrigin() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSendEventWithAudioKeepsFramesTogether(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := streamUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		conn := &streamConn{conn: ws}
+
+		// A reply and pushed reminders racing for the connection
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				conn.sendEventWithAudio(types.StreamEvent{Type: "audio"}, []byte(strings.Repeat("a", i+1)))
+				conn.sendEvent(types.StreamEvent{Type: "response"})
+			}(i)
+		}
+		wg.Wait()
+		ws.ReadMessage() // wait for the client to finish
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), http.Header{"Origin": {server.URL}})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	for clips := 0; clips < 20; {
+		var event types.StreamEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("Message after %d clips is not an event: %v", clips, err)
+		}
+		if event.Type != "audio" {
+			continue
+		}
+		msgType, data, err := conn.ReadMessage()
+		if err != nil || msgType != websocket.BinaryMessage || len(data) != event.AudioSize {
+			t.Fatalf("Frame after an audio event = %d, %d bytes, %v, want %d bytes of audio", msgType, len(data), err, event.AudioSize)
+		}
+		clips++
+	}
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/deca/voicepilot-eino/internal/workflow"
+	"github.com/deca/voicepilot-eino/pkg/types"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// TextStream handles text interaction and streams the output as server-sent events
+//
+// Events: "intent" with the parsed intent, "token" with {"source","delta"} for each
+// generated piece of text, "done" with the full VoiceResponse, or "error".
+func (h *Handler) TextStream(c *gin.Context) {
+	var req struct {
+		Text      string `json:"text" form:"text" binding:"required"`
+		SessionID string `json:"session_id" form:"session_id"`
+		UserID    string `json:"user_id" form:"user_id"`
+		TextOnly  bool   `json:"text_only" form:"text_only"`
+	}
+
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求参数错误",
+		})
+		return
+	}
+
+	userID, ok := authorizedUserID(c, req.UserID)
+	if !ok {
+		return
+	}
+
+	if req.SessionID == "" {
+		req.SessionID = uuid.New().String()
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// Steps of a plan may generate text concurrently, so writes are serialized
+	var mu sync.Mutex
+	send := func(event string, data interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+	}
+
+	opts := []workflow.RunOption{
+		workflow.WithUserID(userID),
+		workflow.WithTokens(func(source, delta string) {
+			send("token", gin.H{"source": source, "delta": delta})
+		}),
+		workflow.WithEvents(func(event string, wfCtx *types.WorkflowContext) {
+			if event == workflow.EventIntent {
+				send("intent", wfCtx.Intent)
+			}
+		}),
+	}
+	if req.TextOnly {
+		opts = append(opts, workflow.WithTextOnly())
+	}
+
+	response, err := h.workflow.ExecuteText(c.Request.Context(), req.Text, req.SessionID, opts...)
+	if err != nil {
+		log.Printf("Text stream workflow execution failed: %v", err)
+		send("error", gin.H{
+			"success":  false,
+			"error":    fmt.Sprintf("处理失败：%v", err),
+			"trace_id": response.TraceID,
+		})
+		return
+	}
+
+	send("done", response)
+}
@@ -0,0 +1,123 @@
+// Package llmjson extracts and validates JSON objects from free-form LLM replies
+package llmjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Repairs applied to a reply before it could be decoded
+const (
+	RepairStripFence    = "strip_fence"    // removed a ``` code fence around the JSON
+	RepairExtractObject = "extract_object" // dropped text before or after the outermost object
+	RepairReprompt      = "reprompt"       // asked the model again with the validation error
+)
+
+// Validator is implemented by types that can check a decoded value against their schema
+type Validator interface {
+	Validate() error
+}
+
+// Extract returns the outermost JSON object in raw along with the repairs needed to find it
+func Extract(raw string) (string, []string, error) {
+	var repairs []string
+	text := strings.TrimSpace(raw)
+
+	if fenced, ok := stripFence(text); ok {
+		text = fenced
+		repairs = append(repairs, RepairStripFence)
+	}
+
+	start := strings.Index(text, "{")
+	if start < 0 {
+		return "", repairs, fmt.Errorf("no JSON object found")
+	}
+	end := matchingBrace(text, start)
+	if end < 0 {
+		return "", repairs, fmt.Errorf("unterminated JSON object")
+	}
+
+	if start > 0 || end < len(text)-1 {
+		repairs = append(repairs, RepairExtractObject)
+	}
+	return text[start : end+1], repairs, nil
+}
+
+// Decode extracts the JSON object from raw, decodes it into v and validates it
+//
+// v must be a non-nil pointer. It is only modified when decoding and
+// validation succeed, so a caller can retry into the same value.
+func Decode(raw string, v interface{}) ([]string, error) {
+	object, repairs, err := Extract(raw)
+	if err != nil {
+		return repairs, err
+	}
+
+	target := reflect.New(reflect.TypeOf(v).Elem())
+	if err := json.Unmarshal([]byte(object), target.Interface()); err != nil {
+		return repairs, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	if validator, ok := target.Interface().(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return repairs, err
+		}
+	}
+
+	reflect.ValueOf(v).Elem().Set(target.Elem())
+	return repairs, nil
+}
+
+// stripFence returns the contents of the first ``` code fence in text, if any
+func stripFence(text string) (string, bool) {
+	open := strings.Index(text, "```")
+	if open < 0 {
+		return "", false
+	}
+	body := text[open+3:]
+
+	// Skip the language tag, e.g. ```json
+	body = strings.TrimLeft(body, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+
+	if close := strings.Index(body, "```"); close >= 0 {
+		body = body[:close]
+	}
+	return strings.TrimSpace(body), true
+}
+
+// matchingBrace returns the index of the brace closing the object that starts at start, or -1
+func matchingBrace(text string, start int) int {
+	depth := 0
+	inString := false
+	escaped := false
+
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
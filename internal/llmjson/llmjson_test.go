@@ -0,0 +1,116 @@
+package llmjson
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name        string
+		raw         string
+		want        string
+		wantRepairs []string
+		wantError   bool
+	}{
+		{
+			name: "plain object",
+			raw:  `{"intent":"open_app"}`,
+			want: `{"intent":"open_app"}`,
+		},
+		{
+			name:        "fenced with language tag",
+			raw:         "```json\n{\"intent\":\"open_app\"}\n```",
+			want:        `{"intent":"open_app"}`,
+			wantRepairs: []string{RepairStripFence},
+		},
+		{
+			name:        "fenced on one line",
+			raw:         "```json {\"intent\":\"open_app\"}```",
+			want:        `{"intent":"open_app"}`,
+			wantRepairs: []string{RepairStripFence},
+		},
+		{
+			name:        "trailing sentence",
+			raw:         `{"intent":"open_app"} 以上是识别结果。`,
+			want:        `{"intent":"open_app"}`,
+			wantRepairs: []string{RepairExtractObject},
+		},
+		{
+			name:        "braces inside strings",
+			raw:         `结果：{"message":"use } and \" carefully","n":{"a":1}}`,
+			want:        `{"message":"use } and \" carefully","n":{"a":1}}`,
+			wantRepairs: []string{RepairExtractObject},
+		},
+		{
+			name:      "no object",
+			raw:       "抱歉，我无法理解",
+			wantError: true,
+		},
+		{
+			name:      "unterminated object",
+			raw:       `{"intent":"open_app"`,
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, repairs, err := Extract(tt.raw)
+			if tt.wantError {
+				if err == nil {
+					t.Errorf("Expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Extract failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+			if !reflect.DeepEqual(repairs, tt.wantRepairs) {
+				t.Errorf("Expected repairs %v, got %v", tt.wantRepairs, repairs)
+			}
+		})
+	}
+}
+
+func TestDecodeValidates(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		target    interface{}
+		wantError bool
+	}{
+		{"valid intent", "```json\n{\"intent\":\"open_app\",\"confidence\":0.9}\n```", &types.Intent{}, false},
+		{"missing intent", `{"parameters":{},"confidence":0.9}`, &types.Intent{}, true},
+		{"confidence out of range", `{"intent":"open_app","confidence":95}`, &types.Intent{}, true},
+		{"wrong field type", `{"intent":"open_app","confidence":"high"}`, &types.Intent{}, true},
+		{"valid plan", `{"steps":[{"action":"open_app","parameters":{"name":"微信"}}]}`, &types.TaskPlan{}, false},
+		{"empty plan", `{"steps":[]}`, &types.TaskPlan{}, true},
+		{"step without action", `{"steps":[{"parameters":{}}]}`, &types.TaskPlan{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.raw, tt.target)
+			if (err != nil) != tt.wantError {
+				t.Errorf("Expected error: %v, got %v", tt.wantError, err)
+			}
+		})
+	}
+}
+
+func TestDecodeLeavesTargetOnError(t *testing.T) {
+	intent := types.Intent{Intent: "previous"}
+
+	if _, err := Decode(`{"intent":"","confidence":0.5}`, &intent); err == nil {
+		t.Fatal("Expected validation error")
+	}
+	if intent.Intent != "previous" {
+		t.Errorf("Expected target to be unchanged, got %+v", intent)
+	}
+}
@@ -0,0 +1,300 @@
+// Package memory keeps facts about users, such as their name and preferences, across sessions
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned by Forget when the user has no fact with the given ID
+var ErrNotFound = errors.New("fact not found")
+
+// ErrForgotten is returned by Remember when the user's memory was deleted after the facts were learned
+var ErrForgotten = errors.New("memory was deleted in the meantime")
+
+// Fact is something learned about a user
+type Fact struct {
+	ID        string    `json:"id"`
+	Key       string    `json:"key"`  // what the fact is about, such as "preferred_name"
+	Text      string    `json:"text"` // the fact itself, as a sentence
+	SessionID string    `json:"session_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store keeps the facts of each user in a <user>.json file of a directory
+//
+// A fact with the key of a stored one replaces it, so a user who changes
+// their mind isn't remembered both ways. Each user keeps at most maxFacts
+// facts; the least recently updated are dropped first.
+type Store struct {
+	dir      string
+	maxFacts int
+
+	mu          sync.Mutex
+	generations map[string]uint64 // per user, how often facts were deleted on request, see Generation
+}
+
+// NewStore creates a store in dir, creating the directory if needed; maxFacts <= 0 keeps every fact
+func NewStore(dir string, maxFacts int) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create memory directory: %w", err)
+	}
+	return &Store{dir: dir, maxFacts: maxFacts, generations: make(map[string]uint64)}, nil
+}
+
+// Generation returns a counter that changes whenever the user's facts are deleted on request
+//
+// Facts learned in the background pass the generation read before learning
+// began to Remember, so they aren't stored if the user asked to be forgotten
+// meanwhile. It starts at 0 and is kept in this process only: when several
+// instances share the memory directory, a deletion made through one of them
+// doesn't stop facts that another is still learning from being stored.
+func (s *Store) Generation(userID string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.generations[userID]
+}
+
+// List returns the facts known about a user, most recently updated first
+func (s *Store) List(userID string) ([]Fact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load(userID)
+}
+
+// Remember stores facts learned in a session and returns the user's facts afterwards
+//
+// generation is the user's Generation from before the facts were learned;
+// if their facts have been deleted since, nothing is stored and ErrForgotten is returned.
+func (s *Store) Remember(userID, sessionID string, generation uint64, facts []Fact) ([]Fact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.generations[userID] != generation {
+		return nil, ErrForgotten
+	}
+	stored, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, fact := range facts {
+		fact.Key = strings.TrimSpace(fact.Key)
+		fact.Text = strings.TrimSpace(fact.Text)
+		if fact.Text == "" {
+			continue
+		}
+
+		i := indexOf(stored, fact)
+		if i < 0 {
+			stored = append(stored, Fact{ID: uuid.New().String(), CreatedAt: now})
+			i = len(stored) - 1
+		}
+		stored[i].Key = fact.Key
+		stored[i].Text = fact.Text
+		stored[i].SessionID = sessionID
+		stored[i].UpdatedAt = now
+	}
+
+	sortFacts(stored)
+	if s.maxFacts > 0 && len(stored) > s.maxFacts {
+		stored = stored[:s.maxFacts]
+	}
+	if err := s.save(userID, stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// Forget removes one fact of a user, on the user's request
+func (s *Store) Forget(userID, id string) (Fact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.load(userID)
+	if err != nil {
+		return Fact{}, err
+	}
+	for i, fact := range stored {
+		if fact.ID == id {
+			s.generations[userID]++
+			return fact, s.save(userID, append(stored[:i], stored[i+1:]...))
+		}
+	}
+	return Fact{}, ErrNotFound
+}
+
+// ForgetKeys removes the facts of a user with the given keys and returns how many were removed
+func (s *Store) ForgetKeys(userID string, keys []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.load(userID)
+	if err != nil {
+		return 0, err
+	}
+	kept := stored[:0]
+	for _, fact := range stored {
+		if !containsKey(keys, fact.Key) {
+			kept = append(kept, fact)
+		}
+	}
+	removed := len(stored) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.save(userID, kept)
+}
+
+// ForgetAll removes every fact of a user, on the user's request, and returns how many there were
+func (s *Store) ForgetAll(userID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.load(userID)
+	if err != nil {
+		return 0, err
+	}
+	s.generations[userID]++
+	if err := os.Remove(s.path(userID)); err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to delete memory file: %w", err)
+	}
+	return len(stored), nil
+}
+
+// Recall returns up to limit facts of a user, the most relevant to query first
+//
+// Relevance is the number of character pairs a fact shares with the query,
+// which works for Chinese without word segmentation. When fewer facts
+// match, the most recently updated ones fill the remaining places.
+func (s *Store) Recall(userID, query string, limit int) ([]Fact, error) {
+	facts, err := s.List(userID)
+	if err != nil || len(facts) == 0 {
+		return facts, err
+	}
+
+	queryPairs := pairs(query)
+	scores := make(map[string]int, len(facts))
+	for _, fact := range facts {
+		for pair := range pairs(fact.Key + " " + fact.Text) {
+			if queryPairs[pair] {
+				scores[fact.ID]++
+			}
+		}
+	}
+	// facts is already newest first, so equal scores keep that order
+	sort.SliceStable(facts, func(i, j int) bool {
+		return scores[facts[i].ID] > scores[facts[j].ID]
+	})
+
+	if limit > 0 && len(facts) > limit {
+		facts = facts[:limit]
+	}
+	return facts, nil
+}
+
+func (s *Store) path(userID string) string {
+	// Escaping keeps any user ID, including one taken from a URL, inside the directory
+	return filepath.Join(s.dir, url.PathEscape(userID)+".json")
+}
+
+// load reads the facts of a user; s.mu must be held
+func (s *Store) load(userID string) ([]Fact, error) {
+	data, err := os.ReadFile(s.path(userID))
+	if os.IsNotExist(err) {
+		return []Fact{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memory file: %w", err)
+	}
+
+	var facts []Fact
+	if err := json.Unmarshal(data, &facts); err != nil {
+		return nil, fmt.Errorf("failed to parse memory file %s: %w", s.path(userID), err)
+	}
+	sortFacts(facts)
+	return facts, nil
+}
+
+// save writes the facts of a user; s.mu must be held
+func (s *Store) save(userID string, facts []Fact) error {
+	data, err := json.MarshalIndent(facts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal facts: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated file
+	path := s.path(userID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write memory file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write memory file: %w", err)
+	}
+	return nil
+}
+
+// indexOf finds the stored fact a new fact replaces: the one with its key, or with its text if it has no key
+func indexOf(stored []Fact, fact Fact) int {
+	for i, old := range stored {
+		if fact.Key != "" && old.Key == fact.Key || fact.Key == "" && old.Text == fact.Text {
+			return i
+		}
+	}
+	return -1
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k != "" && strings.TrimSpace(k) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// sortFacts orders facts most recently updated first, then by ID
+func sortFacts(facts []Fact) {
+	sort.Slice(facts, func(i, j int) bool {
+		if !facts[i].UpdatedAt.Equal(facts[j].UpdatedAt) {
+			return facts[i].UpdatedAt.After(facts[j].UpdatedAt)
+		}
+		return facts[i].ID < facts[j].ID
+	})
+}
+
+// pairs returns the pairs of adjacent letters or digits in text, lowercased
+func pairs(text string) map[string]bool {
+	var runes []rune
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			runes = append(runes, r)
+		} else {
+			runes = append(runes, ' ')
+		}
+	}
+
+	result := make(map[string]bool)
+	for i := 0; i+1 < len(runes); i++ {
+		if runes[i] != ' ' && runes[i+1] != ' ' {
+			result[string(runes[i:i+2])] = true
+		}
+	}
+	return result
+}
@@ -0,0 +1,178 @@
+package memory
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T, maxFacts int) *Store {
+	store, err := NewStore(t.TempDir(), maxFacts)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	return store
+}
+
+func TestRemember(t *testing.T) {
+	store := newTestStore(t, 0)
+
+	facts, err := store.Remember("u1", "s1", 0, []Fact{
+		{Key: "preferred_name", Text: "用户希望被称为小李"},
+		{Key: "favorite_singer", Text: "用户最喜欢的歌手是周杰伦"},
+		{Text: "  "},
+	})
+	if err != nil {
+		t.Fatalf("Remember failed: %v", err)
+	}
+	if len(facts) != 2 || facts[0].ID == "" || facts[0].SessionID != "s1" {
+		t.Fatalf("Remember() = %+v, want 2 facts", facts)
+	}
+
+	// A fact with a known key replaces the old one
+	time.Sleep(time.Millisecond)
+	facts, _ = store.Remember("u1", "s2", 0, []Fact{{Key: "favorite_singer", Text: "用户最喜欢的歌手是林俊杰"}})
+	if len(facts) != 2 || facts[0].Text != "用户最喜欢的歌手是林俊杰" || facts[0].SessionID != "s2" {
+		t.Errorf("Facts after an update = %+v", facts)
+	}
+	if facts[0].CreatedAt.Equal(facts[0].UpdatedAt) {
+		t.Error("Updated fact lost its creation time")
+	}
+
+	// Facts persist and belong to their user only
+	reopened, _ := NewStore(store.dir, 0)
+	if listed, _ := reopened.List("u1"); len(listed) != 2 {
+		t.Errorf("List() after reopening = %+v", listed)
+	}
+	if listed, _ := reopened.List("u2"); len(listed) != 0 {
+		t.Errorf("List() of another user = %+v", listed)
+	}
+}
+
+func TestRememberLimit(t *testing.T) {
+	store := newTestStore(t, 2)
+	for _, text := range []string{"第一条", "第二条", "第三条"} {
+		store.Remember("u1", "s1", 0, []Fact{{Text: text}})
+		time.Sleep(time.Millisecond)
+	}
+	facts, _ := store.List("u1")
+	if len(facts) != 2 || facts[0].Text != "第三条" || facts[1].Text != "第二条" {
+		t.Errorf("Facts beyond the limit = %+v, want the two newest", facts)
+	}
+}
+
+func TestForget(t *testing.T) {
+	store := newTestStore(t, 0)
+	facts, _ := store.Remember("u1", "s1", 0, []Fact{
+		{Key: "preferred_name", Text: "用户希望被称为小李"},
+		{Key: "favorite_singer", Text: "用户最喜欢的歌手是周杰伦"},
+		{Key: "city", Text: "用户住在杭州"},
+	})
+
+	// Facts stored together have no particular order, so pick one by key
+	var name Fact
+	for _, fact := range facts {
+		if fact.Key == "preferred_name" {
+			name = fact
+		}
+	}
+
+	forgotten, err := store.Forget("u1", name.ID)
+	if err != nil || forgotten.ID != name.ID {
+		t.Fatalf("Forget() = %+v, %v", forgotten, err)
+	}
+	if _, err := store.Forget("u1", name.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Forget of a missing fact error = %v, want ErrNotFound", err)
+	}
+
+	if n, err := store.ForgetKeys("u1", []string{"city", "unknown"}); err != nil || n != 1 {
+		t.Errorf("ForgetKeys() = %d, %v", n, err)
+	}
+
+	remaining, _ := store.List("u1")
+	generation := store.Generation("u1")
+	n, err := store.ForgetAll("u1")
+	if err != nil || n != len(remaining) {
+		t.Errorf("ForgetAll() = %d, %v, want %d", n, err, len(remaining))
+	}
+	if listed, _ := store.List("u1"); len(listed) != 0 {
+		t.Errorf("Facts after ForgetAll = %+v", listed)
+	}
+
+	// Facts learned before the deletion aren't stored
+	if _, err := store.Remember("u1", "s1", generation, []Fact{{Text: "用户住在杭州"}}); !errors.Is(err, ErrForgotten) {
+		t.Errorf("Remember() with a stale generation error = %v, want ErrForgotten", err)
+	}
+	if _, err := store.Remember("u1", "s1", store.Generation("u1"), []Fact{{Text: "用户住在杭州"}}); err != nil {
+		t.Errorf("Remember() after ForgetAll failed: %v", err)
+	}
+	if n, err := store.ForgetAll("nobody"); err != nil || n != 0 {
+		t.Errorf("ForgetAll() of an unknown user = %d, %v", n, err)
+	}
+}
+
+func TestRecall(t *testing.T) {
+	store := newTestStore(t, 0)
+	store.Remember("u1", "s1", 0, []Fact{{Key: "favorite_singer", Text: "用户最喜欢的歌手是周杰伦"}})
+	time.Sleep(time.Millisecond)
+	store.Remember("u1", "s1", 0, []Fact{{Key: "city", Text: "用户住在杭州"}})
+	time.Sleep(time.Millisecond)
+	store.Remember("u1", "s1", 0, []Fact{{Key: "preferred_name", Text: "用户希望被称为小李"}})
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"放一首我最喜欢的歌手的歌", []string{"favorite_singer", "preferred_name"}},
+		{"杭州明天天气怎么样", []string{"city", "preferred_name"}},
+		{"你好", []string{"preferred_name", "city"}}, // nothing matches: newest first
+	}
+	for _, tt := range tests {
+		facts, err := store.Recall("u1", tt.query, 2)
+		if err != nil {
+			t.Fatalf("Recall failed: %v", err)
+		}
+		if len(facts) != len(tt.want) {
+			t.Errorf("Recall(%q) = %+v, want %v", tt.query, facts, tt.want)
+			continue
+		}
+		for i := range facts {
+			if facts[i].Key != tt.want[i] {
+				t.Errorf("Recall(%q)[%d] = %s, want %s", tt.query, i, facts[i].Key, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestUserIDStaysInDirectory(t *testing.T) {
+	store := newTestStore(t, 0)
+	if _, err := store.Remember("../../etc/passwd", "s1", 0, []Fact{{Text: "x"}}); err != nil {
+		t.Fatalf("Remember failed: %v", err)
+	}
+	if facts, _ := store.List("../../etc/passwd"); len(facts) != 1 {
+		t.Errorf("List() = %+v, want the fact just stored", facts)
+	}
+	if got := store.path("../x"); got != filepath.Join(store.dir, "..%2Fx.json") {
+		t.Errorf("path() = %s", got)
+	}
+}
+
+func TestUserToken(t *testing.T) {
+	token := UserToken("secret", "user-1")
+	tests := []struct {
+		secret, userID, token string
+		want                  bool
+	}{
+		{"secret", "user-1", token, true},
+		{"secret", "user-2", token, false},
+		{"other", "user-1", token, false},
+		{"secret", "user-1", "", false},
+		{"", "user-1", UserToken("", "user-1"), false},
+	}
+	for _, tt := range tests {
+		if got := ValidUserToken(tt.secret, tt.userID, tt.token); got != tt.want {
+			t.Errorf("ValidUserToken(%q, %q, %q) = %v, want %v", tt.secret, tt.userID, tt.token, got, tt.want)
+		}
+	}
+}
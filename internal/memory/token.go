@@ -0,0 +1,25 @@
+package memory
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// UserToken returns the token that grants access to the memory of a user
+//
+// It is the hex-encoded HMAC-SHA256 of the user ID keyed with secret, so the
+// service that assigns user IDs can hand out tokens without asking the server.
+func UserToken(secret, userID string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(userID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidUserToken reports whether token grants access to the memory of a user; an empty secret grants nothing
+func ValidUserToken(secret, userID, token string) bool {
+	if secret == "" || token == "" {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(UserToken(secret, userID)))
+}
@@ -0,0 +1,415 @@
+// Package plugin loads executor actions described by manifests in a plugins directory
+//
+// Each plugin lives in its own subdirectory with a plugin.yaml (or plugin.json)
+// manifest. The implementation is either an executable that reads a JSON
+// request on stdin and writes a JSON result to stdout, or an HTTP endpoint on
+// the local machine that receives the same request as a POST body.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/executor"
+	"github.com/deca/voicepilot-eino/internal/security"
+	"github.com/deca/voicepilot-eino/internal/telemetry"
+	"github.com/deca/voicepilot-eino/pkg/types"
+	"github.com/goccy/go-yaml"
+)
+
+// Security classes of a plugin action
+const (
+	SecuritySafe      = "safe"      // allowed, runs without confirmation
+	SecurityConfirm   = "confirm"   // allowed after the user confirms (default)
+	SecurityDangerous = "dangerous" // only allowed with safe mode off, and after the user confirms
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+	maxOutputBytes = 1 << 20
+)
+
+// manifestNames are the file names a plugin directory is searched for, in order
+var manifestNames = []string{"plugin.yaml", "plugin.yml", "plugin.json"}
+
+// namePattern restricts action names to what tool-calling APIs accept
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// Manifest describes a plugin action
+type Manifest struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"` // JSON schema of the parameters object
+	Security    string                 `json:"security,omitempty"`   // one of the Security* classes
+	Timeout     int                    `json:"timeout_seconds,omitempty"`
+	Exec        *ExecSpec              `json:"exec,omitempty"`
+	HTTP        *HTTPSpec              `json:"http,omitempty"`
+}
+
+// ExecSpec runs an executable; a relative command path is resolved against the plugin directory
+type ExecSpec struct {
+	Command []string `json:"command"`
+}
+
+// HTTPSpec posts to an endpoint on the local machine
+type HTTPSpec struct {
+	URL string `json:"url"`
+}
+
+// Request is the JSON sent to a plugin
+type Request struct {
+	Action     string                 `json:"action"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+// Plugin is a loaded plugin action
+type Plugin struct {
+	Manifest Manifest
+	Dir      string
+	client   *http.Client
+}
+
+// LoadManifest reads a manifest from a YAML or JSON file
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin manifest: %w", err)
+	}
+
+	// Convert YAML to JSON first so nested schemas decode into JSON-compatible maps
+	if !strings.EqualFold(filepath.Ext(path), ".json") {
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return nil, fmt.Errorf("failed to parse plugin manifest: %w", err)
+		}
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse plugin manifest: %w", err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (m *Manifest) validate() error {
+	if !namePattern.MatchString(m.Name) {
+		return fmt.Errorf("invalid plugin name %q: use lowercase letters, digits and underscores", m.Name)
+	}
+	if m.Description == "" {
+		return fmt.Errorf("plugin %s has no description", m.Name)
+	}
+
+	switch m.Security {
+	case "":
+		m.Security = SecurityConfirm
+	case SecuritySafe, SecurityConfirm, SecurityDangerous:
+	default:
+		return fmt.Errorf("plugin %s has unknown security class %q", m.Name, m.Security)
+	}
+
+	if (m.Exec == nil) == (m.HTTP == nil) {
+		return fmt.Errorf("plugin %s must set exactly one of exec or http", m.Name)
+	}
+	if m.Exec != nil && len(m.Exec.Command) == 0 {
+		return fmt.Errorf("plugin %s has an empty exec command", m.Name)
+	}
+	if m.HTTP != nil {
+		if err := checkLocalURL(m.HTTP.URL); err != nil {
+			return fmt.Errorf("plugin %s: %w", m.Name, err)
+		}
+	}
+
+	if m.Parameters == nil {
+		m.Parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	return nil
+}
+
+// checkLocalURL only accepts http(s) URLs on a loopback address
+func checkLocalURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid http url %q", raw)
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("http url %q is not on the local machine", raw)
+}
+
+// Discover loads the plugins in the subdirectories of dir
+//
+// An empty or missing directory means no plugins. Plugins with invalid
+// manifests are logged and skipped so that one broken plugin does not stop
+// the server.
+func Discover(dir string) ([]*Plugin, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read plugins directory: %w", err)
+	}
+
+	var plugins []*Plugin
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		pluginDir := filepath.Join(dir, entry.Name())
+		path := findManifest(pluginDir)
+		if path == "" {
+			continue
+		}
+
+		m, err := LoadManifest(path)
+		if err != nil {
+			log.Printf("Warning: skipping plugin in %s: %v", pluginDir, err)
+			continue
+		}
+		plugins = append(plugins, New(*m, pluginDir))
+	}
+	return plugins, nil
+}
+
+func findManifest(dir string) string {
+	for _, name := range manifestNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// New creates a plugin from a validated manifest
+func New(m Manifest, dir string) *Plugin {
+	return &Plugin{
+		Manifest: m,
+		Dir:      dir,
+		client: &http.Client{
+			Transport: telemetry.Transport(nil),
+			// The manifest URL is checked to be local, the target of a redirect isn't
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Install discovers the plugins in dir and registers them with the executor and security manager
+//
+// Plugins whose name clashes with an existing action are skipped. The
+// registered plugins are returned.
+func Install(dir string, e *executor.Executor, sm *security.SecurityManager) ([]*Plugin, error) {
+	plugins, err := Discover(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var installed []*Plugin
+	for _, p := range plugins {
+		if e.HasAction(p.Manifest.Name) {
+			log.Printf("Warning: skipping plugin %s: action already registered", p.Manifest.Name)
+			continue
+		}
+		p.Register(e, sm)
+		installed = append(installed, p)
+	}
+	return installed, nil
+}
+
+// Register adds the plugin action to the executor and applies its security class
+func (p *Plugin) Register(e *executor.Executor, sm *security.SecurityManager) {
+	e.RegisterAction(p.Spec(), p.Handle)
+
+	switch p.Manifest.Security {
+	case SecuritySafe:
+		sm.AddAllowedAction(p.Manifest.Name)
+		sm.SetRequiresConfirmation(p.Manifest.Name, false)
+	case SecurityDangerous:
+		sm.AddUnsafeAction(p.Manifest.Name)
+		sm.SetRequiresConfirmation(p.Manifest.Name, true)
+	default:
+		sm.AddAllowedAction(p.Manifest.Name)
+		sm.SetRequiresConfirmation(p.Manifest.Name, true)
+	}
+
+	log.Printf("Registered plugin action %s (%s) from %s", p.Manifest.Name, p.Manifest.Security, p.Dir)
+}
+
+// Spec describes the plugin action to the LLM
+func (p *Plugin) Spec() executor.ActionSpec {
+	return executor.ActionSpec{
+		Name:        p.Manifest.Name,
+		Description: p.Manifest.Description,
+		Parameters:  p.Manifest.Parameters,
+	}
+}
+
+// Handle runs the plugin; it is the plugin's executor.ActionHandler
+func (p *Plugin) Handle(ctx context.Context, params map[string]interface{}) *types.ExecutionResult {
+	timeout := defaultTimeout
+	if p.Manifest.Timeout > 0 {
+		timeout = time.Duration(p.Manifest.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if params == nil {
+		params = make(map[string]interface{})
+	}
+	body, err := json.Marshal(Request{Action: p.Manifest.Name, Parameters: params})
+	if err != nil {
+		return &types.ExecutionResult{Success: false, Error: fmt.Sprintf("插件参数无效：%v", err)}
+	}
+
+	var output []byte
+	if p.Manifest.Exec != nil {
+		output, err = p.runExec(ctx, body)
+	} else {
+		output, err = p.postHTTP(ctx, body)
+	}
+	if err != nil {
+		log.Printf("Plugin %s failed: %v", p.Manifest.Name, err)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return &types.ExecutionResult{Success: false, Error: fmt.Sprintf("插件 %s 执行超时", p.Manifest.Name)}
+		}
+		return &types.ExecutionResult{Success: false, Error: fmt.Sprintf("插件 %s 执行失败：%v", p.Manifest.Name, err)}
+	}
+
+	var result types.ExecutionResult
+	if err := json.Unmarshal(output, &result); err != nil {
+		log.Printf("Plugin %s returned invalid JSON: %v, output: %s", p.Manifest.Name, err, output)
+		return &types.ExecutionResult{Success: false, Error: fmt.Sprintf("插件 %s 返回了无效的结果", p.Manifest.Name)}
+	}
+	if !result.Success && result.Error == "" {
+		result.Error = fmt.Sprintf("插件 %s 执行失败", p.Manifest.Name)
+	}
+	return &result
+}
+
+// runExec writes the request to the executable's stdin and returns its stdout
+//
+// The executable runs with a minimal environment, so secrets of the server
+// don't leak to it, and is killed once it writes more than maxOutputBytes.
+func (p *Plugin) runExec(ctx context.Context, body []byte) ([]byte, error) {
+	argv := p.Manifest.Exec.Command
+	name := argv[0]
+	if !filepath.IsAbs(name) && strings.ContainsRune(name, filepath.Separator) {
+		name = filepath.Join(p.Dir, name)
+	}
+
+	ctx, kill := context.WithCancel(ctx)
+	defer kill()
+
+	cmd := exec.CommandContext(ctx, name, argv[1:]...)
+	cmd.Dir = p.Dir
+	cmd.Env = pluginEnv(p.Dir)
+	cmd.Stdin = bytes.NewReader(body)
+	stdout := &limitedWriter{limit: maxOutputBytes, exceeded: kill}
+	stderr := &limitedWriter{limit: maxOutputBytes, exceeded: kill}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if stdout.overflow || stderr.overflow {
+		return nil, errOutputTooLarge
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.buf.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, truncate(msg, 500))
+		}
+		return nil, err
+	}
+	return stdout.buf.Bytes(), nil
+}
+
+// pluginEnv is the environment exec plugins run with: nothing inherited from the server
+func pluginEnv(dir string) []string {
+	return []string{
+		"PATH=/usr/local/bin:/usr/bin:/bin",
+		"HOME=" + dir,
+		"TMPDIR=" + os.TempDir(),
+		"LANG=C.UTF-8",
+	}
+}
+
+// errOutputTooLarge is returned when a plugin writes more than maxOutputBytes
+var errOutputTooLarge = fmt.Errorf("output exceeds %d bytes", maxOutputBytes)
+
+// limitedWriter keeps up to limit bytes and fails once more are written, calling exceeded
+type limitedWriter struct {
+	buf      bytes.Buffer
+	limit    int
+	exceeded func()
+	overflow bool
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.buf.Len()+len(p) > w.limit {
+		w.overflow = true
+		w.exceeded()
+		return 0, errOutputTooLarge
+	}
+	return w.buf.Write(p)
+}
+
+// postHTTP posts the request to the plugin endpoint and returns the response body
+func (p *Plugin) postHTTP(ctx context.Context, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Manifest.HTTP.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxOutputBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxOutputBytes {
+		return nil, fmt.Errorf("response exceeds %d bytes", maxOutputBytes)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, truncate(string(data), 500))
+	}
+	return data, nil
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
@@ -0,0 +1,239 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+	"github.com/deca/voicepilot-eino/internal/executor"
+	"github.com/deca/voicepilot-eino/internal/security"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// echoScript replies with the received request as the result data
+const echoScript = `#!/bin/sh
+request=$(cat)
+printf '{"success": true, "message": "ok", "data": %s}' "$(printf '%s' "$request" | sed 's/"/\\"/g; s/^/"/; s/$/"/')"
+`
+
+func writePlugin(t *testing.T, dir, name, manifest string, files map[string]string) {
+	pluginDir := filepath.Join(dir, name)
+	if err := os.MkdirAll(pluginDir, 0755); err != nil {
+		t.Fatalf("Failed to create plugin dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(pluginDir, "plugin.yaml"), []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(pluginDir, file), []byte(content), 0755); err != nil {
+			t.Fatalf("Failed to write %s: %v", file, err)
+		}
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  bool
+	}{
+		{"exec plugin", "name: weather\ndescription: 查询天气\nexec:\n  command: [./run.sh]\n", false},
+		{"http plugin", "name: lights\ndescription: 控制灯光\nsecurity: safe\nhttp:\n  url: http://127.0.0.1:9000/run\n", false},
+		{"missing name", "description: x\nexec:\n  command: [a]\n", true},
+		{"invalid name", "name: Bad-Name\ndescription: x\nexec:\n  command: [a]\n", true},
+		{"missing description", "name: a\nexec:\n  command: [a]\n", true},
+		{"unknown security class", "name: a\ndescription: x\nsecurity: maybe\nexec:\n  command: [a]\n", true},
+		{"no implementation", "name: a\ndescription: x\n", true},
+		{"both implementations", "name: a\ndescription: x\nexec:\n  command: [a]\nhttp:\n  url: http://localhost/\n", true},
+		{"remote http", "name: a\ndescription: x\nhttp:\n  url: http://example.com/run\n", true},
+		{"malformed yaml", "name: [\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "plugin.yaml")
+			os.WriteFile(path, []byte(tt.manifest), 0644)
+
+			m, err := LoadManifest(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (m.Security == "" || m.Parameters["type"] != "object") {
+				t.Errorf("Defaults not applied: %+v", m)
+			}
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "good", `
+name: weather
+description: 查询城市天气
+parameters:
+  type: object
+  properties:
+    city:
+      type: string
+      description: 城市名称
+  required: [city]
+exec:
+  command: [./run.sh]
+`, nil)
+	writePlugin(t, dir, "broken", "name: broken\n", nil)
+	os.MkdirAll(filepath.Join(dir, "no-manifest"), 0755)
+
+	plugins, err := Discover(dir)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if len(plugins) != 1 || plugins[0].Manifest.Name != "weather" {
+		t.Fatalf("Discover() = %+v, want only the weather plugin", plugins)
+	}
+
+	// The schema must be JSON-compatible to be offered as a tool
+	if _, err := json.Marshal(plugins[0].Spec().Parameters); err != nil {
+		t.Errorf("Parameters are not JSON-compatible: %v", err)
+	}
+
+	if plugins, err := Discover(filepath.Join(dir, "missing")); err != nil || plugins != nil {
+		t.Errorf("Discover(missing) = %v, %v, want no plugins", plugins, err)
+	}
+}
+
+func TestHandleExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("exec plugin test uses a shell script")
+	}
+
+	dir := t.TempDir()
+	writePlugin(t, dir, "echo", "name: echo\ndescription: echo\nexec:\n  command: [./run.sh]\n", map[string]string{"run.sh": echoScript})
+	writePlugin(t, dir, "fail", "name: fail\ndescription: fail\nexec:\n  command: [sh, -c, 'echo boom >&2; exit 3']\n", nil)
+	writePlugin(t, dir, "garbage", "name: garbage\ndescription: garbage\nexec:\n  command: [echo, not json]\n", nil)
+	writePlugin(t, dir, "slow", "name: slow\ndescription: slow\ntimeout_seconds: 1\nexec:\n  command: [sleep, '5']\n", nil)
+	writePlugin(t, dir, "flood", "name: flood\ndescription: flood\ntimeout_seconds: 20\nexec:\n  command: [yes]\n", nil)
+	writePlugin(t, dir, "env", "name: env\ndescription: env\nexec:\n  command: [sh, -c, 'printf \"{\\\"success\\\": true, \\\"data\\\": \\\"%s\\\"}\" \"$PLUGIN_TEST_SECRET\"']\n", nil)
+	t.Setenv("PLUGIN_TEST_SECRET", "leaked")
+
+	plugins, err := Discover(dir)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	byName := make(map[string]*Plugin)
+	for _, p := range plugins {
+		byName[p.Manifest.Name] = p
+	}
+
+	result := byName["echo"].Handle(context.Background(), map[string]interface{}{"city": "北京"})
+	if !result.Success {
+		t.Fatalf("echo plugin failed: %s", result.Error)
+	}
+	var req Request
+	if err := json.Unmarshal([]byte(result.Data), &req); err != nil {
+		t.Fatalf("Plugin did not receive a JSON request: %v (%q)", err, result.Data)
+	}
+	if req.Action != "echo" || req.Parameters["city"] != "北京" {
+		t.Errorf("Request = %+v", req)
+	}
+
+	if result := byName["env"].Handle(context.Background(), nil); !result.Success || result.Data != "" {
+		t.Errorf("env plugin = %+v, want the server environment hidden", result)
+	}
+
+	for name, want := range map[string]string{"fail": "boom", "garbage": "无效", "slow": "超时", "flood": "exceeds"} {
+		result := byName[name].Handle(context.Background(), nil)
+		if result.Success || !strings.Contains(result.Error, want) {
+			t.Errorf("%s plugin: success=%v error=%q, want error containing %q", name, result.Success, result.Error, want)
+		}
+	}
+}
+
+func TestHandleHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if req.Parameters["room"] == "missing" {
+			http.Error(w, "no such room", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(types.ExecutionResult{Success: true, Message: "已打开" + req.Parameters["room"].(string) + "的灯"})
+	}))
+	defer server.Close()
+
+	p := New(Manifest{Name: "lights", Description: "灯光", HTTP: &HTTPSpec{URL: server.URL}}, t.TempDir())
+
+	result := p.Handle(context.Background(), map[string]interface{}{"room": "客厅"})
+	if !result.Success || result.Message != "已打开客厅的灯" {
+		t.Errorf("Handle() = %+v", result)
+	}
+
+	result = p.Handle(context.Background(), map[string]interface{}{"room": "missing"})
+	if result.Success || !strings.Contains(result.Error, "404") {
+		t.Errorf("Expected HTTP error to be reported, got %+v", result)
+	}
+
+	// Redirects are not followed, the manifest URL is the only one allowed
+	redirect := httptest.NewServer(http.RedirectHandler(server.URL, http.StatusFound))
+	defer redirect.Close()
+	p = New(Manifest{Name: "lights", Description: "灯光", HTTP: &HTTPSpec{URL: redirect.URL}}, t.TempDir())
+	result = p.Handle(context.Background(), map[string]interface{}{"room": "客厅"})
+	if result.Success || !strings.Contains(result.Error, "302") {
+		t.Errorf("Expected the redirect to be reported, got %+v", result)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	config.AppConfig = &config.Config{EnableSafeMode: true}
+	defer func() { config.AppConfig = &config.Config{} }()
+
+	dir := t.TempDir()
+	writePlugin(t, dir, "safe", "name: lights\ndescription: 灯光\nsecurity: safe\nhttp:\n  url: http://localhost:9/\n", nil)
+	writePlugin(t, dir, "confirm", "name: order\ndescription: 下单\nhttp:\n  url: http://localhost:9/\n", nil)
+	writePlugin(t, dir, "dangerous", "name: wipe\ndescription: 清空\nsecurity: dangerous\nhttp:\n  url: http://localhost:9/\n", nil)
+	writePlugin(t, dir, "clash", "name: open_app\ndescription: 重复\nhttp:\n  url: http://localhost:9/\n", nil)
+
+	exec := executor.NewExecutor(nil)
+	sm := security.NewSecurityManager()
+	installed, err := Install(dir, exec, sm)
+	if err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if len(installed) != 3 {
+		t.Errorf("Installed %d plugins, want 3 (open_app clashes with a built-in action)", len(installed))
+	}
+
+	tools := make(map[string]bool)
+	for _, tool := range exec.Tools() {
+		tools[tool.Function.Name] = true
+	}
+	if !tools["lights"] || !tools["order"] || !tools["wipe"] {
+		t.Errorf("Plugin actions missing from tools: %v", tools)
+	}
+
+	if decision, err := sm.ValidateAction("lights", nil); err != nil || decision.Outcome != security.OutcomeAllow {
+		t.Errorf("safe plugin: %+v, %v", decision, err)
+	}
+	if decision, err := sm.ValidateAction("order", nil); err != nil || decision.Outcome != security.OutcomeConfirm {
+		t.Errorf("confirm plugin: %+v, %v", decision, err)
+	}
+	var denial *security.DenialError
+	if _, err := sm.ValidateAction("wipe", nil); !errors.As(err, &denial) || denial.Reason != security.ReasonSafeMode {
+		t.Errorf("dangerous plugin in safe mode: %v", err)
+	}
+
+	config.AppConfig.EnableSafeMode = false
+	if decision, err := sm.ValidateAction("wipe", nil); err != nil || decision.Outcome != security.OutcomeConfirm {
+		t.Errorf("dangerous plugin outside safe mode: %+v, %v", decision, err)
+	}
+}
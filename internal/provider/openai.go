@@ -0,0 +1,316 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+	"github.com/deca/voicepilot-eino/internal/sse"
+	"github.com/deca/voicepilot-eino/internal/telemetry"
+)
+
+func init() {
+	RegisterASR("openai", func(cfg *config.Config) (ASRProvider, error) {
+		return NewOpenAIProvider(cfg), nil
+	})
+	RegisterTTS("openai", func(cfg *config.Config) (TTSProvider, error) {
+		return NewOpenAIProvider(cfg), nil
+	})
+	RegisterChat("openai", func(cfg *config.Config) (ChatProvider, error) {
+		return NewOpenAIProvider(cfg), nil
+	})
+}
+
+// OpenAIProvider talks to any OpenAI-compatible HTTP API (OpenAI, vLLM, Ollama, LocalAI, ...)
+type OpenAIProvider struct {
+	apiKey      string
+	baseURL     string
+	chatModel   string
+	asrModel    string
+	ttsModel    string
+	ttsVoice    string
+	ttsFormat   string
+	maxTokens   int
+	temperature float64
+	audioPath   string
+	httpClient  *http.Client
+	// streamClient has no overall timeout; streamed responses are bounded by the request context
+	streamClient *http.Client
+}
+
+// StatusError is returned when the API answers with a non-200 status
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// HTTPStatus returns the status code the API answered with
+func (e *StatusError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// NewOpenAIProvider creates an OpenAI-compatible provider from configuration
+func NewOpenAIProvider(cfg *config.Config) *OpenAIProvider {
+	return &OpenAIProvider{
+		apiKey:      cfg.OpenAIAPIKey,
+		baseURL:     strings.TrimRight(cfg.OpenAIBaseURL, "/"),
+		chatModel:   cfg.LLMModel,
+		asrModel:    cfg.OpenAIASRModel,
+		ttsModel:    cfg.OpenAITTSModel,
+		ttsVoice:    cfg.OpenAITTSVoice,
+		ttsFormat:   cfg.TTSEncoding,
+		maxTokens:   cfg.LLMMaxTokens,
+		temperature: cfg.LLMTemperature,
+		audioPath:   cfg.StaticAudioPath,
+		httpClient: &http.Client{
+			Timeout:   60 * time.Second,
+			Transport: telemetry.Transport(nil),
+		},
+		streamClient: &http.Client{Transport: telemetry.Transport(nil)},
+	}
+}
+
+// ChatCompletion performs chat completion via POST /chat/completions
+func (p *OpenAIProvider) ChatCompletion(ctx context.Context, messages []Message) (string, error) {
+	log.Printf("Starting OpenAI-compatible chat completion with %d messages", len(messages))
+
+	reqBody := map[string]interface{}{
+		"model":       p.chatModel,
+		"messages":    messages,
+		"max_tokens":  p.maxTokens,
+		"temperature": p.temperature,
+		"stream":      false,
+	}
+
+	respBody, err := p.postJSON(ctx, "/chat/completions", reqBody)
+	if err != nil {
+		return "", fmt.Errorf("chat completion failed: %w", err)
+	}
+	telemetry.RecordLLMUsage("openai", respBody)
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("no choices in response")
+	}
+
+	return result.Choices[0].Message.Content, nil
+}
+
+// ChatCompletionWithTools performs chat completion with function calling via POST /chat/completions
+func (p *OpenAIProvider) ChatCompletionWithTools(ctx context.Context, messages []Message, tools []Tool, toolChoice string) ([]ToolCall, string, error) {
+	log.Printf("Starting OpenAI-compatible chat completion with %d messages and %d tools", len(messages), len(tools))
+
+	reqBody := map[string]interface{}{
+		"model":       p.chatModel,
+		"messages":    messages,
+		"tools":       tools,
+		"tool_choice": toolChoice,
+		"max_tokens":  p.maxTokens,
+		"temperature": p.temperature,
+		"stream":      false,
+	}
+
+	respBody, err := p.postJSON(ctx, "/chat/completions", reqBody)
+	if err != nil {
+		return nil, "", fmt.Errorf("chat completion failed: %w", err)
+	}
+	telemetry.RecordLLMUsage("openai", respBody)
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content   string     `json:"content"`
+				ToolCalls []ToolCall `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(result.Choices) == 0 {
+		return nil, "", fmt.Errorf("no choices in response")
+	}
+
+	msg := result.Choices[0].Message
+	return msg.ToolCalls, msg.Content, nil
+}
+
+// ChatCompletionStream performs streaming chat completion via POST /chat/completions
+func (p *OpenAIProvider) ChatCompletionStream(ctx context.Context, messages []Message) (<-chan ChatChunk, error) {
+	log.Printf("Starting OpenAI-compatible streaming chat completion with %d messages", len(messages))
+
+	reqBytes, err := json.Marshal(map[string]interface{}{
+		"model":       p.chatModel,
+		"messages":    messages,
+		"max_tokens":  p.maxTokens,
+		"temperature": p.temperature,
+		"stream":      true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/chat/completions", bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.streamClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	return sse.ReadChatStream(ctx, resp.Body), nil
+}
+
+// ASR transcribes an audio file via POST /audio/transcriptions
+func (p *OpenAIProvider) ASR(ctx context.Context, audioPath string) (string, error) {
+	log.Printf("Starting OpenAI-compatible ASR for audio file: %s", audioPath)
+
+	file, err := os.Open(audioPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer file.Close()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writer.WriteField("model", p.asrModel); err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+	part, err := writer.CreateFormFile("file", filepath.Base(audioPath))
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return "", fmt.Errorf("failed to read audio file: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/audio/transcriptions", &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	respBody, err := p.do(req)
+	if err != nil {
+		return "", fmt.Errorf("ASR failed: %w", err)
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if result.Text == "" {
+		return "", fmt.Errorf("no recognition result received")
+	}
+
+	return result.Text, nil
+}
+
+// TTS synthesizes speech via POST /audio/speech and saves it to the static audio directory
+func (p *OpenAIProvider) TTS(ctx context.Context, text string) (string, error) {
+	log.Printf("Starting OpenAI-compatible TTS for text: %s", text)
+
+	reqBody := map[string]interface{}{
+		"model":           p.ttsModel,
+		"input":           text,
+		"voice":           p.ttsVoice,
+		"response_format": p.ttsFormat,
+	}
+
+	audio, err := p.postJSON(ctx, "/audio/speech", reqBody)
+	if err != nil {
+		return "", fmt.Errorf("TTS failed: %w", err)
+	}
+	if len(audio) == 0 {
+		return "", fmt.Errorf("empty audio in response")
+	}
+
+	filename := fmt.Sprintf("tts_%d.%s", time.Now().UnixNano(), p.ttsFormat)
+	if err := os.WriteFile(filepath.Join(p.audioPath, filename), audio, 0644); err != nil {
+		return "", fmt.Errorf("failed to save audio file: %w", err)
+	}
+
+	return fmt.Sprintf("/static/audio/%s", filename), nil
+}
+
+func (p *OpenAIProvider) postJSON(ctx context.Context, path string, body interface{}) ([]byte, error) {
+	reqBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+path, bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return p.do(req)
+}
+
+func (p *OpenAIProvider) do(req *http.Request) ([]byte, error) {
+	// Self-hosted servers often run without authentication
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	return respBody, nil
+}
@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+)
+
+// Message represents a chat message sent to a ChatProvider
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ASRProvider converts a recorded audio file to text
+type ASRProvider interface {
+	ASR(ctx context.Context, audioPath string) (string, error)
+}
+
+// TTSProvider converts text to speech and returns a URL the client can fetch the audio from
+type TTSProvider interface {
+	TTS(ctx context.Context, text string) (string, error)
+}
+
+// ChatProvider performs LLM chat completion
+type ChatProvider interface {
+	ChatCompletion(ctx context.Context, messages []Message) (string, error)
+}
+
+// StreamingASROptions describes the audio pushed into a streaming ASR session
+type StreamingASROptions struct {
+	Format     string // "pcm" or "opus"
+	SampleRate int
+}
+
+// StreamingASRResult is a transcript update produced while audio is still arriving
+type StreamingASRResult struct {
+	Text  string `json:"text"`
+	Final bool   `json:"final"`
+}
+
+// StreamingASRSession accepts audio incrementally and reports partial transcripts
+type StreamingASRSession interface {
+	SendAudio(chunk []byte) error
+	Results() <-chan StreamingASRResult
+	Finish(ctx context.Context) (string, error)
+	Close() error
+}
+
+// StreamingASRProvider is implemented by ASR providers that can transcribe audio while it is recorded
+type StreamingASRProvider interface {
+	StartStreamingASR(ctx context.Context, opts StreamingASROptions) (StreamingASRSession, error)
+}
+
+// Providers bundles the speech and language providers used by the workflow
+type Providers struct {
+	ASR  ASRProvider
+	TTS  TTSProvider
+	Chat ChatProvider
+}
+
+// Factory functions build a provider from the application configuration
+type (
+	ASRFactory  func(cfg *config.Config) (ASRProvider, error)
+	TTSFactory  func(cfg *config.Config) (TTSProvider, error)
+	ChatFactory func(cfg *config.Config) (ChatProvider, error)
+)
+
+var (
+	registryMu    sync.RWMutex
+	asrFactories  = make(map[string]ASRFactory)
+	ttsFactories  = make(map[string]TTSFactory)
+	chatFactories = make(map[string]ChatFactory)
+)
+
+// RegisterASR registers an ASR provider factory under the given name
+func RegisterASR(name string, factory ASRFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	asrFactories[name] = factory
+}
+
+// RegisterTTS registers a TTS provider factory under the given name
+func RegisterTTS(name string, factory TTSFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	ttsFactories[name] = factory
+}
+
+// RegisterChat registers a chat provider factory under the given name
+func RegisterChat(name string, factory ChatFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	chatFactories[name] = factory
+}
+
+// New builds the providers selected by cfg.ASRProvider, cfg.TTSProvider and cfg.LLMProvider
+func New(cfg *config.Config) (*Providers, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	asrFactory, ok := asrFactories[cfg.ASRProvider]
+	if !ok {
+		return nil, fmt.Errorf("unknown ASR provider %q (available: %v)", cfg.ASRProvider, names(asrFactories))
+	}
+	ttsFactory, ok := ttsFactories[cfg.TTSProvider]
+	if !ok {
+		return nil, fmt.Errorf("unknown TTS provider %q (available: %v)", cfg.TTSProvider, names(ttsFactories))
+	}
+	chatFactory, ok := chatFactories[cfg.LLMProvider]
+	if !ok {
+		return nil, fmt.Errorf("unknown LLM provider %q (available: %v)", cfg.LLMProvider, names(chatFactories))
+	}
+
+	asr, err := asrFactory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ASR provider %s: %w", cfg.ASRProvider, err)
+	}
+	tts, err := ttsFactory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TTS provider %s: %w", cfg.TTSProvider, err)
+	}
+	chat, err := chatFactory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LLM provider %s: %w", cfg.LLMProvider, err)
+	}
+
+	return &Providers{ASR: asr, TTS: tts, Chat: chat}, nil
+}
+
+func names[F any](factories map[string]F) []string {
+	result := make([]string, 0, len(factories))
+	for name := range factories {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,243 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+)
+
+func testConfig(baseURL string) *config.Config {
+	return &config.Config{
+		ASRProvider:     "openai",
+		TTSProvider:     "openai",
+		LLMProvider:     "openai",
+		OpenAIBaseURL:   baseURL,
+		OpenAIASRModel:  "whisper-1",
+		OpenAITTSModel:  "tts-1",
+		OpenAITTSVoice:  "alloy",
+		TTSEncoding:     "mp3",
+		LLMModel:        "test-model",
+		LLMMaxTokens:    100,
+		LLMTemperature:  0.5,
+		StaticAudioPath: os.TempDir(),
+	}
+}
+
+func TestNewSelectsRegisteredProviders(t *testing.T) {
+	providers, err := New(testConfig("http://localhost"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if _, ok := providers.Chat.(*OpenAIProvider); !ok {
+		t.Errorf("Expected OpenAIProvider for chat, got %T", providers.Chat)
+	}
+}
+
+func TestNewUnknownProvider(t *testing.T) {
+	cfg := testConfig("http://localhost")
+	cfg.LLMProvider = "does-not-exist"
+
+	if _, err := New(cfg); err == nil {
+		t.Error("Expected error for unknown provider")
+	}
+}
+
+func TestNewQiniuRequiresAPIKey(t *testing.T) {
+	cfg := testConfig("http://localhost")
+	cfg.TTSProvider = "qiniu"
+
+	if _, err := New(cfg); err == nil {
+		t.Error("Expected error when QINIU_API_KEY is missing")
+	}
+}
+
+func TestRegisterCustomProvider(t *testing.T) {
+	RegisterChat("static", func(cfg *config.Config) (ChatProvider, error) {
+		return staticChat("hello"), nil
+	})
+
+	cfg := testConfig("http://localhost")
+	cfg.LLMProvider = "static"
+
+	providers, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	reply, _ := providers.Chat.ChatCompletion(context.Background(), nil)
+	if reply != "hello" {
+		t.Errorf("Expected reply 'hello', got %s", reply)
+	}
+}
+
+type staticChat string
+
+func (s staticChat) ChatCompletion(ctx context.Context, messages []Message) (string, error) {
+	return string(s), nil
+}
+
+func TestOpenAIChatCompletion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Unexpected Authorization header: %s", auth)
+		}
+
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["model"] != "test-model" {
+			t.Errorf("Expected model test-model, got %v", req["model"])
+		}
+
+		w.Write([]byte(`{"choices":[{"message":{"content":"你好"}}]}`))
+	}))
+	defer server.Close()
+
+	cfg := testConfig(server.URL)
+	cfg.OpenAIAPIKey = "secret"
+
+	reply, err := NewOpenAIProvider(cfg).ChatCompletion(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("ChatCompletion failed: %v", err)
+	}
+	if reply != "你好" {
+		t.Errorf("Expected reply '你好', got %s", reply)
+	}
+}
+
+func TestOpenAIErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	_, err := NewOpenAIProvider(testConfig(server.URL)).ChatCompletion(context.Background(), nil)
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("Expected status 429 error, got %v", err)
+	}
+}
+
+func TestOpenAIASRAndTTS(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/audio/transcriptions":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("Expected multipart form: %v", err)
+			}
+			if r.FormValue("model") != "whisper-1" {
+				t.Errorf("Expected model whisper-1, got %s", r.FormValue("model"))
+			}
+			w.Write([]byte(`{"text":"打开微信"}`))
+		case "/audio/speech":
+			w.Write([]byte("fake-mp3"))
+		default:
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	cfg := testConfig(server.URL)
+	cfg.StaticAudioPath = t.TempDir()
+	p := NewOpenAIProvider(cfg)
+
+	audioPath := filepath.Join(t.TempDir(), "in.wav")
+	os.WriteFile(audioPath, []byte("RIFF"), 0644)
+
+	text, err := p.ASR(context.Background(), audioPath)
+	if err != nil {
+		t.Fatalf("ASR failed: %v", err)
+	}
+	if text != "打开微信" {
+		t.Errorf("Expected '打开微信', got %s", text)
+	}
+
+	url, err := p.TTS(context.Background(), "好的")
+	if err != nil {
+		t.Fatalf("TTS failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(cfg.StaticAudioPath, filepath.Base(url)))
+	if err != nil || string(data) != "fake-mp3" {
+		t.Errorf("Expected saved TTS audio, got %q (err: %v)", data, err)
+	}
+}
+
+func TestCompleteStreaming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		if req["stream"] != true {
+			w.Write([]byte(`{"choices":[{"message":{"content":"完整回复"}}]}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"完整\"}}]}\n\n"))
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"回复\"}}]}\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	chat := NewOpenAIProvider(testConfig(server.URL))
+
+	var deltas []string
+	ctx := WithTokenSink(context.Background(), func(source, delta string) {
+		if source != "response" {
+			t.Errorf("Expected source 'response', got %s", source)
+		}
+		deltas = append(deltas, delta)
+	})
+
+	text, err := CompleteStreaming(ctx, chat, nil, "response")
+	if err != nil {
+		t.Fatalf("CompleteStreaming failed: %v", err)
+	}
+	if text != "完整回复" || len(deltas) != 2 {
+		t.Errorf("Expected 2 deltas forming '完整回复', got %q from %v", text, deltas)
+	}
+
+	// Without a sink the regular completion endpoint is used
+	text, err = CompleteStreaming(context.Background(), chat, nil, "response")
+	if err != nil || text != "完整回复" {
+		t.Errorf("Expected non-streaming fallback, got %q (err: %v)", text, err)
+	}
+}
+
+func TestOpenAIChatCompletionWithTools(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Tools      []Tool `json:"tools"`
+			ToolChoice string `json:"tool_choice"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Tools) != 1 || req.Tools[0].Function.Name != "open_app" {
+			t.Errorf("Unexpected tools: %+v", req.Tools)
+		}
+		if req.ToolChoice != ToolChoiceAuto {
+			t.Errorf("Expected tool_choice auto, got %s", req.ToolChoice)
+		}
+
+		w.Write([]byte(`{"choices":[{"message":{"content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"open_app","arguments":"{\"name\":\"微信\"}"}}]}}]}`))
+	}))
+	defer server.Close()
+
+	tools := []Tool{NewFunctionTool("open_app", "打开应用", map[string]interface{}{"type": "object"})}
+	calls, _, err := NewOpenAIProvider(testConfig(server.URL)).ChatCompletionWithTools(context.Background(), nil, tools, ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("ChatCompletionWithTools failed: %v", err)
+	}
+
+	if len(calls) != 1 || calls[0].Function.Name != "open_app" || calls[0].Function.Arguments != `{"name":"微信"}` {
+		t.Errorf("Unexpected tool calls: %+v", calls)
+	}
+}
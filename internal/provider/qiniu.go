@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+	"github.com/deca/voicepilot-eino/internal/qiniu"
+)
+
+func init() {
+	RegisterASR("qiniu", func(cfg *config.Config) (ASRProvider, error) {
+		return newQiniuProvider(cfg)
+	})
+	RegisterTTS("qiniu", func(cfg *config.Config) (TTSProvider, error) {
+		return newQiniuProvider(cfg)
+	})
+	RegisterChat("qiniu", func(cfg *config.Config) (ChatProvider, error) {
+		return newQiniuProvider(cfg)
+	})
+}
+
+// QiniuProvider adapts the Qiniu Cloud client to the provider interfaces
+type QiniuProvider struct {
+	client *qiniu.Client
+}
+
+// NewQiniuProvider wraps an existing Qiniu client
+func NewQiniuProvider(client *qiniu.Client) *QiniuProvider {
+	return &QiniuProvider{client: client}
+}
+
+func newQiniuProvider(cfg *config.Config) (*QiniuProvider, error) {
+	if cfg.QiniuAPIKey == "" {
+		return nil, fmt.Errorf("QINIU_API_KEY is required")
+	}
+	return NewQiniuProvider(qiniu.NewClient()), nil
+}
+
+// ASR performs speech-to-text conversion
+func (p *QiniuProvider) ASR(ctx context.Context, audioPath string) (string, error) {
+	return p.client.ASR(ctx, audioPath)
+}
+
+// TTS performs text-to-speech conversion
+func (p *QiniuProvider) TTS(ctx context.Context, text string) (string, error) {
+	return p.client.TTS(ctx, text)
+}
+
+// ChatCompletion performs LLM chat completion
+func (p *QiniuProvider) ChatCompletion(ctx context.Context, messages []Message) (string, error) {
+	return p.client.ChatCompletion(ctx, toQiniuMessages(messages))
+}
+
+// ChatCompletionStream performs streaming LLM chat completion
+func (p *QiniuProvider) ChatCompletionStream(ctx context.Context, messages []Message) (<-chan ChatChunk, error) {
+	return p.client.ChatCompletionStream(ctx, toQiniuMessages(messages))
+}
+
+// ChatCompletionWithTools performs LLM chat completion with function calling
+func (p *QiniuProvider) ChatCompletionWithTools(ctx context.Context, messages []Message, tools []Tool, toolChoice string) ([]ToolCall, string, error) {
+	qiniuTools := make([]qiniu.Tool, 0, len(tools))
+	for _, tool := range tools {
+		qiniuTools = append(qiniuTools, qiniu.Tool{
+			Type:     tool.Type,
+			Function: qiniu.ToolFunction(tool.Function),
+		})
+	}
+
+	calls, content, err := p.client.ChatCompletionWithTools(ctx, toQiniuMessages(messages), qiniuTools, toolChoice)
+	if err != nil {
+		return nil, "", err
+	}
+
+	result := make([]ToolCall, 0, len(calls))
+	for _, call := range calls {
+		result = append(result, ToolCall{
+			ID:       call.ID,
+			Type:     call.Type,
+			Function: ToolCallFunction(call.Function),
+		})
+	}
+	return result, content, nil
+}
+
+// StartStreamingASR opens a Qiniu WebSocket ASR session
+func (p *QiniuProvider) StartStreamingASR(ctx context.Context, opts StreamingASROptions) (StreamingASRSession, error) {
+	session, err := p.client.StartStreamingASR(ctx, qiniu.StreamingASROptions{
+		Format:     opts.Format,
+		SampleRate: opts.SampleRate,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newQiniuStreamingSession(session), nil
+}
+
+func toQiniuMessages(messages []Message) []qiniu.Message {
+	result := make([]qiniu.Message, 0, len(messages))
+	for _, msg := range messages {
+		result = append(result, qiniu.Message{Role: msg.Role, Content: msg.Content})
+	}
+	return result
+}
+
+// qiniuStreamingSession converts Qiniu streaming results to provider results
+type qiniuStreamingSession struct {
+	*qiniu.StreamingASRSession
+	results chan StreamingASRResult
+}
+
+func newQiniuStreamingSession(session *qiniu.StreamingASRSession) *qiniuStreamingSession {
+	s := &qiniuStreamingSession{
+		StreamingASRSession: session,
+		results:             make(chan StreamingASRResult, 16),
+	}
+	go func() {
+		defer close(s.results)
+		for result := range session.Results() {
+			s.results <- StreamingASRResult{Text: result.Text, Final: result.Final}
+		}
+	}()
+	return s
+}
+
+// Results returns the channel of partial transcripts
+func (s *qiniuStreamingSession) Results() <-chan StreamingASRResult {
+	return s.results
+}
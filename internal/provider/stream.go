@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"strings"
+
+	"github.com/deca/voicepilot-eino/internal/sse"
+)
+
+// ChatChunk is one piece of a streamed chat completion
+type ChatChunk = sse.ChatChunk
+
+// StreamingChatProvider is implemented by chat providers that can stream tokens as they are generated
+type StreamingChatProvider interface {
+	ChatCompletionStream(ctx context.Context, messages []Message) (<-chan ChatChunk, error)
+}
+
+// TokenSink receives generated text as it streams from the model
+//
+// source identifies what is being generated, e.g. "response" or "generate_text".
+type TokenSink func(source, delta string)
+
+type tokenSinkKey struct{}
+
+// WithTokenSink returns a context whose streamed completions are forwarded to sink
+func WithTokenSink(ctx context.Context, sink TokenSink) context.Context {
+	return context.WithValue(ctx, tokenSinkKey{}, sink)
+}
+
+// TokenSinkFromContext returns the token sink attached to ctx, if any
+func TokenSinkFromContext(ctx context.Context) TokenSink {
+	sink, _ := ctx.Value(tokenSinkKey{}).(TokenSink)
+	return sink
+}
+
+// CompleteStreaming performs a chat completion and forwards tokens to the sink in ctx
+//
+// It falls back to a regular completion when no sink is attached or the
+// provider cannot stream, so callers can use it unconditionally.
+func CompleteStreaming(ctx context.Context, chat ChatProvider, messages []Message, source string) (string, error) {
+	sink := TokenSinkFromContext(ctx)
+	streamer, ok := chat.(StreamingChatProvider)
+	if sink == nil || !ok {
+		return chat.ChatCompletion(ctx, messages)
+	}
+
+	chunks, err := streamer.ChatCompletionStream(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+
+	var full strings.Builder
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			return "", chunk.Err
+		}
+		full.WriteString(chunk.Delta)
+		sink(source, chunk.Delta)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	return full.String(), nil
+}
@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"context"
+)
+
+// Tool describes a function the model may call, in the OpenAI "tools" format
+type Tool struct {
+	Type     string       `json:"type"` // always "function"
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction is the name, description and JSON-schema parameters of a callable function
+type ToolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	ID       string           `json:"id,omitempty"`
+	Type     string           `json:"type,omitempty"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction holds the called function name and its JSON-encoded arguments
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Tool choice values accepted by ToolCallingChatProvider
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceRequired = "required"
+)
+
+// ToolCallingChatProvider is implemented by chat providers that support native function calling
+//
+// The returned content is whatever text the model produced alongside (or instead of) tool calls.
+type ToolCallingChatProvider interface {
+	ChatCompletionWithTools(ctx context.Context, messages []Message, tools []Tool, toolChoice string) ([]ToolCall, string, error)
+}
+
+// NewFunctionTool builds a function tool definition
+func NewFunctionTool(name, description string, parameters map[string]interface{}) Tool {
+	return Tool{
+		Type: "function",
+		Function: ToolFunction{
+			Name:        name,
+			Description: description,
+			Parameters:  parameters,
+		},
+	}
+}
@@ -0,0 +1,107 @@
+// Package push delivers server-initiated events to connected sessions and to a webhook
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/telemetry"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// subscriberBuffer is how many events may queue for a slow subscriber before new ones are dropped
+const subscriberBuffer = 16
+
+// webhookTimeout bounds a single webhook delivery
+const webhookTimeout = 10 * time.Second
+
+// Hub fans events out to the clients subscribed to a session
+type Hub struct {
+	mu   sync.Mutex
+	subs map[string]map[chan types.StreamEvent]struct{}
+}
+
+// NewHub creates an empty hub
+func NewHub() *Hub {
+	return &Hub{subs: make(map[string]map[chan types.StreamEvent]struct{})}
+}
+
+// Subscribe returns a channel receiving the session's events and a function that unsubscribes and closes it
+func (h *Hub) Subscribe(sessionID string) (<-chan types.StreamEvent, func()) {
+	ch := make(chan types.StreamEvent, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subs[sessionID] == nil {
+		h.subs[sessionID] = make(map[chan types.StreamEvent]struct{})
+	}
+	h.subs[sessionID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			delete(h.subs[sessionID], ch)
+			if len(h.subs[sessionID]) == 0 {
+				delete(h.subs, sessionID)
+			}
+			close(ch)
+		})
+	}
+}
+
+// Publish sends the event to the subscribers of event.SessionID and returns how many received it
+//
+// Publish never blocks: subscribers whose buffer is full miss the event.
+func (h *Hub) Publish(event types.StreamEvent) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delivered := 0
+	for ch := range h.subs[event.SessionID] {
+		select {
+		case ch <- event:
+			delivered++
+		default:
+			log.Printf("Push subscriber for session %s is not keeping up, dropping %s event", event.SessionID, event.Type)
+		}
+	}
+	return delivered
+}
+
+var webhookClient = &http.Client{
+	Timeout:   webhookTimeout,
+	Transport: telemetry.Transport(nil),
+}
+
+// SendWebhook posts the event as JSON to url and fails on a non-2xx response
+func SendWebhook(ctx context.Context, url string, event types.StreamEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+
+	a, unsubscribeA := hub.Subscribe("s1")
+	b, unsubscribeB := hub.Subscribe("s1")
+	other, unsubscribeOther := hub.Subscribe("s2")
+	defer unsubscribeA()
+	defer unsubscribeOther()
+
+	if n := hub.Publish(types.StreamEvent{Type: "reminder", SessionID: "s1", Text: "喝水"}); n != 2 {
+		t.Errorf("Publish() delivered to %d subscribers, want 2", n)
+	}
+	for _, ch := range []<-chan types.StreamEvent{a, b} {
+		if event := <-ch; event.Text != "喝水" {
+			t.Errorf("Received %+v", event)
+		}
+	}
+	select {
+	case event := <-other:
+		t.Errorf("Other session received %+v", event)
+	default:
+	}
+
+	unsubscribeB()
+	unsubscribeB() // unsubscribing twice is harmless
+	if _, ok := <-b; ok {
+		t.Error("Channel not closed after unsubscribe")
+	}
+	if n := hub.Publish(types.StreamEvent{Type: "reminder", SessionID: "s1"}); n != 1 {
+		t.Errorf("Publish() after unsubscribe delivered to %d subscribers, want 1", n)
+	}
+
+	// A full subscriber drops events instead of blocking the publisher
+	for i := 0; i < subscriberBuffer+5; i++ {
+		hub.Publish(types.StreamEvent{Type: "reminder", SessionID: "s2"})
+	}
+	if len(other) != subscriberBuffer {
+		t.Errorf("Subscriber buffered %d events, want %d", len(other), subscriberBuffer)
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+	var received types.StreamEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	event := types.StreamEvent{Type: "reminder", SessionID: "s1", Text: "喝水"}
+	if err := SendWebhook(context.Background(), server.URL, event); err != nil {
+		t.Fatalf("SendWebhook() error = %v", err)
+	}
+	if received != event {
+		t.Errorf("Webhook received %+v, want %+v", received, event)
+	}
+
+	if err := SendWebhook(context.Background(), server.URL+"/fail", event); err == nil {
+		t.Error("Expected an error for a failing webhook")
+	}
+}
@@ -15,6 +15,9 @@ import (
 	"time"
 
 	"github.com/deca/voicepilot-eino/internal/config"
+	"github.com/deca/voicepilot-eino/internal/sse"
+	"github.com/deca/voicepilot-eino/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Client is the Qiniu Cloud API client
@@ -22,6 +25,24 @@ type Client struct {
 	apiKey     string
 	baseURL    string
 	httpClient *http.Client
+	// streamClient has no overall timeout; streamed responses are bounded by the request context
+	streamClient *http.Client
+}
+
+// StatusError is returned when an API answers with a non-200 status
+type StatusError struct {
+	API        string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned status %d: %s", e.API, e.StatusCode, e.Body)
+}
+
+// HTTPStatus returns the status code the API answered with
+func (e *StatusError) HTTPStatus() int {
+	return e.StatusCode
 }
 
 // NewClient creates a new Qiniu Cloud API client
@@ -30,8 +51,10 @@ func NewClient() *Client {
 		apiKey:  config.AppConfig.QiniuAPIKey,
 		baseURL: config.AppConfig.QiniuBaseURL,
 		httpClient: &http.Client{
-			Timeout: 60 * time.Second,
+			Timeout:   60 * time.Second,
+			Transport: telemetry.Transport(nil),
 		},
+		streamClient: &http.Client{Transport: telemetry.Transport(nil)},
 	}
 }
 
@@ -94,15 +117,16 @@ func (c *Client) ASR(ctx context.Context, audioPath string) (string, error) {
 	log.Printf("Starting ASR for audio file: %s", audioPath)
 
 	// Strategy 1: Try HTTP REST API with storage upload (most reliable)
-	result, err := c.ASRWithStorage(ctx, audioPath)
-	if err == nil && result != "" {
+	result, err := c.tryASR(ctx, "storage", c.ASRWithStorage, audioPath)
+	if err == nil {
 		return result, nil
 	}
 	log.Printf("Storage-based ASR not available: %v", err)
+	telemetry.RecordASRFallback("storage", "websocket")
 
 	// Strategy 2: Try WebSocket ASR (implemented but may need permissions)
-	result, err = c.WebSocketASR(ctx, audioPath)
-	if err == nil && result != "" {
+	result, err = c.tryASR(ctx, "websocket", c.WebSocketASR, audioPath)
+	if err == nil {
 		return result, nil
 	}
 	log.Printf("WebSocket ASR not available: %v", err)
@@ -111,6 +135,20 @@ func (c *Client) ASR(ctx context.Context, audioPath string) (string, error) {
 	return "", fmt.Errorf("语音识别暂不可用。请配置七牛云对象存储(QINIU_ACCESS_KEY/SECRET_KEY)或使用文字输入")
 }
 
+// tryASR runs one ASR strategy inside a tracing span and records its outcome
+func (c *Client) tryASR(ctx context.Context, strategy string, recognize func(context.Context, string) (string, error), audioPath string) (string, error) {
+	ctx, span := telemetry.StartSpan(ctx, "qiniu.asr."+strategy, attribute.String("asr.strategy", strategy))
+
+	text, err := recognize(ctx, audioPath)
+	if err == nil && text == "" {
+		err = fmt.Errorf("empty recognition result")
+	}
+
+	telemetry.EndSpan(span, err)
+	telemetry.RecordASRAttempt(strategy, err)
+	return text, err
+}
+
 // ASRWithStorage uploads audio to storage and uses HTTP REST API
 func (c *Client) ASRWithStorage(ctx context.Context, audioPath string) (string, error) {
 	// Upload to storage
@@ -131,7 +169,7 @@ func (c *Client) ASRWithStorage(ctx context.Context, audioPath string) (string,
 	}
 
 	reqBytes, _ := json.Marshal(reqBody)
-	client := &http.Client{Timeout: 60 * time.Second}
+	client := &http.Client{Timeout: 60 * time.Second, Transport: telemetry.Transport(nil)}
 	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/voice/asr", bytes.NewReader(reqBytes))
 	if err != nil {
 		return "", err
@@ -295,16 +333,87 @@ func (c *Client) ChatCompletion(ctx context.Context, messages []Message) (string
 		"stream":      false,
 	}
 
+	respBody, err := c.postChat(ctx, reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	// Parse response
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("no choices in response")
+	}
+
+	content := result.Choices[0].Message.Content
+	log.Printf("Chat completion successful: %s", content)
+	return content, nil
+}
+
+// ChatCompletionWithTools performs LLM chat completion with function calling
+//
+// tools and toolChoice are sent as the "tools" and "tool_choice" request
+// fields; the model's tool calls are returned along with any text content.
+func (c *Client) ChatCompletionWithTools(ctx context.Context, messages []Message, tools []Tool, toolChoice string) ([]ToolCall, string, error) {
+	log.Printf("Starting chat completion with %d messages and %d tools", len(messages), len(tools))
+
+	reqBody := map[string]interface{}{
+		"model":       config.AppConfig.LLMModel,
+		"messages":    messages,
+		"tools":       tools,
+		"tool_choice": toolChoice,
+		"max_tokens":  config.AppConfig.LLMMaxTokens,
+		"temperature": config.AppConfig.LLMTemperature,
+		"stream":      false,
+	}
+
+	respBody, err := c.postChat(ctx, reqBody)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content   string     `json:"content"`
+				ToolCalls []ToolCall `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(result.Choices) == 0 {
+		return nil, "", fmt.Errorf("no choices in response")
+	}
+
+	msg := result.Choices[0].Message
+	log.Printf("Chat completion returned %d tool calls", len(msg.ToolCalls))
+	return msg.ToolCalls, msg.Content, nil
+}
+
+// postChat sends a non-streaming request to the chat completions endpoint and returns the raw body
+func (c *Client) postChat(ctx context.Context, reqBody map[string]interface{}) ([]byte, error) {
 	reqBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Create HTTP request
 	url := c.baseURL + "/chat/completions"
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBytes))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -313,39 +422,68 @@ func (c *Client) ChatCompletion(ctx context.Context, messages []Message) (string
 	// Send request
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		log.Printf("Chat API error response: %s", string(respBody))
-		return "", fmt.Errorf("Chat API returned status %d: %s", resp.StatusCode, string(respBody))
+		return nil, &StatusError{API: "Chat API", StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
-	// Parse response
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
+	telemetry.RecordLLMUsage("qiniu", respBody)
+	return respBody, nil
+}
+
+// ChatCompletionStream performs LLM chat completion with "stream": true
+//
+// Generated text is delivered on the returned channel as it arrives; a chunk
+// with a non-nil Err ends the stream early.
+func (c *Client) ChatCompletionStream(ctx context.Context, messages []Message) (<-chan sse.ChatChunk, error) {
+	log.Printf("Starting streaming chat completion with %d messages", len(messages))
+
+	// Build request
+	reqBody := map[string]interface{}{
+		"model":       config.AppConfig.LLMModel,
+		"messages":    messages,
+		"max_tokens":  config.AppConfig.LLMMaxTokens,
+		"temperature": config.AppConfig.LLMTemperature,
+		"stream":      true,
 	}
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+
+	reqBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
+	url := c.baseURL + "/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	content := result.Choices[0].Message.Content
-	log.Printf("Chat completion successful: %s", content)
-	return content, nil
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.streamClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		log.Printf("Chat API error response: %s", string(respBody))
+		return nil, &StatusError{API: "Chat API", StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	return sse.ReadChatStream(ctx, resp.Body), nil
 }
 
 // Message represents a chat message
@@ -353,3 +491,29 @@ type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
 }
+
+// Tool describes a function the model may call
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction is the name, description and JSON-schema parameters of a callable function
+type ToolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	ID       string           `json:"id,omitempty"`
+	Type     string           `json:"type,omitempty"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction holds the called function name and its JSON-encoded arguments
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
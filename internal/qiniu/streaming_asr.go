@@ -0,0 +1,230 @@
+package qiniu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/telemetry"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StreamingASROptions describes the audio that will be pushed into a streaming ASR session
+type StreamingASROptions struct {
+	Format     string // "pcm" (16-bit little-endian) or "opus"
+	SampleRate int    // defaults to 16000
+}
+
+// StreamingASRResult is a transcript update produced while audio is still arriving
+type StreamingASRResult struct {
+	Text  string `json:"text"`
+	Final bool   `json:"final"`
+}
+
+// StreamingASRSession is an open WebSocket ASR session that accepts audio incrementally
+type StreamingASRSession struct {
+	conn     *websocket.Conn
+	mu       sync.Mutex
+	sequence int32
+	finished bool
+
+	results chan StreamingASRResult
+	done    chan struct{}
+
+	textMu   sync.Mutex
+	lastText string
+	readErr  error
+
+	// span covers the session from connect until Finish or Close
+	span    trace.Span
+	endOnce sync.Once
+}
+
+// StartStreamingASR opens a WebSocket ASR session for audio that is sent chunk by chunk
+func (c *Client) StartStreamingASR(ctx context.Context, opts StreamingASROptions) (*StreamingASRSession, error) {
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = 16000
+	}
+
+	audio := WSASRAudio{
+		Format:     "pcm",
+		SampleRate: opts.SampleRate,
+		Bits:       16,
+		Channel:    1,
+		Codec:      "raw",
+	}
+	switch opts.Format {
+	case "", "pcm":
+	case "opus":
+		audio.Format = "ogg"
+		audio.Codec = "opus"
+	default:
+		return nil, fmt.Errorf("unsupported streaming audio format: %s", opts.Format)
+	}
+
+	log.Printf("Starting streaming ASR session (format=%s, sample_rate=%d)", audio.Codec, audio.SampleRate)
+
+	ctx, span := telemetry.StartSpan(ctx, "qiniu.asr.streaming",
+		attribute.String("asr.codec", audio.Codec),
+		attribute.Int("asr.sample_rate", audio.SampleRate),
+	)
+
+	conn, err := c.dialASR(ctx, audio)
+	if err != nil {
+		telemetry.EndSpan(span, err)
+		telemetry.RecordASRAttempt("streaming", err)
+		return nil, err
+	}
+
+	s := &StreamingASRSession{
+		conn: conn,
+		// Sequence starts from 2, as 0-1 are reserved
+		sequence: 2,
+		results:  make(chan StreamingASRResult, 16),
+		done:     make(chan struct{}),
+		span:     span,
+	}
+	go s.readLoop()
+
+	return s, nil
+}
+
+// Results returns the channel of partial transcripts. It is closed when the session ends.
+func (s *StreamingASRSession) Results() <-chan StreamingASRResult {
+	return s.results
+}
+
+// SendAudio forwards one chunk of audio to the ASR service
+func (s *StreamingASRSession) SendAudio(chunk []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.finished {
+		return fmt.Errorf("streaming ASR session already finished")
+	}
+
+	// Split oversized chunks so every frame stays within the protocol's expected size
+	for offset := 0; offset < len(chunk); offset += audioChunkSize {
+		end := offset + audioChunkSize
+		if end > len(chunk) {
+			end = len(chunk)
+		}
+
+		frame := buildFrame(msgTypeAudioOnlyRequest, flagPosSequence, serializationNone, compressionNone, s.sequence, chunk[offset:end])
+		if err := s.conn.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+			return fmt.Errorf("failed to send audio frame: %w", err)
+		}
+		s.sequence++
+	}
+
+	return nil
+}
+
+// Finish signals the end of audio and waits for the final transcript
+func (s *StreamingASRSession) Finish(ctx context.Context) (text string, err error) {
+	defer func() {
+		s.end(err)
+		telemetry.RecordASRAttempt("streaming", err)
+	}()
+
+	s.mu.Lock()
+	if !s.finished {
+		s.finished = true
+		// Send close message to indicate end of audio
+		s.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		s.conn.Close()
+		return "", ctx.Err()
+	case <-time.After(30 * time.Second):
+		s.conn.Close()
+		return "", fmt.Errorf("timeout waiting for ASR result")
+	}
+
+	s.textMu.Lock()
+	defer s.textMu.Unlock()
+
+	if s.lastText == "" {
+		if s.readErr != nil {
+			return "", s.readErr
+		}
+		return "", fmt.Errorf("no recognition result received")
+	}
+	return s.lastText, nil
+}
+
+// Close aborts the session without waiting for a result
+func (s *StreamingASRSession) Close() error {
+	s.mu.Lock()
+	s.finished = true
+	s.mu.Unlock()
+	s.end(nil)
+	return s.conn.Close()
+}
+
+// end closes the session span once
+func (s *StreamingASRSession) end(err error) {
+	s.endOnce.Do(func() { telemetry.EndSpan(s.span, err) })
+}
+
+// readLoop reads ASR responses and publishes transcript updates until the connection closes
+func (s *StreamingASRSession) readLoop() {
+	defer close(s.done)
+	defer close(s.results)
+
+	for {
+		_, message, err := s.conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.textMu.Lock()
+				s.readErr = fmt.Errorf("failed to read message: %w", err)
+				s.textMu.Unlock()
+			}
+			s.publish(StreamingASRResult{Text: s.text(), Final: true})
+			return
+		}
+
+		_, _, payload, err := parseFrame(message)
+		if err != nil {
+			log.Printf("Failed to parse frame: %v", err)
+			continue
+		}
+
+		text := extractASRText(payload)
+		if text == "" || text == s.text() {
+			continue
+		}
+
+		s.textMu.Lock()
+		s.lastText = text
+		s.textMu.Unlock()
+
+		s.publish(StreamingASRResult{Text: text})
+	}
+}
+
+func (s *StreamingASRSession) text() string {
+	s.textMu.Lock()
+	defer s.textMu.Unlock()
+	return s.lastText
+}
+
+// publish delivers a result without blocking the read loop on a slow consumer
+func (s *StreamingASRSession) publish(result StreamingASRResult) {
+	if result.Text == "" {
+		return
+	}
+	select {
+	case s.results <- result:
+	default:
+		log.Printf("Dropping streaming ASR update, consumer is not keeping up")
+	}
+}
@@ -12,8 +12,10 @@ import (
 	"os"
 	"time"
 
+	"github.com/deca/voicepilot-eino/internal/telemetry"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -25,8 +27,8 @@ const (
 	headerSize      = 0x1 // 1 word (4 bytes)
 
 	// Message types
-	msgTypeFullClientRequest  = 0x1 // 0b0001 - Full client request
-	msgTypeAudioOnlyRequest   = 0x2 // 0b0010 - Audio-only data
+	msgTypeFullClientRequest   = 0x1 // 0b0001 - Full client request
+	msgTypeAudioOnlyRequest    = 0x2 // 0b0010 - Audio-only data
 	msgTypeFullServiceResponse = 0x9 // 0b1001 - Full service response
 
 	// Message type specific flags
@@ -71,11 +73,11 @@ type WSASRRequest struct {
 
 // WSASRResponse represents the WebSocket ASR response
 type WSASRResponse struct {
-	Code      int              `json:"code"`
-	Message   string           `json:"message"`
-	Reqid     string           `json:"reqid"`
-	Result    WSASRResult      `json:"result"`
-	AudioInfo WSASRAudioInfo   `json:"audio_info"`
+	Code      int            `json:"code"`
+	Message   string         `json:"message"`
+	Reqid     string         `json:"reqid"`
+	Result    WSASRResult    `json:"result"`
+	AudioInfo WSASRAudioInfo `json:"audio_info"`
 }
 
 type WSASRResult struct {
@@ -90,10 +92,10 @@ type WSASRAudioInfo struct {
 func buildFrame(msgType byte, flags byte, serializationMethod byte, compressionMethod byte, sequence int32, payload []byte) []byte {
 	// Build 4-byte header
 	header := make([]byte, 4)
-	header[0] = (protocolVersion << 4) | headerSize               // Protocol version | Header size
-	header[1] = (msgType << 4) | flags                            // Message type | Flags
-	header[2] = (serializationMethod << 4) | compressionMethod    // Serialization | Compression
-	header[3] = 0x0                                               // Reserved
+	header[0] = (protocolVersion << 4) | headerSize            // Protocol version | Header size
+	header[1] = (msgType << 4) | flags                         // Message type | Flags
+	header[2] = (serializationMethod << 4) | compressionMethod // Serialization | Compression
+	header[3] = 0x0                                            // Reserved
 
 	buf := new(bytes.Buffer)
 	binary.Write(buf, binary.BigEndian, header)
@@ -211,74 +213,19 @@ func (c *Client) WebSocketASR(ctx context.Context, audioPath string) (string, er
 
 	log.Printf("PCM data size: %d bytes", len(pcmData))
 
-	// Establish WebSocket connection
-	header := http.Header{}
-	header.Add("Authorization", "Bearer "+c.apiKey)
-
-	dialer := websocket.Dialer{
-		HandshakeTimeout: 10 * time.Second,
-	}
-
-	conn, _, err := dialer.Dial(wsASRURL, header)
+	// Establish WebSocket connection and send the configuration frame
+	conn, err := c.dialASR(ctx, WSASRAudio{
+		Format:     "pcm",
+		SampleRate: 16000,
+		Bits:       16,
+		Channel:    1,
+		Codec:      "raw",
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to connect to WebSocket: %w", err)
+		return "", err
 	}
 	defer conn.Close()
 
-	log.Println("WebSocket connection established")
-
-	// Prepare configuration
-	config := WSASRConfig{
-		User: WSASRUser{
-			UID: uuid.New().String(),
-		},
-		Audio: WSASRAudio{
-			Format:     "pcm",
-			SampleRate: 16000,
-			Bits:       16,
-			Channel:    1,
-			Codec:      "raw",
-		},
-		Request: WSASRRequest{
-			ModelName:  "asr",
-			EnablePunc: true,
-		},
-	}
-
-	// Serialize configuration
-	configJSON, err := json.Marshal(config)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal config: %w", err)
-	}
-
-	log.Printf("Sending configuration: %s", string(configJSON))
-
-	// Build and send configuration frame (no sequence, no compression)
-	configFrame := buildFrame(msgTypeFullClientRequest, flagNoSequence, serializationJSON, compressionNone, 0, configJSON)
-	err = conn.WriteMessage(websocket.BinaryMessage, configFrame)
-	if err != nil {
-		return "", fmt.Errorf("failed to send config frame: %w", err)
-	}
-
-	log.Println("Configuration frame sent")
-
-	// Wait for configuration acknowledgment
-	_, message, err := conn.ReadMessage()
-	if err != nil {
-		return "", fmt.Errorf("failed to read config ack: %w", err)
-	}
-
-	msgType, _, payload, err := parseFrame(message)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse config ack: %w", err)
-	}
-
-	log.Printf("Config acknowledgment received (type=0x%x)", msgType)
-	if msgType == 0xF {
-		// Error response
-		return "", fmt.Errorf("config rejected: %s", string(payload))
-	}
-
 	// Channel to collect results
 	resultChan := make(chan string, 1)
 	errChan := make(chan error, 1)
@@ -314,35 +261,10 @@ func (c *Client) WebSocketASR(ctx context.Context, audioPath string) (string, er
 			if len(payload) > 0 {
 				log.Printf("Payload preview: %s", string(payload)[:min(500, len(payload))])
 
-				// Try parsing as WSASRResponse
-				var response WSASRResponse
-				err = json.Unmarshal(payload, &response)
-				if err == nil && response.Result.Text != "" {
-					fullText = response.Result.Text
+				if text := extractASRText(payload); text != "" {
+					fullText = text
 					log.Printf("✅ Recognized text: %s", fullText)
 					// Don't return immediately, wait for more results or close
-				} else {
-					// Try parsing as generic map to see structure
-					var genericResp map[string]interface{}
-					if json.Unmarshal(payload, &genericResp) == nil {
-						log.Printf("Response structure: %+v", genericResp)
-
-						// Try to extract text from various possible structures
-						if result, ok := genericResp["result"].(map[string]interface{}); ok {
-							if text, ok := result["text"].(string); ok && text != "" {
-								fullText = text
-								log.Printf("✅ Extracted text: %s", fullText)
-							}
-						}
-						if data, ok := genericResp["data"].(map[string]interface{}); ok {
-							if result, ok := data["result"].(map[string]interface{}); ok {
-								if text, ok := result["text"].(string); ok && text != "" {
-									fullText = text
-									log.Printf("✅ Extracted text from data: %s", fullText)
-								}
-							}
-						}
-					}
 				}
 			}
 		}
@@ -390,3 +312,107 @@ func (c *Client) WebSocketASR(ctx context.Context, audioPath string) (string, er
 		return "", fmt.Errorf("timeout waiting for ASR result")
 	}
 }
+
+// dialASR opens a WebSocket ASR connection and completes the configuration handshake
+func (c *Client) dialASR(ctx context.Context, audio WSASRAudio) (conn *websocket.Conn, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "qiniu.asr.websocket.connect", attribute.String("asr.codec", audio.Codec))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	header := http.Header{}
+	header.Add("Authorization", "Bearer "+c.apiKey)
+
+	dialer := websocket.Dialer{
+		HandshakeTimeout: 10 * time.Second,
+	}
+
+	conn, _, err = dialer.DialContext(ctx, wsASRURL, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to WebSocket: %w", err)
+	}
+
+	log.Println("WebSocket connection established")
+
+	// Prepare configuration
+	config := WSASRConfig{
+		User: WSASRUser{
+			UID: uuid.New().String(),
+		},
+		Audio: audio,
+		Request: WSASRRequest{
+			ModelName:  "asr",
+			EnablePunc: true,
+		},
+	}
+
+	// Serialize configuration
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	log.Printf("Sending configuration: %s", string(configJSON))
+
+	// Build and send configuration frame (no sequence, no compression)
+	configFrame := buildFrame(msgTypeFullClientRequest, flagNoSequence, serializationJSON, compressionNone, 0, configJSON)
+	if err := conn.WriteMessage(websocket.BinaryMessage, configFrame); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send config frame: %w", err)
+	}
+
+	log.Println("Configuration frame sent")
+
+	// Wait for configuration acknowledgment
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read config ack: %w", err)
+	}
+
+	msgType, _, payload, err := parseFrame(message)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to parse config ack: %w", err)
+	}
+
+	log.Printf("Config acknowledgment received (type=0x%x)", msgType)
+	if msgType == 0xF {
+		// Error response
+		conn.Close()
+		return nil, fmt.Errorf("config rejected: %s", string(payload))
+	}
+
+	return conn, nil
+}
+
+// extractASRText extracts recognized text from a WebSocket ASR response payload
+func extractASRText(payload []byte) string {
+	// Try parsing as WSASRResponse
+	var response WSASRResponse
+	if err := json.Unmarshal(payload, &response); err == nil && response.Result.Text != "" {
+		return response.Result.Text
+	}
+
+	// Try parsing as generic map to see structure
+	var genericResp map[string]interface{}
+	if json.Unmarshal(payload, &genericResp) != nil {
+		return ""
+	}
+	log.Printf("Response structure: %+v", genericResp)
+
+	// Try to extract text from various possible structures
+	if result, ok := genericResp["result"].(map[string]interface{}); ok {
+		if text, ok := result["text"].(string); ok && text != "" {
+			return text
+		}
+	}
+	if data, ok := genericResp["data"].(map[string]interface{}); ok {
+		if result, ok := data["result"].(map[string]interface{}); ok {
+			if text, ok := result["text"].(string); ok && text != "" {
+				return text
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,230 @@
+// Package sandbox runs commands with a timeout, output cap, scrubbed environment and OS-level isolation
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Isolation modes
+const (
+	ModeAuto       = "auto"       // bubblewrap when installed, otherwise namespaces; fails where neither works
+	ModeBubblewrap = "bwrap"      // bubblewrap: read-only system dirs, private work dir, no network
+	ModeNamespaces = "namespaces" // Linux user/mount/net/pid/ipc namespaces: same filesystem view as bwrap
+	ModeNone       = "none"       // resource limits only
+)
+
+// A namespaces sandbox that can't set itself up exits with setupFailedCode
+// and a message starting with setupErrorPrefix, before the command runs.
+const (
+	setupFailedCode  = 125
+	setupErrorPrefix = "sandbox setup failed"
+)
+
+// Config controls how commands are run
+type Config struct {
+	Mode           string
+	Timeout        time.Duration // wall-clock limit per command
+	MaxOutputBytes int           // cap for each of stdout and stderr
+	WorkDir        string        // working directory, created if missing
+	MemoryMB       int           // address space limit, 0 for none
+	CPUSeconds     int           // CPU time limit, 0 for none
+}
+
+// DefaultConfig returns conservative limits for voice-triggered commands
+func DefaultConfig() Config {
+	return Config{
+		Mode:           ModeAuto,
+		Timeout:        10 * time.Second,
+		MaxOutputBytes: 64 * 1024,
+		WorkDir:        filepath.Join(os.TempDir(), "voicepilot-sandbox"),
+		MemoryMB:       256,
+		CPUSeconds:     10,
+	}
+}
+
+// Result is the outcome of a command that was started
+type Result struct {
+	ExitCode        int           `json:"exit_code"` // -1 when the process was killed
+	Stdout          string        `json:"stdout"`
+	Stderr          string        `json:"stderr"`
+	StdoutTruncated bool          `json:"stdout_truncated,omitempty"`
+	StderrTruncated bool          `json:"stderr_truncated,omitempty"`
+	TimedOut        bool          `json:"timed_out,omitempty"`
+	Duration        time.Duration `json:"duration"`
+	Isolation       string        `json:"isolation"` // mode actually used
+}
+
+// Runner runs commands inside the sandbox
+type Runner struct {
+	cfg Config
+}
+
+// New creates a runner
+//
+// An empty Mode or WorkDir and a non-positive Timeout or MaxOutputBytes take
+// their DefaultConfig values; MemoryMB and CPUSeconds are used as given.
+func New(cfg Config) *Runner {
+	def := DefaultConfig()
+	if cfg.Mode == "" {
+		cfg.Mode = def.Mode
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = def.Timeout
+	}
+	if cfg.MaxOutputBytes <= 0 {
+		cfg.MaxOutputBytes = def.MaxOutputBytes
+	}
+	if cfg.WorkDir == "" {
+		cfg.WorkDir = def.WorkDir
+	}
+	return &Runner{cfg: cfg}
+}
+
+// Run executes argv and waits for it to finish
+//
+// A non-zero exit status or a timeout is reported in the Result, not as an
+// error. err is set when the command could not be started (the Result is
+// nil) or could not be waited for.
+func (r *Runner) Run(ctx context.Context, argv []string) (*Result, error) {
+	if len(argv) == 0 {
+		return nil, errors.New("empty command")
+	}
+
+	workDir, err := filepath.Abs(r.cfg.WorkDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sandbox work dir: %w", err)
+	}
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create sandbox work dir: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+
+	mode := r.cfg.Mode
+	if mode == ModeAuto {
+		if mode, err = autoMode(); err != nil {
+			return nil, fmt.Errorf("%w; set SANDBOX_MODE=none to run commands with resource limits only", err)
+		}
+	}
+
+	result, err := r.run(ctx, mode, workDir, argv)
+	if result == nil && err != nil && mode != ModeNone {
+		// e.g. user namespaces disabled by the kernel or container runtime; never fall back to running the command unisolated
+		log.Printf("Sandbox isolation %s unavailable: %v", mode, err)
+		return nil, fmt.Errorf("sandbox isolation %s unavailable (set SANDBOX_MODE=none to run commands with resource limits only): %w", mode, err)
+	}
+	return result, err
+}
+
+func (r *Runner) run(ctx context.Context, mode, workDir string, argv []string) (*Result, error) {
+	name, args, err := r.wrap(mode, workDir, withLimits(r.cfg, argv))
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = workDir
+	cmd.Env = scrubbedEnv(workDir)
+	configureProcess(cmd, mode)
+	// Don't wait forever for grandchildren that keep the output pipes open
+	cmd.WaitDelay = time.Second
+
+	stdout := &limitedBuffer{limit: r.cfg.MaxOutputBytes}
+	stderr := &limitedBuffer{limit: r.cfg.MaxOutputBytes}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+	waitErr := cmd.Wait()
+
+	result := &Result{
+		ExitCode:        cmd.ProcessState.ExitCode(),
+		Stdout:          stdout.String(),
+		Stderr:          stderr.String(),
+		StdoutTruncated: stdout.truncated,
+		StderrTruncated: stderr.truncated,
+		TimedOut:        errors.Is(ctx.Err(), context.DeadlineExceeded),
+		Duration:        time.Since(start),
+		Isolation:       mode,
+	}
+
+	if mode == ModeNamespaces && result.ExitCode == setupFailedCode && strings.HasPrefix(result.Stderr, setupErrorPrefix) {
+		// The command itself never ran
+		return nil, errors.New(strings.TrimSpace(result.Stderr))
+	}
+
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) && !result.TimedOut && !errors.Is(waitErr, exec.ErrWaitDelay) {
+		return result, fmt.Errorf("command failed: %w", waitErr)
+	}
+	return result, nil
+}
+
+// withLimits prefixes argv with a shell that applies resource limits and then execs the command
+//
+// The command is passed as positional parameters, so it is never interpreted by the shell.
+func withLimits(cfg Config, argv []string) []string {
+	if runtime.GOOS == "windows" {
+		return argv
+	}
+
+	script := ""
+	if cfg.CPUSeconds > 0 {
+		script += fmt.Sprintf("ulimit -t %d; ", cfg.CPUSeconds)
+	}
+	if cfg.MemoryMB > 0 {
+		script += fmt.Sprintf("ulimit -v %d; ", cfg.MemoryMB*1024)
+	}
+	// Limit files the command writes to ten times the output cap (in 512-byte blocks)
+	script += fmt.Sprintf("ulimit -f %d; ", cfg.MaxOutputBytes*10/512+1)
+	script += `exec "$@"`
+
+	return append([]string{"/bin/sh", "-c", script, "sh"}, argv...)
+}
+
+// scrubbedEnv is the environment commands run with: nothing inherited from the server
+func scrubbedEnv(workDir string) []string {
+	return []string{
+		"PATH=/usr/local/bin:/usr/bin:/bin",
+		"HOME=" + workDir,
+		"TMPDIR=" + workDir,
+		"LANG=C.UTF-8",
+	}
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining < len(p) {
+		b.truncated = true
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+		// Report the full length so the command isn't killed by a short write
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
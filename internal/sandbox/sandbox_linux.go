@@ -0,0 +1,240 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// initEnv marks the copy of the server a namespaces sandbox starts to set up its root filesystem
+const initEnv = "VOICEPILOT_SANDBOX_INIT=1"
+
+// systemDirs are visible read-only inside the sandbox, nothing else from the host
+var systemDirs = []string{"/usr", "/bin", "/sbin", "/lib", "/lib64", "/etc/alternatives"}
+
+// devices are bound from the host into the sandbox's /dev
+var devices = []string{"/dev/null", "/dev/zero", "/dev/random", "/dev/urandom"}
+
+// The namespaces mode re-executes the running binary, which sets up the
+// sandbox's root filesystem here, before main runs, and then execs the command.
+func init() {
+	if !isSandboxInit() {
+		return
+	}
+	if len(os.Args) < 3 {
+		fmt.Fprintf(os.Stderr, "%s: missing command\n", setupErrorPrefix)
+		os.Exit(setupFailedCode)
+	}
+	if err := enterRoot(os.Args[1]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", setupErrorPrefix, err)
+		os.Exit(setupFailedCode)
+	}
+
+	argv := os.Args[2:]
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", setupErrorPrefix, err)
+		os.Exit(setupFailedCode)
+	}
+	env := make([]string, 0, len(os.Environ()))
+	for _, kv := range os.Environ() {
+		if kv != initEnv {
+			env = append(env, kv)
+		}
+	}
+	err = syscall.Exec(path, argv, env)
+	fmt.Fprintf(os.Stderr, "%s: %v\n", setupErrorPrefix, err)
+	os.Exit(setupFailedCode)
+}
+
+func isSandboxInit() bool {
+	for _, kv := range os.Environ() {
+		if kv == initEnv {
+			return true
+		}
+	}
+	return false
+}
+
+// autoMode prefers bubblewrap, which also restricts the filesystem, over bare namespaces
+func autoMode() (string, error) {
+	if _, err := exec.LookPath("bwrap"); err == nil {
+		return ModeBubblewrap, nil
+	}
+	return ModeNamespaces, nil
+}
+
+// wrap returns the program and arguments that run argv under the given isolation mode
+func (r *Runner) wrap(mode, workDir string, argv []string) (string, []string, error) {
+	switch mode {
+	case ModeNone:
+		return argv[0], argv[1:], nil
+	case ModeNamespaces:
+		// The copy of this binary builds the root filesystem inside the new namespaces, see init
+		return "/proc/self/exe", append([]string{workDir}, argv...), nil
+	case ModeBubblewrap:
+		bwrap, err := exec.LookPath("bwrap")
+		if err != nil {
+			return "", nil, fmt.Errorf("bubblewrap not found: %w", err)
+		}
+		args := []string{
+			// System directories are visible read-only, nothing else from the host
+			"--ro-bind", "/usr", "/usr",
+			"--ro-bind-try", "/bin", "/bin",
+			"--ro-bind-try", "/sbin", "/sbin",
+			"--ro-bind-try", "/lib", "/lib",
+			"--ro-bind-try", "/lib64", "/lib64",
+			"--ro-bind-try", "/etc/alternatives", "/etc/alternatives",
+			"--proc", "/proc",
+			"--dev", "/dev",
+			"--tmpfs", "/tmp",
+			"--bind", workDir, workDir,
+			"--chdir", workDir,
+			// Separate network, PID, IPC, UTS and user namespaces
+			"--unshare-all",
+			"--die-with-parent",
+			"--new-session",
+			"--",
+		}
+		return bwrap, append(args, argv...), nil
+	}
+	return "", nil, fmt.Errorf("unknown sandbox mode: %s", mode)
+}
+
+// configureProcess puts the command in its own process group, and its own namespaces when requested
+func configureProcess(cmd *exec.Cmd, mode string) {
+	attr := &syscall.SysProcAttr{Setpgid: true}
+	if mode == ModeNamespaces {
+		attr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET |
+			syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+		// Map the server user to itself so file ownership inside the work dir is unchanged
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+		cmd.Env = append(cmd.Env, initEnv)
+	}
+	cmd.SysProcAttr = attr
+
+	// Kill the whole process group on timeout, not just the direct child
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// enterRoot pivots into a fresh root holding the system directories read-only and workDir read-write
+//
+// It runs in the new user and mount namespaces, so none of the mounts are
+// visible on the host.
+func enterRoot(workDir string) error {
+	// Keep the mounts below from propagating back to the host
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+
+	// Every sandbox mounts its own tmpfs on this directory, in its own namespace
+	root := filepath.Join(os.TempDir(), "voicepilot-sandbox-root")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("failed to create root: %w", err)
+	}
+	if err := syscall.Mount("tmpfs", root, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("failed to mount root: %w", err)
+	}
+
+	for _, dir := range systemDirs {
+		if err := bindSystemDir(root, dir); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Mkdir(filepath.Join(root, "dev"), 0755); err != nil {
+		return fmt.Errorf("failed to create /dev: %w", err)
+	}
+	for _, dev := range devices {
+		target := filepath.Join(root, dev)
+		if err := os.WriteFile(target, nil, 0644); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dev, err)
+		}
+		if err := syscall.Mount(dev, target, "", syscall.MS_BIND, ""); err != nil {
+			return fmt.Errorf("failed to bind %s: %w", dev, err)
+		}
+	}
+
+	// A fresh /proc shows the sandbox's own PID namespace; some container
+	// runtimes forbid mounting it, and commands mostly do without
+	if err := os.Mkdir(filepath.Join(root, "proc"), 0555); err != nil {
+		return fmt.Errorf("failed to create /proc: %w", err)
+	}
+	syscall.Mount("proc", filepath.Join(root, "proc"), "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "")
+
+	if err := os.Mkdir(filepath.Join(root, "tmp"), 01777); err != nil {
+		return fmt.Errorf("failed to create /tmp: %w", err)
+	}
+	os.Chmod(filepath.Join(root, "tmp"), 01777)
+
+	target := filepath.Join(root, workDir)
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("failed to create work dir: %w", err)
+	}
+	if err := syscall.Mount(workDir, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("failed to bind work dir: %w", err)
+	}
+
+	// Stacking the old root on top of the new one and detaching it leaves only the new root
+	if err := os.Chdir(root); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("failed to pivot root: %w", err)
+	}
+	if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("failed to detach old root: %w", err)
+	}
+	return os.Chdir(workDir)
+}
+
+// bindSystemDir makes a host directory visible read-only at the same path under root
+//
+// Symlinks, such as /bin on merged-/usr systems, are copied as symlinks.
+func bindSystemDir(root, dir string) error {
+	info, err := os.Lstat(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(root, dir)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(dir), err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(dir)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, target)
+	}
+
+	if err := os.Mkdir(target, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	if err := syscall.Mount(dir, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("failed to bind %s: %w", dir, err)
+	}
+	// Flags the host mount already has are locked in a user namespace and must be kept
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(target, &st); err != nil {
+		return err
+	}
+	locked := uintptr(st.Flags) & (syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC |
+		syscall.MS_NOATIME | syscall.MS_NODIRATIME | syscall.MS_RELATIME)
+	flags := syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY | locked
+	if err := syscall.Mount("", target, "", flags, ""); err != nil {
+		return fmt.Errorf("failed to make %s read-only: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNamespacesIsolateNetwork(t *testing.T) {
+	r := newTestRunner(t, Config{Mode: ModeNamespaces})
+
+	result, err := r.Run(context.Background(), []string{"cat", "/proc/net/dev"})
+	if err != nil {
+		t.Skipf("User namespaces unavailable: %v", err)
+	}
+
+	// A fresh network namespace only has the loopback interface
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		name, _, found := strings.Cut(strings.TrimSpace(line), ":")
+		if found && name != "lo" {
+			t.Errorf("Unexpected network interface %q inside the sandbox", name)
+		}
+	}
+}
+
+func TestNamespacesRestrictFilesystem(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(secret, []byte("hunter2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRunner(t, Config{Mode: ModeNamespaces})
+
+	script := "echo note > note.txt; cat note.txt; cat " + secret + "; touch /usr/sandbox-test; ls /"
+	result, err := r.Run(context.Background(), []string{"sh", "-c", script})
+	if err != nil {
+		t.Skipf("User namespaces unavailable: %v", err)
+	}
+
+	if !strings.HasPrefix(result.Stdout, "note\n") {
+		t.Errorf("Work dir is not writable: stdout %q, stderr %q", result.Stdout, result.Stderr)
+	}
+	if data, _ := os.ReadFile(filepath.Join(r.cfg.WorkDir, "note.txt")); string(data) != "note\n" {
+		t.Errorf("Work dir file on the host = %q", data)
+	}
+	if strings.Contains(result.Stdout, "hunter2") {
+		t.Error("Host file outside the work dir is readable inside the sandbox")
+	}
+	if _, err := os.Stat("/usr/sandbox-test"); err == nil {
+		os.Remove("/usr/sandbox-test")
+		t.Error("System directory is writable inside the sandbox")
+	}
+	if strings.Contains(result.Stdout, "home") || strings.Contains(result.Stdout, "root") {
+		t.Errorf("Host directories visible inside the sandbox: %q", result.Stdout)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// autoMode fails: namespaces and bubblewrap are Linux-specific
+func autoMode() (string, error) {
+	return "", errors.New("command isolation is only supported on Linux")
+}
+
+// wrap returns the program and arguments that run argv under the given isolation mode
+func (r *Runner) wrap(mode, workDir string, argv []string) (string, []string, error) {
+	if mode != ModeNone {
+		return "", nil, fmt.Errorf("sandbox mode %s is only supported on Linux", mode)
+	}
+	return argv[0], argv[1:], nil
+}
+
+// configureProcess needs no platform-specific settings outside Linux
+func configureProcess(cmd *exec.Cmd, mode string) {}
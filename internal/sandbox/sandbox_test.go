@@ -0,0 +1,101 @@
+package sandbox
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRunner(t *testing.T, cfg Config) *Runner {
+	if cfg.Mode == "" {
+		cfg.Mode = ModeNone
+	}
+	cfg.WorkDir = t.TempDir()
+	return New(cfg)
+}
+
+func TestRunCapturesOutputAndExitCode(t *testing.T) {
+	r := newTestRunner(t, Config{})
+
+	result, err := r.Run(context.Background(), []string{"sh", "-c", "echo out; echo err >&2; exit 3"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if result.ExitCode != 3 {
+		t.Errorf("ExitCode = %d, want 3", result.ExitCode)
+	}
+	if result.Stdout != "out\n" || result.Stderr != "err\n" {
+		t.Errorf("Unexpected output: stdout %q, stderr %q", result.Stdout, result.Stderr)
+	}
+	if result.Isolation != ModeNone {
+		t.Errorf("Isolation = %q, want %q", result.Isolation, ModeNone)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	r := newTestRunner(t, Config{Timeout: 200 * time.Millisecond})
+
+	result, err := r.Run(context.Background(), []string{"sleep", "5"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if !result.TimedOut {
+		t.Error("Expected command to time out")
+	}
+	if result.Duration > 3*time.Second {
+		t.Errorf("Command ran for %v after the timeout", result.Duration)
+	}
+}
+
+func TestRunTruncatesOutput(t *testing.T) {
+	r := newTestRunner(t, Config{MaxOutputBytes: 10})
+
+	result, err := r.Run(context.Background(), []string{"sh", "-c", "yes | head -c 1000"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(result.Stdout) != 10 || !result.StdoutTruncated {
+		t.Errorf("Expected 10 truncated bytes, got %d (truncated: %v)", len(result.Stdout), result.StdoutTruncated)
+	}
+}
+
+func TestRunScrubsEnvironment(t *testing.T) {
+	t.Setenv("VOICEPILOT_TEST_SECRET", "hunter2")
+	r := newTestRunner(t, Config{})
+
+	result, err := r.Run(context.Background(), []string{"sh", "-c", "env; pwd"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if strings.Contains(result.Stdout, "hunter2") {
+		t.Error("Server environment leaked into the command")
+	}
+	if !strings.Contains(result.Stdout, "HOME="+r.cfg.WorkDir) || !strings.HasSuffix(result.Stdout, r.cfg.WorkDir+"\n") {
+		t.Errorf("Expected HOME and working directory %s, got %q", r.cfg.WorkDir, result.Stdout)
+	}
+}
+
+func TestRunResourceLimits(t *testing.T) {
+	r := newTestRunner(t, Config{MemoryMB: 64, CPUSeconds: 5})
+
+	result, err := r.Run(context.Background(), []string{"sh", "-c", "ulimit -v; ulimit -t"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if result.Stdout != "65536\n5\n" {
+		t.Errorf("Unexpected limits: %q", result.Stdout)
+	}
+}
+
+func TestRunEmptyCommand(t *testing.T) {
+	r := newTestRunner(t, Config{})
+	if _, err := r.Run(context.Background(), nil); err == nil {
+		t.Error("Expected error for empty command")
+	}
+}
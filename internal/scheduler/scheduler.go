@@ -0,0 +1,278 @@
+// Package scheduler runs one-off jobs at a given time and keeps them on disk across restarts
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned by Cancel when the session has no job with the given ID
+var ErrNotFound = errors.New("job not found")
+
+// Job is a reminder due at FireAt for a session
+type Job struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id"`
+	Text      string    `json:"text"`
+	FireAt    time.Time `json:"fire_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FireFunc is called, on its own goroutine, when a job is due
+type FireFunc func(job Job)
+
+// Scheduler keeps pending jobs in a JSON file and fires them when they are due
+//
+// Jobs that came due while the server was down fire as soon as the
+// scheduler is started again.
+type Scheduler struct {
+	path string // JSON file the jobs are stored in, empty keeps them in memory only
+
+	mu   sync.Mutex
+	jobs map[string]Job
+	fire FireFunc
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a scheduler that stores its jobs at path, loading any jobs saved there
+func New(path string) (*Scheduler, error) {
+	s := &Scheduler{
+		path: path,
+		jobs: make(map[string]Job),
+		wake: make(chan struct{}, 1),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scheduled jobs: %w", err)
+	}
+
+	var jobs []Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("failed to parse scheduled jobs %s: %w", path, err)
+	}
+	for _, job := range jobs {
+		s.jobs[job.ID] = job
+	}
+	log.Printf("Loaded %d scheduled jobs from %s", len(jobs), path)
+	return s, nil
+}
+
+// Start fires due jobs in the background by calling fire until Stop is called
+func (s *Scheduler) Start(fire FireFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	s.fire = fire
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(s.stop, s.done)
+}
+
+// Stop stops firing jobs; pending jobs stay stored
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop = nil
+	s.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// Add schedules a job for the session and saves it
+func (s *Scheduler) Add(sessionID, text string, fireAt time.Time) (Job, error) {
+	job := Job{
+		ID:        uuid.New().String()[:8],
+		SessionID: sessionID,
+		Text:      text,
+		FireAt:    fireAt,
+		CreatedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	s.jobs[job.ID] = job
+	err := s.save()
+	if err != nil {
+		delete(s.jobs, job.ID)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return Job{}, err
+	}
+
+	s.notify()
+	return job, nil
+}
+
+// List returns the session's pending jobs, earliest first
+func (s *Scheduler) List(sessionID string) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var jobs []Job
+	for _, job := range s.jobs {
+		if job.SessionID == sessionID {
+			jobs = append(jobs, job)
+		}
+	}
+	sortJobs(jobs)
+	return jobs
+}
+
+// Cancel removes a pending job of the session
+func (s *Scheduler) Cancel(sessionID, id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || job.SessionID != sessionID {
+		return Job{}, ErrNotFound
+	}
+	delete(s.jobs, id)
+	if err := s.save(); err != nil {
+		s.jobs[id] = job
+		return Job{}, err
+	}
+	return job, nil
+}
+
+// notify wakes the run loop so it picks up a changed schedule
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run waits for the next job to come due and fires it
+func (s *Scheduler) run(stop, done chan struct{}) {
+	defer close(done)
+
+	for {
+		var timer *time.Timer
+		var due <-chan time.Time
+		if next, ok := s.next(); ok {
+			timer = time.NewTimer(time.Until(next))
+			due = timer.C
+		}
+
+		select {
+		case <-stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-s.wake:
+			if timer != nil {
+				timer.Stop()
+			}
+		case <-due:
+			s.fireDue()
+		}
+	}
+}
+
+// next returns when the earliest pending job is due
+func (s *Scheduler) next() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next time.Time
+	for _, job := range s.jobs {
+		if next.IsZero() || job.FireAt.Before(next) {
+			next = job.FireAt
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// fireDue removes the jobs that are due and hands them to the fire function
+func (s *Scheduler) fireDue() {
+	now := time.Now()
+
+	s.mu.Lock()
+	var due []Job
+	for id, job := range s.jobs {
+		if !job.FireAt.After(now) {
+			due = append(due, job)
+			delete(s.jobs, id)
+		}
+	}
+	if len(due) > 0 {
+		if err := s.save(); err != nil {
+			log.Printf("Failed to save scheduled jobs: %v", err)
+		}
+	}
+	fire := s.fire
+	s.mu.Unlock()
+
+	sortJobs(due)
+	for _, job := range due {
+		log.Printf("Firing scheduled job %s for session %s (due %s)", job.ID, job.SessionID, job.FireAt.Format(time.RFC3339))
+		go fire(job)
+	}
+}
+
+// save writes the jobs to the store file; s.mu must be held
+func (s *Scheduler) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	sortJobs(jobs)
+
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal scheduled jobs: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create scheduler directory: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated store
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write scheduled jobs: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write scheduled jobs: %w", err)
+	}
+	return nil
+}
+
+// sortJobs orders jobs by due time, then ID
+func sortJobs(jobs []Job) {
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].FireAt.Equal(jobs[j].FireAt) {
+			return jobs[i].FireAt.Before(jobs[j].FireAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+}
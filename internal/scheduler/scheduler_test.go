@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSchedulerFires(t *testing.T) {
+	s, err := New("")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	fired := make(chan Job, 2)
+	s.Start(func(job Job) { fired <- job })
+	defer s.Stop()
+
+	later, _ := s.Add("s1", "开会", time.Now().Add(time.Hour))
+	first, _ := s.Add("s1", "喝水", time.Now().Add(50*time.Millisecond))
+
+	select {
+	case job := <-fired:
+		if job.ID != first.ID || job.Text != "喝水" {
+			t.Errorf("Fired %+v, want %+v", job, first)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Job did not fire")
+	}
+
+	if jobs := s.List("s1"); len(jobs) != 1 || jobs[0].ID != later.ID {
+		t.Errorf("List() after firing = %+v, want only the later job", jobs)
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s, _ := New("")
+	job, _ := s.Add("s1", "喝水", time.Now().Add(time.Hour))
+
+	if _, err := s.Cancel("s2", job.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Cancel() from another session error = %v, want ErrNotFound", err)
+	}
+	if cancelled, err := s.Cancel("s1", job.ID); err != nil || cancelled.ID != job.ID {
+		t.Errorf("Cancel() = %+v, %v", cancelled, err)
+	}
+	if jobs := s.List("s1"); len(jobs) != 0 {
+		t.Errorf("List() after cancel = %+v", jobs)
+	}
+}
+
+func TestSchedulerPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler", "jobs.json")
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	pending, _ := s.Add("s1", "开会", time.Now().Add(time.Hour))
+	missed, _ := s.Add("s1", "喝水", time.Now().Add(20*time.Millisecond))
+
+	// Restart after the second job came due while nothing was running
+	time.Sleep(50 * time.Millisecond)
+	s, err = New(path)
+	if err != nil {
+		t.Fatalf("New() after restart error = %v", err)
+	}
+	if jobs := s.List("s1"); len(jobs) != 2 {
+		t.Fatalf("Restored %d jobs, want 2", len(jobs))
+	}
+
+	fired := make(chan Job, 1)
+	s.Start(func(job Job) { fired <- job })
+	defer s.Stop()
+
+	select {
+	case job := <-fired:
+		if job.ID != missed.ID {
+			t.Errorf("Fired %s, want the missed job %s", job.ID, missed.ID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Missed job did not fire after restart")
+	}
+
+	// Fired jobs are removed from the store
+	restored, err := New(path)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if jobs := restored.List("s1"); len(jobs) != 1 || jobs[0].ID != pending.ID {
+		t.Errorf("Stored jobs after firing = %+v, want only %s", jobs, pending.ID)
+	}
+
+	os.WriteFile(path, []byte("not json"), 0644)
+	if _, err := New(path); err == nil {
+		t.Error("Expected an error for a corrupt store")
+	}
+}
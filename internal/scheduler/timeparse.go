@@ -0,0 +1,274 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrPast is returned by ParseTime for times that have already passed
+var ErrPast = errors.New("time is in the past")
+
+// absoluteLayouts are the date-time formats ParseTime accepts, interpreted in now's location
+var absoluteLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+var (
+	// relativePattern matches "10分钟后", "一个半小时以后", "半小时", "in 10 minutes", ...
+	relativePattern = regexp.MustCompile(`^(?:in\s+|过)?([0-9]+(?:\.[0-9]+)?|[零一二两三四五六七八九十百半]+)\s*(个半|个)?\s*(秒钟|秒|分钟|分|刻钟|小时|钟头|天|seconds?|secs?|minutes?|mins?|hours?|hrs?|days?)\s*(?:后|以后|之后|later)?$`)
+
+	// clockPattern matches "15:30", "3点", "明天上午9点半", "下周一晚上八点十五分", ...
+	clockPattern = regexp.MustCompile(`^(今天|明天|后天|(?:下|这|本)?(?:周|星期|礼拜)[一二三四五六日天])?\s*(凌晨|早上|早晨|上午|中午|下午|傍晚|晚上)?\s*([0-9]{1,2}|[零一二两三四五六七八九十]+)(?:[:：]([0-9]{2})|点钟?(?:(半)|([0-9]{1,2}|[零一二三四五六七八九十]+)分?)?)$`)
+
+	// clockShorthands expands colloquial day-and-period words so clockPattern can match them
+	clockShorthands = strings.NewReplacer("今晚", "今天晚上", "明晚", "明天晚上", "明早", "明天早上")
+
+	// weekdayPattern matches a weekday word, optionally of this or next week: "周三", "下星期一", ...
+	weekdayPattern = regexp.MustCompile(`^(下|这|本)?(?:周|星期|礼拜)([一二三四五六日天])$`)
+
+	// monthDayPattern matches a date within the year: "10月20日", "3月5号"
+	monthDayPattern = regexp.MustCompile(`^([0-9]{1,2})月([0-9]{1,2})[日号]?$`)
+
+	// nextDaysPattern matches a span of days starting today: "未来3天", "接下来7天"
+	nextDaysPattern = regexp.MustCompile(`^(?:未来|接下来|最近|之后)([0-9]+|[一二两三四五六七八九十]+)天$`)
+)
+
+// weekdayIndex maps weekday characters to days since Monday
+var weekdayIndex = map[string]int{"一": 0, "二": 1, "三": 2, "四": 3, "五": 4, "六": 5, "日": 6, "天": 6}
+
+// relativeUnits maps the units of relativePattern to durations
+var relativeUnits = map[string]time.Duration{
+	"秒钟": time.Second, "秒": time.Second, "second": time.Second, "seconds": time.Second, "sec": time.Second, "secs": time.Second,
+	"分钟": time.Minute, "分": time.Minute, "minute": time.Minute, "minutes": time.Minute, "min": time.Minute, "mins": time.Minute,
+	"刻钟": 15 * time.Minute,
+	"小时": time.Hour, "钟头": time.Hour, "hour": time.Hour, "hours": time.Hour, "hr": time.Hour, "hrs": time.Hour,
+	"天": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+}
+
+// ParseTime resolves a time expression from the user's request relative to now
+//
+// It understands absolute date-times ("2026-10-17 15:00", RFC 3339), Go
+// durations ("1h30m"), relative expressions ("10分钟后", "半小时后",
+// "in 10 minutes") and clock times ("3点半", "明天上午9点", "下周一10点",
+// "21:00"). A clock time without a day means its next occurrence; an hour
+// without a period that has passed this morning is taken as the afternoon.
+func ParseTime(expr string, now time.Time) (time.Time, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return time.Time{}, errors.New("empty time expression")
+	}
+
+	for _, layout := range absoluteLayouts {
+		if t, err := time.ParseInLocation(layout, expr, now.Location()); err == nil {
+			return future(t, now)
+		}
+	}
+
+	if d, err := time.ParseDuration(expr); err == nil {
+		return future(now.Add(d), now)
+	}
+
+	if m := relativePattern.FindStringSubmatch(strings.ToLower(expr)); m != nil {
+		amount, ok := parseNumber(m[1])
+		if !ok {
+			return time.Time{}, fmt.Errorf("unrecognized time %q", expr)
+		}
+		if m[2] == "个半" {
+			amount += 0.5
+		}
+		return future(now.Add(time.Duration(amount*float64(relativeUnits[m[3]]))), now)
+	}
+
+	if m := clockPattern.FindStringSubmatch(clockShorthands.Replace(expr)); m != nil {
+		return parseClock(m, now, expr)
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized time %q", expr)
+}
+
+// parseClock resolves a clockPattern match
+func parseClock(m []string, now time.Time, expr string) (time.Time, error) {
+	day, period := m[1], m[2]
+	offset, bareWeekday := dayOffset(day, now)
+
+	hour, ok := parseNumber(m[3])
+	if !ok {
+		return time.Time{}, fmt.Errorf("unrecognized time %q", expr)
+	}
+	minute := 0.0
+	switch {
+	case m[4] != "":
+		minute, ok = parseNumber(m[4])
+	case m[5] != "":
+		minute = 30
+	case m[6] != "":
+		minute, ok = parseNumber(m[6])
+	}
+	if !ok || hour > 23 || minute > 59 || hour != float64(int(hour)) {
+		return time.Time{}, fmt.Errorf("unrecognized time %q", expr)
+	}
+
+	h := int(hour)
+	switch period {
+	case "下午", "傍晚", "晚上":
+		if h < 12 {
+			h += 12
+		}
+	case "中午":
+		if h < 11 {
+			h += 12
+		}
+	}
+
+	y, mo, d := now.Date()
+	t := time.Date(y, mo, d+offset, h, int(minute), 0, 0, now.Location())
+
+	if bareWeekday && !t.After(now) {
+		t = t.AddDate(0, 0, 7)
+	}
+	if day == "" && !t.After(now) {
+		if period == "" && h < 12 && t.Add(12*time.Hour).After(now) {
+			t = t.Add(12 * time.Hour)
+		} else {
+			t = t.AddDate(0, 0, 1)
+		}
+	}
+	return future(t, now)
+}
+
+// dayOffset returns how many days after now's date a day word of clockPattern refers to
+//
+// A bare weekday ("周三") means its next occurrence, which may be today; it
+// is reported so a time already passed today can move on to next week.
+func dayOffset(day string, now time.Time) (offset int, bareWeekday bool) {
+	switch day {
+	case "", "今天":
+		return 0, false
+	case "明天":
+		return 1, false
+	case "后天":
+		return 2, false
+	}
+
+	m := weekdayPattern.FindStringSubmatch(day)
+	target := weekdayIndex[m[2]]
+	today := (int(now.Weekday()) + 6) % 7
+	switch m[1] {
+	case "下":
+		return 7 - today + target, false
+	case "这", "本":
+		return target - today, false
+	}
+	return (target - today + 7) % 7, true
+}
+
+// ParseDateRange resolves a day or span expression to the half-open interval [from, to) in now's location
+//
+// It understands "今天", "明天", "后天", weekdays ("周三", "下周一"), "本周",
+// "下周", "本月", "下个月", "未来3天", dates ("2026-10-17", "10月20日").
+func ParseDateRange(expr string, now time.Time) (from, to time.Time, err error) {
+	expr = strings.TrimSpace(expr)
+	y, mo, d := now.Date()
+	today := time.Date(y, mo, d, 0, 0, 0, 0, now.Location())
+	day := func(t time.Time) (time.Time, time.Time, error) { return t, t.AddDate(0, 0, 1), nil }
+
+	switch expr {
+	case "今天", "明天", "后天":
+		offset, _ := dayOffset(expr, now)
+		return day(today.AddDate(0, 0, offset))
+	case "本周", "这周", "这个星期", "本星期":
+		monday := today.AddDate(0, 0, -((int(now.Weekday()) + 6) % 7))
+		return monday, monday.AddDate(0, 0, 7), nil
+	case "下周", "下个星期", "下星期":
+		monday := today.AddDate(0, 0, 7-(int(now.Weekday())+6)%7)
+		return monday, monday.AddDate(0, 0, 7), nil
+	case "本月", "这个月":
+		first := time.Date(y, mo, 1, 0, 0, 0, 0, now.Location())
+		return first, first.AddDate(0, 1, 0), nil
+	case "下个月", "下月":
+		first := time.Date(y, mo+1, 1, 0, 0, 0, 0, now.Location())
+		return first, first.AddDate(0, 1, 0), nil
+	}
+
+	if weekdayPattern.MatchString(expr) {
+		offset, _ := dayOffset(expr, now)
+		return day(today.AddDate(0, 0, offset))
+	}
+	if t, err := time.ParseInLocation("2006-01-02", expr, now.Location()); err == nil {
+		return day(t)
+	}
+	if m := monthDayPattern.FindStringSubmatch(expr); m != nil {
+		month, _ := strconv.Atoi(m[1])
+		date, _ := strconv.Atoi(m[2])
+		t := time.Date(y, time.Month(month), date, 0, 0, 0, 0, now.Location())
+		if month < 1 || month > 12 || t.Day() != date {
+			return time.Time{}, time.Time{}, fmt.Errorf("unrecognized date %q", expr)
+		}
+		if t.Before(today) {
+			t = t.AddDate(1, 0, 0)
+		}
+		return day(t)
+	}
+	if m := nextDaysPattern.FindStringSubmatch(expr); m != nil {
+		if n, ok := parseNumber(m[1]); ok && n >= 1 {
+			return today, today.AddDate(0, 0, int(n)), nil
+		}
+	}
+
+	return time.Time{}, time.Time{}, fmt.Errorf("unrecognized date %q", expr)
+}
+
+// future returns t, or ErrPast if it is not after now
+func future(t, now time.Time) (time.Time, error) {
+	if !t.After(now) {
+		return time.Time{}, ErrPast
+	}
+	return t, nil
+}
+
+// chineseDigits are the values of the Chinese numerals parseNumber understands
+var chineseDigits = map[rune]int{
+	'零': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4,
+	'五': 5, '六': 6, '七': 7, '八': 8, '九': 9,
+}
+
+// parseNumber parses an Arabic number or a Chinese numeral up to the hundreds ("十五", "两百"); "半" is 0.5
+func parseNumber(s string) (float64, bool) {
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return n, true
+	}
+	if s == "半" {
+		return 0.5, true
+	}
+
+	total, current := 0, 0
+	for _, r := range s {
+		switch r {
+		case '十', '百':
+			unit := 10
+			if r == '百' {
+				unit = 100
+			}
+			if current == 0 {
+				current = 1
+			}
+			total += current * unit
+			current = 0
+		default:
+			digit, ok := chineseDigits[r]
+			if !ok {
+				return 0, false
+			}
+			current = digit
+		}
+	}
+	return float64(total + current), s != ""
+}
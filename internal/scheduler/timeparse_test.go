@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, loc)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, loc)
+	}
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"10分钟后", now.Add(10 * time.Minute)},
+		{"10 分钟以后", now.Add(10 * time.Minute)},
+		{"三十秒后", now.Add(30 * time.Second)},
+		{"半小时后", now.Add(30 * time.Minute)},
+		{"一个半小时后", now.Add(90 * time.Minute)},
+		{"两个小时之后", now.Add(2 * time.Hour)},
+		{"一刻钟后", now.Add(15 * time.Minute)},
+		{"in 10 minutes", now.Add(10 * time.Minute)},
+		{"1h30m", now.Add(90 * time.Minute)},
+		{"2026-10-17 15:00", at(17, 15, 0)},
+		{"2026-10-17T15:00:00+08:00", at(17, 15, 0)},
+		{"15:30", at(16, 15, 30)},
+		{"11点", at(16, 11, 0)},
+		{"3点半", at(16, 15, 30)}, // 3am has passed, so the afternoon is meant
+		{"9点", at(16, 21, 0)},   // likewise 9am
+		{"上午9点", at(17, 9, 0)},  // an explicit morning rolls over to tomorrow
+		{"明天上午9点", at(17, 9, 0)},
+		{"明天早上八点十五分", at(17, 8, 15)},
+		{"后天下午3点", at(18, 15, 0)},
+		{"今晚8点", at(16, 20, 0)},
+		{"中午12点", at(16, 12, 0)},
+		{"周五下午3点", at(16, 15, 0)}, // today is a Friday
+		{"周五上午9点", at(23, 9, 0)},  // already passed, so next Friday
+		{"下周一上午10点", at(19, 10, 0)},
+		{"星期日晚上8点", at(18, 20, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := ParseTime(tt.expr, now)
+			if err != nil {
+				t.Fatalf("ParseTime(%q) error = %v", tt.expr, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseTime(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeErrors(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+
+	for _, expr := range []string{"", "等会儿", "25点", "明天", "soon"} {
+		if _, err := ParseTime(expr, now); err == nil || errors.Is(err, ErrPast) {
+			t.Errorf("ParseTime(%q) error = %v, want unrecognized", expr, err)
+		}
+	}
+
+	for _, expr := range []string{"2026-10-15 09:00", "今天上午9点", "-5m", "这周三9点"} {
+		if _, err := ParseTime(expr, now); !errors.Is(err, ErrPast) {
+			t.Errorf("ParseTime(%q) error = %v, want ErrPast", expr, err)
+		}
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, loc) // a Friday
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, loc) }
+
+	tests := []struct {
+		expr     string
+		from, to time.Time
+	}{
+		{"今天", day(10, 16), day(10, 17)},
+		{"明天", day(10, 17), day(10, 18)},
+		{"周三", day(10, 21), day(10, 22)},
+		{"下周一", day(10, 19), day(10, 20)},
+		{"本周", day(10, 12), day(10, 19)},
+		{"下周", day(10, 19), day(10, 26)},
+		{"本月", day(10, 1), day(11, 1)},
+		{"下个月", day(11, 1), day(12, 1)},
+		{"未来三天", day(10, 16), day(10, 19)},
+		{"2026-12-01", day(12, 1), day(12, 2)},
+		{"10月20日", day(10, 20), day(10, 21)},
+		{"1月5号", time.Date(2027, 1, 5, 0, 0, 0, 0, loc), time.Date(2027, 1, 6, 0, 0, 0, 0, loc)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			from, to, err := ParseDateRange(tt.expr, now)
+			if err != nil {
+				t.Fatalf("ParseDateRange(%q) error = %v", tt.expr, err)
+			}
+			if !from.Equal(tt.from) || !to.Equal(tt.to) {
+				t.Errorf("ParseDateRange(%q) = [%v, %v), want [%v, %v)", tt.expr, from, to, tt.from, tt.to)
+			}
+		})
+	}
+
+	for _, expr := range []string{"", "以后", "2月30日", "13月1日"} {
+		if _, _, err := ParseDateRange(expr, now); err == nil {
+			t.Errorf("ParseDateRange(%q) succeeded, want error", expr)
+		}
+	}
+}
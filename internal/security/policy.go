@@ -0,0 +1,331 @@
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Policy outcomes
+const (
+	OutcomeAllow   = "allow"
+	OutcomeDeny    = "deny"
+	OutcomeConfirm = "confirm"
+)
+
+// Decision is the result of a successful ValidateAction call
+type Decision struct {
+	Outcome string `json:"outcome"` // OutcomeAllow or OutcomeConfirm
+	// Rule is the name of the policy rule that matched; empty when the built-in checks decided
+	Rule string `json:"rule,omitempty"`
+}
+
+// PolicyDefinition is the declarative security policy read from a YAML or JSON file
+//
+// Rules are checked in order and the first one that matches the action and
+// all of its matchers decides the outcome. Actions no rule matches fall back
+// to the built-in checks.
+type PolicyDefinition struct {
+	Rules []RuleDefinition `json:"rules" yaml:"rules"`
+}
+
+// RuleDefinition allows, denies or asks for confirmation of matching actions
+type RuleDefinition struct {
+	Name    string `json:"name" yaml:"name"`
+	Action  string `json:"action" yaml:"action"` // action name, "*" or empty matches any action
+	Outcome string `json:"outcome" yaml:"outcome"`
+	Message string `json:"message,omitempty" yaml:"message,omitempty"` // shown to the user on deny
+	Match   Match  `json:"match,omitempty" yaml:"match,omitempty"`
+}
+
+// Match holds the parameter matchers of a rule; every matcher that is set must match
+type Match struct {
+	Binaries     []string          `json:"binaries,omitempty" yaml:"binaries,omitempty"`           // command binaries: bare names run through PATH, or absolute paths
+	Argv         []string          `json:"argv,omitempty" yaml:"argv,omitempty"`                   // regexes, any must match the parsed arguments joined by spaces
+	PathPrefixes []string          `json:"path_prefixes,omitempty" yaml:"path_prefixes,omitempty"` // prefixes of the "path" parameter
+	Apps         []string          `json:"apps,omitempty" yaml:"apps,omitempty"`                   // app names, case-insensitive
+	Params       map[string]string `json:"params,omitempty" yaml:"params,omitempty"`               // regex per parameter
+}
+
+// LoadPolicyDefinition reads a policy definition from a YAML or JSON file
+func LoadPolicyDefinition(path string) (*PolicyDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read security policy: %w", err)
+	}
+
+	var def PolicyDefinition
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &def)
+	} else {
+		err = yaml.Unmarshal(data, &def)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse security policy: %w", err)
+	}
+
+	return &def, nil
+}
+
+// policy is a compiled PolicyDefinition
+type policy struct {
+	rules []*rule
+}
+
+type rule struct {
+	def    RuleDefinition
+	argv   []*regexp.Regexp
+	params map[string]*regexp.Regexp
+}
+
+// compilePolicy validates a definition and compiles its regexes
+func compilePolicy(def *PolicyDefinition) (*policy, error) {
+	p := &policy{}
+	for i, rd := range def.Rules {
+		if rd.Name == "" {
+			rd.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		switch rd.Outcome {
+		case OutcomeAllow, OutcomeDeny, OutcomeConfirm:
+		default:
+			return nil, fmt.Errorf("rule %s has unknown outcome %q", rd.Name, rd.Outcome)
+		}
+
+		r := &rule{def: rd, params: make(map[string]*regexp.Regexp)}
+		for _, expr := range rd.Match.Argv {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s has invalid argv pattern: %w", rd.Name, err)
+			}
+			r.argv = append(r.argv, re)
+		}
+		for name, expr := range rd.Match.Params {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s has invalid pattern for parameter %s: %w", rd.Name, name, err)
+			}
+			r.params[name] = re
+		}
+		p.rules = append(p.rules, r)
+	}
+	return p, nil
+}
+
+// match returns the first rule matching the action, or nil
+//
+// argv is the parsed command of an execute_command action.
+func (p *policy) match(action string, params map[string]interface{}, argv []string) *rule {
+	for _, r := range p.rules {
+		if r.matches(action, params, argv) {
+			return r
+		}
+	}
+	return nil
+}
+
+// unconditional returns the rule that decides the action whatever its parameters, or nil
+//
+// That is the first rule for the action, if it has no matchers.
+func (p *policy) unconditional(action string) *rule {
+	for _, r := range p.rules {
+		if !r.appliesTo(action) {
+			continue
+		}
+		if r.hasMatchers() {
+			return nil
+		}
+		return r
+	}
+	return nil
+}
+
+// mentions reports whether a non-deny rule names the action explicitly
+func (p *policy) mentions(action string) bool {
+	for _, r := range p.rules {
+		if r.def.Action == action && r.def.Outcome != OutcomeDeny {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *rule) appliesTo(action string) bool {
+	return r.def.Action == "" || r.def.Action == "*" || r.def.Action == action
+}
+
+func (r *rule) hasMatchers() bool {
+	m := r.def.Match
+	return len(m.Binaries) > 0 || len(m.Argv) > 0 || len(m.PathPrefixes) > 0 || len(m.Apps) > 0 || len(m.Params) > 0
+}
+
+func (r *rule) matches(action string, params map[string]interface{}, argv []string) bool {
+	if !r.appliesTo(action) {
+		return false
+	}
+
+	m := r.def.Match
+
+	if len(m.Binaries) > 0 {
+		if len(argv) == 0 || !matchesBinary(m.Binaries, argv[0], r.def.Outcome != OutcomeAllow) {
+			return false
+		}
+	}
+
+	if len(r.argv) > 0 {
+		if len(argv) == 0 {
+			return false
+		}
+		line := strings.Join(argv, " ")
+		matched := false
+		for _, re := range r.argv {
+			if re.MatchString(line) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(m.PathPrefixes) > 0 {
+		path, ok := params["path"].(string)
+		if !ok || !hasPathPrefix(path, m.PathPrefixes) {
+			return false
+		}
+	}
+
+	if len(m.Apps) > 0 {
+		name, ok := params["name"].(string)
+		if !ok || !containsFold(m.Apps, name) {
+			return false
+		}
+	}
+
+	for name, re := range r.params {
+		value, ok := params[name]
+		if !ok || !re.MatchString(fmt.Sprint(value)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesBinary reports whether the program a command runs is one of the binaries of a rule
+//
+// A bare name only matches a program looked up through PATH, and an absolute
+// path only matches itself, so allowing "ls" doesn't allow "/tmp/x/ls". Rules
+// that restrict a command (byBase) also match a program given by path through
+// its base name, so denying "rm" still catches "/bin/rm".
+func matchesBinary(binaries []string, program string, byBase bool) bool {
+	qualified := strings.Contains(program, "/")
+	for _, binary := range binaries {
+		switch {
+		case strings.Contains(binary, "/"):
+			if qualified && filepath.Clean(binary) == filepath.Clean(program) {
+				return true
+			}
+		case !qualified || byBase:
+			if strings.EqualFold(binary, filepath.Base(program)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasPathPrefix reports whether the cleaned path lies within one of the prefixes
+func hasPathPrefix(path string, prefixes []string) bool {
+	path = filepath.Clean(expandHome(path))
+	for _, prefix := range prefixes {
+		prefix = filepath.Clean(expandHome(prefix))
+		if path == prefix || strings.HasPrefix(path, prefix+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandHome replaces a leading "~/" with the user's home directory
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadPolicy loads the policy file, replacing the current policy
+func (s *SecurityManager) LoadPolicy(path string) error {
+	def, err := LoadPolicyDefinition(path)
+	if err != nil {
+		return err
+	}
+	p, err := compilePolicy(def)
+	if err != nil {
+		return fmt.Errorf("invalid security policy: %w", err)
+	}
+
+	s.mu.Lock()
+	s.policy = p
+	s.mu.Unlock()
+
+	log.Printf("Loaded security policy from %s (%d rules)", path, len(p.rules))
+	return nil
+}
+
+// WatchPolicy reloads the policy file whenever its modification time changes
+//
+// The file is checked every interval. A policy that fails to load is logged
+// and the previous one stays in effect. Call the returned function to stop watching.
+func (s *SecurityManager) WatchPolicy(path string, interval time.Duration) func() {
+	var lastMod time.Time
+	if info, err := os.Stat(path); err == nil {
+		lastMod = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil || info.ModTime().Equal(lastMod) {
+					continue
+				}
+				lastMod = info.ModTime()
+				if err := s.LoadPolicy(path); err != nil {
+					log.Printf("Failed to reload security policy, keeping previous one: %v", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
@@ -0,0 +1,221 @@
+package security
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+)
+
+const testPolicy = `
+rules:
+  - name: deny-rm
+    action: execute_command
+    outcome: deny
+    match:
+      binaries: [rm]
+  - name: git-status
+    action: execute_command
+    outcome: allow
+    match:
+      argv: ["^git (status|log)( |$)"]
+  - name: read-only
+    action: execute_command
+    outcome: allow
+    match:
+      binaries: [ls, cat, /usr/bin/stat]
+  - name: other-commands
+    action: execute_command
+    outcome: confirm
+  - name: documents
+    action: save_file
+    outcome: allow
+    match:
+      path_prefixes: [docs]
+  - name: known-apps
+    action: open_app
+    outcome: allow
+    match:
+      apps: [Safari]
+  - name: short-songs
+    action: play_music
+    outcome: deny
+    match:
+      params:
+        song: "^.{0,1}$"
+`
+
+func writePolicy(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write policy: %v", err)
+	}
+	return path
+}
+
+func TestPolicyValidateAction(t *testing.T) {
+	config.AppConfig = &config.Config{EnableSafeMode: false, WorkspaceRoot: t.TempDir()}
+
+	sm := NewSecurityManager()
+	if err := sm.LoadPolicy(writePolicy(t, testPolicy)); err != nil {
+		t.Fatalf("LoadPolicy failed: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		action      string
+		params      map[string]interface{}
+		wantOutcome string // OutcomeDeny means an error is expected
+		wantRule    string
+	}{
+		{"binary denylist", "execute_command", map[string]interface{}{"command": "/bin/rm -rf tmp"}, OutcomeDeny, "deny-rm"},
+		{"argv regex", "execute_command", map[string]interface{}{"command": "git   status"}, OutcomeAllow, "git-status"},
+		{"argv regex mismatch", "execute_command", map[string]interface{}{"command": "git push"}, OutcomeConfirm, "other-commands"},
+		// "su" and "del" are built-in dangerous keywords, but the policy allows these commands
+		{"binary allowlist", "execute_command", map[string]interface{}{"command": "cat summary.txt model.txt"}, OutcomeAllow, "read-only"},
+		{"allowlisted name at another path", "execute_command", map[string]interface{}{"command": "/tmp/x/ls"}, OutcomeConfirm, "other-commands"},
+		{"allowlisted absolute path", "execute_command", map[string]interface{}{"command": "/usr/bin/stat a.txt"}, OutcomeAllow, "read-only"},
+		{"absolute path rule needs the path", "execute_command", map[string]interface{}{"command": "stat a.txt"}, OutcomeConfirm, "other-commands"},
+		{"allowlisted command with traversal", "execute_command", map[string]interface{}{"command": "cat ../../secret"}, OutcomeDeny, ""},
+		{"path prefix", "save_file", map[string]interface{}{"path": "docs/a.txt"}, OutcomeAllow, "documents"},
+		{"path prefix boundary falls back to built-in", "save_file", map[string]interface{}{"path": "docs2/a.txt"}, OutcomeConfirm, ""},
+		{"path prefix traversal", "save_file", map[string]interface{}{"path": "docs/../../.ssh/id_rsa"}, OutcomeDeny, ""},
+		{"path outside workspace", "save_file", map[string]interface{}{"path": "/etc/passwd"}, OutcomeDeny, ""},
+		{"app list", "open_app", map[string]interface{}{"name": "safari"}, OutcomeAllow, "known-apps"},
+		{"app not listed falls back to built-in", "open_app", map[string]interface{}{"name": "Music"}, OutcomeAllow, ""},
+		{"param regex", "play_music", map[string]interface{}{"song": "a"}, OutcomeDeny, "short-songs"},
+		{"command chain is always rejected", "execute_command", map[string]interface{}{"command": "ls; rm -rf /"}, OutcomeDeny, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := sm.ValidateAction(tt.action, tt.params)
+			if tt.wantOutcome == OutcomeDeny {
+				var denial *DenialError
+				if !errors.As(err, &denial) {
+					t.Fatalf("ValidateAction() error = %v, want *DenialError", err)
+				}
+				if denial.Rule != tt.wantRule {
+					t.Errorf("Rule = %q, want %q", denial.Rule, tt.wantRule)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ValidateAction() error = %v", err)
+			}
+			if decision.Outcome != tt.wantOutcome || decision.Rule != tt.wantRule {
+				t.Errorf("Decision = %+v, want outcome %q rule %q", decision, tt.wantOutcome, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestLoadPolicyErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+	}{
+		{"unknown outcome", "rules:\n  - name: r\n    outcome: maybe\n"},
+		{"invalid argv regex", "rules:\n  - name: r\n    outcome: allow\n    match:\n      argv: [\"(\"]\n"},
+		{"invalid param regex", "rules:\n  - name: r\n    outcome: allow\n    match:\n      params:\n        song: \"[\"\n"},
+		{"malformed yaml", "rules: [\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewSecurityManager()
+			if err := sm.LoadPolicy(writePolicy(t, tt.policy)); err == nil {
+				t.Error("Expected error loading policy")
+			}
+		})
+	}
+}
+
+func TestExamplePolicy(t *testing.T) {
+	sm := NewSecurityManager()
+	if err := sm.LoadPolicy("../../security_policy.example.yaml"); err != nil {
+		t.Fatalf("Example policy failed to load: %v", err)
+	}
+}
+
+func TestWatchPolicy(t *testing.T) {
+	config.AppConfig = &config.Config{EnableSafeMode: false}
+
+	path := writePolicy(t, "rules:\n  - name: first\n    action: open_app\n    outcome: allow\n")
+	sm := NewSecurityManager()
+	if err := sm.LoadPolicy(path); err != nil {
+		t.Fatalf("LoadPolicy failed: %v", err)
+	}
+	stop := sm.WatchPolicy(path, 10*time.Millisecond)
+	defer stop()
+
+	if err := os.WriteFile(path, []byte("rules:\n  - name: second\n    action: open_app\n    outcome: confirm\n"), 0644); err != nil {
+		t.Fatalf("Failed to rewrite policy: %v", err)
+	}
+	// Make sure the modification time changes even on coarse-grained filesystems
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		decision, err := sm.ValidateAction("open_app", map[string]interface{}{"name": "Music"})
+		if err == nil && decision.Rule == "second" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Policy was not reloaded after the file changed")
+}
+
+func TestActionPermitted(t *testing.T) {
+	policy := `
+rules:
+  - name: no-music
+    action: play_music
+    outcome: deny
+  - name: some-apps
+    action: open_app
+    outcome: deny
+    match:
+      apps: [Terminal]
+  - name: email
+    action: send_email
+    outcome: confirm
+`
+
+	tests := []struct {
+		name     string
+		safeMode bool
+		policy   string
+		action   string
+		want     bool
+	}{
+		{"allowed action", true, "", "open_app", true},
+		{"command in safe mode", true, "", "execute_command", false},
+		{"command outside safe mode", false, "", "execute_command", true},
+		{"unknown action", false, "", "send_email", false},
+		{"policy denies unconditionally", false, policy, "play_music", false},
+		{"policy denies some parameters", false, policy, "open_app", true},
+		{"policy allows unlisted action", false, policy, "send_email", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AppConfig = &config.Config{EnableSafeMode: tt.safeMode}
+			sm := NewSecurityManager()
+			if tt.policy != "" {
+				if err := sm.LoadPolicy(writePolicy(t, tt.policy)); err != nil {
+					t.Fatalf("LoadPolicy failed: %v", err)
+				}
+			}
+
+			if got := sm.ActionPermitted(tt.action); got != tt.want {
+				t.Errorf("ActionPermitted(%s) = %v, want %v", tt.action, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,17 +1,56 @@
 package security
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
 	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/deca/voicepilot-eino/internal/config"
+	"github.com/deca/voicepilot-eino/internal/shell"
+	"github.com/deca/voicepilot-eino/internal/telemetry"
+	"github.com/deca/voicepilot-eino/internal/workspace"
 )
 
+// Reasons reported by a DenialError
+const (
+	ReasonSafeMode         = "safe_mode"
+	ReasonUnknownAction    = "unknown_action"
+	ReasonInvalidParams    = "invalid_params"
+	ReasonDangerousKeyword = "dangerous_keyword"
+	ReasonPathTraversal    = "path_traversal"
+	ReasonCommandChain     = "command_chain"
+	ReasonPolicy           = "policy"
+)
+
+// DenialError is returned by ValidateAction when an action is rejected
+type DenialError struct {
+	Reason  string // one of the Reason* constants, suitable as a metric label
+	Rule    string // policy rule that denied the action, if any
+	Message string
+}
+
+func (e *DenialError) Error() string {
+	return e.Message
+}
+
+// deny builds a DenialError and counts it in the security metrics
+func deny(reason, format string, args ...interface{}) error {
+	telemetry.RecordSecurityDenial(reason)
+	return &DenialError{Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
 // SecurityManager manages security and permission checks
 type SecurityManager struct {
 	allowedActions    map[string]bool
+	confirmActions    map[string]bool // allowed actions that still need the user's confirmation
 	dangerousKeywords []string
+
+	mu     sync.RWMutex
+	policy *policy // rules from the policy file, nil when none is loaded
 }
 
 // NewSecurityManager creates a new security manager
@@ -25,6 +64,23 @@ func NewSecurityManager() *SecurityManager {
 			"clarify":         true,
 			"error":           true,
 			"execute_command": false, // Only allowed in non-safe mode
+			"save_file":       true,
+			"read_file":       true,
+			"list_dir":        true,
+			"search_files":    true,
+			"summarize_file":  true,
+			"set_reminder":    true,
+			"list_reminders":  true,
+			"cancel_reminder": true,
+			"add_event":       true,
+			"list_events":     true,
+			"delete_event":    true,
+			"undo_last":       true,
+		},
+		confirmActions: map[string]bool{
+			"execute_command": true,
+			"save_file":       true,
+			"delete_event":    true,
 		},
 		dangerousKeywords: []string{
 			"rm -rf", "del", "format", "shutdown", "reboot",
@@ -39,83 +95,267 @@ func NewSecurityManager() *SecurityManager {
 }
 
 // ValidateAction validates if an action is allowed
-func (s *SecurityManager) ValidateAction(action string, params map[string]interface{}) error {
+//
+// Safe mode and malformed parameters are always rejected. Otherwise the
+// policy file, if loaded, decides and the returned decision names the rule
+// that matched; actions no rule matches go through the built-in checks.
+func (s *SecurityManager) ValidateAction(action string, params map[string]interface{}) (*Decision, error) {
 	log.Printf("Security check for action: %s", action)
 
 	// Check if safe mode is enabled
 	if config.AppConfig.EnableSafeMode {
 		// In safe mode, only allow explicitly safe actions
 		if action == "execute_command" {
-			return fmt.Errorf("在安全模式下不允许执行系统命令")
+			return nil, deny(ReasonSafeMode, "在安全模式下不允许执行系统命令")
+		}
+		if allowed, exists := s.allowedActions[action]; exists && !allowed {
+			return nil, deny(ReasonSafeMode, "操作 %s 在安全模式下被禁止", action)
+		}
+	}
+
+	// Checks no policy rule can override
+	var argv []string
+	switch action {
+	case "execute_command":
+		var err error
+		if argv, err = parseCommand(params); err != nil {
+			return nil, err
+		}
+		if err := checkCommandPaths(argv); err != nil {
+			return nil, err
+		}
+	case "open_app":
+		if err := s.validateAppName(params); err != nil {
+			return nil, err
+		}
+	case "save_file", "read_file", "summarize_file":
+		if err := validateWorkspacePath(params, true); err != nil {
+			return nil, err
+		}
+	case "list_dir", "search_files":
+		if err := validateWorkspacePath(params, false); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.RLock()
+	p := s.policy
+	s.mu.RUnlock()
+	if p != nil {
+		if r := p.match(action, params, argv); r != nil {
+			log.Printf("Security policy rule %s matched action %s: %s", r.def.Name, action, r.def.Outcome)
+			if r.def.Outcome == OutcomeDeny {
+				message := r.def.Message
+				if message == "" {
+					message = fmt.Sprintf("操作 %s 被安全策略禁止（规则：%s）", action, r.def.Name)
+				}
+				telemetry.RecordSecurityDenial(ReasonPolicy)
+				return nil, &DenialError{Reason: ReasonPolicy, Rule: r.def.Name, Message: message}
+			}
+			return &Decision{Outcome: r.def.Outcome, Rule: r.def.Name}, nil
 		}
 	}
 
 	// Check if action is in allowed list
 	allowed, exists := s.allowedActions[action]
 	if !exists {
-		return fmt.Errorf("未知的操作类型：%s", action)
+		return nil, deny(ReasonUnknownAction, "未知的操作类型：%s", action)
 	}
 
 	if !allowed && config.AppConfig.EnableSafeMode {
-		return fmt.Errorf("操作 %s 在安全模式下被禁止", action)
+		return nil, deny(ReasonSafeMode, "操作 %s 在安全模式下被禁止", action)
 	}
 
 	// Additional validation for specific actions
-	switch action {
-	case "execute_command":
+	if action == "execute_command" {
 		if err := s.validateCommand(params); err != nil {
-			return err
+			return nil, err
 		}
-	case "open_app":
-		if err := s.validateAppName(params); err != nil {
-			return err
+	}
+
+	if s.RequiresConfirmation(action) {
+		return &Decision{Outcome: OutcomeConfirm}, nil
+	}
+	return &Decision{Outcome: OutcomeAllow}, nil
+}
+
+// ActionPermitted reports whether an action can be allowed under the current mode for some parameters
+//
+// It is used to decide which actions are offered to the planner; every step
+// still goes through ValidateAction before it runs.
+func (s *SecurityManager) ActionPermitted(action string) bool {
+	s.mu.RLock()
+	p := s.policy
+	s.mu.RUnlock()
+
+	allowed, exists := s.allowedActions[action]
+	if config.AppConfig.EnableSafeMode && (action == "execute_command" || (exists && !allowed)) {
+		return false
+	}
+	if p != nil {
+		if r := p.unconditional(action); r != nil {
+			return r.def.Outcome != OutcomeDeny
+		}
+		if p.mentions(action) {
+			return true
 		}
 	}
+	return exists
+}
 
-	return nil
+// RequiresConfirmation reports whether an allowed action must be confirmed by the user before it runs
+func (s *SecurityManager) RequiresConfirmation(action string) bool {
+	return s.confirmActions[action]
 }
 
 // validateCommand validates if a command is safe to execute
 func (s *SecurityManager) validateCommand(params map[string]interface{}) error {
-	command, ok := params["command"].(string)
-	if !ok {
-		return fmt.Errorf("命令参数无效")
+	argv, err := parseCommand(params)
+	if err != nil {
+		return err
 	}
 
-	command = strings.ToLower(command)
-
-	// Check for dangerous keywords
+	// Match whole words, so "summary.txt" doesn't hit "su"; words inside
+	// arguments count too, so "sh -c 'rm -rf /'" is still caught
+	words := commandWords(argv)
 	for _, keyword := range s.dangerousKeywords {
-		if strings.Contains(command, strings.ToLower(keyword)) {
-			log.Printf("Blocked dangerous command: %s (keyword: %s)", command, keyword)
-			return fmt.Errorf("命令包含危险关键字：%s", keyword)
+		if containsKeyword(words, keyword) {
+			log.Printf("Blocked dangerous command: %s (keyword: %s)", strings.Join(argv, " "), keyword)
+			return deny(ReasonDangerousKeyword, "命令包含危险关键字：%s", keyword)
 		}
 	}
 
-	// Check for dangerous patterns
-	if strings.Contains(command, "..") {
-		return fmt.Errorf("命令包含危险路径模式")
+	return checkCommandPaths(argv)
+}
+
+// checkCommandPaths rejects commands with ".." in an argument; policy rules can't allow them
+func checkCommandPaths(argv []string) error {
+	for _, arg := range argv {
+		if strings.Contains(arg, "..") {
+			return deny(ReasonPathTraversal, "命令包含危险路径模式")
+		}
 	}
+	return nil
+}
 
-	if strings.Contains(command, "|") || strings.Contains(command, ";") || strings.Contains(command, "&&") {
-		return fmt.Errorf("不允许使用管道或命令链")
+// commandWords splits the arguments of a command into lowercased words at spaces and shell punctuation
+//
+// Redirection operators are kept as words of their own.
+func commandWords(argv []string) []string {
+	var words []string
+	for _, arg := range argv {
+		var word strings.Builder
+		flush := func() {
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+		}
+		for _, r := range strings.ToLower(arg) {
+			switch {
+			case unicode.IsSpace(r) || strings.ContainsRune(";&|()`'\"$", r):
+				flush()
+			case r == '<' || r == '>':
+				flush()
+				words = append(words, string(r))
+			default:
+				word.WriteRune(r)
+			}
+		}
+		flush()
 	}
+	return words
+}
 
-	return nil
+// containsKeyword reports whether the words of a keyword appear one after another among words
+//
+// A keyword word ending in "=" or "/" also matches words it starts, and a
+// word naming a path also matches by its base name, so "/sbin/reboot" hits "reboot".
+func containsKeyword(words []string, keyword string) bool {
+	keywordWords := strings.Fields(strings.ToLower(keyword))
+	if len(keywordWords) == 0 {
+		return false
+	}
+	for i := 0; i+len(keywordWords) <= len(words); i++ {
+		matched := true
+		for j, k := range keywordWords {
+			if !wordMatches(words[i+j], k) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func wordMatches(word, keyword string) bool {
+	if word == keyword || filepath.Base(word) == keyword {
+		return true
+	}
+	return (strings.HasSuffix(keyword, "=") || strings.HasSuffix(keyword, "/")) && strings.HasPrefix(word, keyword)
+}
+
+// parseCommand parses the command parameter into the argv the executor will run
+//
+// Pipes, command chains and redirections are rejected: the executor runs a
+// single program without a shell, so they could never work as written.
+func parseCommand(params map[string]interface{}) ([]string, error) {
+	command, ok := params["command"].(string)
+	if !ok {
+		return nil, deny(ReasonInvalidParams, "命令参数无效")
+	}
+
+	cmd, err := shell.Parse(command)
+	if err != nil {
+		return nil, deny(ReasonInvalidParams, "命令格式无效：%v", err)
+	}
+	if cmd.HasOperators() {
+		return nil, deny(ReasonCommandChain, "不允许使用管道、重定向或命令链")
+	}
+	if len(cmd.Simple) == 0 {
+		return nil, deny(ReasonInvalidParams, "命令为空")
+	}
+
+	return cmd.Argv(), nil
 }
 
 // validateAppName validates if an application name is safe
 func (s *SecurityManager) validateAppName(params map[string]interface{}) error {
 	appName, ok := params["name"].(string)
 	if !ok {
-		return fmt.Errorf("应用程序名称参数无效")
+		return deny(ReasonInvalidParams, "应用程序名称参数无效")
 	}
 
 	// Check for path traversal attempts
 	if strings.Contains(appName, "..") || strings.Contains(appName, "/") || strings.Contains(appName, "\\") {
-		return fmt.Errorf("应用程序名称包含非法字符")
+		return deny(ReasonPathTraversal, "应用程序名称包含非法字符")
+	}
+
+	return nil
+}
+
+// validateWorkspacePath checks that the "path" parameter stays inside the workspace root
+//
+// When the path is optional, a missing one means the workspace root itself.
+func validateWorkspacePath(params map[string]interface{}, required bool) error {
+	path, ok := params["path"].(string)
+	if !ok {
+		if _, present := params["path"]; present || required {
+			return deny(ReasonInvalidParams, "文件路径参数无效")
+		}
+		path = "."
 	}
 
+	if _, err := workspace.Resolve(config.AppConfig.WorkspaceRoot, path); err != nil {
+		if errors.Is(err, workspace.ErrTraversal) || errors.Is(err, workspace.ErrOutside) {
+			log.Printf("Blocked path outside workspace: %s (%v)", path, err)
+			return deny(ReasonPathTraversal, "文件路径超出工作区范围：%s", path)
+		}
+		return deny(ReasonInvalidParams, "文件路径无效：%v", err)
+	}
 	return nil
 }
 
@@ -131,6 +371,18 @@ func (s *SecurityManager) RemoveAllowedAction(action string) {
 	log.Printf("Removed allowed action: %s", action)
 }
 
+// AddUnsafeAction adds an action that is only allowed when safe mode is off
+func (s *SecurityManager) AddUnsafeAction(action string) {
+	s.allowedActions[action] = false
+	log.Printf("Added action allowed outside safe mode: %s", action)
+}
+
+// SetRequiresConfirmation sets whether an action must be confirmed by the user before it runs
+func (s *SecurityManager) SetRequiresConfirmation(action string, required bool) {
+	s.confirmActions[action] = required
+	log.Printf("Set confirmation requirement for action %s: %v", action, required)
+}
+
 // AddDangerousKeyword adds a keyword to the dangerous list
 func (s *SecurityManager) AddDangerousKeyword(keyword string) {
 	s.dangerousKeywords = append(s.dangerousKeywords, keyword)
@@ -1,6 +1,9 @@
 package security
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/deca/voicepilot-eino/internal/config"
@@ -70,7 +73,7 @@ func TestValidateAction(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			config.AppConfig.EnableSafeMode = tt.safeMode
-			err := sm.ValidateAction(tt.action, tt.params)
+			_, err := sm.ValidateAction(tt.action, tt.params)
 
 			if (err != nil) != tt.wantError {
 				t.Errorf("ValidateAction() error = %v, wantError %v", err, tt.wantError)
@@ -79,6 +82,44 @@ func TestValidateAction(t *testing.T) {
 	}
 }
 
+func TestDenialReason(t *testing.T) {
+	config.AppConfig = &config.Config{
+		EnableSafeMode: false,
+	}
+
+	sm := NewSecurityManager()
+
+	tests := []struct {
+		name       string
+		action     string
+		params     map[string]interface{}
+		safeMode   bool
+		wantReason string
+	}{
+		{"safe mode", "execute_command", map[string]interface{}{"command": "ls"}, true, ReasonSafeMode},
+		{"unknown action", "format_disk", map[string]interface{}{}, false, ReasonUnknownAction},
+		{"missing command", "execute_command", map[string]interface{}{}, false, ReasonInvalidParams},
+		{"dangerous keyword", "execute_command", map[string]interface{}{"command": "sudo ls"}, false, ReasonDangerousKeyword},
+		{"path traversal", "open_app", map[string]interface{}{"name": "../Music"}, false, ReasonPathTraversal},
+		{"command chain", "execute_command", map[string]interface{}{"command": "ls | wc"}, false, ReasonCommandChain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AppConfig.EnableSafeMode = tt.safeMode
+			_, err := sm.ValidateAction(tt.action, tt.params)
+
+			var denial *DenialError
+			if !errors.As(err, &denial) {
+				t.Fatalf("ValidateAction() error = %v, want *DenialError", err)
+			}
+			if denial.Reason != tt.wantReason {
+				t.Errorf("Reason = %q, want %q", denial.Reason, tt.wantReason)
+			}
+		})
+	}
+}
+
 func TestValidateCommand(t *testing.T) {
 	sm := NewSecurityManager()
 
@@ -122,6 +163,56 @@ func TestValidateCommand(t *testing.T) {
 			params:    map[string]interface{}{},
 			wantError: true,
 		},
+		{
+			name:      "quoted pipe is an argument",
+			params:    map[string]interface{}{"command": `echo "a|b"`},
+			wantError: false,
+		},
+		{
+			name:      "redirection",
+			params:    map[string]interface{}{"command": "echo hi > notes.txt"},
+			wantError: true,
+		},
+		{
+			name:      "background job",
+			params:    map[string]interface{}{"command": "sleep 100 &"},
+			wantError: true,
+		},
+		{
+			name:      "keyword hidden by quotes",
+			params:    map[string]interface{}{"command": `"r""m" -rf /`},
+			wantError: true,
+		},
+		{
+			name:      "keyword inside a word",
+			params:    map[string]interface{}{"command": "cat summary.txt model.txt"},
+			wantError: false,
+		},
+		{
+			name:      "keyword run through a shell",
+			params:    map[string]interface{}{"command": `sh -c "sudo ls"`},
+			wantError: true,
+		},
+		{
+			name:      "keyword given by path",
+			params:    map[string]interface{}{"command": "/sbin/reboot"},
+			wantError: true,
+		},
+		{
+			name:      "keyword with a prefix word",
+			params:    map[string]interface{}{"command": "dd if=/dev/zero of=disk.img"},
+			wantError: true,
+		},
+		{
+			name:      "unterminated quote",
+			params:    map[string]interface{}{"command": `echo "hello`},
+			wantError: true,
+		},
+		{
+			name:      "empty command",
+			params:    map[string]interface{}{"command": "  "},
+			wantError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -197,6 +288,22 @@ func TestRemoveAllowedAction(t *testing.T) {
 	}
 }
 
+func TestRequiresConfirmation(t *testing.T) {
+	sm := NewSecurityManager()
+
+	if !sm.RequiresConfirmation("execute_command") {
+		t.Error("execute_command should require confirmation")
+	}
+	if sm.RequiresConfirmation("play_music") {
+		t.Error("play_music should not require confirmation")
+	}
+
+	sm.SetRequiresConfirmation("open_app", true)
+	if !sm.RequiresConfirmation("open_app") {
+		t.Error("open_app should require confirmation after SetRequiresConfirmation")
+	}
+}
+
 func TestAddDangerousKeyword(t *testing.T) {
 	sm := NewSecurityManager()
 
@@ -207,3 +314,47 @@ func TestAddDangerousKeyword(t *testing.T) {
 		t.Error("dangerous keyword should be added")
 	}
 }
+
+func TestValidateWorkspacePath(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "workspace")
+	os.MkdirAll(filepath.Join(root, "notes"), 0755)
+	if err := os.Symlink(base, filepath.Join(root, "escape")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	config.AppConfig = &config.Config{EnableSafeMode: true, WorkspaceRoot: root}
+
+	sm := NewSecurityManager()
+	tests := []struct {
+		name       string
+		action     string
+		params     map[string]interface{}
+		wantReason string // empty means allowed
+	}{
+		{"relative path", "read_file", map[string]interface{}{"path": "notes/a.txt"}, ""},
+		{"new file needs confirmation", "save_file", map[string]interface{}{"path": "notes/new.txt", "content": "x"}, ""},
+		{"list root by default", "list_dir", map[string]interface{}{}, ""},
+		{"dot dot", "read_file", map[string]interface{}{"path": "../secret"}, ReasonPathTraversal},
+		{"absolute outside", "summarize_file", map[string]interface{}{"path": "/etc/passwd"}, ReasonPathTraversal},
+		{"symlink escape", "save_file", map[string]interface{}{"path": "escape/evil.txt", "content": "x"}, ReasonPathTraversal},
+		{"symlink escape in search", "search_files", map[string]interface{}{"query": "x", "path": "escape"}, ReasonPathTraversal},
+		{"missing path", "read_file", map[string]interface{}{}, ReasonInvalidParams},
+		{"path of wrong type", "list_dir", map[string]interface{}{"path": 1}, ReasonInvalidParams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sm.ValidateAction(tt.action, tt.params)
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Errorf("ValidateAction() error = %v", err)
+				}
+				return
+			}
+			var denial *DenialError
+			if !errors.As(err, &denial) || denial.Reason != tt.wantReason {
+				t.Errorf("ValidateAction() error = %v, want reason %s", err, tt.wantReason)
+			}
+		})
+	}
+}
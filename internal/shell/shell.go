@@ -0,0 +1,263 @@
+// Package shell parses POSIX-style command lines into argv and operators
+//
+// Quoting and escaping follow the POSIX shell rules, but nothing is expanded:
+// variables, globs, "~" and command substitutions stay literal because
+// commands are executed directly, never through a shell.
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TokenKind distinguishes words from operators
+type TokenKind int
+
+const (
+	Word TokenKind = iota
+	Operator
+)
+
+// Token is a single word or operator of a command line
+type Token struct {
+	Kind  TokenKind
+	Value string // unquoted word, or operator text including any fd prefix (e.g. "2>")
+}
+
+// Command is a parsed command line: simple commands joined by control operators
+type Command struct {
+	Simple []SimpleCommand
+	// Operators[i] follows Simple[i], e.g. "|", "&&", "||", ";", "&"
+	Operators []string
+}
+
+// SimpleCommand is one program invocation with its redirections
+type SimpleCommand struct {
+	Argv      []string
+	Redirects []Redirect
+}
+
+// Redirect is an I/O redirection such as "> out.txt" or "2>&1"
+type Redirect struct {
+	Op     string
+	Target string
+}
+
+// HasOperators reports whether the command uses pipes, chaining, background jobs or redirections
+func (c *Command) HasOperators() bool {
+	if len(c.Operators) > 0 || len(c.Simple) > 1 {
+		return true
+	}
+	for _, simple := range c.Simple {
+		if len(simple.Redirects) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Argv returns the arguments of a single simple command, or nil if the line is empty or uses operators
+func (c *Command) Argv() []string {
+	if len(c.Simple) != 1 || c.HasOperators() {
+		return nil
+	}
+	return c.Simple[0].Argv
+}
+
+// Parse tokenizes a command line and groups the words into simple commands
+func Parse(line string) (*Command, error) {
+	tokens, err := Tokenize(line)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := &Command{}
+	current := SimpleCommand{}
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.Kind == Word {
+			current.Argv = append(current.Argv, tok.Value)
+			continue
+		}
+
+		if isRedirect(tok.Value) {
+			if i+1 >= len(tokens) || tokens[i+1].Kind != Word {
+				return nil, fmt.Errorf("missing target for redirection %q", tok.Value)
+			}
+			i++
+			current.Redirects = append(current.Redirects, Redirect{Op: tok.Value, Target: tokens[i].Value})
+			continue
+		}
+
+		if len(current.Argv) == 0 && len(current.Redirects) == 0 {
+			return nil, fmt.Errorf("syntax error near %q", tok.Value)
+		}
+		cmd.Simple = append(cmd.Simple, current)
+		cmd.Operators = append(cmd.Operators, tok.Value)
+		current = SimpleCommand{}
+	}
+
+	if len(current.Argv) > 0 || len(current.Redirects) > 0 {
+		cmd.Simple = append(cmd.Simple, current)
+	} else if n := len(cmd.Operators); n > 0 && !isTerminator(cmd.Operators[n-1]) {
+		// "ls |" or "ls &&" need another command
+		return nil, fmt.Errorf("syntax error: unexpected end after %q", cmd.Operators[n-1])
+	}
+
+	return cmd, nil
+}
+
+// operators lists the recognized operators, longest first so that "&&" wins over "&"
+var operators = []string{
+	"&>>",
+	"&&", "||", ";;", ">>", "<<", "&>", ">&", "<&", ">|", "<>",
+	"|", "&", ";", "<", ">", "(", ")",
+}
+
+func isRedirect(op string) bool {
+	op = strings.TrimLeft(op, "0123456789")
+	return strings.ContainsAny(op, "<>")
+}
+
+func isTerminator(op string) bool {
+	return op == ";" || op == "&"
+}
+
+// Tokenize splits a command line into words and operators, removing quotes and escapes
+func Tokenize(line string) ([]Token, error) {
+	var tokens []Token
+	var word strings.Builder
+	inWord := false // a word has started, possibly as an empty quoted string
+	quoted := false // the current word contains quoted or escaped characters
+
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, Token{Kind: Word, Value: word.String()})
+		}
+		word.Reset()
+		inWord = false
+		quoted = false
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			flush()
+
+		case c == '\'':
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			inWord, quoted = true, true
+			i = end
+
+		case c == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				// Inside double quotes a backslash only escapes $ ` " \ and newline
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("$`\"\\\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+			inWord, quoted = true, true
+
+		case c == '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("trailing backslash")
+			}
+			i++
+			if runes[i] == '\n' {
+				continue // line continuation
+			}
+			word.WriteRune(runes[i])
+			inWord, quoted = true, true
+
+		case c == '#' && !inWord:
+			// Comment until end of line
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+
+		case strings.ContainsRune("|&;<>()", c):
+			op := matchOperator(runes[i:])
+			// Digits directly before a redirection name a file descriptor, e.g. "2>"
+			if inWord && !quoted && isDigits(word.String()) && strings.ContainsAny(op[:1], "<>") {
+				op = word.String() + op
+				word.Reset()
+				inWord = false
+			}
+			flush()
+			tokens = append(tokens, Token{Kind: Operator, Value: op})
+			i += len([]rune(strings.TrimLeft(op, "0123456789"))) - 1
+
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	flush()
+
+	return tokens, nil
+}
+
+func matchOperator(runes []rune) string {
+	rest := string(runes)
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			return op
+		}
+	}
+	return string(runes[0])
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Quote returns s quoted for a POSIX shell if it contains special characters
+func Quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if !strings.ContainsAny(s, " \t\n'\"\\|&;<>()$`*?[]#~=%{}!") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Join quotes each argument and joins them with spaces, the inverse of Parse for a simple command
+func Join(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = Quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
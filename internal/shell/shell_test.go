@@ -0,0 +1,96 @@
+package shell
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name          string
+		line          string
+		wantArgv      []string // nil when the command has operators
+		wantOperators []string
+		wantRedirects []Redirect // redirects of the first simple command
+	}{
+		{name: "plain words", line: "ls -la  /tmp", wantArgv: []string{"ls", "-la", "/tmp"}},
+		{name: "single quotes", line: `echo 'hello   world' '$HOME'`, wantArgv: []string{"echo", "hello   world", "$HOME"}},
+		{name: "double quotes", line: `echo "say \"hi\"" "a\b"`, wantArgv: []string{"echo", `say "hi"`, `a\b`}},
+		{name: "adjacent quotes join", line: `"r""m" a'b'c`, wantArgv: []string{"rm", "abc"}},
+		{name: "empty quoted argument", line: `printf '' x`, wantArgv: []string{"printf", "", "x"}},
+		{name: "backslash escapes", line: `touch my\ file \|`, wantArgv: []string{"touch", "my file", "|"}},
+		{name: "line continuation", line: "echo a\\\nb", wantArgv: []string{"echo", "ab"}},
+		{name: "comment", line: "ls # list files", wantArgv: []string{"ls"}},
+		{name: "hash inside word", line: "echo a#b", wantArgv: []string{"echo", "a#b"}},
+		{name: "no expansion", line: "echo $HOME ~ *.txt", wantArgv: []string{"echo", "$HOME", "~", "*.txt"}},
+		{name: "quoted operators", line: `grep "a|b;c&&d" 'x > y'`, wantArgv: []string{"grep", "a|b;c&&d", "x > y"}},
+		{name: "unicode", line: "echo '你好 世界'", wantArgv: []string{"echo", "你好 世界"}},
+		{name: "pipe", line: "ls|grep go", wantOperators: []string{"|"}},
+		{name: "chain", line: "make && make test || echo fail", wantOperators: []string{"&&", "||"}},
+		{name: "sequence", line: "cd /tmp; ls", wantOperators: []string{";"}},
+		{name: "background", line: "sleep 10 &", wantOperators: []string{"&"}},
+		{name: "redirect", line: "echo hi >out.txt", wantRedirects: []Redirect{{Op: ">", Target: "out.txt"}}},
+		{name: "fd redirect", line: "cmd 2>&1 >> log", wantRedirects: []Redirect{{Op: "2>&", Target: "1"}, {Op: ">>", Target: "log"}}},
+		{name: "quoted digit is not an fd", line: `echo '2'>x`, wantRedirects: []Redirect{{Op: ">", Target: "x"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.line, err)
+			}
+			if got := cmd.Argv(); !reflect.DeepEqual(got, tt.wantArgv) {
+				t.Errorf("Argv() = %q, want %q", got, tt.wantArgv)
+			}
+			if !reflect.DeepEqual(cmd.Operators, tt.wantOperators) {
+				t.Errorf("Operators = %q, want %q", cmd.Operators, tt.wantOperators)
+			}
+			if tt.wantRedirects != nil && !reflect.DeepEqual(cmd.Simple[0].Redirects, tt.wantRedirects) {
+				t.Errorf("Redirects = %+v, want %+v", cmd.Simple[0].Redirects, tt.wantRedirects)
+			}
+			if cmd.HasOperators() != (tt.wantArgv == nil) {
+				t.Errorf("HasOperators() = %v", cmd.HasOperators())
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, line := range []string{
+		`echo 'unterminated`,
+		`echo "unterminated`,
+		`echo trailing\`,
+		"| grep x",
+		"ls &&",
+		"ls ; ; ls",
+		"echo >",
+	} {
+		if _, err := Parse(line); err == nil {
+			t.Errorf("Parse(%q) expected an error", line)
+		}
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	for _, line := range []string{"", "   ", "# only a comment"} {
+		cmd, err := Parse(line)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", line, err)
+		}
+		if len(cmd.Simple) != 0 || cmd.Argv() != nil {
+			t.Errorf("Parse(%q) = %+v, want no commands", line, cmd)
+		}
+	}
+}
+
+func TestJoinRoundTrip(t *testing.T) {
+	argv := []string{"echo", "hello world", "it's", "", "$HOME", "a|b", "plain"}
+	cmd, err := Parse(Join(argv))
+	if err != nil {
+		t.Fatalf("Parse(Join()) error = %v", err)
+	}
+	if got := cmd.Argv(); !reflect.DeepEqual(got, argv) {
+		t.Errorf("Round trip = %q, want %q", got, argv)
+	}
+}
@@ -0,0 +1,91 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ChatChunk is one piece of a streamed chat completion
+//
+// A chunk carries either generated text in Delta or a terminal error in Err.
+type ChatChunk struct {
+	Delta string
+	Err   error
+}
+
+// ReadChatStream parses an OpenAI-style "data: {...}" event stream into chunks
+//
+// The returned channel is closed when the stream ends with [DONE], the body is
+// exhausted, or ctx is cancelled. The body is closed once reading finishes.
+func ReadChatStream(ctx context.Context, body io.ReadCloser) <-chan ChatChunk {
+	chunks := make(chan ChatChunk)
+
+	go func() {
+		defer close(chunks)
+		defer body.Close()
+
+		send := func(chunk ChatChunk) bool {
+			select {
+			case chunks <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data:") {
+				// Blank separators, comments and other SSE fields carry no content
+				continue
+			}
+
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "[DONE]" {
+				return
+			}
+
+			var event struct {
+				Choices []struct {
+					Delta struct {
+						Content string `json:"content"`
+					} `json:"delta"`
+				} `json:"choices"`
+				Error *struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				send(ChatChunk{Err: fmt.Errorf("failed to parse stream chunk: %w", err)})
+				return
+			}
+
+			if event.Error != nil {
+				send(ChatChunk{Err: fmt.Errorf("stream error: %s", event.Error.Message)})
+				return
+			}
+
+			for _, choice := range event.Choices {
+				if choice.Delta.Content == "" {
+					continue
+				}
+				if !send(ChatChunk{Delta: choice.Delta.Content}) {
+					return
+				}
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			send(ChatChunk{Err: fmt.Errorf("failed to read stream: %w", err)})
+		}
+	}()
+
+	return chunks
+}
@@ -0,0 +1,76 @@
+package sse
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func collect(chunks <-chan ChatChunk) (string, error) {
+	var text strings.Builder
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			return text.String(), chunk.Err
+		}
+		text.WriteString(chunk.Delta)
+	}
+	return text.String(), nil
+}
+
+func TestReadChatStream(t *testing.T) {
+	stream := `: keep-alive
+
+data: {"choices":[{"delta":{"role":"assistant"}}]}
+
+data: {"choices":[{"delta":{"content":"你好"}}]}
+
+data: {"choices":[{"delta":{"content":"，世界"}}]}
+
+data: [DONE]
+
+data: {"choices":[{"delta":{"content":"ignored"}}]}
+`
+
+	text, err := collect(ReadChatStream(context.Background(), io.NopCloser(strings.NewReader(stream))))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if text != "你好，世界" {
+		t.Errorf("Expected '你好，世界', got %q", text)
+	}
+}
+
+func TestReadChatStreamErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+	}{
+		{
+			name:   "invalid json",
+			stream: "data: {not json}\n\n",
+		},
+		{
+			name:   "error event",
+			stream: "data: {\"choices\":[{\"delta\":{\"content\":\"a\"}}]}\n\ndata: {\"error\":{\"message\":\"overloaded\"}}\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := collect(ReadChatStream(context.Background(), io.NopCloser(strings.NewReader(tt.stream))))
+			if err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestReadChatStreamWithoutDone(t *testing.T) {
+	stream := "data: {\"choices\":[{\"delta\":{\"content\":\"partial\"}}]}\n"
+
+	text, err := collect(ReadChatStream(context.Background(), io.NopCloser(strings.NewReader(stream))))
+	if err != nil || text != "partial" {
+		t.Errorf("Expected 'partial' without error, got %q (err: %v)", text, err)
+	}
+}
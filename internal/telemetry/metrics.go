@@ -0,0 +1,106 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "voicepilot_http_requests_total",
+		Help: "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "voicepilot_http_request_duration_seconds",
+		Help:    "HTTP request latency, by method and route.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"method", "route"})
+
+	nodeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "voicepilot_workflow_node_duration_seconds",
+		Help:    "Workflow node latency, by node and outcome.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"node", "status"})
+
+	asrAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "voicepilot_asr_attempts_total",
+		Help: "ASR attempts, by strategy and result.",
+	}, []string{"strategy", "result"})
+
+	asrFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "voicepilot_asr_fallbacks_total",
+		Help: "Fallbacks from one ASR strategy to the next.",
+	}, []string{"from", "to"})
+
+	llmTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "voicepilot_llm_tokens_total",
+		Help: "LLM tokens reported by the provider, by provider and type (prompt or completion).",
+	}, []string{"provider", "type"})
+
+	securityDenials = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "voicepilot_security_denials_total",
+		Help: "Actions rejected by the security check, by reason.",
+	}, []string{"reason"})
+)
+
+// MetricsHandler serves the Prometheus metrics
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveNode records the latency of a workflow node
+func ObserveNode(node string, duration time.Duration, err error) {
+	nodeDuration.WithLabelValues(node, status(err)).Observe(duration.Seconds())
+}
+
+// RecordASRAttempt counts an ASR attempt with the given strategy
+func RecordASRAttempt(strategy string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	asrAttempts.WithLabelValues(strategy, result).Inc()
+}
+
+// RecordASRFallback counts a fallback from one ASR strategy to another
+func RecordASRFallback(from, to string) {
+	asrFallbacks.WithLabelValues(from, to).Inc()
+}
+
+// RecordLLMTokens counts the token usage reported for one completion
+func RecordLLMTokens(provider string, promptTokens, completionTokens int) {
+	llmTokens.WithLabelValues(provider, "prompt").Add(float64(promptTokens))
+	llmTokens.WithLabelValues(provider, "completion").Add(float64(completionTokens))
+}
+
+// RecordLLMUsage counts the token usage in an OpenAI-style chat completion response body
+func RecordLLMUsage(provider string, respBody []byte) {
+	var result struct {
+		Usage *struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil || result.Usage == nil {
+		return
+	}
+	RecordLLMTokens(provider, result.Usage.PromptTokens, result.Usage.CompletionTokens)
+}
+
+// RecordSecurityDenial counts an action rejected by the security check
+func RecordSecurityDenial(reason string) {
+	securityDenials.WithLabelValues(reason).Inc()
+}
+
+func status(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
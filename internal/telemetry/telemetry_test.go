@@ -0,0 +1,129 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		wantErr  bool
+	}{
+		{"disabled", ExporterNone, false},
+		{"empty", "", false},
+		{"unknown", "zipkin", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := Init(context.Background(), tt.exporter, "test")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if err := shutdown(context.Background()); err != nil {
+					t.Errorf("shutdown() error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordLLMUsage(t *testing.T) {
+	prompt := testutil.ToFloat64(llmTokens.WithLabelValues("usage-test", "prompt"))
+	completion := testutil.ToFloat64(llmTokens.WithLabelValues("usage-test", "completion"))
+
+	RecordLLMUsage("usage-test", []byte(`{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":5,"total_tokens":17}}`))
+	// Bodies without usage or that are not JSON are ignored
+	RecordLLMUsage("usage-test", []byte(`{"choices":[]}`))
+	RecordLLMUsage("usage-test", []byte(`not json`))
+
+	if got := testutil.ToFloat64(llmTokens.WithLabelValues("usage-test", "prompt")) - prompt; got != 12 {
+		t.Errorf("prompt tokens = %v, want 12", got)
+	}
+	if got := testutil.ToFloat64(llmTokens.WithLabelValues("usage-test", "completion")) - completion; got != 5 {
+		t.Errorf("completion tokens = %v, want 5", got)
+	}
+}
+
+func TestRecordASRAttempt(t *testing.T) {
+	success := testutil.ToFloat64(asrAttempts.WithLabelValues("asr-test", "success"))
+	failure := testutil.ToFloat64(asrAttempts.WithLabelValues("asr-test", "failure"))
+
+	RecordASRAttempt("asr-test", nil)
+	RecordASRAttempt("asr-test", errors.New("timeout"))
+	RecordASRAttempt("asr-test", errors.New("timeout"))
+
+	if got := testutil.ToFloat64(asrAttempts.WithLabelValues("asr-test", "success")) - success; got != 1 {
+		t.Errorf("success attempts = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(asrAttempts.WithLabelValues("asr-test", "failure")) - failure; got != 2 {
+		t.Errorf("failure attempts = %v, want 2", got)
+	}
+}
+
+func TestGinMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(GinMiddleware())
+	r.GET("/api/items/:id", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+	})
+
+	ok := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/api/items/:id", "200"))
+	missing := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "unmatched", "404"))
+
+	for _, path := range []string{"/api/items/1", "/api/items/2", "/nope"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	// Requests are labelled by route template, not by raw path
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/api/items/:id", "200")) - ok; got != 2 {
+		t.Errorf("matched requests = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "unmatched", "404")) - missing; got != 1 {
+		t.Errorf("unmatched requests = %v, want 1", got)
+	}
+}
+
+func TestTransportInjectsTraceparent(t *testing.T) {
+	if _, err := Init(context.Background(), ExporterNone, "test"); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	provider := sdktrace.NewTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer provider.Shutdown(context.Background())
+
+	var gotHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	ctx, span := StartSpan(context.Background(), "parent")
+	defer span.End()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	client := &http.Client{Transport: Transport(nil)}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+
+	traceID := span.SpanContext().TraceID().String()
+	if !strings.Contains(gotHeader, traceID) {
+		t.Errorf("traceparent = %q, want trace id %s", gotHeader, traceID)
+	}
+}
@@ -0,0 +1,55 @@
+// Package tokens approximates how many tokens chat models need for a text
+//
+// No tokenizer is exact across models, so budgets built on these estimates
+// should keep some headroom.
+package tokens
+
+import "unicode"
+
+// MessageOverhead is the tokens a chat message costs beyond its content (role and separators)
+const MessageOverhead = 4
+
+// Estimate approximates the tokens of s
+//
+// Chinese, Japanese and Korean characters take about one token each, other
+// text about one token per four bytes.
+func Estimate(s string) int {
+	tokens, other := 0, 0
+	for _, r := range s {
+		if isCJK(r) {
+			tokens++
+		} else {
+			other += len(string(r))
+		}
+	}
+	return tokens + (other+3)/4
+}
+
+// EstimateMessage approximates the tokens of a chat message with the given content
+func EstimateMessage(content string) int {
+	return Estimate(content) + MessageOverhead
+}
+
+// Truncate cuts s to about maxTokens, marking the cut with an ellipsis; maxTokens <= 0 keeps s whole
+func Truncate(s string, maxTokens int) string {
+	if maxTokens <= 0 || Estimate(s) <= maxTokens {
+		return s
+	}
+
+	runes := []rune(s)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if Estimate(string(runes[:mid]))+1 <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return string(runes[:lo]) + "…"
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		unicode.In(r, unicode.Punct) && r > unicode.MaxLatin1
+}
@@ -0,0 +1,40 @@
+package tokens
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"播放晴天", 4},
+		{"hello world!", 3},
+		{"播放 Jay Chou 的歌。", 8}, // five CJK characters and ten other bytes
+		{"こんにちは", 5},
+	}
+	for _, tt := range tests {
+		if got := Estimate(tt.text); got != tt.want {
+			t.Errorf("Estimate(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+	if got := EstimateMessage("播放晴天"); got != 4+MessageOverhead {
+		t.Errorf("EstimateMessage() = %d, want %d", got, 4+MessageOverhead)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	long := strings.Repeat("晴天", 100)
+	if got := Truncate(long, 10); Estimate(got) > 10 || !strings.HasSuffix(got, "…") {
+		t.Errorf("Truncate() = %q (%d tokens), want at most 10", got, Estimate(got))
+	}
+	if got := Truncate("晴天", 10); got != "晴天" {
+		t.Errorf("Truncate() of a short text = %q", got)
+	}
+	if got := Truncate(long, 0); got != long {
+		t.Errorf("Truncate() without a limit cut the text to %q", got)
+	}
+}
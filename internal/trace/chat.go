@@ -0,0 +1,137 @@
+package trace
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/provider"
+)
+
+// LLMCall is the record of one request to the chat provider
+type LLMCall struct {
+	Kind       string              `json:"kind"` // "chat", "stream" or "tools"
+	StartTime  time.Time           `json:"start_time"`
+	DurationMs int64               `json:"duration_ms"`
+	Messages   []provider.Message  `json:"messages"`
+	Tools      []string            `json:"tools,omitempty"`
+	Response   string              `json:"response,omitempty"`
+	ToolCalls  []provider.ToolCall `json:"tool_calls,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// WrapChat returns a chat provider that records every call on the span in the request context
+//
+// The wrapper implements the same optional interfaces (streaming, tool
+// calling) as chat, so capability checks on it behave like on chat itself.
+func WrapChat(chat provider.ChatProvider) provider.ChatProvider {
+	base := &tracedChat{chat: chat}
+	streamer, canStream := chat.(provider.StreamingChatProvider)
+	tooler, canCallTools := chat.(provider.ToolCallingChatProvider)
+
+	switch {
+	case canStream && canCallTools:
+		return struct {
+			*tracedChat
+			*tracedStream
+			*tracedTools
+		}{base, &tracedStream{streamer}, &tracedTools{tooler}}
+	case canStream:
+		return struct {
+			*tracedChat
+			*tracedStream
+		}{base, &tracedStream{streamer}}
+	case canCallTools:
+		return struct {
+			*tracedChat
+			*tracedTools
+		}{base, &tracedTools{tooler}}
+	default:
+		return base
+	}
+}
+
+type tracedChat struct {
+	chat provider.ChatProvider
+}
+
+func (c *tracedChat) ChatCompletion(ctx context.Context, messages []provider.Message) (string, error) {
+	call := startCall("chat", messages)
+	response, err := c.chat.ChatCompletion(ctx, messages)
+	call.Response = response
+	call.finish(ctx, err)
+	return response, err
+}
+
+type tracedStream struct {
+	chat provider.StreamingChatProvider
+}
+
+func (c *tracedStream) ChatCompletionStream(ctx context.Context, messages []provider.Message) (<-chan provider.ChatChunk, error) {
+	call := startCall("stream", messages)
+	chunks, err := c.chat.ChatCompletionStream(ctx, messages)
+	if err != nil {
+		call.finish(ctx, err)
+		return nil, err
+	}
+
+	// Relay the chunks, recording the full text once the stream ends
+	out := make(chan provider.ChatChunk)
+	go func() {
+		defer close(out)
+		var full strings.Builder
+		var streamErr error
+		for chunk := range chunks {
+			if chunk.Err != nil {
+				streamErr = chunk.Err
+			}
+			full.WriteString(chunk.Delta)
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+			}
+		}
+		call.Response = full.String()
+		call.finish(ctx, streamErr)
+	}()
+	return out, nil
+}
+
+type tracedTools struct {
+	chat provider.ToolCallingChatProvider
+}
+
+func (c *tracedTools) ChatCompletionWithTools(ctx context.Context, messages []provider.Message, tools []provider.Tool, toolChoice string) ([]provider.ToolCall, string, error) {
+	call := startCall("tools", messages)
+	for _, tool := range tools {
+		call.Tools = append(call.Tools, tool.Function.Name)
+	}
+
+	calls, content, err := c.chat.ChatCompletionWithTools(ctx, messages, tools, toolChoice)
+	call.Response = content
+	call.ToolCalls = calls
+	call.finish(ctx, err)
+	return calls, content, err
+}
+
+func startCall(kind string, messages []provider.Message) *LLMCall {
+	return &LLMCall{
+		Kind:      kind,
+		StartTime: time.Now(),
+		Messages:  messages,
+	}
+}
+
+// finish records the call on the span in ctx, if any
+func (call *LLMCall) finish(ctx context.Context, err error) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+
+	call.DurationMs = time.Since(call.StartTime).Milliseconds()
+	if err != nil {
+		call.Error = err.Error()
+	}
+	span.addLLMCall(call)
+}
@@ -0,0 +1,98 @@
+package trace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned when no trace exists with the requested ID
+var ErrNotFound = errors.New("trace not found")
+
+// Store persists traces as JSON files in a directory
+type Store struct {
+	dir string
+}
+
+// NewStore creates a store that keeps traces in dir
+func NewStore(dir string) *Store {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("Warning: failed to create trace directory: %v", err)
+	}
+	return &Store{dir: dir}
+}
+
+// Save writes a trace to disk
+func (s *Store) Save(t *Trace) error {
+	t.mu.Lock()
+	data, err := json.MarshalIndent(t, "", "  ")
+	t.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal trace: %w", err)
+	}
+
+	if err := os.WriteFile(s.path(t.ID), data, 0644); err != nil {
+		return fmt.Errorf("failed to write trace file: %w", err)
+	}
+	return nil
+}
+
+// Load reads a trace by ID
+func (s *Store) Load(id string) (*Trace, error) {
+	// IDs are UUIDs; rejecting anything else keeps lookups inside the store directory
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+
+	data, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trace file: %w", err)
+	}
+
+	var t Trace
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse trace file: %w", err)
+	}
+	return &t, nil
+}
+
+// DeleteOlderThan removes traces saved more than maxAge ago and returns how many were removed
+//
+// Traces hold full prompts and responses, so they shouldn't be kept forever.
+func (s *Store) DeleteOlderThan(maxAge time.Duration) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read trace directory: %w", err)
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("failed to delete trace file: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
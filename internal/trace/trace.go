@@ -0,0 +1,156 @@
+// Package trace records a structured, per-request account of a workflow run
+package trace
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/google/uuid"
+)
+
+// Trace is the record of one workflow run
+type Trace struct {
+	ID         string    `json:"id"`
+	SessionID  string    `json:"session_id"`
+	Entry      string    `json:"entry"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	DurationMs int64     `json:"duration_ms"`
+	Nodes      []*Span   `json:"nodes"`
+	Error      string    `json:"error,omitempty"`
+
+	mu       sync.Mutex
+	redacted map[string]bool // message contents recorded as redactedMessage, see Redact
+}
+
+// redactedMessage replaces the content of model messages that must not be kept in traces
+const redactedMessage = "[已隐藏]"
+
+// Span is the record of one node execution within a trace
+type Span struct {
+	Node       string                 `json:"node"`
+	StartTime  time.Time              `json:"start_time"`
+	EndTime    time.Time              `json:"end_time"`
+	DurationMs int64                  `json:"duration_ms"`
+	LLMCalls   []*LLMCall             `json:"llm_calls,omitempty"`
+	Output     map[string]interface{} `json:"output,omitempty"` // workflow state the node produced
+	Error      string                 `json:"error,omitempty"`
+
+	trace *Trace
+}
+
+// New starts a trace for a workflow run
+func New(sessionID, entry string) *Trace {
+	return &Trace{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		Entry:     entry,
+		StartTime: time.Now(),
+		Nodes:     []*Span{},
+	}
+}
+
+// StartNode opens a span for a node
+func (t *Trace) StartNode(node string) *Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := &Span{Node: node, StartTime: time.Now(), trace: t}
+	t.Nodes = append(t.Nodes, span)
+	return span
+}
+
+// Finish closes the trace, recording err if the run failed
+func (t *Trace) Finish(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.EndTime = time.Now()
+	t.DurationMs = t.EndTime.Sub(t.StartTime).Milliseconds()
+	if err != nil {
+		t.Error = err.Error()
+	}
+}
+
+// End closes the span, recording err if the node failed
+func (s *Span) End(err error) {
+	s.trace.mu.Lock()
+	defer s.trace.mu.Unlock()
+
+	s.EndTime = time.Now()
+	s.DurationMs = s.EndTime.Sub(s.StartTime).Milliseconds()
+	if err != nil {
+		s.Error = err.Error()
+	}
+}
+
+// Set records a value the node produced
+func (s *Span) Set(key string, value interface{}) {
+	s.trace.mu.Lock()
+	defer s.trace.mu.Unlock()
+
+	if s.Output == nil {
+		s.Output = make(map[string]interface{})
+	}
+	s.Output[key] = value
+}
+
+// addLLMCall appends a model call to the span, hiding redacted messages
+func (s *Span) addLLMCall(call *LLMCall) {
+	s.trace.mu.Lock()
+	defer s.trace.mu.Unlock()
+
+	if len(s.trace.redacted) > 0 {
+		// The caller still owns the messages, so they are copied before changing them
+		messages := make([]provider.Message, len(call.Messages))
+		for i, msg := range call.Messages {
+			if s.trace.redacted[msg.Content] {
+				msg.Content = redactedMessage
+			}
+			messages[i] = msg
+		}
+		call.Messages = messages
+	}
+	s.LLMCalls = append(s.LLMCalls, call)
+}
+
+type spanKey struct{}
+
+// WithSpan returns a context whose model calls and annotations are recorded on span
+func WithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span attached to ctx, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Redact keeps a message with the given content out of the trace of the span in ctx
+//
+// Model calls of the whole trace record such messages as "[已隐藏]", e.g. for
+// personal data that must not outlive its source. It is a no-op when ctx is not traced.
+func Redact(ctx context.Context, content string) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	t := span.trace
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.redacted == nil {
+		t.redacted = make(map[string]bool)
+	}
+	t.redacted[content] = true
+}
+
+// Annotate records a value on the span in ctx; it is a no-op when ctx is not traced
+func Annotate(ctx context.Context, key string, value interface{}) {
+	if span := SpanFromContext(ctx); span != nil {
+		span.Set(key, value)
+	}
+}
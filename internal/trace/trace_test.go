@@ -0,0 +1,166 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/provider"
+)
+
+type plainChat struct{}
+
+func (plainChat) ChatCompletion(ctx context.Context, messages []provider.Message) (string, error) {
+	return "plain", nil
+}
+
+type fullChat struct{ plainChat }
+
+func (fullChat) ChatCompletionStream(ctx context.Context, messages []provider.Message) (<-chan provider.ChatChunk, error) {
+	ch := make(chan provider.ChatChunk, 2)
+	ch <- provider.ChatChunk{Delta: "你"}
+	ch <- provider.ChatChunk{Delta: "好"}
+	close(ch)
+	return ch, nil
+}
+
+func (fullChat) ChatCompletionWithTools(ctx context.Context, messages []provider.Message, tools []provider.Tool, toolChoice string) ([]provider.ToolCall, string, error) {
+	return nil, "", errors.New("tools unavailable")
+}
+
+func TestWrapChatPreservesCapabilities(t *testing.T) {
+	tests := []struct {
+		name          string
+		chat          provider.ChatProvider
+		wantStreaming bool
+		wantTools     bool
+	}{
+		{"plain", plainChat{}, false, false},
+		{"streaming and tools", fullChat{}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped := WrapChat(tt.chat)
+			if _, ok := wrapped.(provider.StreamingChatProvider); ok != tt.wantStreaming {
+				t.Errorf("Expected streaming support %v, got %v", tt.wantStreaming, ok)
+			}
+			if _, ok := wrapped.(provider.ToolCallingChatProvider); ok != tt.wantTools {
+				t.Errorf("Expected tool calling support %v, got %v", tt.wantTools, ok)
+			}
+		})
+	}
+}
+
+func TestWrapChatRecordsCalls(t *testing.T) {
+	tr := New("session-1", "text")
+	span := tr.StartNode("intent")
+	ctx := WithSpan(context.Background(), span)
+
+	chat := WrapChat(fullChat{})
+	messages := []provider.Message{{Role: "user", Content: "hi"}}
+
+	chat.ChatCompletion(ctx, messages)
+	chat.(provider.ToolCallingChatProvider).ChatCompletionWithTools(ctx, messages, []provider.Tool{provider.NewFunctionTool("open_app", "", nil)}, provider.ToolChoiceAuto)
+	if _, err := provider.CompleteStreaming(provider.WithTokenSink(ctx, func(string, string) {}), chat, messages, "response"); err != nil {
+		t.Fatalf("CompleteStreaming failed: %v", err)
+	}
+	span.End(nil)
+
+	if len(span.LLMCalls) != 3 {
+		t.Fatalf("Expected 3 recorded calls, got %d", len(span.LLMCalls))
+	}
+
+	chatCall, toolCall, streamCall := span.LLMCalls[0], span.LLMCalls[1], span.LLMCalls[2]
+	if chatCall.Kind != "chat" || chatCall.Response != "plain" || len(chatCall.Messages) != 1 {
+		t.Errorf("Unexpected chat call: %+v", chatCall)
+	}
+	if toolCall.Kind != "tools" || toolCall.Error == "" || toolCall.Tools[0] != "open_app" {
+		t.Errorf("Unexpected tool call: %+v", toolCall)
+	}
+	if streamCall.Kind != "stream" || streamCall.Response != "你好" {
+		t.Errorf("Unexpected stream call: %+v", streamCall)
+	}
+
+	// Calls outside a traced context are not recorded anywhere
+	chat.ChatCompletion(context.Background(), messages)
+	if len(span.LLMCalls) != 3 {
+		t.Error("Untraced call should not be recorded on the span")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tr := New("session-1", "text")
+	ctx := WithSpan(context.Background(), tr.StartNode("intent"))
+	Redact(ctx, "用户住在杭州")
+
+	// Redactions hold for every node of the trace
+	span := tr.StartNode("response")
+	messages := []provider.Message{{Role: "system", Content: "用户住在杭州"}, {Role: "user", Content: "今天天气怎么样"}}
+	WrapChat(fullChat{}).ChatCompletion(WithSpan(context.Background(), span), messages)
+
+	recorded := span.LLMCalls[0].Messages
+	if recorded[0].Content != redactedMessage || recorded[1].Content != "今天天气怎么样" {
+		t.Errorf("Recorded messages = %+v, want the first one hidden", recorded)
+	}
+	if messages[0].Content != "用户住在杭州" {
+		t.Error("Redact changed the caller's messages")
+	}
+}
+
+func TestStoreSaveLoad(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	tr := New("session-1", "voice")
+	span := tr.StartNode("asr")
+	span.Set("recognized_text", "打开微信")
+	span.End(nil)
+	tr.Finish(errors.New("intent node failed"))
+
+	if err := store.Save(tr); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := store.Load(tr.ID)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.SessionID != "session-1" || loaded.Error != "intent node failed" {
+		t.Errorf("Unexpected trace: %+v", loaded)
+	}
+	if len(loaded.Nodes) != 1 || loaded.Nodes[0].Output["recognized_text"] != "打开微信" {
+		t.Errorf("Unexpected nodes: %+v", loaded.Nodes)
+	}
+
+	for _, id := range []string{"00000000-0000-0000-0000-000000000000", "../sessions/abc", ""} {
+		if _, err := store.Load(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for %q, got %v", id, err)
+		}
+	}
+}
+
+func TestStoreDeleteOlderThan(t *testing.T) {
+	store := NewStore(t.TempDir())
+	old, recent := New("session-1", "text"), New("session-1", "text")
+	for _, tr := range []*Trace{old, recent} {
+		tr.Finish(nil)
+		if err := store.Save(tr); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	aged := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(store.path(old.ID), aged, aged)
+
+	n, err := store.DeleteOlderThan(24 * time.Hour)
+	if err != nil || n != 1 {
+		t.Fatalf("DeleteOlderThan() = %d, %v, want 1", n, err)
+	}
+	if _, err := store.Load(old.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Old trace still loads: %v", err)
+	}
+	if _, err := store.Load(recent.ID); err != nil {
+		t.Errorf("Recent trace was deleted: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package workflow
+
+import (
+	"encoding/json"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+	ctxmanager "github.com/deca/voicepilot-eino/internal/context"
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/internal/tokens"
+)
+
+// contextBudget splits the context window of the chat model between a prompt, its history and the completion
+type contextBudget struct {
+	Window     int
+	System     int // tokens of the system messages (the prompt and what is known about the user) and the tool definitions
+	Input      int // tokens of the new user message
+	Completion int // tokens reserved for the reply (LLM_MAX_TOKENS)
+}
+
+// newContextBudget estimates the fixed parts of a request to the configured model; empty system messages are not sent
+func newContextBudget(input string, systemMessages ...string) contextBudget {
+	b := contextBudget{
+		Window:     config.AppConfig.ContextWindow(config.AppConfig.LLMModel),
+		Input:      tokens.EstimateMessage(input),
+		Completion: config.AppConfig.LLMMaxTokens,
+	}
+	for _, message := range systemMessages {
+		if message != "" {
+			b.System += tokens.EstimateMessage(message)
+		}
+	}
+	return b
+}
+
+// withTools adds the tool definitions sent along with the messages to the system tokens
+//
+// They count as their JSON encoding, roughly what the model sees.
+func (b contextBudget) withTools(tools []provider.Tool) contextBudget {
+	if len(tools) == 0 {
+		return b
+	}
+	data, err := json.Marshal(tools)
+	if err != nil {
+		return b
+	}
+	b.System += tokens.Estimate(string(data))
+	return b
+}
+
+// History returns the tokens left for the conversation history, never negative
+func (b contextBudget) History() int {
+	return max(b.Window-b.System-b.Input-b.Completion, 0)
+}
+
+// report is the token usage of a request recorded in the trace
+func (b contextBudget) report(usage ctxmanager.ContextUsage) map[string]interface{} {
+	return map[string]interface{}{
+		"window":           b.Window,
+		"system":           b.System,
+		"input":            b.Input,
+		"completion":       b.Completion,
+		"summary":          usage.SummaryTokens,
+		"history":          usage.HistoryTokens,
+		"history_messages": usage.Messages,
+		"omitted_messages": usage.OmittedMessages,
+		"prompt":           b.System + b.Input + usage.Used(),
+	}
+}
@@ -0,0 +1,70 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+	ctxmanager "github.com/deca/voicepilot-eino/internal/context"
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/internal/trace"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+func TestIntentMessagesBudget(t *testing.T) {
+	config.AppConfig = &config.Config{
+		LLMModel:          "small-model",
+		LLMMaxTokens:      100,
+		LLMContextWindow:  100000,
+		LLMContextWindows: map[string]int{"small-model": 200},
+	}
+	w := &VoiceWorkflow{contextManager: ctxmanager.NewContextManager(t.TempDir(), 50, time.Hour)}
+	w.contextManager.AddInteraction("s1", "写一篇文章", "generate_text", strings.Repeat("很长的文章", 40))
+	w.contextManager.AddInteraction("s1", "几点了", "unknown", "下午三点")
+
+	span := trace.New("s1", EntryText).StartNode("intent")
+	ctx := trace.WithSpan(context.Background(), span)
+	wfCtx := &types.WorkflowContext{SessionID: "s1", RecognizedText: "谢谢"}
+	messages := w.intentMessages(ctx, wfCtx, "你是语音助手", nil)
+
+	// 200 - 100 for the reply - 10 for the prompt - 6 for the input leaves 84 for history:
+	// the last turn fits, the 200-token article does not
+	if len(messages) != 4 || messages[1].Content != "几点了" || messages[3].Content != "谢谢" {
+		t.Fatalf("intentMessages() = %+v, want prompt, last turn and input", messages)
+	}
+	usage, ok := span.Output["context_tokens"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("No token usage in the trace: %v", span.Output)
+	}
+	want := map[string]int{"window": 200, "system": 10, "input": 6, "completion": 100, "history": 15, "history_messages": 2, "omitted_messages": 2, "prompt": 31}
+	for key, value := range want {
+		if usage[key] != value {
+			t.Errorf("context_tokens[%s] = %v, want %d", key, usage[key], value)
+		}
+	}
+
+	// Tool definitions sent along take room from the history
+	tools := []provider.Tool{{Type: "function", Function: provider.ToolFunction{
+		Name:        "play_music",
+		Description: "play a song by name, optionally by a given artist, on the default music player",
+		Parameters: map[string]interface{}{"type": "object", "properties": map[string]interface{}{
+			"song":   map[string]interface{}{"type": "string", "description": "name of the song"},
+			"artist": map[string]interface{}{"type": "string", "description": "name of the artist"},
+		}},
+	}}}
+	if messages := w.intentMessages(ctx, wfCtx, "你是语音助手", tools); len(messages) != 2 {
+		t.Errorf("intentMessages() with tools = %+v, want no room for history", messages)
+	}
+	usage = span.Output["context_tokens"].(map[string]interface{})
+	if usage["system"].(int) <= 80 {
+		t.Errorf("context_tokens[system] with tools = %v, want the tools counted", usage["system"])
+	}
+
+	// A window smaller than the reservation leaves no history at all
+	config.AppConfig.LLMMaxTokens = 1000
+	if messages := w.intentMessages(ctx, wfCtx, "你是语音助手", nil); len(messages) != 2 {
+		t.Errorf("intentMessages() with no room = %d messages, want 2", len(messages))
+	}
+}
@@ -0,0 +1,43 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// clarificationQuestion turns a user_fixable failure into a question about the parameter it needs
+//
+// The parameter is named with the first clause of its description in the
+// failed action's spec. Errors that already ask the user something are used
+// as they are.
+func (w *VoiceWorkflow) clarificationQuestion(result *types.ExecutionResult) (string, bool) {
+	if result.ErrorClass != types.ErrorUserFixable {
+		return "", false
+	}
+	if strings.Contains(result.Error, "请") {
+		return result.Error, true
+	}
+
+	label := w.paramLabel(result.Action, result.Param)
+	if label == "" {
+		return fmt.Sprintf("%s，请补充后再说一遍。", result.Error), true
+	}
+	return fmt.Sprintf("%s。请告诉我%s。", result.Error, label), true
+}
+
+// paramLabel returns a short name of an action parameter for speech, e.g. "歌曲名称", or "" if it has no description
+func (w *VoiceWorkflow) paramLabel(action, param string) string {
+	spec, ok := w.executor.Spec(action)
+	if !ok || param == "" {
+		return ""
+	}
+	properties, _ := spec.Parameters["properties"].(map[string]interface{})
+	schema, _ := properties[param].(map[string]interface{})
+	description, _ := schema["description"].(string)
+	if i := strings.IndexAny(description, "，；,;"); i >= 0 {
+		description = description[:i]
+	}
+	return strings.TrimSpace(description)
+}
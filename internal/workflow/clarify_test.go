@@ -0,0 +1,74 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+
+	"github.com/deca/voicepilot-eino/internal/executor"
+	"github.com/deca/voicepilot-eino/internal/scheduler"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+func TestResponseNodeClarifiesFixableErrors(t *testing.T) {
+	chat := &toolChat{reply: "不应调用模型"}
+	w := newToolTestWorkflow(t, chat)
+	reminders, _ := scheduler.New("")
+	w.executor = executor.NewExecutor(chat, executor.WithScheduler(reminders))
+
+	tests := []struct {
+		name   string
+		plan   []types.TaskStep
+		answer string
+		param  string
+	}{
+		{
+			name:   "missing parameter",
+			plan:   []types.TaskStep{{Action: "play_music", Parameters: map[string]interface{}{}}},
+			answer: "缺少歌曲名称参数。请告诉我歌曲名称。",
+			param:  "song",
+		},
+		{
+			name:   "error that already asks",
+			plan:   []types.TaskStep{{Action: "cancel_reminder", Parameters: map[string]interface{}{}}},
+			answer: "请说明要取消哪个提醒",
+			param:  "text",
+		},
+		{
+			name:   "fatal error",
+			plan:   []types.TaskStep{{Action: "error", Parameters: map[string]interface{}{"message": "出错了"}}},
+			answer: "出错了",
+		},
+	}
+
+	for _, tt := range tests {
+		wfCtx := newTestContext()
+		wfCtx.SessionID = "s1"
+		wfCtx.TaskPlan = &types.TaskPlan{Steps: tt.plan}
+		if err := w.executorNode(context.Background(), wfCtx); err != nil {
+			t.Fatalf("%s: executorNode failed: %v", tt.name, err)
+		}
+		if err := w.responseNode(context.Background(), wfCtx); err != nil {
+			t.Fatalf("%s: responseNode failed: %v", tt.name, err)
+		}
+		if wfCtx.ResponseText != tt.answer {
+			t.Errorf("%s: response = %q, want %q", tt.name, wfCtx.ResponseText, tt.answer)
+		}
+		if param, _ := wfCtx.Context["clarification_param"].(string); param != tt.param {
+			t.Errorf("%s: clarification_param = %q, want %q", tt.name, param, tt.param)
+		}
+	}
+}
+
+func TestResponseNodeWithoutExecution(t *testing.T) {
+	w := newToolTestWorkflow(t, &toolChat{reply: "不应调用模型"})
+
+	// A custom graph may route to the response node without running a plan
+	wfCtx := newTestContext()
+	wfCtx.SessionID = "s1"
+	if err := w.responseNode(context.Background(), wfCtx); err != nil {
+		t.Fatalf("responseNode failed: %v", err)
+	}
+	if wfCtx.ResponseText == "" {
+		t.Error("Expected a response without an execution result")
+	}
+}
@@ -0,0 +1,247 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// EntryConfirm resumes or discards a pending plan with an explicit decision
+const EntryConfirm = "confirm"
+
+// pendingConfirmationKey is the session context key holding the plan awaiting confirmation
+const pendingConfirmationKey = "pending_confirmation"
+
+// pendingConfirmationTTL is how long a plan waits for the user's answer before it is discarded
+const pendingConfirmationTTL = 10 * time.Minute
+
+// ErrNoPendingConfirmation is returned by Confirm when the session has no plan awaiting confirmation
+var ErrNoPendingConfirmation = errors.New("no pending confirmation")
+
+// Confirmation decisions
+const (
+	decisionConfirm = "confirm"
+	decisionCancel  = "cancel"
+)
+
+// pendingConfirmation is a security-checked plan waiting for the user's yes/no
+type pendingConfirmation struct {
+	Plan           *types.TaskPlan `json:"plan"`
+	Intent         *types.Intent   `json:"intent,omitempty"`
+	RecognizedText string          `json:"recognized_text"`
+	Prompt         string          `json:"prompt"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// Confirm resumes (confirmed) or discards the plan awaiting confirmation in a session
+//
+// ErrNoPendingConfirmation is returned, possibly wrapped, when there is no
+// plan or another request took it first.
+func (w *VoiceWorkflow) Confirm(ctx context.Context, sessionID string, confirmed bool, opts ...RunOption) (*types.VoiceResponse, error) {
+	log.Printf("Confirmation for session %s: %v", sessionID, confirmed)
+
+	if w.loadPendingConfirmation(sessionID) == nil {
+		return nil, ErrNoPendingConfirmation
+	}
+
+	wfCtx := &types.WorkflowContext{
+		SessionID:      sessionID,
+		RecognizedText: "确认执行",
+		Context:        map[string]interface{}{"confirmation_decision": decisionConfirm},
+	}
+	if !confirmed {
+		wfCtx.RecognizedText = "取消执行"
+		wfCtx.Context["confirmation_decision"] = decisionCancel
+	}
+
+	return w.run(ctx, EntryConfirm, wfCtx, opts)
+}
+
+// resumeNode answers a pending confirmation with the user's reply
+//
+// A confirmed plan is restored for security and execution; a declined one is
+// replaced by a cancellation message. Any other reply discards the pending plan
+// and is handled as a new request.
+func (w *VoiceWorkflow) resumeNode(ctx context.Context, wfCtx *types.WorkflowContext) error {
+	decision, explicit := wfCtx.Context["confirmation_decision"].(string)
+
+	// Taking the plan out of the session decides which of several replies answers it
+	pending, err := w.takePendingConfirmation(wfCtx.SessionID)
+	if err != nil {
+		return err
+	}
+	if pending == nil {
+		if explicit {
+			return ErrNoPendingConfirmation
+		}
+		return nil
+	}
+
+	if decision == "" {
+		decision = parseConfirmationReply(wfCtx.RecognizedText)
+	}
+
+	switch decision {
+	case decisionConfirm:
+		log.Printf("Resume Node: User confirmed pending plan with %d steps", len(pending.Plan.Steps))
+		wfCtx.Intent = pending.Intent
+		wfCtx.TaskPlan = pending.Plan
+		wfCtx.Context["confirmed"] = true
+	case decisionCancel:
+		log.Printf("Resume Node: User declined pending plan")
+		wfCtx.Intent = pending.Intent
+		wfCtx.TaskPlan = &types.TaskPlan{
+			Steps: []types.TaskStep{
+				{
+					Action: "clarify",
+					Parameters: map[string]interface{}{
+						"message": "好的，已取消该操作。",
+					},
+				},
+			},
+		}
+	default:
+		log.Printf("Resume Node: Reply is not a confirmation, discarding pending plan")
+	}
+	return nil
+}
+
+// awaitConfirmationNode stores the checked plan in the session and asks the user to confirm it
+func (w *VoiceWorkflow) awaitConfirmationNode(ctx context.Context, wfCtx *types.WorkflowContext) error {
+	log.Printf("Await Confirmation Node: Pausing plan with %d steps", len(wfCtx.TaskPlan.Steps))
+
+	prompt := confirmationPrompt(wfCtx.TaskPlan)
+	pending := &pendingConfirmation{
+		Plan:           wfCtx.TaskPlan,
+		Intent:         wfCtx.Intent,
+		RecognizedText: wfCtx.RecognizedText,
+		Prompt:         prompt,
+		CreatedAt:      time.Now(),
+	}
+	if err := w.contextManager.SetContextData(wfCtx.SessionID, pendingConfirmationKey, pending); err != nil {
+		return fmt.Errorf("failed to save pending confirmation: %w", err)
+	}
+
+	wfCtx.ResponseText = prompt
+	return nil
+}
+
+// loadPendingConfirmation returns the session's unexpired pending confirmation, if any
+func (w *VoiceWorkflow) loadPendingConfirmation(sessionID string) *pendingConfirmation {
+	value, ok := w.contextManager.GetContextData(sessionID, pendingConfirmationKey)
+	if !ok {
+		return nil
+	}
+
+	pending := decodePendingConfirmation(sessionID, value)
+	if pending == nil {
+		if err := w.contextManager.DeleteContextData(sessionID, pendingConfirmationKey); err != nil {
+			log.Printf("Warning: failed to clear pending confirmation: %v", err)
+		}
+	}
+	return pending
+}
+
+// takePendingConfirmation removes the session's pending confirmation and returns it unless it has expired
+//
+// If it can't be removed an error is returned, so the plan doesn't run
+// while it could still be confirmed again.
+func (w *VoiceWorkflow) takePendingConfirmation(sessionID string) (*pendingConfirmation, error) {
+	value, ok, err := w.contextManager.TakeContextData(sessionID, pendingConfirmationKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clear pending confirmation: %w", err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	return decodePendingConfirmation(sessionID, value), nil
+}
+
+// decodePendingConfirmation returns the pending confirmation stored in a session, or nil if it is invalid or expired
+func decodePendingConfirmation(sessionID string, value interface{}) *pendingConfirmation {
+	// Sessions read back from storage hold the pending plan as a generic map
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var pending pendingConfirmation
+	if err := json.Unmarshal(data, &pending); err != nil || pending.Plan == nil {
+		log.Printf("Warning: invalid pending confirmation in session %s: %v", sessionID, err)
+		return nil
+	}
+
+	if time.Since(pending.CreatedAt) > pendingConfirmationTTL {
+		log.Printf("Pending confirmation in session %s expired", sessionID)
+		return nil
+	}
+	return &pending
+}
+
+// confirmationPrompt describes the steps that need confirmation
+func confirmationPrompt(plan *types.TaskPlan) string {
+	var b strings.Builder
+	b.WriteString("即将执行以下操作：")
+	for i, step := range plan.Steps {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, describeStep(step))
+	}
+	b.WriteString("\n确认执行吗？请回答“确认”或“取消”。")
+	return b.String()
+}
+
+// describeStep renders a plan step for the confirmation prompt
+func describeStep(step types.TaskStep) string {
+	switch step.Action {
+	case "execute_command":
+		if command, ok := step.Parameters["command"].(string); ok {
+			return "执行命令 " + command
+		}
+	case "save_file":
+		if path, ok := step.Parameters["path"].(string); ok {
+			return "保存文件 " + path
+		}
+	case "open_app":
+		if name, ok := step.Parameters["name"].(string); ok {
+			return "打开应用 " + name
+		}
+	case "play_music":
+		if song, ok := step.Parameters["song"].(string); ok {
+			return "播放音乐 " + song
+		}
+	}
+	return step.Action
+}
+
+var (
+	confirmReplies = map[string]bool{
+		"确认": true, "确定": true, "是": true, "是的": true, "对": true, "好": true, "好的": true,
+		"可以": true, "行": true, "执行": true, "确认执行": true, "继续": true, "同意": true, "没问题": true,
+		"yes": true, "y": true, "ok": true, "okay": true, "sure": true,
+	}
+	cancelReplies = map[string]bool{
+		"取消": true, "不": true, "不要": true, "不用": true, "不是": true, "不行": true, "不可以": true,
+		"否": true, "算了": true, "别": true, "不执行": true, "取消执行": true, "停止": true,
+		"no": true, "n": true, "cancel": true,
+	}
+)
+
+// parseConfirmationReply classifies a reply as decisionConfirm, decisionCancel or "" (not an answer)
+func parseConfirmationReply(text string) string {
+	reply := strings.ToLower(strings.TrimSpace(text))
+	reply = strings.TrimRight(reply, " 。.！!，,？?~")
+	// Trailing modal particles, e.g. "好的吧", "取消吧"
+	reply = strings.TrimRight(reply, "吧啊呀呢")
+
+	switch {
+	case confirmReplies[reply]:
+		return decisionConfirm
+	case cancelReplies[reply]:
+		return decisionCancel
+	}
+	return ""
+}
@@ -0,0 +1,253 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+	ctxmanager "github.com/deca/voicepilot-eino/internal/context"
+	"github.com/deca/voicepilot-eino/internal/executor"
+	"github.com/deca/voicepilot-eino/internal/sandbox"
+	"github.com/deca/voicepilot-eino/internal/security"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+func TestParseConfirmationReply(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  string
+	}{
+		{"确认", decisionConfirm},
+		{"好的。", decisionConfirm},
+		{"  OK! ", decisionConfirm},
+		{"确认执行吧", decisionConfirm},
+		{"取消", decisionCancel},
+		{"不要", decisionCancel},
+		{"算了吧", decisionCancel},
+		{"No.", decisionCancel},
+		{"是什么意思", ""},
+		{"播放晴天", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := parseConfirmationReply(tt.reply); got != tt.want {
+			t.Errorf("parseConfirmationReply(%q) = %q, want %q", tt.reply, got, tt.want)
+		}
+	}
+}
+
+func TestConfirmationFlow(t *testing.T) {
+	config.AppConfig = &config.Config{EnableSafeMode: false}
+
+	newWorkflow := func(t *testing.T) *VoiceWorkflow {
+		return &VoiceWorkflow{
+			security:       security.NewSecurityManager(),
+			contextManager: ctxmanager.NewContextManager(t.TempDir(), 10, time.Hour),
+		}
+	}
+	riskyPlan := func() *types.TaskPlan {
+		return &types.TaskPlan{Steps: []types.TaskStep{
+			{Action: "execute_command", Parameters: map[string]interface{}{"command": "ls"}},
+		}}
+	}
+
+	// pause runs a risky plan through security and the confirmation node
+	pause := func(t *testing.T, w *VoiceWorkflow) {
+		wfCtx := newTestContext()
+		wfCtx.SessionID = "session-1"
+		wfCtx.RecognizedText = "列出文件"
+		wfCtx.TaskPlan = riskyPlan()
+
+		if err := w.securityNode(context.Background(), wfCtx); err != nil {
+			t.Fatalf("securityNode failed: %v", err)
+		}
+		if !conditions["requires_confirmation"](wfCtx) {
+			t.Fatal("Expected execute_command plan to require confirmation")
+		}
+		if err := w.awaitConfirmationNode(context.Background(), wfCtx); err != nil {
+			t.Fatalf("awaitConfirmationNode failed: %v", err)
+		}
+		if wfCtx.ResponseText == "" {
+			t.Error("Expected a confirmation prompt")
+		}
+		if w.loadPendingConfirmation("session-1") == nil {
+			t.Fatal("Expected pending confirmation to be stored in the session")
+		}
+	}
+
+	t.Run("confirmed plan passes security and runs", func(t *testing.T) {
+		w := newWorkflow(t)
+		pause(t, w)
+
+		wfCtx := newTestContext()
+		wfCtx.SessionID = "session-1"
+		wfCtx.RecognizedText = "确认"
+		if err := w.resumeNode(context.Background(), wfCtx); err != nil {
+			t.Fatalf("resumeNode failed: %v", err)
+		}
+		if !conditions["resumed"](wfCtx) || wfCtx.TaskPlan.Steps[0].Action != "execute_command" {
+			t.Fatalf("Expected pending plan to be restored, got %+v", wfCtx.TaskPlan)
+		}
+
+		if err := w.securityNode(context.Background(), wfCtx); err != nil {
+			t.Fatalf("securityNode failed: %v", err)
+		}
+		if conditions["requires_confirmation"](wfCtx) {
+			t.Error("Confirmed plan should not ask for confirmation again")
+		}
+		if w.loadPendingConfirmation("session-1") != nil {
+			t.Error("Expected pending confirmation to be cleared")
+		}
+	})
+
+	t.Run("declined plan is replaced", func(t *testing.T) {
+		w := newWorkflow(t)
+		pause(t, w)
+
+		wfCtx := newTestContext()
+		wfCtx.SessionID = "session-1"
+		wfCtx.Context["confirmation_decision"] = decisionCancel
+		if err := w.resumeNode(context.Background(), wfCtx); err != nil {
+			t.Fatalf("resumeNode failed: %v", err)
+		}
+		if wfCtx.TaskPlan == nil || wfCtx.TaskPlan.Steps[0].Action != "clarify" {
+			t.Errorf("Expected cancellation message step, got %+v", wfCtx.TaskPlan)
+		}
+	})
+
+	t.Run("unrelated reply discards the plan", func(t *testing.T) {
+		w := newWorkflow(t)
+		pause(t, w)
+
+		wfCtx := newTestContext()
+		wfCtx.SessionID = "session-1"
+		wfCtx.RecognizedText = "播放晴天"
+		if err := w.resumeNode(context.Background(), wfCtx); err != nil {
+			t.Fatalf("resumeNode failed: %v", err)
+		}
+		if conditions["resumed"](wfCtx) {
+			t.Error("Unrelated reply should continue to intent recognition")
+		}
+		if w.loadPendingConfirmation("session-1") != nil {
+			t.Error("Expected pending confirmation to be discarded")
+		}
+	})
+
+	t.Run("only one reply gets the plan", func(t *testing.T) {
+		w := newWorkflow(t)
+		pause(t, w)
+
+		results := make(chan error, 2)
+		plans := make(chan *types.TaskPlan, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				wfCtx := newTestContext()
+				wfCtx.SessionID = "session-1"
+				wfCtx.Context["confirmation_decision"] = decisionConfirm
+				results <- w.resumeNode(context.Background(), wfCtx)
+				plans <- wfCtx.TaskPlan
+			}()
+		}
+		var resumed, missing int
+		for i := 0; i < 2; i++ {
+			if err := <-results; errors.Is(err, ErrNoPendingConfirmation) {
+				missing++
+			} else if err != nil {
+				t.Errorf("resumeNode failed: %v", err)
+			}
+			if plan := <-plans; plan != nil {
+				resumed++
+			}
+		}
+		if resumed != 1 || missing != 1 {
+			t.Errorf("Plan resumed %d times, %d replies found none, want 1 and 1", resumed, missing)
+		}
+	})
+
+	t.Run("plan that can't be cleared doesn't run", func(t *testing.T) {
+		store := &failingSaveStore{MemoryStore: ctxmanager.NewMemoryStore()}
+		w := &VoiceWorkflow{
+			security:       security.NewSecurityManager(),
+			contextManager: ctxmanager.NewContextManagerWithStore(store, 10, time.Hour),
+		}
+		pause(t, w)
+		store.fail = true
+
+		wfCtx := newTestContext()
+		wfCtx.SessionID = "session-1"
+		wfCtx.RecognizedText = "确认"
+		if err := w.resumeNode(context.Background(), wfCtx); err == nil {
+			t.Error("Expected resumeNode to fail")
+		}
+		if wfCtx.TaskPlan != nil {
+			t.Errorf("Plan restored although it is still pending: %+v", wfCtx.TaskPlan)
+		}
+	})
+
+	t.Run("confirm without pending plan", func(t *testing.T) {
+		w := newWorkflow(t)
+		if _, err := w.Confirm(context.Background(), "session-2", true); err != ErrNoPendingConfirmation {
+			t.Errorf("Expected ErrNoPendingConfirmation, got %v", err)
+		}
+	})
+}
+
+// failingSaveStore is a memory store whose saves fail once fail is set
+type failingSaveStore struct {
+	*ctxmanager.MemoryStore
+	fail bool
+}
+
+func (s *failingSaveStore) Save(session *ctxmanager.Session) error {
+	if s.fail {
+		return errors.New("store unavailable")
+	}
+	return s.MemoryStore.Save(session)
+}
+
+func TestReferencesInConfirmedSteps(t *testing.T) {
+	config.AppConfig = &config.Config{EnableSafeMode: false}
+	sm := security.NewSecurityManager()
+	generated := func() *types.TaskPlan {
+		return &types.TaskPlan{Steps: []types.TaskStep{
+			{ID: "gen", Action: "generate_text", Parameters: map[string]interface{}{"topic": "写一条清理命令"}},
+			{Action: "execute_command", Parameters: map[string]interface{}{"command": "{{steps.gen.data}}"}},
+		}}
+	}
+
+	// The confirmation prompt could only show the reference, so the plan is rejected up front
+	w := &VoiceWorkflow{security: sm}
+	wfCtx := newTestContext()
+	wfCtx.TaskPlan = generated()
+	if err := w.securityNode(context.Background(), wfCtx); err != nil {
+		t.Fatalf("securityNode failed: %v", err)
+	}
+	if conditions["requires_confirmation"](wfCtx) || wfCtx.TaskPlan.Steps[0].Action != "error" {
+		t.Errorf("Plan after securityNode = %+v, want an error step", wfCtx.TaskPlan)
+	}
+
+	// A step that only needs confirmation once resolved fails instead of running
+	chat := &sequenceChat{replies: []string{"touch ran.txt"}}
+	dir := t.TempDir()
+	runner := sandbox.New(sandbox.Config{Mode: sandbox.ModeNone, WorkDir: dir})
+	exec := executor.NewExecutor(chat, executor.WithSandbox(runner), executor.WithParamCheck(resolvedParamCheck(sm)))
+	result := exec.Execute(context.Background(), generated())
+	if result.Success || !strings.Contains(result.Error, errUnconfirmedReference.Error()) {
+		t.Errorf("Execute() = %+v, want the command step to fail", result)
+	}
+	if len(chat.replies) != 0 {
+		t.Error("Expected the text to be generated")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Command ran without confirmation: %v", entries)
+	}
+
+	if err := resolvedParamCheck(sm)("open_app", map[string]interface{}{"name": "Safari"}); err != nil {
+		t.Errorf("resolvedParamCheck() of an allowed step = %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/deca/voicepilot-eino/pkg/types"
+	"github.com/goccy/go-yaml"
+)
+
+// Node is a single step of the workflow graph
+type Node interface {
+	Name() string
+	Run(ctx context.Context, wfCtx *types.WorkflowContext) error
+}
+
+// RunFunc executes a node against the workflow context
+type RunFunc func(ctx context.Context, wfCtx *types.WorkflowContext) error
+
+// Middleware wraps node execution, e.g. to emit events or record timings
+type Middleware func(node string, next RunFunc) RunFunc
+
+// Condition decides whether an edge is taken after its source node finishes
+type Condition func(wfCtx *types.WorkflowContext) bool
+
+// funcNode adapts a RunFunc to the Node interface
+type funcNode struct {
+	name string
+	run  RunFunc
+}
+
+// NewNode creates a node from a function
+func NewNode(name string, run RunFunc) Node {
+	return &funcNode{name: name, run: run}
+}
+
+func (n *funcNode) Name() string { return n.name }
+
+func (n *funcNode) Run(ctx context.Context, wfCtx *types.WorkflowContext) error {
+	return n.run(ctx, wfCtx)
+}
+
+// GraphDefinition is the declarative description of a workflow graph
+//
+// Entries name the node each entry point (e.g. "voice", "text") starts from.
+// After a node finishes, its outgoing edges are checked in declaration order
+// and the first one whose condition holds is taken; if none matches the run ends.
+type GraphDefinition struct {
+	Entries map[string]string `json:"entries" yaml:"entries"`
+	Edges   []EdgeDefinition  `json:"edges" yaml:"edges"`
+}
+
+// EdgeDefinition connects two nodes, optionally guarded by a named condition
+type EdgeDefinition struct {
+	From string `json:"from" yaml:"from"`
+	To   string `json:"to" yaml:"to"`
+	When string `json:"when,omitempty" yaml:"when,omitempty"` // condition name, "!" prefix negates
+}
+
+// LoadGraphDefinition reads a graph definition from a YAML or JSON file
+func LoadGraphDefinition(path string) (*GraphDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow graph: %w", err)
+	}
+
+	return ParseGraphDefinition(data, filepath.Ext(path))
+}
+
+// ParseGraphDefinition parses a graph definition; ext selects JSON (".json") or YAML (anything else)
+func ParseGraphDefinition(data []byte, ext string) (*GraphDefinition, error) {
+	var def GraphDefinition
+	var err error
+	if strings.EqualFold(ext, ".json") {
+		err = json.Unmarshal(data, &def)
+	} else {
+		err = yaml.Unmarshal(data, &def)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse workflow graph: %w", err)
+	}
+
+	return &def, nil
+}
+
+type edge struct {
+	to     string
+	cond   Condition
+	negate bool
+}
+
+func (e edge) matches(wfCtx *types.WorkflowContext) bool {
+	if e.cond == nil {
+		return true
+	}
+	return e.cond(wfCtx) != e.negate
+}
+
+// Graph is a validated, acyclic workflow graph ready to run
+type Graph struct {
+	nodes   map[string]Node
+	edges   map[string][]edge
+	entries map[string]string
+}
+
+// NewGraph wires nodes together according to def and checks that the result is a DAG
+//
+// def must define every entry point in required, so a custom graph missing
+// one fails at startup rather than on the first request using it.
+func NewGraph(def *GraphDefinition, nodes []Node, conditions map[string]Condition, required ...string) (*Graph, error) {
+	g := &Graph{
+		nodes:   make(map[string]Node),
+		edges:   make(map[string][]edge),
+		entries: make(map[string]string),
+	}
+
+	for _, node := range nodes {
+		g.nodes[node.Name()] = node
+	}
+
+	if len(def.Entries) == 0 {
+		return nil, fmt.Errorf("workflow graph has no entry points")
+	}
+	for entry, start := range def.Entries {
+		if _, ok := g.nodes[start]; !ok {
+			return nil, fmt.Errorf("entry %q starts at unknown node %q", entry, start)
+		}
+		g.entries[entry] = start
+	}
+	for _, entry := range required {
+		if _, ok := g.entries[entry]; !ok {
+			return nil, fmt.Errorf("workflow graph has no %q entry point", entry)
+		}
+	}
+
+	for _, e := range def.Edges {
+		if _, ok := g.nodes[e.From]; !ok {
+			return nil, fmt.Errorf("edge references unknown node %q", e.From)
+		}
+		if _, ok := g.nodes[e.To]; !ok {
+			return nil, fmt.Errorf("edge references unknown node %q", e.To)
+		}
+
+		compiled := edge{to: e.To}
+		if e.When != "" {
+			name := strings.TrimPrefix(e.When, "!")
+			cond, ok := conditions[name]
+			if !ok {
+				return nil, fmt.Errorf("edge %s -> %s uses unknown condition %q", e.From, e.To, name)
+			}
+			compiled.cond = cond
+			compiled.negate = strings.HasPrefix(e.When, "!")
+		}
+		g.edges[e.From] = append(g.edges[e.From], compiled)
+	}
+
+	if err := g.checkAcyclic(); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// checkAcyclic rejects graphs with cycles so every run is guaranteed to terminate
+func (g *Graph) checkAcyclic() error {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("workflow graph has a cycle: %s -> %s", strings.Join(path, " -> "), name)
+		case done:
+			return nil
+		}
+
+		state[name] = visiting
+		for _, e := range g.edges[name] {
+			if err := visit(e.to, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		return nil
+	}
+
+	for name := range g.nodes {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run executes the graph from the given entry point
+func (g *Graph) Run(ctx context.Context, entry string, wfCtx *types.WorkflowContext, middleware ...Middleware) error {
+	current, ok := g.entries[entry]
+	if !ok {
+		return fmt.Errorf("unknown workflow entry point: %s", entry)
+	}
+
+	for current != "" {
+		node := g.nodes[current]
+
+		run := RunFunc(node.Run)
+		for i := len(middleware) - 1; i >= 0; i-- {
+			run = middleware[i](current, run)
+		}
+
+		if err := run(ctx, wfCtx); err != nil {
+			return fmt.Errorf("%s node failed: %w", current, err)
+		}
+
+		next := ""
+		for _, e := range g.edges[current] {
+			if e.matches(wfCtx) {
+				next = e.to
+				break
+			}
+		}
+		current = next
+	}
+
+	return nil
+}
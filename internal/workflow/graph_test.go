@@ -0,0 +1,270 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/deca/voicepilot-eino/internal/trace"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// recordingNodes creates nodes that append their name to visited when run
+func recordingNodes(visited *[]string, names ...string) []Node {
+	nodes := make([]Node, 0, len(names))
+	for _, name := range names {
+		name := name
+		nodes = append(nodes, NewNode(name, func(ctx context.Context, wfCtx *types.WorkflowContext) error {
+			*visited = append(*visited, name)
+			return nil
+		}))
+	}
+	return nodes
+}
+
+func newTestContext() *types.WorkflowContext {
+	return &types.WorkflowContext{Context: make(map[string]interface{})}
+}
+
+func TestDefaultGraphRouting(t *testing.T) {
+	def, err := ParseGraphDefinition(defaultGraphYAML, ".yaml")
+	if err != nil {
+		t.Fatalf("Failed to parse default graph: %v", err)
+	}
+
+	tests := []struct {
+		name                 string
+		entry                string
+		intent               *types.Intent
+		textOnly             bool
+		resumed              bool
+		requiresConfirmation bool
+		want                 []string
+	}{
+		{
+			name:   "voice entry runs full pipeline",
+			entry:  EntryVoice,
+			intent: &types.Intent{Intent: "play_music", Confidence: 0.9},
+			want:   []string{"asr", "resume", "intent", "planner", "security", "executor", "response", "tts"},
+		},
+		{
+			name:   "text entry skips asr",
+			entry:  EntryText,
+			intent: &types.Intent{Intent: "play_music", Confidence: 0.9},
+			want:   []string{"resume", "intent", "planner", "security", "executor", "response", "tts"},
+		},
+		{
+			name:   "unknown intent skips planner",
+			entry:  EntryText,
+			intent: &types.Intent{Intent: "unknown"},
+			want:   []string{"resume", "intent", "clarify", "executor", "response", "tts"},
+		},
+		{
+			name:     "text-only client skips tts",
+			entry:    EntryText,
+			intent:   &types.Intent{Intent: "open_app", Confidence: 0.9},
+			textOnly: true,
+			want:     []string{"resume", "intent", "planner", "security", "executor", "response"},
+		},
+		{
+			name:                 "risky plan waits for confirmation",
+			entry:                EntryText,
+			intent:               &types.Intent{Intent: "execute_command", Confidence: 0.9},
+			requiresConfirmation: true,
+			want:                 []string{"resume", "intent", "planner", "security", "await_confirmation", "tts"},
+		},
+		{
+			name:    "confirmation reply resumes the pending plan",
+			entry:   EntryText,
+			resumed: true,
+			want:    []string{"resume", "security", "executor", "response", "tts"},
+		},
+		{
+			name:     "confirm entry resumes the pending plan",
+			entry:    EntryConfirm,
+			resumed:  true,
+			textOnly: true,
+			want:     []string{"resume", "security", "executor", "response"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var visited []string
+			nodes := recordingNodes(&visited, "asr", "clarify", "planner", "executor", "response", "tts", "await_confirmation")
+			nodes = append(nodes,
+				NewNode("intent", func(ctx context.Context, wfCtx *types.WorkflowContext) error {
+					visited = append(visited, "intent")
+					wfCtx.Intent = tt.intent
+					return nil
+				}),
+				NewNode("resume", func(ctx context.Context, wfCtx *types.WorkflowContext) error {
+					visited = append(visited, "resume")
+					if tt.resumed {
+						wfCtx.TaskPlan = &types.TaskPlan{}
+					}
+					return nil
+				}),
+				NewNode("security", func(ctx context.Context, wfCtx *types.WorkflowContext) error {
+					visited = append(visited, "security")
+					wfCtx.Context["requires_confirmation"] = tt.requiresConfirmation
+					return nil
+				}),
+			)
+
+			graph, err := NewGraph(def, nodes, conditions)
+			if err != nil {
+				t.Fatalf("NewGraph failed: %v", err)
+			}
+
+			wfCtx := newTestContext()
+			wfCtx.Context["text_only"] = tt.textOnly
+			if err := graph.Run(context.Background(), tt.entry, wfCtx); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(visited, tt.want) {
+				t.Errorf("Expected path %v, got %v", tt.want, visited)
+			}
+		})
+	}
+}
+
+func TestNewGraphValidation(t *testing.T) {
+	var visited []string
+	nodes := recordingNodes(&visited, "a", "b", "c")
+
+	tests := []struct {
+		name     string
+		def      string
+		required []string
+		wantErr  string
+	}{
+		{
+			name:    "cycle",
+			def:     `{"entries":{"main":"a"},"edges":[{"from":"a","to":"b"},{"from":"b","to":"c"},{"from":"c","to":"a"}]}`,
+			wantErr: "cycle",
+		},
+		{
+			name:    "unknown node",
+			def:     `{"entries":{"main":"a"},"edges":[{"from":"a","to":"missing"}]}`,
+			wantErr: "unknown node",
+		},
+		{
+			name:    "unknown condition",
+			def:     `{"entries":{"main":"a"},"edges":[{"from":"a","to":"b","when":"nope"}]}`,
+			wantErr: "unknown condition",
+		},
+		{
+			name:    "no entries",
+			def:     `{"edges":[]}`,
+			wantErr: "no entry points",
+		},
+		{
+			name:     "missing required entry",
+			def:      `{"entries":{"voice":"a","text":"b"},"edges":[]}`,
+			required: []string{EntryVoice, EntryText, EntryConfirm},
+			wantErr:  `no "confirm" entry point`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def, err := ParseGraphDefinition([]byte(tt.def), ".json")
+			if err != nil {
+				t.Fatalf("ParseGraphDefinition failed: %v", err)
+			}
+
+			_, err = NewGraph(def, nodes, conditions, tt.required...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGraphRunMiddlewareAndErrors(t *testing.T) {
+	def := &GraphDefinition{
+		Entries: map[string]string{"main": "a"},
+		Edges:   []EdgeDefinition{{From: "a", To: "b"}},
+	}
+	nodes := []Node{
+		NewNode("a", func(ctx context.Context, wfCtx *types.WorkflowContext) error { return nil }),
+		NewNode("b", func(ctx context.Context, wfCtx *types.WorkflowContext) error { return errors.New("boom") }),
+	}
+
+	graph, err := NewGraph(def, nodes, nil)
+	if err != nil {
+		t.Fatalf("NewGraph failed: %v", err)
+	}
+
+	var seen []string
+	record := func(node string, next RunFunc) RunFunc {
+		return func(ctx context.Context, wfCtx *types.WorkflowContext) error {
+			seen = append(seen, node)
+			return next(ctx, wfCtx)
+		}
+	}
+
+	err = graph.Run(context.Background(), "main", newTestContext(), record)
+	if err == nil || !strings.Contains(err.Error(), "b node failed: boom") {
+		t.Errorf("Expected node b failure, got %v", err)
+	}
+	if !reflect.DeepEqual(seen, []string{"a", "b"}) {
+		t.Errorf("Expected middleware to see [a b], got %v", seen)
+	}
+
+	if err := graph.Run(context.Background(), "missing", newTestContext()); err == nil {
+		t.Error("Expected error for unknown entry point")
+	}
+}
+
+func TestTraceMiddleware(t *testing.T) {
+	def := &GraphDefinition{
+		Entries: map[string]string{"text": "intent"},
+		Edges:   []EdgeDefinition{{From: "intent", To: "response"}},
+	}
+	nodes := []Node{
+		NewNode("intent", func(ctx context.Context, wfCtx *types.WorkflowContext) error {
+			wfCtx.Intent = &types.Intent{Intent: "open_app", Confidence: 0.9}
+			wfCtx.Context["llm_repairs"] = []string{"strip_fence"}
+			return nil
+		}),
+		NewNode("response", func(ctx context.Context, wfCtx *types.WorkflowContext) error {
+			return errors.New("boom")
+		}),
+	}
+
+	g, err := NewGraph(def, nodes, nil)
+	if err != nil {
+		t.Fatalf("NewGraph failed: %v", err)
+	}
+
+	wfCtx := newTestContext()
+	wfCtx.Context["text_only"] = true
+	tr := trace.New("session-1", "text")
+	if err := g.Run(context.Background(), "text", wfCtx, traceMiddleware(tr)); err == nil {
+		t.Fatal("Expected response node error")
+	}
+
+	if len(tr.Nodes) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(tr.Nodes))
+	}
+
+	intent := tr.Nodes[0]
+	if _, ok := intent.Output["intent"]; !ok {
+		t.Errorf("Expected intent output, got %v", intent.Output)
+	}
+	if _, ok := intent.Output["context.llm_repairs"]; !ok {
+		t.Errorf("Expected context.llm_repairs output, got %v", intent.Output)
+	}
+	if _, ok := intent.Output["context.text_only"]; ok {
+		t.Error("Unchanged context entries should not be recorded")
+	}
+
+	if tr.Nodes[1].Error != "boom" {
+		t.Errorf("Expected response span error, got %q", tr.Nodes[1].Error)
+	}
+}
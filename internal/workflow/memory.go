@@ -0,0 +1,145 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/llmjson"
+	"github.com/deca/voicepilot-eino/internal/memory"
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/internal/trace"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// memoryTimeout bounds a background fact extraction so a stuck model call is given up
+const memoryTimeout = time.Minute
+
+// memoryUpdate is what the model learned from one exchange
+type memoryUpdate struct {
+	Facts  []memory.Fact `json:"facts"`
+	Forget []string      `json:"forget"` // keys of facts the user asked to be forgotten or that no longer hold
+}
+
+// Validate implements llmjson.Validator
+func (u *memoryUpdate) Validate() error {
+	for _, fact := range u.Facts {
+		if strings.TrimSpace(fact.Text) == "" {
+			return fmt.Errorf("fact %q has no text", fact.Key)
+		}
+	}
+	return nil
+}
+
+// Memory returns the long-term memory of users, nil when it is disabled
+func (w *VoiceWorkflow) Memory() *memory.Store {
+	return w.memory
+}
+
+// userMemory returns the facts about the user relevant to the request, as a system message
+//
+// The facts are recalled once per run and kept out of the trace. It returns
+// "" for anonymous requests and when nothing is known about the user.
+func (w *VoiceWorkflow) userMemory(ctx context.Context, wfCtx *types.WorkflowContext) string {
+	if w.memory == nil || wfCtx.UserID == "" {
+		return ""
+	}
+	if message, ok := wfCtx.Context["user_memory"].(string); ok {
+		return message
+	}
+
+	facts, err := w.memory.Recall(wfCtx.UserID, wfCtx.RecognizedText, w.recallLimit)
+	if err != nil {
+		log.Printf("Warning: failed to recall memory of user %s: %v", wfCtx.UserID, err)
+	}
+	var message string
+	if len(facts) > 0 {
+		var b strings.Builder
+		b.WriteString("关于用户的已知信息（来自以往的对话，可能已过时，仅在相关时参考）：")
+		for _, fact := range facts {
+			fmt.Fprintf(&b, "\n- %s", fact.Text)
+		}
+		message = b.String()
+		// Traces outlive deleted memories, so they only record how many facts were used
+		trace.Redact(ctx, message)
+		trace.Annotate(ctx, "user_memory_facts", len(facts))
+	}
+	wfCtx.Context["user_memory"] = message
+	return message
+}
+
+// extractFacts asks the chat model what an exchange revealed about the user
+func (w *VoiceWorkflow) extractFacts(ctx context.Context, known []memory.Fact, userText, responseText string) (*memoryUpdate, error) {
+	systemPrompt := `你负责为语音助手整理关于用户的长期记忆。根据一轮对话，找出值得长期记住的用户信息，例如称呼、姓名、住址、家人、喜好、习惯和重要的日子。不要记录一次性的请求内容、助手的回复和时效很短的信息。
+
+输出格式：
+{
+  "facts": [{"key": "英文小写的信息类别，如 preferred_name, favorite_singer", "text": "一句完整的陈述，如：用户希望被称为小李"}],
+  "forget": ["用户要求忘掉或已不再成立的信息类别"]
+}
+
+已知信息与新信息属于同一类别时，使用相同的 key。没有需要记住或忘掉的信息时输出 {"facts": [], "forget": []}。只输出JSON，不要输出其他内容。`
+
+	knownJSON, _ := json.Marshal(known)
+	userPrompt := fmt.Sprintf("已知信息：%s\n用户：%s\n助手：%s", string(knownJSON), userText, responseText)
+
+	response, err := w.chat.ChatCompletion(ctx, []provider.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userPrompt},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var update memoryUpdate
+	if _, err := llmjson.Decode(response, &update); err != nil {
+		return nil, fmt.Errorf("invalid memory update %q: %w", response, err)
+	}
+	return &update, nil
+}
+
+// rememberFacts stores what an exchange revealed about the user, logging failures
+//
+// It runs after the response has been sent, so the reply isn't delayed.
+func (w *VoiceWorkflow) rememberFacts(userID, sessionID, userText, responseText string) {
+	ctx, cancel := context.WithTimeout(context.Background(), memoryTimeout)
+	defer cancel()
+
+	// A user who deletes their memory while the model is looking at the exchange stays forgotten
+	generation := w.memory.Generation(userID)
+	known, err := w.memory.List(userID)
+	if err != nil {
+		log.Printf("Warning: failed to load memory of user %s: %v", userID, err)
+		return
+	}
+	update, err := w.extractFacts(ctx, known, userText, responseText)
+	if err != nil {
+		log.Printf("Warning: failed to extract facts for user %s: %v", userID, err)
+		return
+	}
+
+	if len(update.Forget) > 0 {
+		n, err := w.memory.ForgetKeys(userID, update.Forget)
+		if err != nil {
+			log.Printf("Warning: failed to forget facts of user %s: %v", userID, err)
+		} else if n > 0 {
+			log.Printf("Forgot %d facts of user %s", n, userID)
+		}
+	}
+	if len(update.Facts) > 0 {
+		_, err := w.memory.Remember(userID, sessionID, generation, update.Facts)
+		if errors.Is(err, memory.ErrForgotten) {
+			log.Printf("Memory of user %s was deleted meanwhile, dropping %d facts", userID, len(update.Facts))
+			return
+		}
+		if err != nil {
+			log.Printf("Warning: failed to remember facts of user %s: %v", userID, err)
+			return
+		}
+		log.Printf("Remembered %d facts of user %s", len(update.Facts), userID)
+	}
+}
@@ -0,0 +1,143 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+	ctxmanager "github.com/deca/voicepilot-eino/internal/context"
+	"github.com/deca/voicepilot-eino/internal/memory"
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/internal/trace"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+func newMemoryTestWorkflow(t *testing.T, chat *sequenceChat) *VoiceWorkflow {
+	store, err := memory.NewStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	return &VoiceWorkflow{
+		chat:           chat,
+		contextManager: ctxmanager.NewContextManager(t.TempDir(), 10, time.Hour),
+		memory:         store,
+		recallLimit:    5,
+	}
+}
+
+func TestRememberFacts(t *testing.T) {
+	chat := &sequenceChat{replies: []string{
+		`{"facts": [{"key": "preferred_name", "text": "用户希望被称为小李"}, {"key": "favorite_singer", "text": "用户最喜欢的歌手是周杰伦"}], "forget": []}`,
+		"```json\n{\"facts\": [], \"forget\": [\"favorite_singer\"]}\n```",
+		"好的",
+	}}
+	w := newMemoryTestWorkflow(t, chat)
+
+	w.rememberFacts("u1", "s1", "以后叫我小李，我最喜欢周杰伦", "好的，小李")
+	facts, _ := w.memory.List("u1")
+	if len(facts) != 2 {
+		t.Fatalf("Facts = %+v, want 2", facts)
+	}
+	if !strings.Contains(chat.requests[0][1].Content, "用户：以后叫我小李，我最喜欢周杰伦") {
+		t.Errorf("Extraction prompt = %q", chat.requests[0][1].Content)
+	}
+
+	// Known facts are shown to the model so it can reuse or drop their keys
+	w.rememberFacts("u1", "s1", "忘掉我喜欢的歌手", "好的，已经忘记了")
+	if !strings.Contains(chat.requests[1][1].Content, "用户希望被称为小李") {
+		t.Errorf("Known facts missing from the prompt: %q", chat.requests[1][1].Content)
+	}
+	facts, _ = w.memory.List("u1")
+	if len(facts) != 1 || facts[0].Key != "preferred_name" {
+		t.Errorf("Facts after forgetting = %+v, want the name only", facts)
+	}
+
+	// An unusable reply changes nothing
+	w.rememberFacts("u1", "s1", "你好", "你好")
+	if facts, _ := w.memory.List("u1"); len(facts) != 1 {
+		t.Errorf("Facts after an invalid reply = %+v", facts)
+	}
+}
+
+// forgettingChat deletes the user's memory while it is asked what to remember
+type forgettingChat struct {
+	store *memory.Store
+	reply string
+}
+
+func (c *forgettingChat) ChatCompletion(ctx context.Context, messages []provider.Message) (string, error) {
+	c.store.ForgetAll("u1")
+	return c.reply, nil
+}
+
+func TestRememberFactsAfterForgetAll(t *testing.T) {
+	w := newMemoryTestWorkflow(t, nil)
+	w.memory.Remember("u1", "s0", 0, []memory.Fact{{Key: "city", Text: "用户住在杭州"}})
+	w.chat = &forgettingChat{store: w.memory, reply: `{"facts": [{"key": "preferred_name", "text": "用户希望被称为小李"}], "forget": []}`}
+
+	w.rememberFacts("u1", "s1", "以后叫我小李", "好的，小李")
+	if facts, _ := w.memory.List("u1"); len(facts) != 0 {
+		t.Errorf("Facts after the user deleted their memory = %+v, want none", facts)
+	}
+}
+
+func TestUserMemoryInPrompts(t *testing.T) {
+	config.AppConfig = &config.Config{LLMMaxTokens: 100, LLMContextWindow: 4000}
+	chat := &sequenceChat{replies: []string{"小李，这就为你播放周杰伦的歌"}}
+	w := newMemoryTestWorkflow(t, chat)
+	w.chat = trace.WrapChat(chat)
+	w.memory.Remember("u1", "s0", 0, []memory.Fact{
+		{Key: "preferred_name", Text: "用户希望被称为小李"},
+		{Key: "favorite_singer", Text: "用户最喜欢的歌手是周杰伦"},
+	})
+
+	span := trace.New("s1", EntryText).StartNode("intent")
+	ctx := trace.WithSpan(context.Background(), span)
+	wfCtx := &types.WorkflowContext{
+		SessionID:      "s1",
+		UserID:         "u1",
+		RecognizedText: "放一首我最喜欢的歌手的歌",
+		Context:        map[string]interface{}{},
+	}
+
+	messages := w.intentMessages(ctx, wfCtx, "你是语音助手", nil)
+	if len(messages) != 3 || messages[1].Role != "system" || !strings.Contains(messages[1].Content, "- 用户最喜欢的歌手是周杰伦\n- 用户希望被称为小李") {
+		t.Fatalf("intentMessages() = %+v, want the relevant fact first", messages)
+	}
+	if span.Output["user_memory_facts"] != 2 {
+		t.Errorf("Recalled facts in the trace = %v, want 2", span.Output["user_memory_facts"])
+	}
+	usage := span.Output["context_tokens"].(map[string]interface{})
+	if usage["system"].(int) <= 10 {
+		t.Errorf("context_tokens[system] = %v, want the facts counted", usage["system"])
+	}
+
+	wfCtx.ExecutionResult = &types.ExecutionResult{Success: true, Message: "正在播放"}
+	if err := w.responseNode(ctx, wfCtx); err != nil {
+		t.Fatalf("responseNode failed: %v", err)
+	}
+	request := chat.requests[0]
+	if len(request) != 3 || request[1].Content != messages[1].Content {
+		t.Errorf("Response request = %+v, want the same facts", request)
+	}
+
+	// The facts stay out of the trace, which outlives a deleted memory
+	if len(span.LLMCalls) != 1 || span.LLMCalls[0].Messages[1].Content != "[已隐藏]" {
+		t.Errorf("Recorded model call = %+v, want the facts hidden", span.LLMCalls)
+	}
+	if _, ok := snapshot(wfCtx)["context.user_memory"]; ok {
+		t.Error("Snapshot of the workflow context contains the facts")
+	}
+	if recorded, _ := json.Marshal(span); strings.Contains(string(recorded), "用户最喜欢的歌手是") {
+		t.Errorf("Trace contains the facts: %s", recorded)
+	}
+
+	// Anonymous requests see no memory
+	wfCtx = &types.WorkflowContext{SessionID: "s2", RecognizedText: "你好", Context: map[string]interface{}{}}
+	if messages := w.intentMessages(ctx, wfCtx, "你是语音助手", nil); len(messages) != 2 {
+		t.Errorf("intentMessages() without a user = %+v", messages)
+	}
+}
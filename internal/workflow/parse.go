@@ -0,0 +1,71 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/deca/voicepilot-eino/internal/llmjson"
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// repairsKey is the workflow context key holding the JSON repairs made during a run
+const repairsKey = "llm_repairs"
+
+// LLMRepair records how a node's JSON reply had to be repaired before it could be used
+type LLMRepair struct {
+	Node    string   `json:"node"`
+	Repairs []string `json:"repairs"`
+	Success bool     `json:"success"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// decodeLLMJSON decodes a JSON reply into v, re-prompting the model once if it does not validate
+//
+// messages are the messages that produced response; v is left untouched when
+// an error is returned so the caller can apply its own fallback.
+func (w *VoiceWorkflow) decodeLLMJSON(ctx context.Context, wfCtx *types.WorkflowContext, node string, messages []provider.Message, response string, v interface{}) error {
+	repairs, err := llmjson.Decode(response, v)
+	if err != nil && w.reprompt {
+		log.Printf("%s reply failed validation: %v, re-prompting once", node, err)
+
+		retry := append(messages[:len(messages):len(messages)],
+			provider.Message{Role: "assistant", Content: response},
+			provider.Message{Role: "user", Content: fmt.Sprintf("你的输出不符合要求：%v。请修正后只输出JSON，不要输出其他内容。", err)},
+		)
+
+		var retryResponse string
+		retryResponse, err = w.chat.ChatCompletion(ctx, retry)
+		if err == nil {
+			var retryRepairs []string
+			retryRepairs, err = llmjson.Decode(retryResponse, v)
+			repairs = append(append(repairs, llmjson.RepairReprompt), retryRepairs...)
+		}
+	}
+
+	if len(repairs) > 0 || err != nil {
+		recordRepair(wfCtx, LLMRepair{
+			Node:    node,
+			Repairs: repairs,
+			Success: err == nil,
+			Error:   errorString(err),
+		})
+	}
+	return err
+}
+
+// recordRepair appends a repair record to the workflow context
+func recordRepair(wfCtx *types.WorkflowContext, repair LLMRepair) {
+	log.Printf("%s reply repaired: %v (success: %v)", repair.Node, repair.Repairs, repair.Success)
+
+	repairs, _ := wfCtx.Context[repairsKey].([]LLMRepair)
+	wfCtx.Context[repairsKey] = append(repairs, repair)
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,109 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+
+	"github.com/deca/voicepilot-eino/internal/llmjson"
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// sequenceChat replies with each of its replies in turn and records the requests
+type sequenceChat struct {
+	replies  []string
+	requests [][]provider.Message
+}
+
+func (s *sequenceChat) ChatCompletion(ctx context.Context, messages []provider.Message) (string, error) {
+	s.requests = append(s.requests, messages)
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return reply, nil
+}
+
+func TestDecodeLLMJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    string
+		retries     []string
+		reprompt    bool
+		wantIntent  string
+		wantRepairs []string
+		wantError   bool
+	}{
+		{
+			name:       "clean reply is not recorded",
+			response:   `{"intent":"open_app","confidence":0.9}`,
+			reprompt:   true,
+			wantIntent: "open_app",
+		},
+		{
+			name:        "fenced reply",
+			response:    "```json\n{\"intent\":\"open_app\",\"confidence\":0.9}\n```",
+			reprompt:    true,
+			wantIntent:  "open_app",
+			wantRepairs: []string{llmjson.RepairStripFence},
+		},
+		{
+			name:        "reprompt fixes invalid reply",
+			response:    `{"confidence":0.9}`,
+			retries:     []string{`{"intent":"play_music","confidence":0.8}`},
+			reprompt:    true,
+			wantIntent:  "play_music",
+			wantRepairs: []string{llmjson.RepairReprompt},
+		},
+		{
+			name:      "reprompt disabled",
+			response:  `{"confidence":0.9}`,
+			wantError: true,
+		},
+		{
+			name:        "reprompt still invalid",
+			response:    "不知道",
+			retries:     []string{"还是不知道"},
+			reprompt:    true,
+			wantError:   true,
+			wantRepairs: []string{llmjson.RepairReprompt},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chat := &sequenceChat{replies: tt.retries}
+			w := &VoiceWorkflow{chat: chat, reprompt: tt.reprompt}
+			wfCtx := newTestContext()
+			messages := []provider.Message{{Role: "user", Content: "打开微信"}}
+
+			var intent types.Intent
+			err := w.decodeLLMJSON(context.Background(), wfCtx, "intent", messages, tt.response, &intent)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("Expected error: %v, got %v", tt.wantError, err)
+			}
+			if intent.Intent != tt.wantIntent {
+				t.Errorf("Expected intent %q, got %q", tt.wantIntent, intent.Intent)
+			}
+
+			if len(tt.retries) > 0 && len(chat.requests[0]) != 3 {
+				t.Errorf("Expected re-prompt to include the original reply and the error, got %d messages", len(chat.requests[0]))
+			}
+
+			repairs, _ := wfCtx.Context[repairsKey].([]LLMRepair)
+			if len(tt.wantRepairs) == 0 && !tt.wantError {
+				if len(repairs) != 0 {
+					t.Errorf("Expected no repairs recorded, got %+v", repairs)
+				}
+				return
+			}
+			if len(repairs) != 1 {
+				t.Fatalf("Expected one repair record, got %+v", repairs)
+			}
+			if repairs[0].Success == tt.wantError {
+				t.Errorf("Expected success %v, got %+v", !tt.wantError, repairs[0])
+			}
+			if len(tt.wantRepairs) > 0 && repairs[0].Repairs[0] != tt.wantRepairs[0] {
+				t.Errorf("Expected repairs %v, got %v", tt.wantRepairs, repairs[0].Repairs)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/deca/voicepilot-eino/internal/executor"
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// plannerActions returns the registered actions the security manager permits under the current mode
+func (w *VoiceWorkflow) plannerActions() []executor.ActionSpec {
+	var specs []executor.ActionSpec
+	for _, spec := range w.executor.Specs() {
+		if w.security.ActionPermitted(spec.Name) {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// plannerTools returns the planner actions as tool definitions
+func (w *VoiceWorkflow) plannerTools() []provider.Tool {
+	specs := w.plannerActions()
+	tools := make([]provider.Tool, 0, len(specs))
+	for _, spec := range specs {
+		tools = append(tools, spec.Tool())
+	}
+	return tools
+}
+
+// plannerPrompt builds the JSON planner system prompt listing the given actions
+func plannerPrompt(specs []executor.ActionSpec) string {
+	return `你是一个任务规划模块。根据用户的意图，生成详细的执行计划。
+
+输出格式：
+{
+  "steps": [
+    {"action": "动作类型", "parameters": {"参数名": "参数值"}},
+    ...
+  ]
+}
+
+步骤之间有数据传递或先后顺序时，给步骤加上 "id"，并用 "depends_on" 列出它要等待的步骤；
+参数中可以用 {{steps.步骤id.data}} 引用该步骤的结果数据，用 {{steps.步骤id.message}} 引用其结果描述，引用的步骤会自动先执行。
+没有依赖关系的步骤会同时执行。例如“写一篇关于秋天的短文并保存到 notes.md”：
+{
+  "steps": [
+    {"id": "gen", "action": "generate_text", "parameters": {"topic": "秋天", "content_type": "短文"}},
+    {"id": "save", "action": "save_file", "parameters": {"path": "notes.md", "content": "{{steps.gen.data}}"}, "depends_on": ["gen"]}
+  ]
+}
+所有步骤都不带 "id" 时按顺序依次执行。
+
+支持的动作类型（只能使用以下动作）：
+` + describeActions(specs) + `
+只输出JSON，不要输出其他内容。`
+}
+
+// plannerUserPrompt describes the recognized intent to the planner
+func plannerUserPrompt(wfCtx *types.WorkflowContext) string {
+	intentJSON, _ := json.Marshal(wfCtx.Intent)
+	return fmt.Sprintf("用户意图：%s\n用户原始输入：%s", string(intentJSON), wfCtx.RecognizedText)
+}
+
+// describeActions lists actions for the planner prompt with their parameter names, types and examples
+func describeActions(specs []executor.ActionSpec) string {
+	var b strings.Builder
+	for _, spec := range specs {
+		fmt.Fprintf(&b, "- %s: %s\n", spec.Name, spec.Description)
+		if params := describeParameters(spec.Parameters); params != "" {
+			fmt.Fprintf(&b, "  参数：%s\n", params)
+		}
+	}
+	return b.String()
+}
+
+// describeParameters summarizes the properties of a JSON schema, e.g. `name（string，必填）应用程序名称，示例："微信"`
+func describeParameters(schema map[string]interface{}) string {
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	required := make(map[string]bool)
+	for _, name := range toSlice(schema["required"]) {
+		required[fmt.Sprint(name)] = true
+	}
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		prop, _ := properties[name].(map[string]interface{})
+
+		typ, _ := prop["type"].(string)
+		if typ == "" {
+			typ = "any"
+		}
+		if required[name] {
+			typ += "，必填"
+		}
+		part := fmt.Sprintf("%s（%s）", name, typ)
+
+		if description, ok := prop["description"].(string); ok && description != "" {
+			part += description
+		}
+		if examples := toSlice(prop["examples"]); len(examples) > 0 {
+			quoted := make([]string, len(examples))
+			for i, example := range examples {
+				data, _ := json.Marshal(example)
+				quoted[i] = string(data)
+			}
+			part += "，示例：" + strings.Join(quoted, "、")
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "；")
+}
+
+// toSlice converts a []string or a decoded JSON array to []interface{}
+func toSlice(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		return v
+	case []string:
+		out := make([]interface{}, len(v))
+		for i, s := range v {
+			out[i] = s
+		}
+		return out
+	}
+	return nil
+}
+
+// unknownActions returns the actions in the plan the executor has no handler for, without duplicates
+func (w *VoiceWorkflow) unknownActions(plan *types.TaskPlan) []string {
+	var unknown []string
+	seen := make(map[string]bool)
+	for _, step := range plan.Steps {
+		if !w.executor.HasAction(step.Action) && !seen[step.Action] {
+			seen[step.Action] = true
+			unknown = append(unknown, step.Action)
+		}
+	}
+	return unknown
+}
+
+// checkPlanActions asks the model once for a new plan when the plan uses unknown actions
+//
+// If the new plan still uses unknown actions, or re-planning fails, the user
+// is told the request isn't supported instead of running a plan that would fail.
+func (w *VoiceWorkflow) checkPlanActions(ctx context.Context, wfCtx *types.WorkflowContext, plan *types.TaskPlan) *types.TaskPlan {
+	unknown := w.unknownActions(plan)
+	if len(unknown) == 0 {
+		return plan
+	}
+	log.Printf("Planner Node: Plan uses unknown actions %v, re-planning once", unknown)
+
+	planJSON, _ := json.Marshal(plan)
+	messages := []provider.Message{
+		{Role: "system", Content: plannerPrompt(w.plannerActions())},
+		{Role: "user", Content: plannerUserPrompt(wfCtx)},
+		{Role: "assistant", Content: string(planJSON)},
+		{Role: "user", Content: fmt.Sprintf("计划中包含不支持的动作：%s。请只使用支持的动作类型重新生成执行计划，只输出JSON，不要输出其他内容。", strings.Join(unknown, "、"))},
+	}
+
+	var replanned types.TaskPlan
+	response, err := w.chat.ChatCompletion(ctx, messages)
+	if err == nil {
+		err = w.decodeLLMJSON(ctx, wfCtx, "replan", messages, response, &replanned)
+	}
+	if err == nil {
+		if still := w.unknownActions(&replanned); len(still) > 0 {
+			unknown = still
+			err = fmt.Errorf("plan still uses unknown actions %v", still)
+		}
+	}
+	if err != nil {
+		log.Printf("Planner Node: Re-planning failed: %v", err)
+		return &types.TaskPlan{
+			Steps: []types.TaskStep{
+				{
+					Action: "clarify",
+					Parameters: map[string]interface{}{
+						"message": fmt.Sprintf("抱歉，我暂时还不支持这个操作（%s）。", strings.Join(unknown, "、")),
+					},
+				},
+			},
+		}
+	}
+
+	log.Printf("Planner Node: Re-planned with %d steps", len(replanned.Steps))
+	return &replanned
+}
@@ -0,0 +1,122 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+	"github.com/deca/voicepilot-eino/internal/executor"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+func TestPlannerPrompt(t *testing.T) {
+	w := newToolTestWorkflow(t, &toolChat{})
+
+	tests := []struct {
+		safeMode    bool
+		wantCommand bool
+	}{
+		{safeMode: true, wantCommand: false},
+		{safeMode: false, wantCommand: true},
+	}
+
+	for _, tt := range tests {
+		config.AppConfig.EnableSafeMode = tt.safeMode
+		prompt := plannerPrompt(w.plannerActions())
+
+		if strings.Contains(prompt, "- execute_command:") != tt.wantCommand {
+			t.Errorf("safe mode %v: execute_command listed = %v, want %v", tt.safeMode, !tt.wantCommand, tt.wantCommand)
+		}
+		// Unregistered actions and handler-only aliases are never offered
+		for _, action := range []string{"write_article", "- error:"} {
+			if strings.Contains(prompt, action) {
+				t.Errorf("Prompt should not list %s", action)
+			}
+		}
+		if !strings.Contains(prompt, `name（string，必填）应用程序名称，示例："微信"、"Safari"`) {
+			t.Errorf("Prompt is missing open_app parameter details:\n%s", prompt)
+		}
+	}
+}
+
+func TestDescribeParameters(t *testing.T) {
+	// Schemas decoded from JSON, as plugin manifests are
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"city": map[string]interface{}{"type": "string", "description": "城市名称", "examples": []interface{}{"北京"}},
+			"days": map[string]interface{}{"type": "integer", "examples": []interface{}{float64(3)}},
+			"raw":  map[string]interface{}{},
+		},
+		"required": []interface{}{"city"},
+	}
+
+	want := `city（string，必填）城市名称，示例："北京"；days（integer），示例：3；raw（any）`
+	if got := describeParameters(schema); got != want {
+		t.Errorf("describeParameters() = %q, want %q", got, want)
+	}
+	if got := describeParameters(nil); got != "" {
+		t.Errorf("describeParameters(nil) = %q, want empty", got)
+	}
+}
+
+func TestCheckPlanActions(t *testing.T) {
+	unknownPlan := func() *types.TaskPlan {
+		return &types.TaskPlan{Steps: []types.TaskStep{
+			{Action: "open_app", Parameters: map[string]interface{}{"name": "微信"}},
+			{Action: "send_email", Parameters: map[string]interface{}{"to": "a@example.com"}},
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		plan       *types.TaskPlan
+		replies    []string
+		wantAction string // action of the last step
+		wantCalls  int
+	}{
+		{
+			name:       "known actions are kept",
+			plan:       &types.TaskPlan{Steps: []types.TaskStep{{Action: "write_article"}}},
+			wantAction: "write_article",
+		},
+		{
+			name:       "re-plan fixes unknown action",
+			plan:       unknownPlan(),
+			replies:    []string{`{"steps":[{"action":"open_app","parameters":{"name":"微信"}},{"action":"generate_text","parameters":{"topic":"笔记"}}]}`},
+			wantAction: "generate_text",
+			wantCalls:  1,
+		},
+		{
+			name:       "re-plan still unknown",
+			plan:       unknownPlan(),
+			replies:    []string{`{"steps":[{"action":"write_file","parameters":{}}]}`},
+			wantAction: "clarify",
+			wantCalls:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chat := &sequenceChat{replies: tt.replies}
+			w := newToolTestWorkflow(t, chat)
+			w.executor = executor.NewExecutor(chat)
+
+			plan := w.checkPlanActions(context.Background(), newTestContext(), tt.plan)
+
+			if got := plan.Steps[len(plan.Steps)-1].Action; got != tt.wantAction {
+				t.Errorf("Last step action = %s, want %s", got, tt.wantAction)
+			}
+			if len(chat.requests) != tt.wantCalls {
+				t.Fatalf("Made %d LLM calls, want %d", len(chat.requests), tt.wantCalls)
+			}
+			if tt.wantCalls > 0 {
+				feedback := chat.requests[0][len(chat.requests[0])-1].Content
+				if !strings.Contains(feedback, "send_email") {
+					t.Errorf("Re-plan request should name the unknown action, got %q", feedback)
+				}
+			}
+		})
+	}
+}
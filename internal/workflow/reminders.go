@@ -0,0 +1,60 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+	"github.com/deca/voicepilot-eino/internal/push"
+	"github.com/deca/voicepilot-eino/internal/scheduler"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// reminderDeliveryTimeout bounds speech synthesis and webhook delivery of a fired reminder
+const reminderDeliveryTimeout = 30 * time.Second
+
+// Subscribe returns a channel receiving events pushed to the session, such as fired reminders
+//
+// The returned function unsubscribes and closes the channel.
+func (w *VoiceWorkflow) Subscribe(sessionID string) (<-chan types.StreamEvent, func()) {
+	return w.push.Subscribe(sessionID)
+}
+
+// deliverReminder speaks a fired reminder and pushes it to the session's clients and the webhook
+func (w *VoiceWorkflow) deliverReminder(job scheduler.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), reminderDeliveryTimeout)
+	defer cancel()
+
+	event := types.StreamEvent{
+		Type:       "reminder",
+		SessionID:  job.SessionID,
+		Text:       fmt.Sprintf("提醒：%s", job.Text),
+		ReminderID: job.ID,
+	}
+
+	audioURL, err := w.tts.TTS(ctx, event.Text)
+	if err != nil {
+		log.Printf("TTS failed for reminder %s: %v, delivering text only", job.ID, err)
+	} else {
+		event.AudioURL = audioURL
+		event.AudioFormat = config.AppConfig.TTSEncoding
+	}
+
+	delivered := w.push.Publish(event)
+
+	if url := config.AppConfig.PushWebhookURL; url != "" {
+		if err := push.SendWebhook(ctx, url, event); err != nil {
+			log.Printf("Failed to post reminder %s to webhook: %v", job.ID, err)
+		} else {
+			delivered++
+		}
+	}
+
+	if delivered == 0 {
+		log.Printf("Reminder %s for session %s fired with no connected client or webhook", job.ID, job.SessionID)
+		return
+	}
+	log.Printf("Delivered reminder %s to session %s", job.ID, job.SessionID)
+}
@@ -0,0 +1,73 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+	"github.com/deca/voicepilot-eino/internal/push"
+	"github.com/deca/voicepilot-eino/internal/scheduler"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// fakeTTS returns a fixed audio URL, or err when set
+type fakeTTS struct{ err error }
+
+func (f fakeTTS) TTS(ctx context.Context, text string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	return "/static/audio/reminder.mp3", nil
+}
+
+func TestDeliverReminder(t *testing.T) {
+	webhook := make(chan types.StreamEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event types.StreamEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		webhook <- event
+	}))
+	defer server.Close()
+
+	config.AppConfig = &config.Config{TTSEncoding: "mp3", PushWebhookURL: server.URL}
+	defer func() { config.AppConfig = &config.Config{} }()
+
+	w := &VoiceWorkflow{tts: fakeTTS{}, push: push.NewHub()}
+	events, unsubscribe := w.Subscribe("s1")
+	defer unsubscribe()
+
+	w.deliverReminder(scheduler.Job{ID: "r1", SessionID: "s1", Text: "喝水", FireAt: time.Now()})
+
+	want := types.StreamEvent{
+		Type:        "reminder",
+		SessionID:   "s1",
+		Text:        "提醒：喝水",
+		AudioURL:    "/static/audio/reminder.mp3",
+		AudioFormat: "mp3",
+		ReminderID:  "r1",
+	}
+	select {
+	case event := <-events:
+		if event != want {
+			t.Errorf("Pushed %+v, want %+v", event, want)
+		}
+	default:
+		t.Error("Reminder was not pushed to the subscribed session")
+	}
+	if event := <-webhook; event != want {
+		t.Errorf("Webhook received %+v, want %+v", event, want)
+	}
+
+	// Without speech the text is still delivered
+	config.AppConfig.PushWebhookURL = ""
+	w.tts = fakeTTS{err: errors.New("tts down")}
+	w.deliverReminder(scheduler.Job{ID: "r2", SessionID: "s1", Text: "开会"})
+	if event := <-events; event.Text != "提醒：开会" || event.AudioURL != "" {
+		t.Errorf("Pushed %+v, want text only", event)
+	}
+}
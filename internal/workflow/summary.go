@@ -0,0 +1,67 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	ctxmanager "github.com/deca/voicepilot-eino/internal/context"
+	"github.com/deca/voicepilot-eino/internal/provider"
+)
+
+// summaryTimeout bounds a background summarization so a stuck model call is given up
+const summaryTimeout = time.Minute
+
+// summarizeTurns is the ctxmanager.Summarizer of the workflow, asking the chat model for the summary
+func (w *VoiceWorkflow) summarizeTurns(ctx context.Context, previous string, messages []ctxmanager.Message, maxTokens int) (string, error) {
+	systemPrompt := fmt.Sprintf(`你负责为语音助手压缩对话历史。把已有摘要和新的对话合并成一份新的摘要，供之后的对话参考。要求：
+1. 保留用户提到的人名、地点、时间、偏好和尚未完成的事项
+2. 保留助手已经执行过的操作及其结果
+3. 省略寒暄和重复内容
+4. 不超过 %d 字
+
+直接输出摘要文本，不要包含额外的格式或标记。`, maxTokens)
+
+	var transcript strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&transcript, "已有摘要：\n%s\n\n", previous)
+	}
+	transcript.WriteString("新的对话：\n")
+	for _, msg := range messages {
+		role := "用户"
+		if msg.Role == "assistant" {
+			role = "助手"
+		}
+		fmt.Fprintf(&transcript, "%s：%s\n", role, msg.Content)
+	}
+
+	summary, err := w.chat.ChatCompletion(ctx, []provider.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: transcript.String()},
+	})
+	if err != nil {
+		return "", err
+	}
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return "", fmt.Errorf("model returned an empty summary")
+	}
+	return summary, nil
+}
+
+// summarizeSession condenses the older turns of a long session, logging failures
+//
+// It runs after the response has been sent, so the summary is ready for the
+// next turn without delaying this one.
+func (w *VoiceWorkflow) summarizeSession(sessionID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+	defer cancel()
+
+	if err := w.contextManager.Summarize(ctx, sessionID); err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	log.Printf("Summarized earlier turns of session %s", sessionID)
+}
@@ -0,0 +1,57 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	ctxmanager "github.com/deca/voicepilot-eino/internal/context"
+)
+
+func TestSummarizeTurns(t *testing.T) {
+	chat := &sequenceChat{replies: []string{"  用户叫小李，想听周杰伦的歌。\n", "   "}}
+	w := &VoiceWorkflow{chat: chat}
+	messages := []ctxmanager.Message{
+		{Role: "user", Content: "我叫小李"},
+		{Role: "assistant", Content: "你好，小李"},
+	}
+
+	summary, err := w.summarizeTurns(context.Background(), "用户想听周杰伦的歌", messages, 200)
+	if err != nil {
+		t.Fatalf("summarizeTurns failed: %v", err)
+	}
+	if summary != "用户叫小李，想听周杰伦的歌。" {
+		t.Errorf("summary = %q", summary)
+	}
+	request := chat.requests[0]
+	if !strings.Contains(request[0].Content, "不超过 200 字") {
+		t.Errorf("System prompt lacks the budget: %q", request[0].Content)
+	}
+	if want := "已有摘要：\n用户想听周杰伦的歌\n\n新的对话：\n用户：我叫小李\n助手：你好，小李\n"; request[1].Content != want {
+		t.Errorf("Transcript = %q, want %q", request[1].Content, want)
+	}
+
+	if _, err := w.summarizeTurns(context.Background(), "", messages, 200); err == nil {
+		t.Error("summarizeTurns accepted an empty summary")
+	}
+}
+
+func TestSummarizeSession(t *testing.T) {
+	w := &VoiceWorkflow{
+		chat:           &sequenceChat{replies: []string{"用户叫小李"}},
+		contextManager: ctxmanager.NewContextManager(t.TempDir(), 10, time.Hour),
+	}
+	w.contextManager.EnableSummaries(w.summarizeTurns, ctxmanager.SummaryOptions{Threshold: 2, Keep: 2, MaxTokens: 100})
+	w.contextManager.AddInteraction("s1", "我叫小李", "unknown", "你好，小李")
+	w.contextManager.AddInteraction("s1", "几点了", "unknown", "下午三点")
+
+	w.summarizeSession("s1")
+	if summary := w.contextManager.GetSummary("s1"); summary != "用户叫小李" {
+		t.Errorf("Summary = %q", summary)
+	}
+	history := w.contextManager.BuildLLMContext("s1", 4)
+	if len(history) != 3 || history[0]["content"] != "此前对话的摘要：用户叫小李" {
+		t.Errorf("BuildLLMContext() = %v", history)
+	}
+}
@@ -0,0 +1,60 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// toolChat returns the chat provider for native tool calling, if enabled and supported
+func (w *VoiceWorkflow) toolChat() (provider.ToolCallingChatProvider, bool) {
+	if !w.toolCalling {
+		return nil, false
+	}
+	chat, ok := w.chat.(provider.ToolCallingChatProvider)
+	return chat, ok
+}
+
+// planWithTools offers tools, normally plannerTools, and turns the model's tool calls into a task plan
+func (w *VoiceWorkflow) planWithTools(ctx context.Context, chat provider.ToolCallingChatProvider, messages []provider.Message, tools []provider.Tool, toolChoice string) (*types.TaskPlan, error) {
+	calls, content, err := chat.ChatCompletionWithTools(ctx, messages, tools, toolChoice)
+	if err != nil {
+		return nil, err
+	}
+	if len(calls) == 0 {
+		return nil, fmt.Errorf("model returned no tool calls: %s", content)
+	}
+	return taskPlanFromToolCalls(calls)
+}
+
+// taskPlanFromToolCalls converts tool calls into task steps, one step per call in order
+func taskPlanFromToolCalls(calls []provider.ToolCall) (*types.TaskPlan, error) {
+	plan := &types.TaskPlan{}
+	for _, call := range calls {
+		params := make(map[string]interface{})
+		if args := strings.TrimSpace(call.Function.Arguments); args != "" {
+			if err := json.Unmarshal([]byte(args), &params); err != nil {
+				return nil, fmt.Errorf("invalid arguments for tool %s: %w", call.Function.Name, err)
+			}
+		}
+		plan.Steps = append(plan.Steps, types.TaskStep{
+			Action:     call.Function.Name,
+			Parameters: params,
+		})
+	}
+	return plan, nil
+}
+
+// intentFromPlan derives the intent from the first step of a tool-call plan
+func intentFromPlan(plan *types.TaskPlan) *types.Intent {
+	step := plan.Steps[0]
+	return &types.Intent{
+		Intent:     step.Action,
+		Parameters: step.Parameters,
+		Confidence: 1.0, // the model committed to a concrete action
+	}
+}
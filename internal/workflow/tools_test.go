@@ -0,0 +1,128 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/deca/voicepilot-eino/internal/config"
+	ctxmanager "github.com/deca/voicepilot-eino/internal/context"
+	"github.com/deca/voicepilot-eino/internal/executor"
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/internal/security"
+)
+
+// toolChat is a tool-calling ChatProvider with canned replies
+type toolChat struct {
+	calls    []provider.ToolCall
+	toolErr  error
+	reply    string
+	toolSeen []provider.Tool
+}
+
+func (f *toolChat) ChatCompletion(ctx context.Context, messages []provider.Message) (string, error) {
+	return f.reply, nil
+}
+
+func (f *toolChat) ChatCompletionWithTools(ctx context.Context, messages []provider.Message, tools []provider.Tool, toolChoice string) ([]provider.ToolCall, string, error) {
+	f.toolSeen = tools
+	return f.calls, "", f.toolErr
+}
+
+func newToolTestWorkflow(t *testing.T, chat provider.ChatProvider) *VoiceWorkflow {
+	config.AppConfig = &config.Config{EnableSafeMode: true}
+	return &VoiceWorkflow{
+		chat:           chat,
+		executor:       executor.NewExecutor(chat),
+		security:       security.NewSecurityManager(),
+		contextManager: ctxmanager.NewContextManager(t.TempDir(), 10, time.Hour),
+		toolCalling:    true,
+	}
+}
+
+func toolCall(name, args string) provider.ToolCall {
+	return provider.ToolCall{Type: "function", Function: provider.ToolCallFunction{Name: name, Arguments: args}}
+}
+
+func TestTaskPlanFromToolCalls(t *testing.T) {
+	plan, err := taskPlanFromToolCalls([]provider.ToolCall{
+		toolCall("open_app", `{"name":"微信"}`),
+		toolCall("clarify", ""),
+	})
+	if err != nil {
+		t.Fatalf("taskPlanFromToolCalls failed: %v", err)
+	}
+
+	if len(plan.Steps) != 2 {
+		t.Fatalf("Expected 2 steps, got %d", len(plan.Steps))
+	}
+	if plan.Steps[0].Action != "open_app" || plan.Steps[0].Parameters["name"] != "微信" {
+		t.Errorf("Unexpected first step: %+v", plan.Steps[0])
+	}
+	if plan.Steps[1].Parameters == nil {
+		t.Error("Expected empty parameters map for call without arguments")
+	}
+
+	if _, err := taskPlanFromToolCalls([]provider.ToolCall{toolCall("open_app", "{bad")}); err == nil {
+		t.Error("Expected error for malformed arguments")
+	}
+}
+
+func TestIntentNodeToolCalls(t *testing.T) {
+	chat := &toolChat{calls: []provider.ToolCall{toolCall("play_music", `{"song":"晴天"}`)}}
+	w := newToolTestWorkflow(t, chat)
+
+	wfCtx := newTestContext()
+	wfCtx.RecognizedText = "播放晴天"
+	if err := w.intentNode(context.Background(), wfCtx); err != nil {
+		t.Fatalf("intentNode failed: %v", err)
+	}
+
+	if wfCtx.Intent.Intent != "play_music" || needsClarification(wfCtx.Intent) {
+		t.Errorf("Expected confident play_music intent, got %+v", wfCtx.Intent)
+	}
+	if wfCtx.TaskPlan == nil || wfCtx.TaskPlan.Steps[0].Parameters["song"] != "晴天" {
+		t.Errorf("Expected task plan from tool calls, got %+v", wfCtx.TaskPlan)
+	}
+	if len(chat.toolSeen) == 0 {
+		t.Error("Expected executor actions to be offered as tools")
+	}
+
+	// The planner keeps the plan produced by tool calls
+	if err := w.plannerNode(context.Background(), wfCtx); err != nil {
+		t.Fatalf("plannerNode failed: %v", err)
+	}
+	if wfCtx.TaskPlan.Steps[0].Action != "play_music" {
+		t.Errorf("Expected planner to keep tool-call plan, got %+v", wfCtx.TaskPlan)
+	}
+}
+
+func TestIntentNodeFallsBackToPrompt(t *testing.T) {
+	tests := []struct {
+		name string
+		chat *toolChat
+	}{
+		{"tool error", &toolChat{toolErr: errors.New("tools not supported"), reply: `{"intent":"open_app","parameters":{"name":"微信"},"confidence":0.9}`}},
+		{"no tool calls", &toolChat{reply: `{"intent":"open_app","parameters":{"name":"微信"},"confidence":0.9}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newToolTestWorkflow(t, tt.chat)
+
+			wfCtx := newTestContext()
+			wfCtx.RecognizedText = "打开微信"
+			if err := w.intentNode(context.Background(), wfCtx); err != nil {
+				t.Fatalf("intentNode failed: %v", err)
+			}
+
+			if wfCtx.Intent.Intent != "open_app" {
+				t.Errorf("Expected open_app from JSON fallback, got %s", wfCtx.Intent.Intent)
+			}
+			if wfCtx.TaskPlan != nil {
+				t.Error("Expected no task plan before planning on the fallback path")
+			}
+		})
+	}
+}
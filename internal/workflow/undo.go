@@ -0,0 +1,31 @@
+package workflow
+
+import (
+	"strings"
+
+	"github.com/deca/voicepilot-eino/pkg/types"
+)
+
+// undoRequests are the utterances taken as "undo the last action" without asking the model
+var undoRequests = map[string]bool{
+	"撤销": true, "撤回": true, "撤销操作": true, "撤销上一步": true, "撤销上一个操作": true,
+	"撤销刚才的操作": true, "撤回刚才的操作": true, "撤销刚才": true, "撤回上一步": true,
+	"恢复原样": true, "取消刚才的操作": true, "undo": true,
+}
+
+// isUndoRequest reports whether the user asked to undo the last action
+func isUndoRequest(text string) bool {
+	request := strings.ToLower(strings.TrimSpace(text))
+	request = strings.TrimRight(request, " 。.！!，,？?~")
+	request = strings.TrimRight(request, "吧啊呀呢")
+	request = strings.TrimPrefix(request, "请")
+	request = strings.TrimPrefix(request, "帮我")
+	return undoRequests[request]
+}
+
+// undoPlan is the plan for an explicit undo request
+func undoPlan() (*types.Intent, *types.TaskPlan) {
+	intent := &types.Intent{Intent: "undo_last", Parameters: map[string]interface{}{}, Confidence: 1.0}
+	plan := &types.TaskPlan{Steps: []types.TaskStep{{Action: "undo_last", Parameters: map[string]interface{}{}}}}
+	return intent, plan
+}
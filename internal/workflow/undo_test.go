@@ -0,0 +1,53 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIsUndoRequest(t *testing.T) {
+	tests := map[string]bool{
+		"撤销":       true,
+		"撤销上一步。":   true,
+		"请撤销刚才的操作": true,
+		"帮我撤回吧":    true,
+		"Undo":     true,
+		"撤销提醒":     false,
+		"取消":       false,
+		"":         false,
+	}
+
+	for text, want := range tests {
+		if got := isUndoRequest(text); got != want {
+			t.Errorf("isUndoRequest(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestIntentNodeUndo(t *testing.T) {
+	chat := &toolChat{reply: `{"intent":"unknown","parameters":{},"confidence":0}`}
+	w := newToolTestWorkflow(t, chat)
+
+	wfCtx := newTestContext()
+	wfCtx.RecognizedText = "撤销上一步"
+	if err := w.intentNode(context.Background(), wfCtx); err != nil {
+		t.Fatalf("intentNode failed: %v", err)
+	}
+	if wfCtx.Intent.Intent != "undo_last" || wfCtx.TaskPlan.Steps[0].Action != "undo_last" {
+		t.Fatalf("Expected an undo plan, got %+v / %+v", wfCtx.Intent, wfCtx.TaskPlan)
+	}
+	if chat.toolSeen != nil {
+		t.Error("Undo request should not be sent to the model")
+	}
+
+	// The planner keeps the plan and the security manager allows it
+	if err := w.plannerNode(context.Background(), wfCtx); err != nil {
+		t.Fatalf("plannerNode failed: %v", err)
+	}
+	if err := w.securityNode(context.Background(), wfCtx); err != nil {
+		t.Fatalf("securityNode failed: %v", err)
+	}
+	if wfCtx.TaskPlan.Steps[0].Action != "undo_last" || wfCtx.Context["requires_confirmation"] == true {
+		t.Errorf("Undo plan changed by planner or security: %+v", wfCtx.TaskPlan)
+	}
+}
@@ -1,116 +1,340 @@
 package workflow
 
 import (
+	"bytes"
 	"context"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
 	"time"
 
-	ctxmanager "github.com/deca/voicepilot-eino/internal/context"
+	"github.com/deca/voicepilot-eino/internal/calendar"
 	"github.com/deca/voicepilot-eino/internal/config"
+	ctxmanager "github.com/deca/voicepilot-eino/internal/context"
 	"github.com/deca/voicepilot-eino/internal/executor"
-	"github.com/deca/voicepilot-eino/internal/qiniu"
+	"github.com/deca/voicepilot-eino/internal/memory"
+	"github.com/deca/voicepilot-eino/internal/plugin"
+	"github.com/deca/voicepilot-eino/internal/provider"
+	"github.com/deca/voicepilot-eino/internal/push"
+	"github.com/deca/voicepilot-eino/internal/sandbox"
+	"github.com/deca/voicepilot-eino/internal/scheduler"
 	"github.com/deca/voicepilot-eino/internal/security"
+	"github.com/deca/voicepilot-eino/internal/telemetry"
+	"github.com/deca/voicepilot-eino/internal/trace"
 	"github.com/deca/voicepilot-eino/pkg/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+//go:embed default_graph.yaml
+var defaultGraphYAML []byte
+
+// Workflow entry points
+const (
+	EntryVoice = "voice"
+	EntryText  = "text"
+)
+
+// Workflow events emitted to an EventFunc as nodes complete
+const (
+	EventIntent   = "intent"
+	EventResponse = "response"
 )
 
+// EventFunc receives intermediate results while a workflow is running
+type EventFunc func(event string, wfCtx *types.WorkflowContext)
+
+// RunOption customizes a single workflow run
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	onEvent  EventFunc
+	onToken  provider.TokenSink
+	textOnly bool
+	userID   string
+}
+
+// WithEvents reports intermediate results to fn as nodes complete
+func WithEvents(fn EventFunc) RunOption {
+	return func(o *runOptions) { o.onEvent = fn }
+}
+
+// WithTokens streams generated text (response and generate_text output) to sink as it is produced
+func WithTokens(sink provider.TokenSink) RunOption {
+	return func(o *runOptions) { o.onToken = sink }
+}
+
+// WithTextOnly marks the client as text-only so speech synthesis is skipped
+func WithTextOnly() RunOption {
+	return func(o *runOptions) { o.textOnly = true }
+}
+
+// WithUserID records the user the session belongs to; an empty ID is ignored
+func WithUserID(userID string) RunOption {
+	return func(o *runOptions) { o.userID = userID }
+}
+
+// policyReloadInterval is how often the security policy file is checked for changes
+const policyReloadInterval = 5 * time.Second
+
 // VoiceWorkflow represents the complete voice interaction workflow
 type VoiceWorkflow struct {
-	qiniuClient    *qiniu.Client
+	asr            provider.ASRProvider
+	tts            provider.TTSProvider
+	chat           provider.ChatProvider
 	executor       *executor.Executor
 	security       *security.SecurityManager
 	contextManager *ctxmanager.ContextManager
+	push           *push.Hub
+	graph          *Graph
+	traces         *trace.Store
+	traceRetention time.Duration // 0 keeps traces forever
+	memory         *memory.Store // nil when long-term memory is disabled
+	recallLimit    int
+	toolCalling    bool
+	reprompt       bool
 }
 
-// NewVoiceWorkflow creates a new voice workflow
-func NewVoiceWorkflow() *VoiceWorkflow {
+// NewVoiceWorkflow creates a new voice workflow backed by the given providers
+//
+// The node wiring is read from config.AppConfig.WorkflowGraphPath when set,
+// otherwise the built-in default graph is used.
+func NewVoiceWorkflow(providers *provider.Providers) (*VoiceWorkflow, error) {
 	// Create context manager with configuration
 	sessionExpiry := time.Duration(config.AppConfig.SessionExpiryHours) * time.Hour
 
-	return &VoiceWorkflow{
-		qiniuClient: qiniu.NewClient(),
-		executor:    executor.NewExecutor(),
-		security:    security.NewSecurityManager(),
-		contextManager: ctxmanager.NewContextManager(
-			config.AppConfig.SessionStoragePath,
+	// Record every LLM call, including those made by executor actions, in the run's trace
+	chat := trace.WrapChat(providers.Chat)
+
+	securityManager := security.NewSecurityManager()
+	if path := config.AppConfig.SecurityPolicyPath; path != "" {
+		if err := securityManager.LoadPolicy(path); err != nil {
+			return nil, err
+		}
+		securityManager.WatchPolicy(path, policyReloadInterval)
+	}
+
+	reminders, err := scheduler.New(config.AppConfig.RemindersStorePath)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := newCalendar()
+	if err != nil {
+		return nil, err
+	}
+	defaultPolicy, policies, err := newPolicies()
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := ctxmanager.OpenStore(config.AppConfig.SessionStore, ctxmanager.StoreOptions{
+		Dir:      config.AppConfig.SessionStoragePath,
+		DBPath:   config.AppConfig.SessionDBPath,
+		RedisURL: config.AppConfig.RedisURL,
+		Expiry:   time.Duration(config.AppConfig.SessionExpiryHours) * time.Hour,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	w := &VoiceWorkflow{
+		asr:  providers.ASR,
+		tts:  providers.TTS,
+		chat: chat,
+		executor: executor.NewExecutor(chat,
+			executor.WithSandbox(newSandbox()),
+			executor.WithWorkspace(config.AppConfig.WorkspaceRoot),
+			executor.WithScheduler(reminders),
+			executor.WithCalendar(cal),
+			executor.WithPolicies(defaultPolicy, policies),
+			executor.WithParamCheck(resolvedParamCheck(securityManager)),
+		),
+		security: securityManager,
+		contextManager: ctxmanager.NewContextManagerWithStore(
+			sessions,
 			config.AppConfig.SessionMaxHistory,
 			sessionExpiry,
 		),
+		push:           push.NewHub(),
+		traces:         trace.NewStore(filepath.Join(config.AppConfig.SessionStoragePath, "traces")),
+		traceRetention: time.Duration(config.AppConfig.TraceRetentionDays) * 24 * time.Hour,
+		toolCalling:    config.AppConfig.LLMToolCalling,
+		reprompt:       config.AppConfig.LLMJSONReprompt,
 	}
-}
 
-// Execute executes the complete voice interaction workflow
-func (w *VoiceWorkflow) Execute(ctx context.Context, audioPath, sessionID string) (*types.VoiceResponse, error) {
-	log.Printf("Starting workflow execution for session: %s", sessionID)
+	w.contextManager.OnSessionEnd(w.executor.ForgetSession)
 
-	// Create workflow context
-	wfCtx := &types.WorkflowContext{
-		SessionID: sessionID,
-		AudioPath: audioPath,
-		Context:   make(map[string]interface{}),
+	if config.AppConfig.MemoryEnabled {
+		if w.memory, err = memory.NewStore(config.AppConfig.MemoryStoragePath, config.AppConfig.MemoryMaxFacts); err != nil {
+			return nil, err
+		}
+		w.recallLimit = config.AppConfig.MemoryRecallLimit
 	}
 
-	// Step 1: ASR Node - Speech to Text
-	if err := w.asrNode(ctx, wfCtx); err != nil {
-		return nil, fmt.Errorf("ASR node failed: %w", err)
+	if threshold := config.AppConfig.SessionSummaryThreshold; threshold > 0 {
+		w.contextManager.EnableSummaries(w.summarizeTurns, ctxmanager.SummaryOptions{
+			Threshold: threshold,
+			Keep:      config.AppConfig.SessionSummaryKeep,
+			MaxTokens: config.AppConfig.SessionSummaryMaxTokens,
+		})
 	}
 
-	// Step 2: Intent Recognition Node - Parse intent from text
-	if err := w.intentNode(ctx, wfCtx); err != nil {
-		return nil, fmt.Errorf("Intent node failed: %w", err)
+	if _, err := plugin.Install(config.AppConfig.PluginsDir, w.executor, w.security); err != nil {
+		return nil, err
 	}
 
-	// Step 3: Planner Node - Create task plan
-	if err := w.plannerNode(ctx, wfCtx); err != nil {
-		return nil, fmt.Errorf("Planner node failed: %w", err)
+	var def *GraphDefinition
+	if path := config.AppConfig.WorkflowGraphPath; path != "" {
+		log.Printf("Loading workflow graph from %s", path)
+		def, err = LoadGraphDefinition(path)
+	} else {
+		def, err = ParseGraphDefinition(defaultGraphYAML, ".yaml")
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// Step 4: Security Check Node - Validate task safety
-	if err := w.securityNode(ctx, wfCtx); err != nil {
-		return nil, fmt.Errorf("Security node failed: %w", err)
+	w.graph, err = NewGraph(def, w.nodes(), conditions, EntryVoice, EntryText, EntryConfirm)
+	if err != nil {
+		return nil, fmt.Errorf("invalid workflow graph: %w", err)
 	}
 
-	// Step 5: Executor Node - Execute the task
-	if err := w.executorNode(ctx, wfCtx); err != nil {
-		return nil, fmt.Errorf("Executor node failed: %w", err)
+	reminders.Start(w.deliverReminder)
+
+	return w, nil
+}
+
+// errUnconfirmedReference rejects steps whose parameters refer to other steps but need the user's confirmation
+//
+// The confirmation prompt could only show the reference, not what would actually run.
+var errUnconfirmedReference = errors.New("需要确认的操作不能使用其他步骤的结果")
+
+// resolvedParamCheck checks a step again once references to other steps' results are resolved
+//
+// A step that needs confirmation only with the resolved values fails instead
+// of running: the user never saw, let alone confirmed, those values.
+func resolvedParamCheck(sm *security.SecurityManager) executor.ParamCheck {
+	return func(action string, params map[string]interface{}) error {
+		decision, err := sm.ValidateAction(action, params)
+		if err != nil {
+			return err
+		}
+		if decision.Outcome == security.OutcomeConfirm {
+			return errUnconfirmedReference
+		}
+		return nil
 	}
+}
+
+// newSandbox creates the execute_command sandbox from the configuration
+func newSandbox() *sandbox.Runner {
+	timeout := config.AppConfig.SandboxTimeoutSeconds
+	return sandbox.New(sandbox.Config{
+		Mode:           config.AppConfig.SandboxMode,
+		Timeout:        time.Duration(timeout) * time.Second,
+		MaxOutputBytes: config.AppConfig.SandboxMaxOutputBytes,
+		WorkDir:        config.AppConfig.SandboxWorkDir,
+		MemoryMB:       config.AppConfig.SandboxMemoryMB,
+		CPUSeconds:     timeout,
+	})
+}
 
-	// Step 6: Response Generation Node - Generate response text
-	if err := w.responseNode(ctx, wfCtx); err != nil {
-		return nil, fmt.Errorf("Response node failed: %w", err)
+// newCalendar creates the calendar from the configuration, on CalDAV when a URL is set and on a local file otherwise
+func newCalendar() (*calendar.Calendar, error) {
+	loc := time.Local
+	if name := config.AppConfig.CalendarTimezone; name != "" {
+		var err error
+		if loc, err = time.LoadLocation(name); err != nil {
+			return nil, fmt.Errorf("invalid calendar time zone %q: %w", name, err)
+		}
 	}
 
-	// Step 7: TTS Node - Convert response to speech
-	if err := w.ttsNode(ctx, wfCtx); err != nil {
-		return nil, fmt.Errorf("TTS node failed: %w", err)
+	if u := config.AppConfig.CalDAVURL; u != "" {
+		store, err := calendar.NewCalDAVStore(u, config.AppConfig.CalDAVUsername, config.AppConfig.CalDAVPassword, loc)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using CalDAV calendar at %s", u)
+		return calendar.New(store, loc), nil
 	}
+	return calendar.New(calendar.NewFileStore(config.AppConfig.CalendarPath, loc), loc), nil
+}
 
-	// Build final response
-	response := &types.VoiceResponse{
-		RecognizedText: wfCtx.RecognizedText, // ASR识别的用户语音
-		Text:           wfCtx.ResponseText,   // 系统响应
-		AudioURL:       wfCtx.ResponseAudio,  // TTS音频
-		SessionID:      sessionID,
-		Success:        true,
+// newPolicies returns the default action timeout and retry policy and the per-action overrides from the configuration
+func newPolicies() (executor.Policy, map[string]executor.Policy, error) {
+	defaults := executor.Policy{
+		Timeout:    time.Duration(config.AppConfig.ActionTimeoutSeconds) * time.Second,
+		MaxRetries: config.AppConfig.ActionMaxRetries,
+		Backoff:    time.Duration(config.AppConfig.ActionRetryBackoffMs) * time.Millisecond,
+	}
+	path := config.AppConfig.ActionPolicyPath
+	if path == "" {
+		return defaults, nil, nil
+	}
+	policies, err := executor.LoadPolicies(path, defaults)
+	if err != nil {
+		return defaults, nil, err
 	}
+	log.Printf("Loaded action policies from %s (%d actions)", path, len(policies))
+	return defaults, policies, nil
+}
 
-	// Save conversation to context manager
-	intentStr := ""
-	if wfCtx.Intent != nil {
-		intentStr = wfCtx.Intent.Intent
+// nodes returns the workflow nodes available to the graph definition
+func (w *VoiceWorkflow) nodes() []Node {
+	return []Node{
+		NewNode("asr", w.asrNode),
+		NewNode("resume", w.resumeNode),
+		NewNode("intent", w.intentNode),
+		NewNode("clarify", w.clarifyNode),
+		NewNode("planner", w.plannerNode),
+		NewNode("security", w.securityNode),
+		NewNode("await_confirmation", w.awaitConfirmationNode),
+		NewNode("executor", w.executorNode),
+		NewNode("response", w.responseNode),
+		NewNode("tts", w.ttsNode),
 	}
-	if err := w.contextManager.AddInteraction(sessionID, wfCtx.RecognizedText, intentStr, wfCtx.ResponseText); err != nil {
-		log.Printf("Warning: failed to save conversation to context: %v", err)
-		// Don't fail the workflow if context saving fails
+}
+
+// conditions are the edge conditions a graph definition may refer to
+var conditions = map[string]Condition{
+	"needs_clarification": func(wfCtx *types.WorkflowContext) bool {
+		return needsClarification(wfCtx.Intent)
+	},
+	"text_only": func(wfCtx *types.WorkflowContext) bool {
+		textOnly, _ := wfCtx.Context["text_only"].(bool)
+		return textOnly
+	},
+	"resumed": func(wfCtx *types.WorkflowContext) bool {
+		return wfCtx.TaskPlan != nil
+	},
+	"requires_confirmation": func(wfCtx *types.WorkflowContext) bool {
+		required, _ := wfCtx.Context["requires_confirmation"].(bool)
+		return required
+	},
+}
+
+// needsClarification reports whether the intent is too uncertain to plan for
+func needsClarification(intent *types.Intent) bool {
+	return intent == nil || intent.Intent == "unknown" || intent.Confidence < 0.5
+}
+
+// Execute executes the complete voice interaction workflow
+func (w *VoiceWorkflow) Execute(ctx context.Context, audioPath, sessionID string, opts ...RunOption) (*types.VoiceResponse, error) {
+	log.Printf("Starting workflow execution for session: %s", sessionID)
+
+	// Create workflow context
+	wfCtx := &types.WorkflowContext{
+		SessionID: sessionID,
+		AudioPath: audioPath,
+		Context:   make(map[string]interface{}),
 	}
 
-	log.Printf("Workflow execution completed successfully for session: %s", sessionID)
-	return response, nil
+	return w.run(ctx, EntryVoice, wfCtx, opts)
 }
 
 // ExecuteText executes text-based interaction workflow (skip ASR)
-func (w *VoiceWorkflow) ExecuteText(ctx context.Context, text, sessionID string) (*types.VoiceResponse, error) {
+func (w *VoiceWorkflow) ExecuteText(ctx context.Context, text, sessionID string, opts ...RunOption) (*types.VoiceResponse, error) {
 	log.Printf("Starting text workflow execution for session: %s", sessionID)
 
 	// Create workflow context with pre-filled text
@@ -120,43 +344,67 @@ func (w *VoiceWorkflow) ExecuteText(ctx context.Context, text, sessionID string)
 		Context:        make(map[string]interface{}),
 	}
 
-	// Skip ASR, start from Intent Recognition
-	if err := w.intentNode(ctx, wfCtx); err != nil {
-		return nil, fmt.Errorf("Intent node failed: %w", err)
-	}
+	return w.run(ctx, EntryText, wfCtx, opts)
+}
 
-	// Planner Node
-	if err := w.plannerNode(ctx, wfCtx); err != nil {
-		return nil, fmt.Errorf("Planner node failed: %w", err)
+// run executes the graph from an entry point and records the interaction in the session
+//
+// Every run is traced. When the run fails, the returned response is still
+// non-nil and carries the trace ID alongside the error.
+func (w *VoiceWorkflow) run(ctx context.Context, entry string, wfCtx *types.WorkflowContext, opts []RunOption) (*types.VoiceResponse, error) {
+	var options runOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	wfCtx.Context["text_only"] = options.textOnly
+	if options.userID != "" {
+		wfCtx.UserID = options.userID
+		if err := w.contextManager.SetUserID(wfCtx.SessionID, options.userID); err != nil {
+			log.Printf("Warning: failed to record user of session %s: %v", wfCtx.SessionID, err)
+		}
 	}
-
-	// Security Check Node
-	if err := w.securityNode(ctx, wfCtx); err != nil {
-		return nil, fmt.Errorf("Security node failed: %w", err)
+	if options.onToken != nil {
+		ctx = provider.WithTokenSink(ctx, options.onToken)
 	}
 
-	// Executor Node
-	if err := w.executorNode(ctx, wfCtx); err != nil {
-		return nil, fmt.Errorf("Executor node failed: %w", err)
-	}
+	ctx, span := telemetry.StartSpan(ctx, "workflow.run",
+		attribute.String("workflow.entry", entry),
+		attribute.String("session.id", wfCtx.SessionID),
+	)
 
-	// Response Generation Node
-	if err := w.responseNode(ctx, wfCtx); err != nil {
-		return nil, fmt.Errorf("Response node failed: %w", err)
+	runTrace := trace.New(wfCtx.SessionID, entry)
+	span.SetAttributes(attribute.String("workflow.trace_id", runTrace.ID))
+	middleware := []Middleware{telemetryMiddleware, traceMiddleware(runTrace)}
+	if options.onEvent != nil {
+		middleware = append(middleware, eventMiddleware(options.onEvent))
 	}
 
-	// TTS Node
-	if err := w.ttsNode(ctx, wfCtx); err != nil {
-		return nil, fmt.Errorf("TTS node failed: %w", err)
+	err := w.graph.Run(ctx, entry, wfCtx, middleware...)
+	telemetry.EndSpan(span, err)
+	runTrace.Finish(err)
+	if saveErr := w.traces.Save(runTrace); saveErr != nil {
+		log.Printf("Warning: failed to save trace %s: %v", runTrace.ID, saveErr)
+	}
+	if err != nil {
+		return &types.VoiceResponse{
+			RecognizedText: wfCtx.RecognizedText,
+			SessionID:      wfCtx.SessionID,
+			TraceID:        runTrace.ID,
+			Success:        false,
+			Error:          err.Error(),
+		}, err
 	}
 
 	// Build final response
+	awaiting, _ := wfCtx.Context["requires_confirmation"].(bool)
 	response := &types.VoiceResponse{
-		RecognizedText: wfCtx.RecognizedText, // 用户输入的文本
-		Text:           wfCtx.ResponseText,   // 系统响应
-		AudioURL:       wfCtx.ResponseAudio,  // TTS音频
-		SessionID:      sessionID,
-		Success:        true,
+		RecognizedText:       wfCtx.RecognizedText, // 用户语音识别结果或输入文本
+		Text:                 wfCtx.ResponseText,   // 系统响应
+		AudioURL:             wfCtx.ResponseAudio,  // TTS音频
+		SessionID:            wfCtx.SessionID,
+		TraceID:              runTrace.ID,
+		ConfirmationRequired: awaiting,
+		Success:              true,
 	}
 
 	// Save conversation to context manager
@@ -164,20 +412,112 @@ func (w *VoiceWorkflow) ExecuteText(ctx context.Context, text, sessionID string)
 	if wfCtx.Intent != nil {
 		intentStr = wfCtx.Intent.Intent
 	}
-	if err := w.contextManager.AddInteraction(sessionID, wfCtx.RecognizedText, intentStr, wfCtx.ResponseText); err != nil {
+	if err := w.contextManager.AddInteraction(wfCtx.SessionID, wfCtx.RecognizedText, intentStr, wfCtx.ResponseText); err != nil {
 		log.Printf("Warning: failed to save conversation to context: %v", err)
 		// Don't fail the workflow if context saving fails
 	}
+	if w.contextManager.NeedsSummary(wfCtx.SessionID) {
+		go w.summarizeSession(wfCtx.SessionID)
+	}
+	if w.memory != nil && wfCtx.UserID != "" {
+		go w.rememberFacts(wfCtx.UserID, wfCtx.SessionID, wfCtx.RecognizedText, wfCtx.ResponseText)
+	}
 
-	log.Printf("Text workflow execution completed successfully for session: %s", sessionID)
+	log.Printf("Workflow execution completed successfully for session: %s", wfCtx.SessionID)
 	return response, nil
 }
 
+// eventMiddleware reports intent and response results as their nodes complete
+func eventMiddleware(onEvent EventFunc) Middleware {
+	events := map[string]string{
+		"intent":   EventIntent,
+		"response": EventResponse,
+	}
+
+	return func(node string, next RunFunc) RunFunc {
+		event, ok := events[node]
+		if !ok {
+			return next
+		}
+		return func(ctx context.Context, wfCtx *types.WorkflowContext) error {
+			if err := next(ctx, wfCtx); err != nil {
+				return err
+			}
+			onEvent(event, wfCtx)
+			return nil
+		}
+	}
+}
+
+// telemetryMiddleware wraps each node in an OpenTelemetry span and records its latency
+func telemetryMiddleware(node string, next RunFunc) RunFunc {
+	return func(ctx context.Context, wfCtx *types.WorkflowContext) error {
+		start := time.Now()
+		ctx, span := telemetry.StartSpan(ctx, "workflow.node."+node, attribute.String("workflow.node", node))
+
+		err := next(ctx, wfCtx)
+
+		telemetry.EndSpan(span, err)
+		telemetry.ObserveNode(node, time.Since(start), err)
+		return err
+	}
+}
+
+// traceMiddleware records each node's timing, model calls and the workflow state it produced
+func traceMiddleware(t *trace.Trace) Middleware {
+	return func(node string, next RunFunc) RunFunc {
+		return func(ctx context.Context, wfCtx *types.WorkflowContext) error {
+			span := t.StartNode(node)
+			before := snapshot(wfCtx)
+
+			err := next(trace.WithSpan(ctx, span), wfCtx)
+
+			after := snapshot(wfCtx)
+			for key, value := range after {
+				if !bytes.Equal(before[key], value) {
+					span.Set(key, value)
+				}
+			}
+			span.End(err)
+			return err
+		}
+	}
+}
+
+// untracedContext are the entries of the Context map that are never recorded in traces
+var untracedContext = map[string]bool{
+	"user_memory": true, // facts about the user, which may be deleted on request
+}
+
+// snapshot flattens the workflow context into JSON values keyed by field name
+//
+// Entries of the free-form Context map are keyed as "context.<key>" so that a
+// node's additions can be told apart from the rest of the map.
+func snapshot(wfCtx *types.WorkflowContext) map[string]json.RawMessage {
+	fields := make(map[string]json.RawMessage)
+	data, err := json.Marshal(wfCtx)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+
+	var extra map[string]json.RawMessage
+	if err := json.Unmarshal(fields["context"], &extra); err == nil {
+		for key, value := range extra {
+			if !untracedContext[key] {
+				fields["context."+key] = value
+			}
+		}
+	}
+	delete(fields, "context")
+	return fields
+}
+
 // asrNode performs speech-to-text conversion
 func (w *VoiceWorkflow) asrNode(ctx context.Context, wfCtx *types.WorkflowContext) error {
 	log.Printf("ASR Node: Processing audio file")
 
-	text, err := w.qiniuClient.ASR(ctx, wfCtx.AudioPath)
+	text, err := w.asr.ASR(ctx, wfCtx.AudioPath)
 	if err != nil {
 		return fmt.Errorf("ASR failed: %w", err)
 	}
@@ -188,9 +528,34 @@ func (w *VoiceWorkflow) asrNode(ctx context.Context, wfCtx *types.WorkflowContex
 }
 
 // intentNode performs intent recognition using LLM
+//
+// With native tool calling the model picks the actions directly, which yields
+// both the intent and the task plan; otherwise the intent is parsed from JSON.
 func (w *VoiceWorkflow) intentNode(ctx context.Context, wfCtx *types.WorkflowContext) error {
 	log.Printf("Intent Node: Parsing intent from text")
 
+	// "撤销" always means the last action, whatever the model would make of it
+	if isUndoRequest(wfCtx.RecognizedText) {
+		wfCtx.Intent, wfCtx.TaskPlan = undoPlan()
+		log.Printf("Intent Node: Recognized undo request")
+		return nil
+	}
+
+	if chat, ok := w.toolChat(); ok {
+		systemPrompt := `你是一个语音助手。请根据用户的语音输入调用合适的工具来完成任务，需要多个步骤时按执行顺序调用多个工具。
+如果无法确定用户的意图，请调用 clarify 工具向用户提问。`
+
+		tools := w.plannerTools()
+		plan, err := w.planWithTools(ctx, chat, w.intentMessages(ctx, wfCtx, systemPrompt, tools), tools, provider.ToolChoiceAuto)
+		if err == nil {
+			wfCtx.Intent = intentFromPlan(plan)
+			wfCtx.TaskPlan = plan
+			log.Printf("Intent Node: Recognized intent via tool calls: %s (%d steps)", wfCtx.Intent.Intent, len(plan.Steps))
+			return nil
+		}
+		log.Printf("Tool calling failed: %v, falling back to JSON prompt", err)
+	}
+
 	systemPrompt := `你是一个语音助手的意图识别模块。请分析用户的语音输入，并将其转换为结构化的意图JSON格式。
 
 输出格式：
@@ -209,34 +574,15 @@ func (w *VoiceWorkflow) intentNode(ctx context.Context, wfCtx *types.WorkflowCon
 
 只输出JSON，不要输出其他内容。`
 
-	// Build messages with conversation history for better context understanding
-	messages := []qiniu.Message{
-		{Role: "system", Content: systemPrompt},
-	}
-
-	// Add conversation history (last 4 messages = 2 interactions)
-	historyContext := w.contextManager.BuildLLMContext(wfCtx.SessionID, 4)
-	for _, msg := range historyContext {
-		messages = append(messages, qiniu.Message{
-			Role:    msg["role"],
-			Content: msg["content"],
-		})
-	}
-
-	// Add current user input
-	messages = append(messages, qiniu.Message{
-		Role:    "user",
-		Content: wfCtx.RecognizedText,
-	})
-
-	response, err := w.qiniuClient.ChatCompletion(ctx, messages)
+	messages := w.intentMessages(ctx, wfCtx, systemPrompt, nil)
+	response, err := w.chat.ChatCompletion(ctx, messages)
 	if err != nil {
 		return fmt.Errorf("intent recognition failed: %w", err)
 	}
 
 	// Parse intent JSON
 	var intent types.Intent
-	if err := json.Unmarshal([]byte(response), &intent); err != nil {
+	if err := w.decodeLLMJSON(ctx, wfCtx, "intent", messages, response, &intent); err != nil {
 		log.Printf("Failed to parse intent JSON: %v, raw response: %s", err, response)
 		// Fallback: treat as unknown intent
 		intent = types.Intent{
@@ -251,62 +597,104 @@ func (w *VoiceWorkflow) intentNode(ctx context.Context, wfCtx *types.WorkflowCon
 	return nil
 }
 
+// intentMessages builds the intent recognition messages with as much recent conversation history as the model has room for
+//
+// tools are the tool definitions the messages will be sent with, if any; they take up room in the window as well.
+func (w *VoiceWorkflow) intentMessages(ctx context.Context, wfCtx *types.WorkflowContext, systemPrompt string, tools []provider.Tool) []provider.Message {
+	// Build messages with conversation history for better context understanding
+	messages := []provider.Message{
+		{Role: "system", Content: systemPrompt},
+	}
+	userMemory := w.userMemory(ctx, wfCtx)
+	if userMemory != "" {
+		messages = append(messages, provider.Message{Role: "system", Content: userMemory})
+	}
+
+	// Add conversation history, newest first, in what the window leaves after the prompts and the completion
+	budget := newContextBudget(wfCtx.RecognizedText, systemPrompt, userMemory).withTools(tools)
+	historyContext, usage := w.contextManager.BuildLLMContextWithin(wfCtx.SessionID, budget.History())
+	trace.Annotate(ctx, "context_tokens", budget.report(usage))
+	for _, msg := range historyContext {
+		messages = append(messages, provider.Message{
+			Role:    msg["role"],
+			Content: msg["content"],
+		})
+	}
+
+	// Add current user input
+	return append(messages, provider.Message{
+		Role:    "user",
+		Content: wfCtx.RecognizedText,
+	})
+}
+
+// clarifyNode plans a clarification question instead of a task
+func (w *VoiceWorkflow) clarifyNode(ctx context.Context, wfCtx *types.WorkflowContext) error {
+	log.Printf("Clarify Node: Asking user to rephrase")
+
+	wfCtx.TaskPlan = &types.TaskPlan{
+		Steps: []types.TaskStep{
+			{
+				Action: "clarify",
+				Parameters: map[string]interface{}{
+					"message": "抱歉，我没有理解您的意思，能否请您再说一遍？",
+				},
+			},
+		},
+	}
+	return nil
+}
+
 // plannerNode creates a task execution plan
+//
+// Only actions the executor has registered and the security manager permits
+// are offered to the model. A plan using unknown actions is re-planned once.
 func (w *VoiceWorkflow) plannerNode(ctx context.Context, wfCtx *types.WorkflowContext) error {
 	log.Printf("Planner Node: Creating task plan")
 
 	// If intent is unknown or confidence is low, ask for clarification
-	if wfCtx.Intent.Intent == "unknown" || wfCtx.Intent.Confidence < 0.5 {
-		wfCtx.TaskPlan = &types.TaskPlan{
-			Steps: []types.TaskStep{
-				{
-					Action: "clarify",
-					Parameters: map[string]interface{}{
-						"message": "抱歉，我没有理解您的意思，能否请您再说一遍？",
-					},
-				},
-			},
-		}
-		return nil
+	if needsClarification(wfCtx.Intent) {
+		return w.clarifyNode(ctx, wfCtx)
 	}
 
-	// Use LLM to create a detailed task plan
-	systemPrompt := `你是一个任务规划模块。根据用户的意图，生成详细的执行计划。
-
-输出格式：
-{
-  "steps": [
-    {"action": "动作类型", "parameters": {"参数名": "参数值"}},
-    ...
-  ]
-}
+	// The intent node already planned, via tool calls or for an undo request
+	if wfCtx.TaskPlan != nil {
+		log.Printf("Planner Node: Using plan from intent recognition with %d steps", len(wfCtx.TaskPlan.Steps))
+		wfCtx.TaskPlan = w.checkPlanActions(ctx, wfCtx, wfCtx.TaskPlan)
+		return nil
+	}
 
-支持的动作类型：
-- execute_command: 执行系统命令
-- open_app: 打开应用程序
-- play_music: 播放音乐
-- generate_text: 生成文本
-- save_file: 保存文件
-- clarify: 请求用户澄清
+	userPrompt := plannerUserPrompt(wfCtx)
 
-只输出JSON，不要输出其他内容。`
+	if chat, ok := w.toolChat(); ok {
+		messages := []provider.Message{
+			{Role: "system", Content: "你是一个任务规划模块。根据用户的意图调用合适的工具，需要多个步骤时按执行顺序调用多个工具。"},
+			{Role: "user", Content: userPrompt},
+		}
 
-	intentJSON, _ := json.Marshal(wfCtx.Intent)
-	userPrompt := fmt.Sprintf("用户意图：%s\n用户原始输入：%s", string(intentJSON), wfCtx.RecognizedText)
+		plan, err := w.planWithTools(ctx, chat, messages, w.plannerTools(), provider.ToolChoiceRequired)
+		if err == nil {
+			log.Printf("Planner Node: Created plan with %d steps via tool calls", len(plan.Steps))
+			wfCtx.TaskPlan = w.checkPlanActions(ctx, wfCtx, plan)
+			return nil
+		}
+		log.Printf("Tool calling failed: %v, falling back to JSON prompt", err)
+	}
 
-	messages := []qiniu.Message{
-		{Role: "system", Content: systemPrompt},
+	// Use LLM to create a detailed task plan
+	messages := []provider.Message{
+		{Role: "system", Content: plannerPrompt(w.plannerActions())},
 		{Role: "user", Content: userPrompt},
 	}
 
-	response, err := w.qiniuClient.ChatCompletion(ctx, messages)
+	response, err := w.chat.ChatCompletion(ctx, messages)
 	if err != nil {
 		return fmt.Errorf("task planning failed: %w", err)
 	}
 
 	// Parse task plan JSON
 	var taskPlan types.TaskPlan
-	if err := json.Unmarshal([]byte(response), &taskPlan); err != nil {
+	if err := w.decodeLLMJSON(ctx, wfCtx, "planner", messages, response, &taskPlan); err != nil {
 		log.Printf("Failed to parse task plan JSON: %v, raw response: %s", err, response)
 		// Fallback: single step execution
 		taskPlan = types.TaskPlan{
@@ -319,17 +707,41 @@ func (w *VoiceWorkflow) plannerNode(ctx context.Context, wfCtx *types.WorkflowCo
 		}
 	}
 
-	wfCtx.TaskPlan = &taskPlan
 	log.Printf("Planner Node: Created plan with %d steps", len(taskPlan.Steps))
+	wfCtx.TaskPlan = w.checkPlanActions(ctx, wfCtx, &taskPlan)
 	return nil
 }
 
 // securityNode validates task safety
+//
+// Plans with actions that need the user's consent are flagged for
+// confirmation unless the user already confirmed them.
 func (w *VoiceWorkflow) securityNode(ctx context.Context, wfCtx *types.WorkflowContext) error {
 	log.Printf("Security Node: Validating task safety")
 
+	confirmed, _ := wfCtx.Context["confirmed"].(bool)
+	requiresConfirmation := false
+
+	var verdicts []securityVerdict
 	for i, step := range wfCtx.TaskPlan.Steps {
-		if err := w.security.ValidateAction(step.Action, step.Parameters); err != nil {
+		decision, err := w.security.ValidateAction(step.Action, step.Parameters)
+		if err == nil && decision.Outcome == security.OutcomeConfirm && len(step.References()) > 0 {
+			err = errUnconfirmedReference
+		}
+		verdict := securityVerdict{Step: i, Action: step.Action, Allowed: err == nil, Reason: errorString(err)}
+		if decision != nil {
+			verdict.Rule = decision.Rule
+			if decision.Outcome == security.OutcomeConfirm && !confirmed && err == nil {
+				verdict.RequiresConfirmation = true
+				requiresConfirmation = true
+			}
+		}
+		var denial *security.DenialError
+		if errors.As(err, &denial) {
+			verdict.Rule = denial.Rule
+		}
+		verdicts = append(verdicts, verdict)
+		if err != nil {
 			log.Printf("Security check failed for step %d: %v", i, err)
 			// Replace dangerous action with a safe error message
 			wfCtx.TaskPlan.Steps = []types.TaskStep{
@@ -340,19 +752,37 @@ func (w *VoiceWorkflow) securityNode(ctx context.Context, wfCtx *types.WorkflowC
 					},
 				},
 			}
+			requiresConfirmation = false
 			break
 		}
 	}
+	trace.Annotate(ctx, "security_verdicts", verdicts)
+
+	if requiresConfirmation {
+		log.Printf("Security Node: Plan requires user confirmation")
+		wfCtx.Context["requires_confirmation"] = true
+		return nil
+	}
 
 	log.Printf("Security Node: Validation passed")
 	return nil
 }
 
+// securityVerdict records the security check result for one plan step
+type securityVerdict struct {
+	Step                 int    `json:"step"`
+	Action               string `json:"action"`
+	Allowed              bool   `json:"allowed"`
+	RequiresConfirmation bool   `json:"requires_confirmation,omitempty"`
+	Rule                 string `json:"rule,omitempty"` // policy rule that decided, if any
+	Reason               string `json:"reason,omitempty"`
+}
+
 // executorNode executes the task plan
 func (w *VoiceWorkflow) executorNode(ctx context.Context, wfCtx *types.WorkflowContext) error {
 	log.Printf("Executor Node: Executing task plan")
 
-	result := w.executor.Execute(ctx, wfCtx.TaskPlan)
+	result := w.executor.Execute(executor.WithSessionID(ctx, wfCtx.SessionID), wfCtx.TaskPlan)
 	wfCtx.ExecutionResult = result
 
 	log.Printf("Executor Node: Execution completed (success: %v)", result.Success)
@@ -363,8 +793,21 @@ func (w *VoiceWorkflow) executorNode(ctx context.Context, wfCtx *types.WorkflowC
 func (w *VoiceWorkflow) responseNode(ctx context.Context, wfCtx *types.WorkflowContext) error {
 	log.Printf("Response Node: Generating response text")
 
-	// If execution failed, use error message
+	// A custom graph may reach this node without running the plan
+	if wfCtx.ExecutionResult == nil {
+		log.Printf("Response Node: No execution result")
+		wfCtx.ResponseText = "抱歉，这个请求没有得到处理。"
+		return nil
+	}
+
+	// If execution failed, ask for what the user can fix or use the error message
 	if !wfCtx.ExecutionResult.Success {
+		if question, ok := w.clarificationQuestion(wfCtx.ExecutionResult); ok {
+			log.Printf("Response Node: Asking about parameter %q of %s", wfCtx.ExecutionResult.Param, wfCtx.ExecutionResult.Action)
+			wfCtx.Context["clarification_param"] = wfCtx.ExecutionResult.Param
+			wfCtx.ResponseText = question
+			return nil
+		}
 		wfCtx.ResponseText = wfCtx.ExecutionResult.Error
 		return nil
 	}
@@ -380,12 +823,15 @@ func (w *VoiceWorkflow) responseNode(ctx context.Context, wfCtx *types.WorkflowC
 	resultJSON, _ := json.Marshal(wfCtx.ExecutionResult)
 	userPrompt := fmt.Sprintf("用户请求：%s\n执行结果：%s", wfCtx.RecognizedText, string(resultJSON))
 
-	messages := []qiniu.Message{
+	messages := []provider.Message{
 		{Role: "system", Content: systemPrompt},
-		{Role: "user", Content: userPrompt},
 	}
+	if userMemory := w.userMemory(ctx, wfCtx); userMemory != "" {
+		messages = append(messages, provider.Message{Role: "system", Content: userMemory})
+	}
+	messages = append(messages, provider.Message{Role: "user", Content: userPrompt})
 
-	response, err := w.qiniuClient.ChatCompletion(ctx, messages)
+	response, err := provider.CompleteStreaming(ctx, w.chat, messages, "response")
 	if err != nil {
 		log.Printf("Response generation failed: %v, using fallback", err)
 		response = wfCtx.ExecutionResult.Message
@@ -400,7 +846,7 @@ func (w *VoiceWorkflow) responseNode(ctx context.Context, wfCtx *types.WorkflowC
 func (w *VoiceWorkflow) ttsNode(ctx context.Context, wfCtx *types.WorkflowContext) error {
 	log.Printf("TTS Node: Converting response to speech")
 
-	audioURL, err := w.qiniuClient.TTS(ctx, wfCtx.ResponseText)
+	audioURL, err := w.tts.TTS(ctx, wfCtx.ResponseText)
 	if err != nil {
 		log.Printf("TTS failed: %v, continuing without audio", err)
 		// TTS is optional, continue even if it fails
@@ -412,7 +858,25 @@ func (w *VoiceWorkflow) ttsNode(ctx context.Context, wfCtx *types.WorkflowContex
 	return nil
 }
 
-// CleanupSessions cleans up expired sessions from context manager
+// LoadTrace returns a persisted workflow trace by ID
+func (w *VoiceWorkflow) LoadTrace(id string) (*trace.Trace, error) {
+	return w.traces.Load(id)
+}
+
+// CleanupSessions cleans up expired sessions from context manager and traces past their retention
 func (w *VoiceWorkflow) CleanupSessions() error {
+	if w.traceRetention > 0 {
+		n, err := w.traces.DeleteOlderThan(w.traceRetention)
+		if err != nil {
+			log.Printf("Warning: failed to delete old traces: %v", err)
+		} else if n > 0 {
+			log.Printf("Deleted %d traces older than %v", n, w.traceRetention)
+		}
+	}
 	return w.contextManager.CleanupExpiredSessions()
 }
+
+// SessionsExpireNatively reports whether the session store expires idle sessions without a cleanup sweep
+func (w *VoiceWorkflow) SessionsExpireNatively() bool {
+	return w.contextManager.ExpiresSessions()
+}
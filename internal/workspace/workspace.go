@@ -0,0 +1,117 @@
+// Package workspace confines file paths to a workspace root directory
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrTraversal is returned for paths with ".." elements
+	ErrTraversal = errors.New("path contains ..")
+	// ErrOutside is returned for paths that lie, or resolve through symlinks, outside the workspace
+	ErrOutside = errors.New("path is outside the workspace")
+)
+
+// Resolve maps path to an absolute path inside root with all symlinks resolved
+//
+// path is relative to root, or absolute but inside it. Paths containing ".."
+// are rejected outright; paths whose existing part resolves through a
+// symlink to somewhere outside root are rejected too. The path itself need
+// not exist, so Resolve can be used for files about to be created.
+func Resolve(root, path string) (string, error) {
+	if root == "" {
+		return "", errors.New("workspace root is not configured")
+	}
+	if strings.TrimSpace(path) == "" {
+		return "", errors.New("empty path")
+	}
+	for _, elem := range strings.FieldsFunc(filepath.ToSlash(path), func(r rune) bool { return r == '/' }) {
+		if elem == ".." {
+			return "", ErrTraversal
+		}
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid workspace root: %w", err)
+	}
+	realRoot, err := filepath.EvalSymlinks(absRoot)
+	if err != nil {
+		return "", fmt.Errorf("workspace root unavailable: %w", err)
+	}
+
+	rel := path
+	if filepath.IsAbs(path) {
+		var ok bool
+		if rel, ok = within(absRoot, path); !ok {
+			if rel, ok = within(realRoot, path); !ok {
+				return "", ErrOutside
+			}
+		}
+	}
+
+	resolved, err := evalExisting(filepath.Join(realRoot, rel))
+	if err != nil {
+		return "", err
+	}
+	if _, ok := within(realRoot, resolved); !ok {
+		return "", ErrOutside
+	}
+	return resolved, nil
+}
+
+// Rel returns the path relative to root for display, or the path itself if that fails
+func Rel(root, path string) string {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return path
+	}
+	realRoot, err := filepath.EvalSymlinks(absRoot)
+	if err != nil {
+		return path
+	}
+	if rel, ok := within(realRoot, path); ok {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// within returns path relative to dir if it is dir or lies inside it
+func within(dir, path string) (string, bool) {
+	rel, err := filepath.Rel(dir, filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// evalExisting resolves symlinks in the longest existing prefix of path and appends the rest
+func evalExisting(path string) (string, error) {
+	var rest []string
+	current := path
+	for {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		// A dangling symlink could be created later pointing anywhere
+		if info, lerr := os.Lstat(current); lerr == nil && info.Mode()&os.ModeSymlink != 0 {
+			return "", ErrOutside
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", err
+		}
+		rest = append([]string{filepath.Base(current)}, rest...)
+		current = parent
+	}
+}
@@ -0,0 +1,80 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "workspace")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{filepath.Join(root, "notes"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+	}
+	os.WriteFile(filepath.Join(root, "notes", "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("s"), 0644)
+
+	// Symlinks inside the workspace pointing in and out of it
+	if err := os.Symlink(filepath.Join(root, "notes"), filepath.Join(root, "inner")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	os.Symlink(outside, filepath.Join(root, "escape"))
+	os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "secret-link"))
+	os.Symlink(filepath.Join(outside, "missing.txt"), filepath.Join(root, "dangling"))
+
+	realRoot, _ := filepath.EvalSymlinks(root)
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string // relative to the resolved root
+		wantErr error  // nil means any error is acceptable when want is empty
+	}{
+		{name: "existing file", path: "notes/a.txt", want: "notes/a.txt"},
+		{name: "new file in new dir", path: "drafts/2024/b.txt", want: "drafts/2024/b.txt"},
+		{name: "root itself", path: ".", want: "."},
+		{name: "absolute inside", path: filepath.Join(root, "notes", "a.txt"), want: "notes/a.txt"},
+		{name: "symlink inside", path: "inner/a.txt", want: "notes/a.txt"},
+		{name: "dot dot", path: "notes/../../outside/secret.txt", wantErr: ErrTraversal},
+		{name: "dot dot that stays inside", path: "notes/../notes/a.txt", wantErr: ErrTraversal},
+		{name: "absolute outside", path: filepath.Join(outside, "secret.txt"), wantErr: ErrOutside},
+		{name: "symlinked dir escape", path: "escape/secret.txt", wantErr: ErrOutside},
+		{name: "symlinked dir escape to new file", path: "escape/new.txt", wantErr: ErrOutside},
+		{name: "symlinked file escape", path: "secret-link", wantErr: ErrOutside},
+		{name: "dangling symlink", path: "dangling", wantErr: ErrOutside},
+		{name: "empty path", path: " "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(root, tt.path)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("Resolve(%q) = %q, want error", tt.path, got)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("Resolve(%q) error = %v, want %v", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q) error = %v", tt.path, err)
+			}
+			if want := filepath.Join(realRoot, tt.want); got != want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.path, got, want)
+			}
+			if rel := Rel(root, got); rel != filepath.ToSlash(tt.want) {
+				t.Errorf("Rel() = %q, want %q", rel, tt.want)
+			}
+		})
+	}
+
+	if _, err := Resolve(filepath.Join(base, "missing-root"), "a.txt"); err == nil {
+		t.Error("Expected error for a missing workspace root")
+	}
+}
@@ -0,0 +1,191 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+var (
+	// stepIDPattern matches the IDs a plan step may have
+	stepIDPattern = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+
+	// stepRefPattern matches a reference to another step's result, e.g. "{{steps.gen.data}}"
+	stepRefPattern = regexp.MustCompile(`\{\{\s*steps\.([\p{L}\p{N}_-]+)\.(\w+)\s*\}\}`)
+)
+
+// stepRefFields are the result fields a step reference can read
+var stepRefFields = map[string]bool{"data": true, "message": true}
+
+// StepID returns the ID of the i-th step, or "step1", "step2", ... for steps without one
+func (p *TaskPlan) StepID(i int) string {
+	if id := p.Steps[i].ID; id != "" {
+		return id
+	}
+	return fmt.Sprintf("step%d", i+1)
+}
+
+// HasDependencies reports whether any step has an ID, depends on or refers to another step
+//
+// Plans without any of them run their steps one after another in order.
+func (p *TaskPlan) HasDependencies() bool {
+	for _, step := range p.Steps {
+		if step.ID != "" || len(step.DependsOn) > 0 || len(step.References()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Dependencies returns for each step the indexes of the steps it waits for:
+// those in its DependsOn and those its parameters refer to
+func (p *TaskPlan) Dependencies() ([][]int, error) {
+	index := make(map[string]int, len(p.Steps))
+	for i := range p.Steps {
+		id := p.StepID(i)
+		if !stepIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("steps[%d] has an invalid id %q", i, id)
+		}
+		if _, dup := index[id]; dup {
+			return nil, fmt.Errorf("duplicate step id %q", id)
+		}
+		index[id] = i
+	}
+
+	deps := make([][]int, len(p.Steps))
+	for i, step := range p.Steps {
+		seen := make(map[int]bool)
+		add := func(id string) error {
+			j, ok := index[id]
+			switch {
+			case !ok:
+				return fmt.Errorf("steps[%d] depends on unknown step %q", i, id)
+			case j == i:
+				return fmt.Errorf("steps[%d] depends on itself", i)
+			case !seen[j]:
+				seen[j] = true
+				deps[i] = append(deps[i], j)
+			}
+			return nil
+		}
+
+		for _, id := range step.DependsOn {
+			if err := add(id); err != nil {
+				return nil, err
+			}
+		}
+		for _, match := range stepRefs(step.Parameters) {
+			if !stepRefFields[match[2]] {
+				return nil, fmt.Errorf("steps[%d] refers to unknown field %q of step %q, use data or message", i, match[2], match[1])
+			}
+			if err := add(match[1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Depth-first search for a step that is reached again while its dependencies are still being visited
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(p.Steps))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("steps form a dependency cycle through %q", p.StepID(i))
+		case done:
+			return nil
+		}
+		state[i] = visiting
+		for _, j := range deps[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		state[i] = done
+		return nil
+	}
+	for i := range p.Steps {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return deps, nil
+}
+
+// References returns the IDs of the steps the parameters refer to, without duplicates
+func (s TaskStep) References() []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, match := range stepRefs(s.Parameters) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			ids = append(ids, match[1])
+		}
+	}
+	return ids
+}
+
+// ResolveReferences returns a copy of params with every step reference in a
+// string, including strings nested in arrays and objects, replaced by
+// lookup(id, field)
+func ResolveReferences(params map[string]interface{}, lookup func(id, field string) string) map[string]interface{} {
+	resolved, _ := resolveValue(params, lookup).(map[string]interface{})
+	return resolved
+}
+
+func resolveValue(v interface{}, lookup func(id, field string) string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return stepRefPattern.ReplaceAllStringFunc(v, func(ref string) string {
+			m := stepRefPattern.FindStringSubmatch(ref)
+			return lookup(m[1], m[2])
+		})
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = resolveValue(item, lookup)
+		}
+		return out
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = resolveValue(item, lookup)
+		}
+		return out
+	}
+	return v
+}
+
+// stepRefs returns the submatches of all step references in the strings of v
+func stepRefs(v interface{}) [][]string {
+	switch v := v.(type) {
+	case string:
+		return stepRefPattern.FindAllStringSubmatch(v, -1)
+	case []interface{}:
+		var refs [][]string
+		for _, item := range v {
+			refs = append(refs, stepRefs(item)...)
+		}
+		return refs
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var refs [][]string
+		for _, key := range keys {
+			refs = append(refs, stepRefs(v[key])...)
+		}
+		return refs
+	}
+	return nil
+}
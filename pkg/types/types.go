@@ -13,17 +13,53 @@ type TaskPlan struct {
 }
 
 // TaskStep represents a single step in a task plan
+//
+// String parameters may refer to the result of another step with
+// "{{steps.<id>.data}}" or "{{steps.<id>.message}}"; the step then waits for
+// that step like for the ones listed in DependsOn.
 type TaskStep struct {
+	ID         string                 `json:"id,omitempty"`
 	Action     string                 `json:"action"`
 	Parameters map[string]interface{} `json:"parameters"`
+	DependsOn  []string               `json:"depends_on,omitempty"`
 }
 
 // ExecutionResult represents the result of task execution
 type ExecutionResult struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	Data    string `json:"data,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success bool           `json:"success"`
+	Message string         `json:"message"`
+	Data    string         `json:"data,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	Command *CommandResult `json:"command,omitempty"` // set by execute_command
+	// RolledBack lists the completed actions that were undone, latest first
+	RolledBack []string `json:"rolled_back,omitempty"`
+	// Action, ErrorClass and Param describe a failure: the action that failed,
+	// how it can be handled and, for user_fixable errors, the parameter to ask about
+	Action     string     `json:"action,omitempty"`
+	ErrorClass ErrorClass `json:"error_class,omitempty"`
+	Param      string     `json:"param,omitempty"`
+}
+
+// ErrorClass tells how a failed action can be handled
+type ErrorClass string
+
+// Error classes of failed actions
+const (
+	ErrorRetryable   ErrorClass = "retryable"    // transient, e.g. a rate-limited or timed out model call
+	ErrorUserFixable ErrorClass = "user_fixable" // a parameter is missing or invalid and the user can supply it
+	ErrorFatal       ErrorClass = "fatal"        // retrying or asking the user won't help
+)
+
+// CommandResult describes a finished system command
+type CommandResult struct {
+	ExitCode        int    `json:"exit_code"`
+	Stdout          string `json:"stdout"`
+	Stderr          string `json:"stderr"`
+	StdoutTruncated bool   `json:"stdout_truncated,omitempty"`
+	StderrTruncated bool   `json:"stderr_truncated,omitempty"`
+	TimedOut        bool   `json:"timed_out,omitempty"`
+	DurationMs      int64  `json:"duration_ms"`
+	Isolation       string `json:"isolation"` // sandbox mode the command ran under
 }
 
 // VoiceRequest represents a voice interaction request
@@ -38,13 +74,17 @@ type VoiceResponse struct {
 	Text           string `json:"text"`                      // 系统响应文本
 	AudioURL       string `json:"audio_url,omitempty"`       // TTS生成的音频URL
 	SessionID      string `json:"session_id"`
-	Success        bool   `json:"success"`
-	Error          string `json:"error,omitempty"`
+	TraceID        string `json:"trace_id,omitempty"` // 本次请求的工作流追踪ID
+	// ConfirmationRequired is set when the plan is paused until the user confirms or cancels it
+	ConfirmationRequired bool   `json:"confirmation_required,omitempty"`
+	Success              bool   `json:"success"`
+	Error                string `json:"error,omitempty"`
 }
 
 // WorkflowContext represents the context passed through the workflow
 type WorkflowContext struct {
 	SessionID       string                 `json:"session_id"`
+	UserID          string                 `json:"user_id,omitempty"`
 	AudioPath       string                 `json:"audio_path,omitempty"`
 	RecognizedText  string                 `json:"recognized_text,omitempty"`
 	Intent          *Intent                `json:"intent,omitempty"`
@@ -54,3 +94,18 @@ type WorkflowContext struct {
 	ResponseAudio   string                 `json:"response_audio,omitempty"`
 	Context         map[string]interface{} `json:"context,omitempty"`
 }
+
+// StreamEvent represents a typed event pushed to streaming voice clients
+type StreamEvent struct {
+	Type        string         `json:"type"` // ready, partial_transcript, final_transcript, intent, response, audio, done, error, reminder
+	SessionID   string         `json:"session_id,omitempty"`
+	Text        string         `json:"text,omitempty"`
+	Intent      *Intent        `json:"intent,omitempty"`
+	AudioURL    string         `json:"audio_url,omitempty"`
+	AudioFormat string         `json:"audio_format,omitempty"`
+	AudioSize   int            `json:"audio_size,omitempty"` // size of the binary frame that follows an audio event
+	Response    *VoiceResponse `json:"response,omitempty"`
+	TraceID     string         `json:"trace_id,omitempty"` // set on error events raised by a workflow run
+	ReminderID  string         `json:"reminder_id,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
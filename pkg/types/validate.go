@@ -0,0 +1,33 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Validate checks that an intent parsed from LLM output is well-formed
+func (i *Intent) Validate() error {
+	if i.Intent == "" {
+		return errors.New(`missing required field "intent"`)
+	}
+	if i.Confidence < 0 || i.Confidence > 1 {
+		return fmt.Errorf(`"confidence" must be between 0 and 1, got %v`, i.Confidence)
+	}
+	return nil
+}
+
+// Validate checks that a task plan parsed from LLM output is well-formed
+func (p *TaskPlan) Validate() error {
+	if len(p.Steps) == 0 {
+		return errors.New(`"steps" must contain at least one step`)
+	}
+	for i, step := range p.Steps {
+		if step.Action == "" {
+			return fmt.Errorf(`steps[%d] is missing required field "action"`, i)
+		}
+	}
+	if _, err := p.Dependencies(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ class VoicePilotClient {
         this.mediaRecorder = null;
         this.audioChunks = [];
         this.isRecording = false;
+        this.eventSource = null;
 
         this.init();
     }
@@ -14,6 +15,26 @@ class VoicePilotClient {
         this.setupEventListeners();
         this.updateSessionDisplay();
         this.checkServerStatus();
+        this.subscribeEvents();
+    }
+
+    // Receive events pushed to the session, such as fired reminders
+    subscribeEvents() {
+        if (!window.EventSource) {
+            return;
+        }
+        if (this.eventSource) {
+            this.eventSource.close();
+        }
+
+        this.eventSource = new EventSource(`${this.baseURL}/api/sessions/${encodeURIComponent(this.sessionId)}/events`);
+        this.eventSource.addEventListener('reminder', (e) => {
+            const event = JSON.parse(e.data);
+            this.addConversationItem('assistant', event.text);
+            if (event.audio_url) {
+                this.playAudioResponse(event.audio_url);
+            }
+        });
     }
 
     generateSessionId() {
@@ -281,6 +302,7 @@ class VoicePilotClient {
         if (data.session_id && data.session_id !== this.sessionId) {
             this.sessionId = data.session_id;
             this.updateSessionDisplay();
+            this.subscribeEvents();
         }
     }
 